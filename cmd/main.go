@@ -36,8 +36,9 @@ import (
 	"github.com/kardiachain/go-kardia/dualchain/service"
 	"github.com/kardiachain/go-kardia/dualnode/dual_proxy"
 	"github.com/kardiachain/go-kardia/dualnode/kardia"
-	"github.com/kardiachain/go-kardia/kai/pos"
+	"github.com/kardiachain/go-kardia/kai/downloader"
 	"github.com/kardiachain/go-kardia/kai/storage"
+	"github.com/kardiachain/go-kardia/kvm"
 	"github.com/kardiachain/go-kardia/lib/common"
 	"github.com/kardiachain/go-kardia/lib/crypto"
 	"github.com/kardiachain/go-kardia/lib/log"
@@ -45,6 +46,8 @@ import (
 	"github.com/kardiachain/go-kardia/lib/p2p/nat"
 	"github.com/kardiachain/go-kardia/lib/sysutils"
 	kai "github.com/kardiachain/go-kardia/mainchain"
+	mainblockchain "github.com/kardiachain/go-kardia/mainchain/blockchain"
+	"github.com/kardiachain/go-kardia/mainchain/gasprice"
 	"github.com/kardiachain/go-kardia/mainchain/genesis"
 	"github.com/kardiachain/go-kardia/mainchain/tx_pool"
 	"github.com/kardiachain/go-kardia/node"
@@ -58,12 +61,21 @@ const (
 	MongoDb
 )
 
+const (
+	defaultGenesisGasLimit uint64 = 16777216           // maximum number of uint24
+	maxGenesisGasLimit     uint64 = 0x7fffffffffffffff // maximum number of int64
+)
+
 type flags struct {
-	config string
+	config   string
+	verifyDB bool
+	repairDB bool
 }
 
 func initFlag(args *flags) {
 	flag.StringVar(&args.config, "config", "", "path to config file, if config is defined then it is priority used.")
+	flag.BoolVar(&args.verifyDB, "verifyDB", false, "verify the main chain database defined in config and exit instead of starting the node.")
+	flag.BoolVar(&args.repairDB, "repairDB", false, "with verifyDB, move the head pointer back to the last consistent block if an inconsistency is found.")
 }
 
 var args flags
@@ -135,28 +147,203 @@ func (c *Config) getDbInfo(isDual bool) storage.DbInfo {
 	}
 }
 
-// getTxPoolConfig gets txPoolConfig from config
+// getTxPoolConfig gets txPoolConfig from config. A relative journal path is
+// resolved against the node's data directory.
 func (c *Config) getTxPoolConfig() tx_pool.TxPoolConfig {
+	nodeDir := filepath.Join(c.DataDir, c.Name)
+	txPoolConfig := *tx_pool.GetDefaultTxPoolConfig(nodeDir)
+
 	txPool := c.MainChain.TxPool
-	return tx_pool.TxPoolConfig{
-		GlobalSlots: txPool.GlobalSlots,
-		GlobalQueue: txPool.GlobalQueue,
+	if txPool == nil {
+		return txPoolConfig
+	}
+	txPoolConfig.GlobalSlots = txPool.GlobalSlots
+	txPoolConfig.GlobalQueue = txPool.GlobalQueue
+	if txPool.AccountSlots > 0 {
+		txPoolConfig.AccountSlots = txPool.AccountSlots
+	}
+	if txPool.AccountQueue > 0 {
+		txPoolConfig.AccountQueue = txPool.AccountQueue
+	}
+	if txPool.LifeTime > 0 {
+		txPoolConfig.Lifetime = time.Duration(txPool.LifeTime) * time.Second
+	}
+	txPoolConfig.NoLocals = txPool.NoLocals == 1
+	txPoolConfig.CreatePriceLimit = txPool.CreatePriceLimit
+	txPoolConfig.CallPriceLimit = txPool.CallPriceLimit
+	if txPool.Journal != "" {
+		txPoolConfig.Journal = txPool.Journal
+		if !filepath.IsAbs(txPool.Journal) {
+			txPoolConfig.Journal = filepath.Join(nodeDir, txPool.Journal)
+		}
+	}
+	if txPool.Rejournal > 0 {
+		txPoolConfig.Rejournal = time.Duration(txPool.Rejournal) * time.Second
+	}
+	if txPool.MaxTxGasFraction > 0 {
+		txPoolConfig.MaxTxGasFraction = txPool.MaxTxGasFraction
+	}
+	if txPool.BroadcastFlood > 0 {
+		txPoolConfig.BroadcastFlood = txPool.BroadcastFlood
+	}
+	if txPool.BroadcastMinFlood > 0 {
+		txPoolConfig.BroadcastMinFlood = txPool.BroadcastMinFlood
+	}
+	for _, addr := range txPool.ProposerAllowlist {
+		if !common.IsHexAddress(addr) {
+			log.Warn("Skipping invalid proposer allowlist address", "address", addr)
+			continue
+		}
+		txPoolConfig.ProposerAllowlist = append(txPoolConfig.ProposerAllowlist, common.HexToAddress(addr))
+	}
+	return txPoolConfig
+}
+
+// getGasPriceConfig gets the gas price oracle config from chain's config, unset values keep their defaults
+func getGasPriceConfig(chain *Chain) *gasprice.Config {
+	gpoConfig := gasprice.DefaultConfig
+	if chain == nil || chain.GasPrice == nil {
+		return &gpoConfig
+	}
+	if chain.GasPrice.Blocks > 0 {
+		gpoConfig.Blocks = chain.GasPrice.Blocks
+	}
+	if chain.GasPrice.Percentile > 0 {
+		gpoConfig.Percentile = chain.GasPrice.Percentile
+	}
+	return &gpoConfig
+}
+
+// getConsensusConfig gets consensus timeouts from chain's config, unset values keep their defaults
+func getConsensusConfig(chain *Chain) (*configs.ConsensusConfig, error) {
+	consensusConfig := configs.DefaultConsensusConfig()
+	if chain == nil || chain.Consensus == nil {
+		return consensusConfig, nil
+	}
+	timeouts := []struct {
+		value  int
+		target *time.Duration
+	}{
+		{chain.Consensus.TimeoutPropose, &consensusConfig.TimeoutPropose},
+		{chain.Consensus.TimeoutProposeDelta, &consensusConfig.TimeoutProposeDelta},
+		{chain.Consensus.TimeoutPrevote, &consensusConfig.TimeoutPrevote},
+		{chain.Consensus.TimeoutPrevoteDelta, &consensusConfig.TimeoutPrevoteDelta},
+		{chain.Consensus.TimeoutPrecommit, &consensusConfig.TimeoutPrecommit},
+		{chain.Consensus.TimeoutPrecommitDelta, &consensusConfig.TimeoutPrecommitDelta},
+		{chain.Consensus.TimeoutCommit, &consensusConfig.TimeoutCommit},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value != 0 {
+			*timeout.target = time.Duration(timeout.value) * time.Millisecond
+		}
+	}
+	if chain.Consensus.MaxBlockBytes != 0 {
+		consensusConfig.MaxBlockBytes = chain.Consensus.MaxBlockBytes
+	}
+	if err := consensusConfig.ValidateBasic(); err != nil {
+		return nil, err
 	}
+	return consensusConfig, nil
+}
+
+// getSyncMode gets the sync mode from chain's config, full sync is used if unset
+func getSyncMode(chain *Chain) (downloader.SyncMode, error) {
+	mode := downloader.FullSync
+	if chain == nil || chain.SyncMode == "" {
+		return mode, nil
+	}
+	if err := mode.UnmarshalText([]byte(chain.SyncMode)); err != nil {
+		return mode, err
+	}
+	return mode, nil
+}
+
+// getArchive gets whether the chain retains the state of every block, archive mode is used if unset
+func getArchive(chain *Chain) bool {
+	return chain == nil || chain.Archive == nil || *chain.Archive == 1
+}
+
+// getCheckpoint gets the trusted checkpoint from chain's config, nil if unset
+func getCheckpoint(chain *Chain) (*downloader.Checkpoint, error) {
+	if chain == nil || chain.Checkpoint == nil {
+		return nil, nil
+	}
+	hash, err := common.Decode(chain.Checkpoint.Hash)
+	if err != nil || len(hash) != common.HashLength {
+		return nil, fmt.Errorf("invalid checkpoint hash %q", chain.Checkpoint.Hash)
+	}
+	checkpoint := &downloader.Checkpoint{Height: chain.Checkpoint.Height, Hash: common.BytesToHash(hash)}
+	if err := checkpoint.ValidateBasic(); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// getChainConfig gets the chain config of the genesis from chain's config. The chain
+// id is the chain's one and forks whose height is set override the activation heights
+// of the default config.
+func getChainConfig(chain *Chain) *types.ChainConfig {
+	config := *configs.TestnetChainConfig
+	if chain == nil {
+		return &config
+	}
+	config.ChainID = new(big.Int).SetUint64(chain.ChainID)
+	if chain.Forks == nil {
+		return &config
+	}
+	forks := []struct {
+		height *uint64
+		target **big.Int
+	}{
+		{chain.Forks.ConstantinopleBlock, &config.ConstantinopleBlock},
+		{chain.Forks.IstanbulBlock, &config.IstanbulBlock},
+		{chain.Forks.LondonBlock, &config.LondonBlock},
+		{chain.Forks.GasFixBlock, &config.GasFixBlock},
+	}
+	for _, fork := range forks {
+		if fork.height != nil {
+			*fork.target = new(big.Int).SetUint64(*fork.height)
+		}
+	}
+	return &config
+}
+
+// getGenesisGasLimit gets the genesis block's gas limit from genesis config, defaultGenesisGasLimit if unset.
+// The limit must fit at least one plain transaction and must not exceed maxGenesisGasLimit.
+func getGenesisGasLimit(g *Genesis) (uint64, error) {
+	if g == nil || g.GasLimit == 0 {
+		return defaultGenesisGasLimit, nil
+	}
+	if g.GasLimit <= kvm.TxGas {
+		return 0, fmt.Errorf("genesis gas limit %d must exceed intrinsic gas %d", g.GasLimit, kvm.TxGas)
+	}
+	if g.GasLimit > maxGenesisGasLimit {
+		return 0, fmt.Errorf("genesis gas limit %d exceeds maximum %d", g.GasLimit, maxGenesisGasLimit)
+	}
+	return g.GasLimit, nil
 }
 
 // getGenesis gets genesis data from config
 func (c *Config) getGenesis(isDual bool) (*genesis.Genesis, error) {
 	var ga genesis.GenesisAlloc
 	var err error
-	g := c.MainChain.Genesis
+	contracts := make([]genesis.GenesisContract, 0)
+	kardiaSmartContracts := make([]*types.KardiaSmartcontract, 0)
+	chain := c.MainChain
 	if isDual {
-		g = c.DualChain.Genesis
+		chain = c.DualChain
+	}
+	g := chain.Genesis
+	gasLimit, err := getGenesisGasLimit(g)
+	if err != nil {
+		return nil, err
 	}
 	if g == nil {
 		ga = make(genesis.GenesisAlloc, 0)
 	} else {
 		genesisAccounts := make(map[string]*big.Int)
 		genesisContracts := make(map[string]string)
+		contractBalances := make(map[string]*big.Int)
 
 		amount, _ := big.NewInt(0).SetString(g.GenesisAmount, 10)
 		for _, address := range g.Addresses {
@@ -164,17 +351,57 @@ func (c *Config) getGenesis(isDual bool) (*genesis.Genesis, error) {
 		}
 
 		for _, contract := range g.Contracts {
-			genesisContracts[contract.Address] = contract.ByteCode
+			if contract.ABI != "" {
+				contractAbi := strings.Replace(contract.ABI, "'", "\"", -1)
+				kardiaSmartContracts = append(kardiaSmartContracts, &types.KardiaSmartcontract{
+					SmcAddress: contract.Address,
+					SmcAbi:     contractAbi,
+					MasterSmc:  contract.Address,
+					MasterAbi:  contractAbi,
+				})
+			}
+			balance := genesis.ToCell(100)
+			if contract.GenesisAmount != "" {
+				amount, ok := big.NewInt(0).SetString(contract.GenesisAmount, 10)
+				if !ok {
+					return nil, fmt.Errorf("invalid genesis amount %v of contract %v", contract.GenesisAmount, contract.Address)
+				}
+				balance = genesis.ToCell(amount.Int64())
+			}
+			if len(contract.ConstructorArgs) == 0 {
+				genesisContracts[contract.Address] = contract.ByteCode
+				contractBalances[contract.Address] = balance
+				continue
+			}
+			gc := genesis.GenesisContract{
+				Address:         common.HexToAddress(contract.Address),
+				ByteCode:        common.Hex2Bytes(contract.ByteCode),
+				Abi:             contract.ABI,
+				ConstructorArgs: contract.ConstructorArgs,
+				Balance:         balance,
+			}
+			// fail at config load rather than while creating genesis block
+			if _, err := gc.CreationCode(); err != nil {
+				return nil, err
+			}
+			contracts = append(contracts, gc)
 		}
 		ga, err = genesis.GenesisAllocFromAccountAndContract(genesisAccounts, genesisContracts)
 		if err != nil {
 			return nil, err
 		}
+		for address, balance := range contractBalances {
+			account := ga[common.HexToAddress(address)]
+			account.Balance = balance
+			ga[common.HexToAddress(address)] = account
+		}
 	}
 	return &genesis.Genesis{
-		Config:   configs.TestnetChainConfig,
-		GasLimit: 16777216, // maximum number of uint24
-		Alloc:    ga,
+		Config:               getChainConfig(chain),
+		GasLimit:             gasLimit,
+		Alloc:                ga,
+		Contracts:            contracts,
+		KardiaSmartContracts: kardiaSmartContracts,
 	}, nil
 }
 
@@ -193,68 +420,45 @@ func (c *Config) getMainChainConfig() (*node.MainChainConfig, error) {
 	if err != nil {
 		return nil, err
 	}
-	genesisAmount, _ := big.NewInt(0).SetString(c.MainChain.Consensus.Deployment.Master.GenesisAmount, 10)
-	minimumStakes, _ := big.NewInt(0).SetString(c.MainChain.Consensus.MinimumStakes, 10)
-	blockReward, _ := big.NewInt(0).SetString(c.MainChain.Consensus.BlockReward, 10)
+	consensusConfig, err := getConsensusConfig(chain)
+	if err != nil {
+		return nil, err
+	}
+	syncMode, err := getSyncMode(chain)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint, err := getCheckpoint(chain)
+	if err != nil {
+		return nil, err
+	}
 	// get consensus info
-	consensus := pos.ConsensusInfo{
-		BlockReward: blockReward,
-		FetchNewValidatorsTime: c.MainChain.Consensus.FetchNewValidatorsTime,
-		MaxValidators:   c.MainChain.Consensus.MaxValidators,
-		ConsensusPeriodInBlock: c.MainChain.Consensus.ConsensusPeriodInBlock,
-		MinimumStakes: minimumStakes,
-		MaxViolatePercentageAllowed: c.MainChain.Consensus.MaxViolatePercentageAllowed,
-		LockedPeriod: c.MainChain.Consensus.LockedPeriod,
-		Master:          pos.MasterSmartContract{
-			Address:       common.HexToAddress(c.MainChain.Consensus.Deployment.Master.Address),
-			ByteCode:      common.Hex2Bytes(c.MainChain.Consensus.Compilation.Master.ByteCode),
-			ABI:           strings.Replace(c.MainChain.Consensus.Compilation.Master.ABI, "'", "\"", -1),
-			GenesisAmount: genesis.ToCell(genesisAmount.Int64()),
-		},
-		Nodes:           pos.Nodes{
-			ABI:         strings.Replace(c.MainChain.Consensus.Compilation.Node.ABI, "'", "\"", -1),
-			ByteCode:    common.Hex2Bytes(c.MainChain.Consensus.Compilation.Node.ByteCode),
-			GenesisInfo: make([]pos.GenesisNodeInfo, 0),
-		},
-		Stakers:         pos.Stakers{
-			ABI:         strings.Replace(c.MainChain.Consensus.Compilation.Staker.ABI, "'", "\"", -1),
-			ByteCode:    common.Hex2Bytes(c.MainChain.Consensus.Compilation.Staker.ByteCode),
-			GenesisInfo: make([]pos.GenesisStakeInfo, 0),
-		},
-	}
-	// get Nodes
-	for _, n := range c.MainChain.Consensus.Deployment.Nodes {
-		consensus.Nodes.GenesisInfo = append(consensus.Nodes.GenesisInfo, pos.GenesisNodeInfo{
-			Address: common.HexToAddress(n.Address),
-			Owner:   common.HexToAddress(n.Owner),
-			PubKey:  n.PubKey,
-			Name:    n.Name,
-			RewardPercentage:  n.RewardPercentage,
-		})
-	}
-	// get stakers
-	for _, s := range c.MainChain.Consensus.Deployment.Stakers {
-		stakeAmount, _ := big.NewInt(0).SetString(s.StakeAmount, 10)
-		consensus.Stakers.GenesisInfo = append(consensus.Stakers.GenesisInfo, pos.GenesisStakeInfo{
-			Address:     common.HexToAddress(s.Address),
-			Owner:       common.HexToAddress(s.Owner),
-			StakedNode:  common.HexToAddress(s.StakedNode),
-			StakeAmount: genesis.ToCell(stakeAmount.Int64()),
-		})
+	consensus, err := c.MainChain.Consensus.ConsensusInfo()
+	if err != nil {
+		return nil, err
 	}
 	// assign consensus to genesisData
 	genesisData.ConsensusInfo = consensus
 	mainChainConfig := node.MainChainConfig{
-		ValidatorIndexes: c.MainChain.Validators,
-		DBInfo:           dbInfo,
-		Genesis:          genesisData,
-		TxPool:           c.getTxPoolConfig(),
-		AcceptTxs:        chain.AcceptTxs,
-		IsZeroFee:        chain.ZeroFee == 1,
-		NetworkId:        chain.NetworkID,
-		ChainId:          chain.ChainID,
-		ServiceName:      chain.ServiceName,
-		BaseAccount:      baseAccount,
+		ValidatorIndexes:   c.MainChain.Validators,
+		DBInfo:             dbInfo,
+		Genesis:            genesisData,
+		TxPool:             c.getTxPoolConfig(),
+		GasPrice:           getGasPriceConfig(chain),
+		Consensus:          consensusConfig,
+		AcceptTxs:          chain.AcceptTxs,
+		IsZeroFee:          chain.ZeroFee == 1,
+		MaxReorgDepth:      chain.MaxReorgDepth,
+		Archive:            getArchive(chain),
+		StateRetention:     chain.StateRetention,
+		TxExecutionWorkers: chain.TxExecutionWorkers,
+		StateSnapshotLimit: chain.StateSnapshotLimit,
+		SyncMode:           syncMode,
+		Checkpoint:         checkpoint,
+		NetworkId:          chain.NetworkID,
+		ChainId:            chain.ChainID,
+		ServiceName:        chain.ServiceName,
+		BaseAccount:        baseAccount,
 	}
 	return &mainChainConfig, nil
 }
@@ -274,18 +478,26 @@ func (c *Config) getDualChainConfig() (*node.DualChainConfig, error) {
 		GlobalQueue:  c.DualChain.EventPool.GlobalQueue,
 		AccountQueue: c.DualChain.EventPool.AccountQueue,
 		AccountSlots: c.DualChain.EventPool.AccountSlots,
+
+		NumberOfWorkers: c.DualChain.EventPool.Workers,
+		WorkerCap:       c.DualChain.EventPool.WorkerCap,
 	}
 
 	baseAccount, err := c.getBaseAccount(true)
 	if err != nil {
 		return nil, err
 	}
+	consensusConfig, err := getConsensusConfig(c.DualChain)
+	if err != nil {
+		return nil, err
+	}
 
 	dualChainConfig := node.DualChainConfig{
 		ValidatorIndexes: c.DualChain.Validators,
 		DBInfo:           dbInfo,
 		DualGenesis:      genesisData,
 		DualEventPool:    eventPool,
+		Consensus:        consensusConfig,
 		DualNetworkID:    c.DualChain.NetworkID,
 		ChainId:          c.DualChain.ChainID,
 		DualProtocolName: *c.DualChain.Protocol,
@@ -303,17 +515,22 @@ func (c *Config) getNodeConfig() (*node.NodeConfig, error) {
 	}
 	p2pConfig.Name = n.Name
 	nodeConfig := node.NodeConfig{
-		Name:             n.Name,
-		DataDir:          n.DataDir,
-		P2P:              *p2pConfig,
-		HTTPHost:         n.HTTPHost,
-		HTTPPort:         n.HTTPPort,
-		HTTPCors:         n.HTTPCors,
-		HTTPVirtualHosts: n.HTTPVirtualHosts,
-		HTTPModules:      n.HTTPModules,
-		MainChainConfig:  node.MainChainConfig{},
-		DualChainConfig:  node.DualChainConfig{},
-		PeerProxyIP:      "",
+		Name:               n.Name,
+		DataDir:            n.DataDir,
+		P2P:                *p2pConfig,
+		HTTPHost:           n.HTTPHost,
+		HTTPPort:           n.HTTPPort,
+		HTTPCors:           n.HTTPCors,
+		HTTPVirtualHosts:   n.HTTPVirtualHosts,
+		HTTPModules:        n.HTTPModules,
+		HTTPStrictModules:  n.HTTPStrictModules,
+		HTTPMaxRequestSize: n.HTTPMaxRequestSize,
+		HTTPRateLimit:      n.HTTPRateLimit,
+		HTTPRateBurst:      n.HTTPRateBurst,
+		HTTPMaxBatchLength: n.HTTPMaxBatchLength,
+		MainChainConfig:    node.MainChainConfig{},
+		DualChainConfig:    node.DualChainConfig{},
+		PeerProxyIP:        "",
 	}
 	mainChainConfig, err := c.getMainChainConfig()
 	if err != nil {
@@ -384,6 +601,13 @@ func (c *Config) Start() {
 		logger.Error("Cannot get node config", "err", err)
 		return
 	}
+	if err := nodeConfig.ValidateIds(); err != nil {
+		logger.Error("Invalid chain ids", "err", err)
+		return
+	}
+	if err := node.CheckKnownIds(nodeConfig.MainChainConfig.ChainId, nodeConfig.MainChainConfig.NetworkId); err != nil {
+		logger.Warn("Unusual chain ids, peers may not connect", "err", err)
+	}
 
 	// init new node from nodeConfig
 	n, err := node.NewNode(nodeConfig)
@@ -591,6 +815,30 @@ func displayKardiaPeers(n *node.Node) {
 	}
 }
 
+// verifyDB opens the main chain database and verifies it, see blockchain.VerifyDB.
+func (c *Config) verifyDB(repair bool) error {
+	if c.MainChain.Database != nil && c.MainChain.Database.Drop == 1 {
+		return fmt.Errorf("refusing to verify a database configured to be dropped")
+	}
+	dbInfo := c.getDbInfo(false)
+	if dbInfo == nil {
+		return fmt.Errorf("cannot get dbInfo")
+	}
+	db, err := dbInfo.Start()
+	if err != nil {
+		return err
+	}
+	head, err := mainblockchain.VerifyDB(log.New(), db, repair)
+	if err != nil {
+		if head == nil || !repair {
+			return err
+		}
+		log.Warn("Database repaired", "err", err)
+	}
+	log.Info("Database verified", "height", head.Height(), "hash", head.Hash())
+	return nil
+}
+
 func waitForever() {
 	select {}
 }
@@ -602,6 +850,13 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
+		if args.verifyDB {
+			if err := config.verifyDB(args.repairDB); err != nil {
+				log.Error("Database verification failed", "err", err)
+				os.Exit(1)
+			}
+			return
+		}
 		config.Start()
 	}
 }
@@ -0,0 +1,341 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardiachain/go-kardia/configs"
+	"github.com/kardiachain/go-kardia/kai/downloader"
+	"github.com/kardiachain/go-kardia/kvm"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/mainchain/gasprice"
+	"github.com/kardiachain/go-kardia/mainchain/genesis"
+	"github.com/kardiachain/go-kardia/mainchain/tx_pool"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGetTxPoolConfig_journalUnderDataDir(t *testing.T) {
+	dataDir := filepath.Join("tmp", "kardia")
+	c := &Config{
+		Node:      Node{Name: "node1", DataDir: dataDir},
+		MainChain: &Chain{TxPool: &Pool{GlobalSlots: 64, GlobalQueue: 1024}},
+	}
+	conf := c.getTxPoolConfig()
+
+	nodeDir := filepath.Join(dataDir, "node1")
+	if want := filepath.Join(nodeDir, tx_pool.DefaultTxPoolConfig.Journal); conf.Journal != want {
+		t.Fatalf("journal path mismatch: have %v, want %v", conf.Journal, want)
+	}
+	if !strings.HasPrefix(conf.Journal, nodeDir) {
+		t.Fatalf("journal %v is not under the data dir %v", conf.Journal, nodeDir)
+	}
+	if conf.Rejournal != tx_pool.DefaultTxPoolConfig.Rejournal {
+		t.Errorf("rejournal mismatch: have %v, want %v", conf.Rejournal, tx_pool.DefaultTxPoolConfig.Rejournal)
+	}
+	if conf.NoLocals {
+		t.Errorf("local transaction handling disabled by default")
+	}
+	if conf.GlobalSlots != 64 || conf.GlobalQueue != 1024 {
+		t.Errorf("pool limits mismatch: have %d/%d, want %d/%d", conf.GlobalSlots, conf.GlobalQueue, 64, 1024)
+	}
+	if conf.AccountSlots != tx_pool.DefaultTxPoolConfig.AccountSlots || conf.AccountQueue != tx_pool.DefaultTxPoolConfig.AccountQueue {
+		t.Errorf("account limits mismatch: have %d/%d, want defaults", conf.AccountSlots, conf.AccountQueue)
+	}
+	if conf.Lifetime != tx_pool.DefaultTxPoolConfig.Lifetime {
+		t.Errorf("lifetime mismatch: have %v, want %v", conf.Lifetime, tx_pool.DefaultTxPoolConfig.Lifetime)
+	}
+}
+
+func TestGetTxPoolConfig_accountLimits(t *testing.T) {
+	c := &Config{MainChain: &Chain{TxPool: &Pool{AccountSlots: 8, AccountQueue: 32, LifeTime: 600}}}
+	conf := c.getTxPoolConfig()
+	if conf.AccountSlots != 8 || conf.AccountQueue != 32 {
+		t.Errorf("account limits mismatch: have %d/%d, want %d/%d", conf.AccountSlots, conf.AccountQueue, 8, 32)
+	}
+	if conf.Lifetime != 10*time.Minute {
+		t.Errorf("lifetime mismatch: have %v, want %v", conf.Lifetime, 10*time.Minute)
+	}
+}
+
+func TestGetTxPoolConfig_customJournal(t *testing.T) {
+	dataDir := filepath.Join("tmp", "kardia")
+	c := &Config{
+		Node: Node{Name: "node1", DataDir: dataDir},
+		MainChain: &Chain{TxPool: &Pool{
+			Journal:   "txs.rlp",
+			Rejournal: 60,
+			NoLocals:  1,
+		}},
+	}
+	conf := c.getTxPoolConfig()
+	if want := filepath.Join(dataDir, "node1", "txs.rlp"); conf.Journal != want {
+		t.Errorf("journal path mismatch: have %v, want %v", conf.Journal, want)
+	}
+	if conf.Rejournal != time.Minute {
+		t.Errorf("rejournal mismatch: have %v, want %v", conf.Rejournal, time.Minute)
+	}
+	if !conf.NoLocals {
+		t.Errorf("local transaction handling not disabled")
+	}
+
+	abs, _ := filepath.Abs(filepath.Join("var", "txs.rlp"))
+	c.MainChain.TxPool.Journal = abs
+	if conf := c.getTxPoolConfig(); conf.Journal != abs {
+		t.Errorf("absolute journal path mismatch: have %v, want %v", conf.Journal, abs)
+	}
+}
+
+func TestGetConsensusConfig(t *testing.T) {
+	conf, err := getConsensusConfig(&Chain{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *conf != *configs.DefaultConsensusConfig() {
+		t.Errorf("consensus config mismatch: have %+v, want defaults", conf)
+	}
+
+	conf, err = getConsensusConfig(&Chain{Consensus: &Consensus{
+		TimeoutPropose:   3000,
+		TimeoutPrevote:   200,
+		TimeoutPrecommit: 300,
+		TimeoutCommit:    400,
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conf.TimeoutPropose != 3*time.Second {
+		t.Errorf("timeout propose mismatch: have %v, want %v", conf.TimeoutPropose, 3*time.Second)
+	}
+	if conf.TimeoutPrevote != 200*time.Millisecond {
+		t.Errorf("timeout prevote mismatch: have %v, want %v", conf.TimeoutPrevote, 200*time.Millisecond)
+	}
+	if conf.TimeoutPrecommit != 300*time.Millisecond {
+		t.Errorf("timeout precommit mismatch: have %v, want %v", conf.TimeoutPrecommit, 300*time.Millisecond)
+	}
+	if conf.TimeoutCommit != 400*time.Millisecond {
+		t.Errorf("timeout commit mismatch: have %v, want %v", conf.TimeoutCommit, 400*time.Millisecond)
+	}
+	if want := configs.DefaultConsensusConfig().TimeoutProposeDelta; conf.TimeoutProposeDelta != want {
+		t.Errorf("unset timeout propose delta mismatch: have %v, want %v", conf.TimeoutProposeDelta, want)
+	}
+	if want := 3*time.Second + 2*conf.TimeoutProposeDelta; conf.Propose(2) != want {
+		t.Errorf("propose timeout at round 2 mismatch: have %v, want %v", conf.Propose(2), want)
+	}
+}
+
+func TestGetConsensusConfig_maxBlockBytes(t *testing.T) {
+	conf, err := getConsensusConfig(&Chain{Consensus: &Consensus{MaxBlockBytes: 1 << 20}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conf.MaxBlockBytes != 1<<20 {
+		t.Errorf("max block bytes mismatch: have %d, want %d", conf.MaxBlockBytes, 1<<20)
+	}
+}
+
+func TestGetConsensusConfig_negativeTimeout(t *testing.T) {
+	if _, err := getConsensusConfig(&Chain{Consensus: &Consensus{TimeoutPrevote: -1}}); err == nil {
+		t.Error("expected negative timeout to be rejected")
+	}
+}
+
+func TestGetGasPriceConfig(t *testing.T) {
+	if conf := getGasPriceConfig(&Chain{}); conf.Blocks != gasprice.DefaultConfig.Blocks || conf.Percentile != gasprice.DefaultConfig.Percentile {
+		t.Errorf("gas price config mismatch: have %+v, want defaults", conf)
+	}
+	conf := getGasPriceConfig(&Chain{GasPrice: &GasPrice{Blocks: 5, Percentile: 90}})
+	if conf.Blocks != 5 || conf.Percentile != 90 {
+		t.Errorf("gas price config mismatch: have blocks %d percentile %d, want 5 and 90", conf.Blocks, conf.Percentile)
+	}
+	if conf.Default.Cmp(gasprice.DefaultConfig.Default) != 0 {
+		t.Errorf("default price mismatch: have %v, want %v", conf.Default, gasprice.DefaultConfig.Default)
+	}
+}
+
+func TestGetSyncMode(t *testing.T) {
+	tests := []struct {
+		syncMode string
+		want     downloader.SyncMode
+	}{
+		{"", downloader.FullSync},
+		{"full", downloader.FullSync},
+		{"headers-first", downloader.HeadersFirstSync},
+	}
+	for _, tt := range tests {
+		mode, err := getSyncMode(&Chain{SyncMode: tt.syncMode})
+		if err != nil {
+			t.Fatalf("sync mode %q: %v", tt.syncMode, err)
+		}
+		if mode != tt.want {
+			t.Errorf("sync mode %q: have %v, want %v", tt.syncMode, mode, tt.want)
+		}
+	}
+	if _, err := getSyncMode(&Chain{SyncMode: "fast"}); err == nil {
+		t.Error("expected unknown sync mode to be rejected")
+	}
+}
+
+func TestGetArchive(t *testing.T) {
+	archive, pruned := uint(1), uint(0)
+	if !getArchive(&Chain{}) {
+		t.Error("archive mode must be the default")
+	}
+	if !getArchive(&Chain{Archive: &archive}) {
+		t.Error("archive mode not enabled")
+	}
+	if getArchive(&Chain{Archive: &pruned}) {
+		t.Error("pruned mode not enabled")
+	}
+}
+
+func TestGetCheckpoint(t *testing.T) {
+	if checkpoint, err := getCheckpoint(&Chain{}); err != nil || checkpoint != nil {
+		t.Fatalf("unset checkpoint mismatch: have %v, %v", checkpoint, err)
+	}
+	hash := common.HexToHash("0x0102")
+	checkpoint, err := getCheckpoint(&Chain{Checkpoint: &Checkpoint{Height: 10, Hash: hash.Hex()}})
+	if err != nil {
+		t.Fatalf("failed to get checkpoint: %v", err)
+	}
+	if checkpoint.Height != 10 || checkpoint.Hash != hash {
+		t.Errorf("checkpoint mismatch: have %d %x, want %d %x", checkpoint.Height, checkpoint.Hash, 10, hash)
+	}
+	for _, bad := range []*Checkpoint{{Height: 10, Hash: "0x0102"}, {Height: 10, Hash: "bad"}, {Hash: hash.Hex()}} {
+		if _, err := getCheckpoint(&Chain{Checkpoint: bad}); err == nil {
+			t.Errorf("invalid checkpoint %+v accepted", bad)
+		}
+	}
+}
+
+func TestGetGenesis_gasLimit(t *testing.T) {
+	c := &Config{
+		MainChain: &Chain{Genesis: &Genesis{GasLimit: 8000000}},
+		DualChain: &Chain{},
+	}
+	g, err := c.getGenesis(false)
+	if err != nil {
+		t.Fatalf("failed to get main genesis: %v", err)
+	}
+	if g.GasLimit != 8000000 {
+		t.Errorf("main genesis gas limit mismatch: have %d, want %d", g.GasLimit, 8000000)
+	}
+	g, err = c.getGenesis(true)
+	if err != nil {
+		t.Fatalf("failed to get dual genesis: %v", err)
+	}
+	if g.GasLimit != defaultGenesisGasLimit {
+		t.Errorf("dual genesis gas limit mismatch: have %d, want %d", g.GasLimit, defaultGenesisGasLimit)
+	}
+	for _, bad := range []uint64{1, kvm.TxGas, maxGenesisGasLimit + 1} {
+		c.DualChain.Genesis = &Genesis{GasLimit: bad}
+		if _, err := c.getGenesis(true); err == nil {
+			t.Errorf("invalid genesis gas limit %d accepted", bad)
+		}
+	}
+}
+
+func TestGetGenesis_contractAmount(t *testing.T) {
+	c := &Config{MainChain: &Chain{Genesis: &Genesis{Contracts: []Contract{
+		{Address: "0x0B", ByteCode: "6000", GenesisAmount: "5"},
+		{Address: "0x0C", ByteCode: "6000"},
+	}}}}
+	g, err := c.getGenesis(false)
+	if err != nil {
+		t.Fatalf("failed to get genesis: %v", err)
+	}
+	if have := g.Alloc[common.HexToAddress("0x0B")].Balance; have.Cmp(genesis.ToCell(5)) != 0 {
+		t.Errorf("contract balance mismatch: have %v, want %v", have, genesis.ToCell(5))
+	}
+	if have := g.Alloc[common.HexToAddress("0x0C")].Balance; have.Cmp(genesis.ToCell(100)) != 0 {
+		t.Errorf("default contract balance mismatch: have %v, want %v", have, genesis.ToCell(100))
+	}
+	c.MainChain.Genesis.Contracts[0].GenesisAmount = "bad"
+	if _, err := c.getGenesis(false); err == nil {
+		t.Error("invalid contract genesis amount accepted")
+	}
+}
+
+func TestGetChainConfig(t *testing.T) {
+	config := getChainConfig(&Chain{})
+	if config == configs.TestnetChainConfig {
+		t.Fatal("default chain config returned without copying")
+	}
+	if config.ConstantinopleBlock.Sign() != 0 || config.IstanbulBlock != nil {
+		t.Errorf("default forks mismatch: have %v/%v, want 0/nil", config.ConstantinopleBlock, config.IstanbulBlock)
+	}
+	var c Config
+	data := "MainChain:\n  ChainId: 24\n  Forks:\n    ConstantinopleBlock: 10\n    IstanbulBlock: 0\n    LondonBlock: 30\n"
+	if err := yaml.Unmarshal([]byte(data), &c); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	config = getChainConfig(c.MainChain)
+	if config.ChainID == nil || config.ChainID.Uint64() != 24 {
+		t.Errorf("chain id mismatch: have %v, want 24", config.ChainID)
+	}
+	if config.ConstantinopleBlock.Uint64() != 10 || config.IstanbulBlock == nil || config.IstanbulBlock.Sign() != 0 {
+		t.Errorf("forks mismatch: have %v/%v, want 10/0", config.ConstantinopleBlock, config.IstanbulBlock)
+	}
+	if config.LondonBlock == nil || config.LondonBlock.Uint64() != 30 || config.GasFixBlock != nil {
+		t.Errorf("forks mismatch: have London %v GasFix %v, want 30/nil", config.LondonBlock, config.GasFixBlock)
+	}
+	if configs.TestnetChainConfig.ConstantinopleBlock.Sign() != 0 || configs.TestnetChainConfig.ChainID != nil {
+		t.Error("default chain config modified")
+	}
+}
+
+func TestChainIdsFromYaml(t *testing.T) {
+	var c Config
+	data := "MainChain:\n  ChainId: 1\n  NetworkId: 100\n"
+	if err := yaml.Unmarshal([]byte(data), &c); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	if c.MainChain.ChainID != 1 || c.MainChain.NetworkID != 100 {
+		t.Errorf("ids mismatch: have %d/%d, want 1/100", c.MainChain.ChainID, c.MainChain.NetworkID)
+	}
+}
+
+func TestConsensusFromYaml(t *testing.T) {
+	var c Config
+	data := "MainChain:\n  Consensus:\n    BlockReward: \"100\"\n    RewardSchedule:\n      - Height: 10\n        Reward: \"50\"\n    TimeoutCommit: 400\n"
+	if err := yaml.Unmarshal([]byte(data), &c); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	consensus := c.MainChain.Consensus
+	if consensus.BlockReward != "100" || len(consensus.RewardSchedule) != 1 || consensus.RewardSchedule[0].Height != 10 {
+		t.Errorf("consensus deployment mismatch: have %+v", consensus.ConsensusConfig)
+	}
+	if consensus.TimeoutCommit != 400 {
+		t.Errorf("timeout commit mismatch: have %d, want 400", consensus.TimeoutCommit)
+	}
+}
+
+func TestGetTxPoolConfig_proposerAllowlist(t *testing.T) {
+	addr := common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+	c := &Config{
+		MainChain: &Chain{TxPool: &Pool{ProposerAllowlist: []string{addr.Hex(), "bad"}}},
+	}
+	conf := c.getTxPoolConfig()
+	if len(conf.ProposerAllowlist) != 1 || conf.ProposerAllowlist[0] != addr {
+		t.Errorf("proposer allowlist mismatch: have %v, want [%x]", conf.ProposerAllowlist, addr)
+	}
+}
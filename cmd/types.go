@@ -18,6 +18,8 @@
 
 package main
 
+import "github.com/kardiachain/go-kardia/mainchain/genesis"
+
 type (
 	Config struct {
 		Node                 `yaml:"Node"`
@@ -25,15 +27,20 @@ type (
 		DualChain   *Chain   `yaml:"DualChain,omitempty"`
 	}
 	Node struct {
-		P2P                        `yaml:"P2P"`
-		LogLevel          string   `yaml:"LogLevel"`
-		Name              string   `yaml:"Name"`
-		DataDir           string   `yaml:"DataDir"`
-		HTTPHost          string   `yaml:"HTTPHost"`
-		HTTPPort          int      `yaml:"HTTPPort"`
-		HTTPModules       []string `yaml:"HTTPModules"`
-		HTTPVirtualHosts  []string `yaml:"HTTPVirtualHosts"`
-		HTTPCors          []string `yaml:"HTTPCors"`
+		P2P                         `yaml:"P2P"`
+		LogLevel           string   `yaml:"LogLevel"`
+		Name               string   `yaml:"Name"`
+		DataDir            string   `yaml:"DataDir"`
+		HTTPHost           string   `yaml:"HTTPHost"`
+		HTTPPort           int      `yaml:"HTTPPort"`
+		HTTPModules        []string `yaml:"HTTPModules"`
+		HTTPStrictModules  bool     `yaml:"HTTPStrictModules,omitempty"`  // HTTPStrictModules refuses to start if HTTPModules lists an unavailable module
+		HTTPMaxRequestSize int64    `yaml:"HTTPMaxRequestSize,omitempty"` // HTTPMaxRequestSize is the maximum request body size in bytes, 0 keeps the default
+		HTTPRateLimit      float64  `yaml:"HTTPRateLimit,omitempty"`      // HTTPRateLimit is the requests per second allowed per IP, 0 disables rate limiting
+		HTTPRateBurst      int      `yaml:"HTTPRateBurst,omitempty"`
+		HTTPMaxBatchLength int      `yaml:"HTTPMaxBatchLength,omitempty"` // HTTPMaxBatchLength is the maximum number of requests in a batch, 0 keeps the default
+		HTTPVirtualHosts   []string `yaml:"HTTPVirtualHosts"`
+		HTTPCors           []string `yaml:"HTTPCors"`
 	}
 	P2P struct {
 		PrivateKey    string    `yaml:"PrivateKey"`
@@ -43,14 +50,23 @@ type (
 	Chain struct {
 		ServiceName   string         `yaml:"ServiceName"`
 		Protocol      *string        `yaml:"Protocol,omitempty"`
-		ChainID       uint64         `yaml:"ChainID"`
-		NetworkID     uint64         `yaml:"NetworkID"`
+		ChainID       uint64         `yaml:"ChainId"`
+		NetworkID     uint64         `yaml:"NetworkId"`
 		AcceptTxs     uint32         `yaml:"AcceptTxs"`
 		ZeroFee       uint           `yaml:"ZeroFee"`
+		MaxReorgDepth uint64         `yaml:"MaxReorgDepth,omitempty"` // MaxReorgDepth is the maximum number of blocks the chain may be rewound, 0 keeps the default
+		Archive       *uint          `yaml:"Archive,omitempty"`       // Archive retains the state of every block (1 is yes, 0 prunes old state), unset keeps archive mode
+		StateRetention uint64        `yaml:"StateRetention,omitempty"` // StateRetention is the number of recent blocks whose state is retained when not in archive mode, 0 keeps the default
+		TxExecutionWorkers int       `yaml:"TxExecutionWorkers,omitempty"` // TxExecutionWorkers is the number of workers applying independent transactions of a block, below 2 is sequential
+		StateSnapshotLimit int       `yaml:"StateSnapshotLimit,omitempty"` // StateSnapshotLimit is the number of recently opened states cached, 0 keeps the default and negative disables the cache
+		SyncMode      string         `yaml:"SyncMode,omitempty"`      // SyncMode is either "full" (default) or "headers-first"
+		Checkpoint    *Checkpoint    `yaml:"Checkpoint,omitempty"`    // Checkpoint is a trusted block to start syncing from instead of genesis
+		Forks         *Forks         `yaml:"Forks,omitempty"`         // Forks sets the activation heights of the chain's forks, unset forks stay disabled
 		IsDual        uint           `yaml:"IsDual"`
 		Consensus     *Consensus     `yaml:"Consensus,omitempty"`
 		Genesis       *Genesis       `yaml:"Genesis,omitempty"`
 		TxPool        *Pool          `yaml:"TxPool,omitempty"`
+		GasPrice      *GasPrice      `yaml:"GasPrice,omitempty"`      // GasPrice configures the gas price oracle, unset values keep their defaults
 		EventPool     *Pool          `yaml:"EventPool,omitempty"`
 		Database      *Database      `yaml:"Database,omitempty"`
 		Seeds         []string       `yaml:"Seeds"`
@@ -64,61 +80,49 @@ type (
 		Addresses      []string      `yaml:"Addresses"`
 		GenesisAmount  string        `yaml:"GenesisAmount"`
 		Contracts      []Contract    `yaml:"Contracts"`
+		GasLimit       uint64        `yaml:"GasLimit,omitempty"` // GasLimit of the genesis block, 0 keeps the default value
 	}
 	Consensus struct {
-		MaxViolatePercentageAllowed uint64           `yaml:"MaxViolatePercentageAllowed"`
-		FetchNewValidatorsTime     uint64            `yaml:"FetchNewValidatorsTime"`
-		MaxValidators              uint64            `yaml:"MaxValidators"`
-		ConsensusPeriodInBlock     uint64            `yaml:"ConsensusPeriod"`
-		BlockReward                string            `yaml:"BlockReward"`
-		MinimumStakes              string            `yaml:"MinimumStakes"` // MinimumStakes defines the minimum amount that a user stakes to a node.
-		LockedPeriod               uint64            `yaml:"LockedPeriod"`  // LockedPeriod defines the period in block that user cannot withdraw staked KAI.
-		Compilation                Compilation       `yaml:"Compilation"`
-		Deployment                 Deployment        `yaml:"Deployment"`
-	}
-	Compilation struct { // Compilation contains compiled bytecodes and abi for Master.sol, Node.sol and Staker.sol
-		Master     CompilationInfo  `yaml:"Master"`
-		Staker     CompilationInfo  `yaml:"Staker"`
-		Node       CompilationInfo  `yaml:"Node"`
-	}
-	CompilationInfo struct {
-		ByteCode     string        `yaml:"ByteCode"`
-		ABI          string        `yaml:"ABI"`
-	}
-	Deployment struct { // Deployment contains consensus genesis information that will be created at the beginning
-		Master     MasterInfo    `yaml:"Master"`
-		Stakers    []StakerInfo  `yaml:"Stakers"`
-		Nodes      []NodeInfo    `yaml:"Nodes"`
-	}
-	MasterInfo struct { // MasterInfo contains master contract address and its genesis amount
-		Address    string      `yaml:"Address"`
-		GenesisAmount string   `yaml:"GenesisAmount"`
-	}
-	NodeInfo struct {
-		Address             string       `yaml:"Address"`
-		Owner               string       `yaml:"Owner"`
-		PubKey              string       `yaml:"PubKey"`
-		Name                string       `yaml:"Name"`
-		RewardPercentage    uint16       `yaml:"RewardPercentage"`  // RewardPercentage defines total KAI that all stakers receive every validated block.
-	}
-	StakerInfo struct { // StakerInfo contains genesis staker address, node that it will stake to from the beginning and stakeAmount
-		Address     string       `yaml:"Address"`       // Address is predefined staker's contract address
-		Owner       string       `yaml:"Owner"`         // Owner is owner's address for staker contract address
-		StakedNode  string       `yaml:"StakedNode"`    // StakedNode is genesis node's address that user will stake to
-		StakeAmount string       `yaml:"StakeAmount"`
+		genesis.ConsensusConfig `yaml:",inline"` // ConsensusConfig is the PoS genesis deployment
+
+		TimeoutPropose        int    `yaml:"TimeoutPropose,omitempty"` // Timeouts are in milliseconds, 0 keeps the default value
+		TimeoutProposeDelta   int    `yaml:"TimeoutProposeDelta,omitempty"`
+		TimeoutPrevote        int    `yaml:"TimeoutPrevote,omitempty"`
+		TimeoutPrevoteDelta   int    `yaml:"TimeoutPrevoteDelta,omitempty"`
+		TimeoutPrecommit      int    `yaml:"TimeoutPrecommit,omitempty"`
+		TimeoutPrecommitDelta int    `yaml:"TimeoutPrecommitDelta,omitempty"`
+		TimeoutCommit         int    `yaml:"TimeoutCommit,omitempty"`
+		MaxBlockBytes         uint64 `yaml:"MaxBlockBytes,omitempty"` // MaxBlockBytes is the maximum size of a proposal block, 0 keeps the default value
 	}
 	Contract struct {
 		Address    string    `yaml:"Address,omitempty"`
 		ByteCode   string    `yaml:"ByteCode"`
 		ABI        string    `yaml:"ABI,omitempty"`
 		GenesisAmount string `yaml:"GenesisAmount,omitempty"`
+		ConstructorArgs []string `yaml:"ConstructorArgs,omitempty"` // if set, ByteCode is the creation code and it is deployed with these args
 	}
 	Pool struct {
-		GlobalSlots  uint64 `yaml:"GlobalSlots"`
-		GlobalQueue  uint64 `yaml:"GlobalQueue"`
-		LifeTime     int    `yaml:"LifeTime"`
-		AccountSlots uint64 `yaml:"AccountSlots"`
-		AccountQueue uint64 `yaml:"AccountQueue"`
+		GlobalSlots       uint64  `yaml:"GlobalSlots"`
+		GlobalQueue       uint64  `yaml:"GlobalQueue"`
+		LifeTime          int     `yaml:"LifeTime"`                    // LifeTime is the maximum time in seconds non-local transactions are kept pending, or queued by an inactive account, 0 keeps the default
+		AccountSlots      uint64  `yaml:"AccountSlots"`                // AccountSlots is the number of executable transaction slots guaranteed per account, 0 keeps the default
+		AccountQueue      uint64  `yaml:"AccountQueue"`                // AccountQueue is the maximum number of non-executable transactions per account, 0 keeps the default
+		Journal           string  `yaml:"Journal,omitempty"`           // Journal is the local transactions file, relative to the node's data dir unless absolute
+		Rejournal         int     `yaml:"Rejournal,omitempty"`         // Rejournal is the journal regeneration interval in seconds
+		NoLocals          uint    `yaml:"NoLocals,omitempty"`          // NoLocals disables local transaction handling (1 is yes, 0 is no)
+		MaxTxGasFraction  float64 `yaml:"MaxTxGasFraction,omitempty"`  // MaxTxGasFraction is the maximum fraction of the block gas limit a single transaction may request
+		CreatePriceLimit  uint64  `yaml:"CreatePriceLimit,omitempty"`  // CreatePriceLimit is the minimum gas price of remote contract creations
+		CallPriceLimit    uint64  `yaml:"CallPriceLimit,omitempty"`    // CallPriceLimit is the minimum gas price of remote transfers and calls
+		BroadcastFlood    float64 `yaml:"BroadcastFlood,omitempty"`    // BroadcastFlood is the fraction of peers new transactions are sent to in full, the rest only get their hashes
+		BroadcastMinFlood int     `yaml:"BroadcastMinFlood,omitempty"` // BroadcastMinFlood is the minimum number of peers new transactions are sent to in full
+		Workers           int     `yaml:"Workers,omitempty"`           // Workers is the number of event pool workers adding batches of events concurrently
+		WorkerCap         int     `yaml:"WorkerCap,omitempty"`         // WorkerCap is the maximum number of events in a batch handed to an event pool worker
+
+		ProposerAllowlist []string `yaml:"ProposerAllowlist,omitempty"` // ProposerAllowlist lists the only senders whose transactions are proposed into blocks, empty allows everyone
+	}
+	GasPrice struct {
+		Blocks     int `yaml:"Blocks,omitempty"`     // Blocks is the number of recent blocks sampled by the gas price oracle
+		Percentile int `yaml:"Percentile,omitempty"` // Percentile is the percentile of the sampled prices suggested by the gas price oracle
 	}
 	Database struct {
 		Type         uint      `yaml:"Type"`
@@ -145,4 +149,14 @@ type (
 		Address      string       `yaml:"Address"`
 		PrivateKey   string       `yaml:"PrivateKey"`
 	}
+	Checkpoint struct { // Checkpoint is a trusted block identified by its height and hex encoded hash
+		Height       uint64       `yaml:"Height"`
+		Hash         string       `yaml:"Hash"`
+	}
+	Forks struct { // Forks contains fork activation heights, 0 activates a fork from genesis
+		ConstantinopleBlock *uint64 `yaml:"ConstantinopleBlock,omitempty"`
+		IstanbulBlock       *uint64 `yaml:"IstanbulBlock,omitempty"`
+		LondonBlock         *uint64 `yaml:"LondonBlock,omitempty"` // LondonBlock starts charging a base fee and accepting dynamic fee transactions
+		GasFixBlock         *uint64 `yaml:"GasFixBlock,omitempty"` // GasFixBlock stops charging the constant gas of dynamically priced instructions twice
+	}
 )
@@ -19,6 +19,7 @@
 package configs
 
 import (
+	"errors"
 	"math/big"
 	"time"
 
@@ -37,6 +38,8 @@ var (
 	TestnetGenesisHash = common.HexToHash("0x41941023680923e0fe4d74a34bdac8141f2540e3ae90623718e47d66d1ca4a2d")
 )
 
+// The shift instructions predate the fork schedule, so the built-in chains enable
+// Constantinople from genesis.
 var (
 	// MainnetChainConfig is the chain parameters to run a node on the main network.
 	MainnetChainConfig = &types.ChainConfig{
@@ -44,6 +47,7 @@ var (
 			Period: 15,
 			Epoch:  30000,
 		},
+		ConstantinopleBlock: big.NewInt(0),
 	}
 
 	// TestnetChainConfig contains the chain parameters to run a node on the test network.
@@ -52,6 +56,7 @@ var (
 			Period: 15,
 			Epoch:  30000,
 		},
+		ConstantinopleBlock: big.NewInt(0),
 	}
 
 	// TestChainConfig contains the chain parameters to run unit test.
@@ -60,6 +65,7 @@ var (
 			Period: 15,
 			Epoch:  30000,
 		},
+		ConstantinopleBlock: big.NewInt(0),
 	}
 )
 
@@ -87,6 +93,12 @@ type ConsensusConfig struct {
 	// Reactor sleep duration parameters are in milliseconds
 	PeerGossipSleepDuration     time.Duration `mapstructure:"peer_gossip_sleep_duration"`
 	PeerQueryMaj23SleepDuration time.Duration `mapstructure:"peer_query_maj23_sleep_duration"`
+
+	// Maximum size in bytes of a proposal block assembled from its parts
+	MaxBlockBytes uint64 `mapstructure:"max_block_bytes"`
+
+	// Number of recent heights for which missed proposals are kept, 0 keeps all of them
+	MissedProposalWindow uint64 `mapstructure:"missed_proposal_window"`
 }
 
 // DefaultConsensusConfig returns a default configuration for the consensus service
@@ -104,7 +116,48 @@ func DefaultConsensusConfig() *ConsensusConfig {
 		CreateEmptyBlocksInterval:   3 * time.Second,
 		PeerGossipSleepDuration:     100 * time.Millisecond,
 		PeerQueryMaj23SleepDuration: 2000 * time.Millisecond,
+		MaxBlockBytes:               types.MaxBlockSizeBytes,
+		MissedProposalWindow:        1000,
+	}
+}
+
+// ValidateBasic performs basic validation (checking param bounds, etc.) and
+// returns an error if any check fails.
+func (cfg *ConsensusConfig) ValidateBasic() error {
+	if cfg.TimeoutPropose < 0 {
+		return errors.New("timeout_propose can't be negative")
+	}
+	if cfg.TimeoutProposeDelta < 0 {
+		return errors.New("timeout_propose_delta can't be negative")
+	}
+	if cfg.TimeoutPrevote < 0 {
+		return errors.New("timeout_prevote can't be negative")
+	}
+	if cfg.TimeoutPrevoteDelta < 0 {
+		return errors.New("timeout_prevote_delta can't be negative")
+	}
+	if cfg.TimeoutPrecommit < 0 {
+		return errors.New("timeout_precommit can't be negative")
+	}
+	if cfg.TimeoutPrecommitDelta < 0 {
+		return errors.New("timeout_precommit_delta can't be negative")
+	}
+	if cfg.TimeoutCommit < 0 {
+		return errors.New("timeout_commit can't be negative")
+	}
+	if cfg.CreateEmptyBlocksInterval < 0 {
+		return errors.New("create_empty_blocks_interval can't be negative")
+	}
+	if cfg.PeerGossipSleepDuration < 0 {
+		return errors.New("peer_gossip_sleep_duration can't be negative")
+	}
+	if cfg.PeerQueryMaj23SleepDuration < 0 {
+		return errors.New("peer_query_maj23_sleep_duration can't be negative")
+	}
+	if cfg.MaxBlockBytes == 0 {
+		return errors.New("max_block_bytes can't be 0")
 	}
+	return nil
 }
 
 // WaitForTxs returns true if the consensus should wait for transactions before entering the propose step
@@ -0,0 +1,48 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package configs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConsensusConfigValidateBasic(t *testing.T) {
+	if err := DefaultConsensusConfig().ValidateBasic(); err != nil {
+		t.Fatalf("default consensus config is invalid: %v", err)
+	}
+
+	cases := map[string]func(cfg *ConsensusConfig){
+		"TimeoutPropose":        func(cfg *ConsensusConfig) { cfg.TimeoutPropose = -1 },
+		"TimeoutProposeDelta":   func(cfg *ConsensusConfig) { cfg.TimeoutProposeDelta = -1 },
+		"TimeoutPrevote":        func(cfg *ConsensusConfig) { cfg.TimeoutPrevote = -1 },
+		"TimeoutPrevoteDelta":   func(cfg *ConsensusConfig) { cfg.TimeoutPrevoteDelta = -1 },
+		"TimeoutPrecommit":      func(cfg *ConsensusConfig) { cfg.TimeoutPrecommit = -1 },
+		"TimeoutPrecommitDelta": func(cfg *ConsensusConfig) { cfg.TimeoutPrecommitDelta = -1 },
+		"TimeoutCommit":         func(cfg *ConsensusConfig) { cfg.TimeoutCommit = -time.Second },
+		"MaxBlockBytes":         func(cfg *ConsensusConfig) { cfg.MaxBlockBytes = 0 },
+	}
+	for name, mutate := range cases {
+		cfg := DefaultConsensusConfig()
+		mutate(cfg)
+		if err := cfg.ValidateBasic(); err == nil {
+			t.Errorf("expected invalid %s to be rejected", name)
+		}
+	}
+}
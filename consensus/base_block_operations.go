@@ -34,6 +34,7 @@ type BaseBlockOperations interface {
 	CreateProposalBlock(height int64, state LastestBlockState, proposerAddr common.Address, commit *types.Commit) (block *types.Block, blockParts *types.PartSet)
 	CommitAndValidateBlockTxs(block *types.Block) (common.Hash, error)
 	SaveBlock(block *types.Block, partSet *types.PartSet, seenCommit *types.Commit)
+	SaveCheckpoint(block *types.Block, partSet *types.PartSet, seenCommit *types.Commit, root common.Hash) error
 	LoadBlockPart(height uint64, index int) *types.Part
 	LoadBlockMeta(height uint64) *types.BlockMeta
 	Blockchain() base.BaseBlockChain
@@ -23,6 +23,7 @@ import (
 	"github.com/ebuchman/fail-test"
 	"github.com/kardiachain/go-kardia/kai/base"
 	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/kvm"
 	cmn "github.com/kardiachain/go-kardia/lib/common"
 	"github.com/kardiachain/go-kardia/lib/log"
 	"github.com/kardiachain/go-kardia/types"
@@ -104,3 +105,13 @@ func updateState(logger log.Logger, state LastestBlockState, appHash cmn.Hash, b
 		AppHash:                     appHash,
 	}, nil
 }
+
+// checkpointValidators returns the validators the Master smart contract holds in root,
+// the state after executing the block with the given header.
+func checkpointValidators(logger log.Logger, bc base.BaseBlockChain, header *types.Header, root cmn.Hash) (*types.ValidatorSet, error) {
+	st, err := state.New(logger, root, state.NewDatabase(bc.DB().DB()))
+	if err != nil {
+		return nil, fmt.Errorf("state %v is unavailable: %v", root.Hex(), err)
+	}
+	return kvm.CollectValidatorSetAt(bc, header, st)
+}
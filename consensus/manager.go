@@ -77,6 +77,41 @@ func (conR *ConsensusManager) Validators() []*types.Validator {
 	return conR.conS.Validators.CurrentValidators()
 }
 
+// Halt pauses block proposal, see ConsensusState.Halt.
+func (conR *ConsensusManager) Halt() {
+	conR.conS.Halt()
+}
+
+// Resume resumes block proposal paused by Halt.
+func (conR *ConsensusManager) Resume() {
+	conR.conS.Resume()
+}
+
+// IsHalted returns whether block proposal is paused.
+func (conR *ConsensusManager) IsHalted() bool {
+	return conR.conS.IsHalted()
+}
+
+// MissedProposals returns the number of proposals the validator missed, see ConsensusState.MissedProposals.
+func (conR *ConsensusManager) MissedProposals(addr cmn.Address, height uint64) uint64 {
+	return conR.conS.MissedProposals(addr, height)
+}
+
+// VerifyCommit checks a commit against the validators in force at its height, see ConsensusState.VerifyCommit.
+func (conR *ConsensusManager) VerifyCommit(blockID types.BlockID, height uint64, commit *types.Commit) error {
+	return conR.conS.VerifyCommit(blockID, height, commit)
+}
+
+// ImportBlock executes and saves a block retrieved from a peer, see ConsensusState.ImportBlock.
+func (conR *ConsensusManager) ImportBlock(block *types.Block, seenCommit *types.Commit) error {
+	return conR.conS.ImportBlock(block, seenCommit)
+}
+
+// ImportCheckpoint saves a trusted block retrieved from a peer along with its state, see ConsensusState.ImportCheckpoint.
+func (conR *ConsensusManager) ImportCheckpoint(block *types.Block, seenCommit *types.Commit, root cmn.Hash) error {
+	return conR.conS.ImportCheckpoint(block, seenCommit, root)
+}
+
 func (conR *ConsensusManager) Start() {
 	conR.logger.Trace("Consensus manager starts!")
 
@@ -201,6 +236,17 @@ func (conR *ConsensusManager) ReceiveNewBlockPart(generalMsg p2p.Msg, src *p2p.P
 		return
 	}
 
+	// Reject parts that do not belong to the proposal block being assembled before
+	// queueing them for the consensus state. As blocks might be reused across rounds,
+	// only the height has to match.
+	rs := conR.conS.GetRoundState()
+	if rs.Height.Equals(msg.Height) && rs.ProposalBlockParts != nil {
+		if err := types.VerifyPart(rs.ProposalBlockParts.Header(), msg.Part); err != nil {
+			conR.logger.Error("Failed to verify block part", "peer", src, "height", msg.Height, "round", msg.Round, "index", msg.Part.Index, "err", err)
+			return
+		}
+	}
+
 	ps, ok := src.Get(conR.GetPeerStateKey()).(*PeerState)
 	if !ok {
 		conR.logger.Error("Downcast failed!!")
@@ -0,0 +1,78 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package consensus
+
+import (
+	"sync"
+
+	cmn "github.com/kardiachain/go-kardia/lib/common"
+)
+
+// missedProposals records validators that were selected to propose a round but
+// whose proposal never arrived, keyed by validator and height. It is the input
+// for slashing or removing validators that keep skipping their turn.
+type missedProposals struct {
+	mtx    sync.RWMutex
+	missed map[cmn.Address]map[uint64]uint64 // validator -> height -> rounds missed
+}
+
+// add records a missed proposal of addr at height and forgets heights that
+// fell out of the window of recent heights. A zero window keeps every height.
+func (mp *missedProposals) add(addr cmn.Address, height uint64, window uint64) {
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	if mp.missed == nil {
+		mp.missed = make(map[cmn.Address]map[uint64]uint64)
+	}
+	if mp.missed[addr] == nil {
+		mp.missed[addr] = make(map[uint64]uint64)
+	}
+	mp.missed[addr][height]++
+
+	if window == 0 || height < window {
+		return
+	}
+	for val, heights := range mp.missed {
+		for h := range heights {
+			if h <= height-window {
+				delete(heights, h)
+			}
+		}
+		if len(heights) == 0 {
+			delete(mp.missed, val)
+		}
+	}
+}
+
+// count returns the number of proposals addr missed at height, or at all kept
+// heights if height is zero.
+func (mp *missedProposals) count(addr cmn.Address, height uint64) uint64 {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	if height != 0 {
+		return mp.missed[addr][height]
+	}
+	var total uint64
+	for _, n := range mp.missed[addr] {
+		total += n
+	}
+	return total
+}
@@ -28,9 +28,9 @@ import (
 	"reflect"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/kardiachain/go-kardia/lib/rlp"
 
 	"github.com/ebuchman/fail-test"
 
@@ -133,6 +133,15 @@ type ConsensusState struct {
 	votingStrategy map[VoteTurn]int
 
 	updateVals bool
+
+	// halted is 1 while block proposal is paused for maintenance (see Halt)
+	halted uint32
+
+	// validators that failed to propose when selected
+	missedProposals missedProposals
+
+	// validator sets in force at the recent heights, to verify commits for them
+	validatorHistory validatorHistory
 }
 
 // NewConsensusState returns a new ConsensusState.
@@ -264,6 +273,7 @@ func (cs *ConsensusState) updateToState(state LastestBlockState) {
 		cs.StartTime = big.NewInt(cs.config.Commit(commitTime).Unix())
 	}
 	cs.Validators = validators
+	cs.validatorHistory.add(height.Uint64(), validators)
 	cs.Proposal = nil
 	cs.ProposalBlock = nil
 	cs.ProposalBlockParts = nil
@@ -710,9 +720,11 @@ func (cs *ConsensusState) addProposalBlockPart(msg *BlockPartMessage, peerID dis
 
 	if added && cs.ProposalBlockParts.IsComplete() {
 		// Added and completed!
-		if err := rlp.Decode(cs.ProposalBlockParts.GetReader(), &cs.ProposalBlock); err != nil {
+		block, err := types.MakeBlockFromPartSet(cs.ProposalBlockParts, cs.config.MaxBlockBytes)
+		if err != nil {
 			return added, err
 		}
+		cs.ProposalBlock = block
 		// NOTE: it's possible to receive complete proposal blocks for future rounds without having the proposal
 		cs.logger.Info("Received complete proposal block", "height", cs.ProposalBlock.Height(), "hash", cs.ProposalBlock.Hash())
 		cs.eventBus.PublishEventCompleteProposal(cs.CompleteProposalEvent())
@@ -815,6 +827,12 @@ func (cs *ConsensusState) enterNewRound(height *cmn.BigInt, round *cmn.BigInt) {
 	// Increment validators if necessary
 	validators := cs.Validators
 	if cs.Round.IsLessThan(round) {
+		// Moving on without a proposal means the proposer of this round missed its turn.
+		if cs.Proposal == nil {
+			proposer := validators.GetProposer().Address
+			logger.Info("Proposer missed its turn", "proposer", proposer.Hex(), "round", cs.Round)
+			cs.missedProposals.add(proposer, height.Uint64(), cs.config.MissedProposalWindow)
+		}
 		// TODO(namdoh): Revisit to see if we need to copy validators here.
 		validators = validators.Copy()
 		validators.AdvanceProposer(round.Int64() - cs.Round.Int64())
@@ -906,6 +924,10 @@ func (cs *ConsensusState) enterPropose(height *cmn.BigInt, round *cmn.BigInt) {
 	}
 
 	logger.Debug("This node is a validator")
+	if cs.IsHalted() {
+		logger.Info("Block proposal is halted, skip proposing")
+		return
+	}
 	if cs.isProposer() {
 		logger.Trace("Our turn to propose")
 		//namdoh@ logger.Info("enterPropose: Our turn to propose", "proposer", cs.Validators.GetProposer().Address, "privValidator", cs.privValidator)
@@ -1039,7 +1061,7 @@ func (cs *ConsensusState) enterPrecommit(height *cmn.BigInt, round *cmn.BigInt)
 	// the latest POLRound should be this round.
 	polRound, _ := cs.Votes.POLInfo()
 	if polRound < round.Int32() {
-		cmn.PanicSanity(cmn.Fmt("This POLRound should be %v but got %", round, polRound))
+		cmn.PanicSanity(cmn.Fmt("This POLRound should be %v but got %v", round, polRound))
 	}
 
 	// +2/3 prevoted nil. Unlock and precommit nil.
@@ -1321,6 +1343,148 @@ func (cs *ConsensusState) isProposalComplete() bool {
 	return cs.Votes.Prevotes(cs.Proposal.POLRound.Int32()).HasTwoThirdsMajority()
 }
 
+// Halt pauses block proposal at runtime, e.g. during an upgrade. The node keeps following
+// consensus messages from its peers, it just never proposes a block itself until Resume.
+func (cs *ConsensusState) Halt() {
+	atomic.StoreUint32(&cs.halted, 1)
+	cs.logger.Info("Block proposal halted")
+}
+
+// Resume resumes block proposal paused by Halt.
+func (cs *ConsensusState) Resume() {
+	atomic.StoreUint32(&cs.halted, 0)
+	cs.logger.Info("Block proposal resumed")
+}
+
+// IsHalted returns whether block proposal is paused.
+func (cs *ConsensusState) IsHalted() bool {
+	return atomic.LoadUint32(&cs.halted) == 1
+}
+
+// MissedProposals returns how many times the validator failed to propose when
+// selected at the given height, or at all tracked heights if height is 0.
+func (cs *ConsensusState) MissedProposals(addr cmn.Address, height uint64) uint64 {
+	return cs.missedProposals.count(addr, height)
+}
+
+// VerifyCommit checks that commit holds +2/3 of the precommits for blockID at height
+// by the validators in force at height. Used to verify blocks retrieved outside of
+// consensus, height can't be above the one consensus is at nor older than the
+// validator sets it keeps track of.
+func (cs *ConsensusState) VerifyCommit(blockID types.BlockID, height uint64, commit *types.Commit) error {
+	cs.mtx.RLock()
+	defer cs.mtx.RUnlock()
+	return cs.verifyCommit(blockID, height, commit)
+}
+
+func (cs *ConsensusState) verifyCommit(blockID types.BlockID, height uint64, commit *types.Commit) error {
+	if current := cs.Height.Uint64(); height > current {
+		return fmt.Errorf("height %v is ahead of consensus at height %v", height, current)
+	}
+	validators := cs.validatorHistory.at(height)
+	if validators == nil {
+		return fmt.Errorf("validator set in force at height %v is unknown", height)
+	}
+	return validators.VerifyCommit(cs.state.ChainID, blockID, int64(height), commit)
+}
+
+// ImportBlock executes a block retrieved from a peer on top of the chain head, saves it
+// with seenCommit as the commit justifying it and moves consensus to the next height,
+// as finalizeCommit does for the blocks decided by this node. seenCommit must hold +2/3
+// of the precommits of the validators in force at the block height.
+func (cs *ConsensusState) ImportBlock(block *types.Block, seenCommit *types.Commit) error {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+
+	if cs.CommitRound.IsGreaterThanInt(-1) {
+		return fmt.Errorf("consensus is committing height %v", cs.Height)
+	}
+	if height := cs.state.LastBlockHeight.Uint64() + 1; block.Height() != height {
+		return fmt.Errorf("block #%v doesn't follow consensus at height %v", block.Height(), height)
+	}
+	blockParts := block.MakePartSet(types.BlockPartSizeBytes)
+	blockID := types.BlockID{Hash: block.Hash(), PartsHeader: blockParts.Header()}
+	if err := cs.verifyCommit(blockID, block.Height(), seenCommit); err != nil {
+		return err
+	}
+
+	stateCopy, err := ApplyBlock(
+		cs.logger,
+		cs.state.Copy(),
+		cs.blockOperations,
+		blockID,
+		block,
+		cs.blockOperations.Blockchain(),
+	)
+	if err != nil {
+		return err
+	}
+	cs.blockOperations.SaveBlock(block, blockParts, seenCommit)
+	cs.logger.Info("Imported block", "height", block.Height(), "hash", block.Hash(), "txs", block.NumTxs())
+
+	// NewHeightStep, with the imported commit as the last commit to propose upon
+	cs.updateToState(stateCopy)
+	cs.reconstructLastCommit(stateCopy)
+	cs.scheduleRound0(&cs.RoundState)
+	return nil
+}
+
+// ImportCheckpoint saves a trusted block above the chain head along with root, the state
+// after executing it, and moves consensus to the next height without executing the blocks
+// in between. seenCommit must hold +2/3 of the precommits of the validators in force at
+// the block height, which are read from root.
+func (cs *ConsensusState) ImportCheckpoint(block *types.Block, seenCommit *types.Commit, root cmn.Hash) error {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+
+	validators, err := checkpointValidators(cs.logger, cs.blockOperations.Blockchain(), block.Header(), root)
+	if err != nil {
+		return err
+	}
+	return cs.importCheckpoint(block, seenCommit, root, validators)
+}
+
+// importCheckpoint saves the checkpoint once seenCommit is verified against validators,
+// the latest ones held in the checkpoint state.
+func (cs *ConsensusState) importCheckpoint(block *types.Block, seenCommit *types.Commit, root cmn.Hash, validators *types.ValidatorSet) error {
+	if cs.CommitRound.IsGreaterThanInt(-1) {
+		return fmt.Errorf("consensus is committing height %v", cs.Height)
+	}
+	if height := cs.state.LastBlockHeight.Uint64(); block.Height() <= height {
+		return fmt.Errorf("checkpoint #%v is not above consensus at height %v", block.Height(), height+1)
+	}
+	// The contract only holds the latest validators, which may not have taken over yet
+	if validators.StartHeight > int64(block.Height()) {
+		return fmt.Errorf("validator set in force at height %v is unknown", block.Height())
+	}
+	blockParts := block.MakePartSet(types.BlockPartSizeBytes)
+	blockID := types.BlockID{Hash: block.Hash(), PartsHeader: blockParts.Header()}
+	if err := validators.VerifyCommit(cs.state.ChainID, blockID, int64(block.Height()), seenCommit); err != nil {
+		return err
+	}
+	if err := cs.blockOperations.SaveCheckpoint(block, blockParts, seenCommit, root); err != nil {
+		return err
+	}
+	cs.logger.Info("Imported checkpoint", "height", block.Height(), "hash", block.Hash(), "root", root)
+	cs.validatorHistory.add(block.Height(), validators)
+
+	state := LastestBlockState{
+		ChainID:                     cs.state.ChainID,
+		LastBlockHeight:             cmn.NewBigUint64(block.Height()),
+		LastBlockID:                 blockID,
+		LastBlockTime:               block.Time(),
+		Validators:                  validators,
+		LastValidators:              validators.Copy(),
+		LastHeightValidatorsChanged: cmn.NewBigInt32(-1),
+		AppHash:                     root,
+		LastBlockTotalTx:            cmn.NewBigInt64(int64(block.NumTxs())),
+	}
+	cs.updateToState(state)
+	cs.reconstructLastCommit(state)
+	cs.scheduleRound0(&cs.RoundState)
+	return nil
+}
+
 func (cs *ConsensusState) isProposer() bool {
 	privValidatorAddress := cs.privValidator.GetAddress()
 	return bytes.Equal(cs.Validators.GetProposer().Address[:], privValidatorAddress[:])
@@ -1563,6 +1727,7 @@ func (state *LastestBlockState) mayRefreshValidatorSet(bc base.BaseBlockChain) {
 	if height >= currentVals.EndHeight {
 		state.Validators = nextVals.Copy()
 		state.PrefetchedFutureValidators = nil
+		bc.RefreshValidatorSet()
 	}
 }
 
@@ -0,0 +1,338 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package consensus
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	cfg "github.com/kardiachain/go-kardia/configs"
+	cstypes "github.com/kardiachain/go-kardia/consensus/types"
+	"github.com/kardiachain/go-kardia/kai/base"
+	cmn "github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// newProposerState returns a ConsensusState at height 1 whose only validator is itself and
+// which already has a valid block to propose.
+func newProposerState(t *testing.T) *ConsensusState {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := types.NewBlock(&types.Header{Height: 1, Time: big.NewInt(1)}, nil, &types.Commit{})
+	return &ConsensusState{
+		logger:           log.New(),
+		config:           cfg.DefaultConsensusConfig(),
+		privValidator:    types.NewPrivValidator(key),
+		internalMsgQueue: make(chan msgInfo, msgQueueSize),
+		timeoutTicker:    NewTimeoutTicker(),
+		evsw:             NewEventSwitch(),
+		RoundState: cstypes.RoundState{
+			Height:          cmn.NewBigInt64(1),
+			Round:           cmn.NewBigInt32(0),
+			Step:            cstypes.RoundStepNewRound,
+			Validators:      types.NewValidatorSet([]*types.Validator{types.NewValidator(key.PublicKey, 1)}, 0, 100),
+			ValidRound:      cmn.NewBigInt32(-1),
+			ValidBlock:      block,
+			ValidBlockParts: block.MakePartSet(types.BlockPartSizeBytes),
+		},
+	}
+}
+
+func TestHaltStopsProposal(t *testing.T) {
+	cs := newProposerState(t)
+	cs.Halt()
+	if !cs.IsHalted() {
+		t.Fatal("expected consensus state to be halted")
+	}
+	cs.enterPropose(cs.Height, cmn.NewBigInt32(0))
+	if len(cs.internalMsgQueue) != 0 {
+		t.Errorf("halted node proposed: %d internal messages", len(cs.internalMsgQueue))
+	}
+	// The round still advances so the node keeps following its peers.
+	if cs.Step != cstypes.RoundStepPropose {
+		t.Errorf("step mismatch: have %v, want %v", cs.Step, cstypes.RoundStepPropose)
+	}
+
+	cs.Resume()
+	if cs.IsHalted() {
+		t.Fatal("expected consensus state to be resumed")
+	}
+	cs.enterPropose(cs.Height, cmn.NewBigInt32(1))
+	if len(cs.internalMsgQueue) == 0 {
+		t.Error("resumed node did not propose")
+	}
+	if _, ok := (<-cs.internalMsgQueue).Msg.(*ProposalMessage); !ok {
+		t.Error("expected a proposal message first")
+	}
+}
+
+func TestMissedProposalTracking(t *testing.T) {
+	cs := newProposerState(t)
+	var vals []*types.Validator
+	for i := 0; i < 2; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals = append(vals, types.NewValidator(key.PublicKey, 1))
+	}
+	cs.Validators = types.NewValidatorSet(vals, 0, 100)
+	cs.Votes = cstypes.NewHeightVoteSet(cs.logger, "test", cs.Height, cs.Validators)
+	cs.StartTime = big.NewInt(0)
+	proposer := cs.Validators.GetProposer().Address
+
+	// Round 0 times out without a proposal.
+	cs.enterNewRound(cs.Height, cmn.NewBigInt32(1))
+	if missed := cs.MissedProposals(proposer, 1); missed != 1 {
+		t.Fatalf("missed proposals mismatch: have %d, want 1", missed)
+	}
+
+	// Round 1 gets a proposal, so its proposer is not penalized.
+	next := cs.Validators.GetProposer().Address
+	cs.Proposal = &types.Proposal{}
+	cs.enterNewRound(cs.Height, cmn.NewBigInt32(2))
+	if missed := cs.MissedProposals(next, 0); next != proposer && missed != 0 {
+		t.Errorf("proposer with a proposal was penalized %d times", missed)
+	}
+	if missed := cs.MissedProposals(proposer, 0); missed != 1 {
+		t.Errorf("total missed proposals mismatch: have %d, want 1", missed)
+	}
+}
+
+func TestMissedProposalsWindow(t *testing.T) {
+	var mp missedProposals
+	addr := cmn.HexToAddress("0x1")
+	for height := uint64(1); height <= 5; height++ {
+		mp.add(addr, height, 3)
+	}
+	if missed := mp.count(addr, 0); missed != 3 {
+		t.Errorf("windowed count mismatch: have %d, want 3", missed)
+	}
+	if missed := mp.count(addr, 2); missed != 0 {
+		t.Errorf("pruned height still counted %d times", missed)
+	}
+}
+
+// testBlockOperations executes no transactions and keeps the saved blocks in memory.
+type testBlockOperations struct {
+	BaseBlockOperations
+	blocks      []*types.Block
+	seenCommits []*types.Commit
+}
+
+func (ops *testBlockOperations) Height() uint64 { return uint64(len(ops.blocks) - 1) }
+
+func (ops *testBlockOperations) CommitAndValidateBlockTxs(block *types.Block) (cmn.Hash, error) {
+	return cmn.Hash{}, nil
+}
+
+func (ops *testBlockOperations) SaveBlock(block *types.Block, partSet *types.PartSet, seenCommit *types.Commit) {
+	ops.blocks = append(ops.blocks, block)
+	ops.seenCommits = append(ops.seenCommits, seenCommit)
+}
+
+func (ops *testBlockOperations) SaveCheckpoint(block *types.Block, partSet *types.PartSet, seenCommit *types.Commit, root cmn.Hash) error {
+	for uint64(len(ops.blocks)) < block.Height() {
+		ops.blocks = append(ops.blocks, nil)
+		ops.seenCommits = append(ops.seenCommits, nil)
+	}
+	ops.SaveBlock(block, partSet, seenCommit)
+	return nil
+}
+
+func (ops *testBlockOperations) LoadSeenCommit(height uint64) *types.Commit {
+	return ops.seenCommits[height]
+}
+
+func (ops *testBlockOperations) Blockchain() base.BaseBlockChain { return testBlockChain{} }
+
+// testBlockChain never changes the validators.
+type testBlockChain struct {
+	base.BaseBlockChain
+}
+
+func (testBlockChain) GetFetchNewValidatorsTime() uint64 { return 0 }
+
+// newImportState returns a ConsensusState at height 1 on top of genesis, validated by key.
+func newImportState(t *testing.T, key *ecdsa.PrivateKey) (*ConsensusState, *testBlockOperations) {
+	genesis := types.NewBlock(&types.Header{Time: big.NewInt(0)}, nil, &types.Commit{})
+	validators := types.NewValidatorSet([]*types.Validator{types.NewValidator(key.PublicKey, 1)}, 0, 100)
+	state := LastestBlockState{
+		ChainID:                     "kaicon",
+		LastBlockHeight:             cmn.NewBigUint64(0),
+		LastBlockID:                 types.BlockID{Hash: genesis.Hash(), PartsHeader: genesis.MakePartSet(types.BlockPartSizeBytes).Header()},
+		LastBlockTime:               genesis.Time(),
+		Validators:                  validators,
+		LastValidators:              validators,
+		LastHeightValidatorsChanged: cmn.NewBigInt32(-1),
+		LastBlockTotalTx:            cmn.NewBigInt64(0),
+	}
+	ops := &testBlockOperations{blocks: []*types.Block{genesis}, seenCommits: []*types.Commit{nil}}
+	return NewConsensusState(log.New(), cfg.DefaultConsensusConfig(), state, ops, nil), ops
+}
+
+// importChild returns a block on top of parent, carrying commit as its last commit.
+func importChild(parent *types.Block, commit *types.Commit) *types.Block {
+	header := &types.Header{
+		Height:      parent.Height() + 1,
+		Time:        big.NewInt(parent.Time().Int64() + 1),
+		LastBlockID: types.BlockID{Hash: parent.Hash(), PartsHeader: parent.MakePartSet(types.BlockPartSizeBytes).Header()},
+	}
+	return types.NewBlock(header, nil, commit)
+}
+
+// signImportCommit returns a commit for block holding the precommit of key.
+func signImportCommit(t *testing.T, key *ecdsa.PrivateKey, block *types.Block) *types.Commit {
+	blockID := types.BlockID{Hash: block.Hash(), PartsHeader: block.MakePartSet(types.BlockPartSizeBytes).Header()}
+	vote := &types.Vote{
+		ValidatorAddress: crypto.PubkeyToAddress(key.PublicKey),
+		ValidatorIndex:   cmn.NewBigInt32(0),
+		Height:           cmn.NewBigUint64(block.Height()),
+		Round:            cmn.NewBigInt32(0),
+		Timestamp:        big.NewInt(0),
+		Type:             types.PrecommitType,
+		BlockID:          blockID,
+	}
+	if err := types.NewPrivValidator(key).SignVote("kaicon", vote); err != nil {
+		t.Fatal(err)
+	}
+	return types.NewCommit(blockID, []*types.CommitSig{vote.CommitSig()})
+}
+
+// Tests that imported blocks are saved and move consensus to the next height, and that
+// blocks without a commit of the validators in force are refused.
+func TestImportBlock(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	outsider, _ := crypto.GenerateKey()
+	cs, ops := newImportState(t, key)
+
+	first := importChild(ops.blocks[0], &types.Commit{})
+	if err := cs.ImportBlock(first, signImportCommit(t, outsider, first)); err == nil {
+		t.Fatal("imported a block committed by an outsider")
+	}
+	commit := signImportCommit(t, key, first)
+	if err := cs.ImportBlock(first, commit); err != nil {
+		t.Fatalf("failed to import block #1: %v", err)
+	}
+	if cs.Height.Uint64() != 2 || ops.Height() != 1 {
+		t.Fatalf("height mismatch: consensus %v, chain %d, want 2 and 1", cs.Height, ops.Height())
+	}
+	if !cs.LastCommit.HasTwoThirdsMajority() {
+		t.Error("last commit not reconstructed from the imported commit")
+	}
+
+	second := importChild(first, commit)
+	if err := cs.ImportBlock(importChild(second, signImportCommit(t, key, second)), signImportCommit(t, key, second)); err == nil {
+		t.Error("imported a block which doesn't follow the consensus height")
+	}
+	if err := cs.ImportBlock(second, signImportCommit(t, key, second)); err != nil {
+		t.Fatalf("failed to import block #2: %v", err)
+	}
+
+	// Past commits are verified against the validators in force at their height
+	blockID := types.BlockID{Hash: first.Hash(), PartsHeader: first.MakePartSet(types.BlockPartSizeBytes).Header()}
+	if err := cs.VerifyCommit(blockID, 1, commit); err != nil {
+		t.Errorf("failed to verify the commit of block #1: %v", err)
+	}
+	if err := cs.VerifyCommit(blockID, 5, commit); err == nil {
+		t.Error("verified a commit above the consensus height")
+	}
+}
+
+// Tests that a checkpoint is only saved if committed by the validators in force at its
+// height, and that blocks above it are imported on top of its state afterwards.
+func TestImportCheckpoint(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	outsider, _ := crypto.GenerateKey()
+	cs, ops := newImportState(t, key)
+
+	var (
+		root       = cmn.HexToHash("0x01")
+		checkpoint = types.NewBlock(&types.Header{Height: 10, Time: big.NewInt(10), AppHash: root}, nil, &types.Commit{})
+		commit     = signImportCommit(t, key, checkpoint)
+		inForce    = types.NewValidatorSet([]*types.Validator{types.NewValidator(key.PublicKey, 1)}, 5, 100)
+		upcoming   = types.NewValidatorSet([]*types.Validator{types.NewValidator(key.PublicKey, 1)}, 11, 100)
+	)
+	if err := cs.importCheckpoint(checkpoint, signImportCommit(t, outsider, checkpoint), root, inForce); err == nil {
+		t.Error("imported a checkpoint committed by an outsider")
+	}
+	if err := cs.importCheckpoint(checkpoint, commit, root, upcoming); err == nil {
+		t.Error("imported a checkpoint committed by validators not in force yet")
+	}
+	if ops.Height() != 0 {
+		t.Fatalf("refused checkpoint saved, chain at height %d", ops.Height())
+	}
+	if err := cs.importCheckpoint(checkpoint, commit, root, inForce); err != nil {
+		t.Fatalf("failed to import checkpoint: %v", err)
+	}
+	if cs.Height.Uint64() != 11 || ops.Height() != 10 {
+		t.Fatalf("height mismatch: consensus %v, chain %d, want 11 and 10", cs.Height, ops.Height())
+	}
+	if !cs.LastCommit.HasTwoThirdsMajority() {
+		t.Error("last commit not reconstructed from the checkpoint commit")
+	}
+	blockID := types.BlockID{Hash: checkpoint.Hash(), PartsHeader: checkpoint.MakePartSet(types.BlockPartSizeBytes).Header()}
+	if err := cs.VerifyCommit(blockID, 10, commit); err != nil {
+		t.Errorf("failed to verify the checkpoint commit: %v", err)
+	}
+
+	header := &types.Header{Height: 11, Time: big.NewInt(11), LastBlockID: blockID, AppHash: root}
+	next := types.NewBlock(header, nil, commit)
+	if err := cs.ImportBlock(next, signImportCommit(t, key, next)); err != nil {
+		t.Fatalf("failed to import the block above the checkpoint: %v", err)
+	}
+	if err := cs.importCheckpoint(checkpoint, commit, root, inForce); err == nil {
+		t.Error("imported a checkpoint below the consensus height")
+	}
+}
+
+func TestValidatorHistory(t *testing.T) {
+	newSet := func(start, end int64) *types.ValidatorSet {
+		key, _ := crypto.GenerateKey()
+		return types.NewValidatorSet([]*types.Validator{types.NewValidator(key.PublicKey, 1)}, start, end)
+	}
+	var (
+		vh     validatorHistory
+		first  = newSet(1, 10)
+		second = newSet(11, 20)
+	)
+	vh.add(1, first)
+	for height := uint64(2); height <= 10; height++ {
+		vh.add(height, first) // same validators, nothing recorded
+	}
+	vh.add(11, second)
+	tests := []struct {
+		height uint64
+		want   *types.ValidatorSet
+	}{{0, nil}, {1, first}, {10, first}, {11, second}, {15, second}}
+	for _, tt := range tests {
+		have := vh.at(tt.height)
+		if (have == nil) != (tt.want == nil) || (have != nil && !sameValidators(have, tt.want)) {
+			t.Errorf("validators at height %d mismatch: have %v, want %v", tt.height, have, tt.want)
+		}
+	}
+	if len(vh.sets) != 2 {
+		t.Errorf("recorded sets mismatch: have %d, want 2", len(vh.sets))
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package consensus
+
+import (
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// maxValidatorHistory is the number of validator sets validatorHistory keeps.
+const maxValidatorHistory = 64
+
+// validatorHistory records the validator sets consensus has run with, along with
+// the height each of them came in force at, so that commits for past heights are
+// verified against the validators that signed them rather than the current ones.
+type validatorHistory struct {
+	sets []heightValidators // ascending by height
+}
+
+type heightValidators struct {
+	height     uint64 // first height the validators are in force at
+	validators *types.ValidatorSet
+}
+
+// add records validators as in force from height on, unless they are the ones
+// already in force. Only the maxValidatorHistory most recent sets are kept.
+func (vh *validatorHistory) add(height uint64, validators *types.ValidatorSet) {
+	if n := len(vh.sets); n > 0 && sameValidators(vh.sets[n-1].validators, validators) {
+		return
+	}
+	vh.sets = append(vh.sets, heightValidators{height: height, validators: validators.Copy()})
+	if len(vh.sets) > maxValidatorHistory {
+		vh.sets = vh.sets[len(vh.sets)-maxValidatorHistory:]
+	}
+}
+
+// at returns the validator set in force at height, or nil if height is below
+// the oldest recorded set.
+func (vh *validatorHistory) at(height uint64) *types.ValidatorSet {
+	for i := len(vh.sets) - 1; i >= 0; i-- {
+		if vh.sets[i].height <= height {
+			return vh.sets[i].validators
+		}
+	}
+	return nil
+}
+
+// sameValidators returns whether a and b hold the same validators with the same
+// voting power over the same period, regardless of their proposer priorities.
+func sameValidators(a, b *types.ValidatorSet) bool {
+	if a.StartHeight != b.StartHeight || a.EndHeight != b.EndHeight || len(a.Validators) != len(b.Validators) {
+		return false
+	}
+	for i, val := range a.Validators {
+		if val.Address != b.Validators[i].Address || val.VotingPower != b.Validators[i].VotingPower {
+			return false
+		}
+	}
+	return true
+}
@@ -212,6 +212,11 @@ func (dbc *DualBlockChain) CheckCommittedStateRoot(root common.Hash) bool {
 	return err == nil
 }
 
+// TrieNode retrieves a state trie node or contract code by hash, from memory or disk.
+func (dbc *DualBlockChain) TrieNode(hash common.Hash) ([]byte, error) {
+	return dbc.stateCache.TrieDB().Node(hash)
+}
+
 // SubscribeChainHeadEvent registers a subscription of ChainHeadEvent.
 func (dbc *DualBlockChain) SubscribeChainHeadEvent(ch chan<- events.ChainHeadEvent) event.Subscription {
 	return dbc.scope.Track(dbc.chainHeadFeed.Subscribe(ch))
@@ -477,6 +482,10 @@ func (dbc *DualBlockChain) GetBlockReward() *big.Int {
 	return nil
 }
 
+func (dbc *DualBlockChain) BlockRewardAt(height uint64) *big.Int {
+	return nil
+}
+
 func (dbc *DualBlockChain) GetConsensusMasterSmartContract() pos.MasterSmartContract {
 	return pos.MasterSmartContract{}
 }
@@ -490,3 +499,5 @@ func (dbc *DualBlockChain) GetConsensusStakerAbi() string {
 }
 
 func (dbc *DualBlockChain) GetFetchNewValidatorsTime() uint64 { return 0 }
+
+func (dbc *DualBlockChain) RefreshValidatorSet() uint64 { return 0 }
@@ -155,6 +155,11 @@ func (dbo *DualBlockOperations) SaveBlock(block *types.Block, blockParts *types.
 	dbo.mtx.Unlock()
 }
 
+// SaveCheckpoint is not supported, as the dual's blockchain is never synced from peers.
+func (dbo *DualBlockOperations) SaveCheckpoint(block *types.Block, blockParts *types.PartSet, seenCommit *types.Commit, root common.Hash) error {
+	return errors.New("dual's blockchain can't start from a checkpoint")
+}
+
 // Returns the Block for the given height.
 // If no block is found for the given height, it returns nil.
 func (dbo *DualBlockOperations) LoadBlock(height uint64) *types.Block {
@@ -167,7 +167,7 @@ func (dhc *DualHeaderChain) SetHead(head uint64, delFn DeleteCallback) {
 		}
 		dhc.kaiDb.DeleteBlockMeta(hash, height)
 
-		dhc.currentHeader.Store(dhc.GetHeader(hdr.LastCommitHash, hdr.Height-1))
+		dhc.currentHeader.Store(dhc.GetHeader(hdr.LastBlockID.Hash, hdr.Height-1))
 	}
 	// Roll back the canonical chain numbering
 	for i := height; i > head; i-- {
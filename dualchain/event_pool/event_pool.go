@@ -18,6 +18,15 @@ const (
 
 	// promotableQueueSize is the size for promotableQueue
 	promotableQueueSize = 1000000
+
+	// defaultNumberOfWorkers is the number of workers adding queued batches when none is configured.
+	defaultNumberOfWorkers = 4
+
+	// defaultWorkerCap is the maximum size of a batch handed to a worker when none is configured.
+	defaultWorkerCap = 512
+
+	// eventsChanSize is the number of batches that may wait for a worker before AddEvents blocks.
+	eventsChanSize = 100
 )
 
 // blockChain provides the state of blockchain and current gas limit to do
@@ -35,6 +44,24 @@ type Config struct {
 	GlobalQueue  uint64
 	AccountSlots uint64
 	AccountQueue uint64
+
+	NumberOfWorkers int // Number of workers adding batches of events concurrently
+	WorkerCap       int // Maximum number of events in a batch handed to a worker
+}
+
+// sanitize checks the provided user configurations and changes anything that's
+// unreasonable or unworkable.
+func (config *Config) sanitize() Config {
+	conf := *config
+	if conf.NumberOfWorkers < 1 {
+		log.Warn("Sanitizing invalid eventpool number of workers", "provided", conf.NumberOfWorkers, "updated", defaultNumberOfWorkers)
+		conf.NumberOfWorkers = defaultNumberOfWorkers
+	}
+	if conf.WorkerCap < 1 {
+		log.Warn("Sanitizing invalid eventpool worker cap", "provided", conf.WorkerCap, "updated", defaultWorkerCap)
+		conf.WorkerCap = defaultWorkerCap
+	}
+	return conf
 }
 
 // EventPool contains all currently interesting events from both external or internal blockchains. Events enter the pool
@@ -46,7 +73,7 @@ type Pool struct {
 	chain  blockChain
 	config Config
 
-	eventsCh chan []interface{}               // eventsCh is used for pending events
+	eventsCh chan []interface{}               // eventsCh queues batches of events for the workers
 	allCh    chan []interface{}               // allCh is used to cache processed events
 	pending  map[common.Hash]*types.DualEvent // current processable events
 	all      map[common.Hash]*types.DualEvent // All events
@@ -67,15 +94,18 @@ type Pool struct {
 }
 
 func NewPool(logger log.Logger, config Config, chain blockChain) *Pool {
+	config = (&config).sanitize()
 	pool := &Pool{
-		logger:      logger,
-		eventsCh:    make(chan []interface{}, 100),
-		allCh:       make(chan []interface{}),
-		pending:     make(map[common.Hash]*types.DualEvent),
-		all:         make(map[common.Hash]*types.DualEvent),
-		chainHeadCh: make(chan events.ChainHeadEvent, chainHeadChanSize),
-		chain:       chain,
-		config:      config,
+		logger:          logger,
+		eventsCh:        make(chan []interface{}, eventsChanSize),
+		allCh:           make(chan []interface{}),
+		pending:         make(map[common.Hash]*types.DualEvent),
+		all:             make(map[common.Hash]*types.DualEvent),
+		numberOfWorkers: config.NumberOfWorkers,
+		workerCap:       config.WorkerCap,
+		chainHeadCh:     make(chan events.ChainHeadEvent, chainHeadChanSize),
+		chain:           chain,
+		config:          config,
 	}
 
 	pool.reset(nil, chain.CurrentBlock().Header())
@@ -83,7 +113,10 @@ func NewPool(logger log.Logger, config Config, chain blockChain) *Pool {
 	// Subscribe events from dual block chain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 
-	// Start the event loop and return
+	// Start the workers and the event loop and return
+	for i := 0; i < pool.numberOfWorkers; i++ {
+		go pool.work()
+	}
 	pool.wg.Add(1)
 	go pool.loop()
 
@@ -96,7 +129,6 @@ func NewPool(logger log.Logger, config Config, chain blockChain) *Pool {
 func (pool *Pool) loop() {
 	// Track the previous head headers for transaction reorgs
 	head := pool.chain.CurrentBlock()
-	collectTicker := time.NewTicker(2000 * time.Millisecond)
 	// Keep waiting for and reacting to the various events
 	for {
 		select {
@@ -106,8 +138,6 @@ func (pool *Pool) loop() {
 		// Be unsubscribed due to system stopped
 		case <-pool.chainHeadSub.Err():
 			return
-		case <-collectTicker.C:
-			go pool.collectEvents()
 		}
 	}
 }
@@ -132,28 +162,28 @@ func (pool *Pool) notifyTxsAvailable() {
 	}
 }
 
-// collectEvents is called periodically to add events from eventsCh to pending pool
-func (pool *Pool) collectEvents() {
-	for i := 0; i < pool.numberOfWorkers; i++ {
-		go pool.work(i, <-pool.eventsCh)
+// work is run by each worker to add the batches queued in eventsCh into the pending pool.
+func (pool *Pool) work() {
+	for evts := range pool.eventsCh {
+		pool.addEvents(evts)
 	}
 }
 
-// work is called by workers to add txs into pool
-func (pool *Pool) work(index int, txs []interface{}) {
-	go pool.addEvents(txs)
-}
-
+// AddEvents splits events into batches of at most workerCap events and queues them
+// for the workers. It blocks while the queue is full, so a huge input never holds
+// more than numberOfWorkers batches in flight besides the queued ones.
 func (pool *Pool) AddEvents(events []interface{}) {
-	if len(events) > 0 {
-		to := pool.workerCap
-		if len(events) < to {
+	if len(events) == 0 {
+		return
+	}
+	for from := 0; from < len(events); from += pool.workerCap {
+		to := from + pool.workerCap
+		if to > len(events) {
 			to = len(events)
 		}
-		pool.eventsCh <- events[0:to]
-		pool.AddEvents(events[to:])
-		pool.notifyTxsAvailable()
+		pool.eventsCh <- events[from:to]
 	}
+	pool.notifyTxsAvailable()
 }
 
 // AddEvent adds a single event into event pool
@@ -0,0 +1,135 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package event_pool
+
+import (
+	"math/big"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kardiachain/go-kardia/kai/events"
+	message "github.com/kardiachain/go-kardia/ksml/proto"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/event"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// testBlockChain is a dual blockchain stuck at an empty head block.
+type testBlockChain struct {
+	head          *types.Block
+	chainHeadFeed event.Feed
+}
+
+func (bc *testBlockChain) CurrentBlock() *types.Block                            { return bc.head }
+func (bc *testBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block { return bc.head }
+func (bc *testBlockChain) DB() types.StoreDB                                     { return nil }
+func (bc *testBlockChain) SubscribeChainHeadEvent(ch chan<- events.ChainHeadEvent) event.Subscription {
+	return bc.chainHeadFeed.Subscribe(ch)
+}
+
+// makeEvents creates n external events with distinct tx hashes. They all carry the
+// same signature, which recovers to some sender for each of them.
+func makeEvents(t *testing.T, n int) []interface{} {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := common.Hash{}
+	signed, err := types.SignEvent(types.NewDualEvent(1, true, "ETH", &hash, &message.EventMessage{}, nil), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 65)
+	signed.R.FillBytes(sig[:32])
+	signed.S.FillBytes(sig[32:64])
+	sig[64] = byte(new(big.Int).Sub(signed.V, big.NewInt(27)).Uint64())
+
+	evts := make([]interface{}, n)
+	for i := range evts {
+		hash := common.BigToHash(big.NewInt(int64(i + 1)))
+		evt, err := types.NewDualEvent(1, true, "ETH", &hash, &message.EventMessage{}, nil).WithSignature(sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		evts[i] = evt
+	}
+	return evts
+}
+
+func TestAddEventsBoundedWorkers(t *testing.T) {
+	// Recovering the senders dominates the run time.
+	count := 100000
+	if testing.Short() {
+		count = 10000
+	}
+	evts := makeEvents(t, count)
+
+	chain := &testBlockChain{head: types.NewBlock(&types.Header{Time: big.NewInt(0)}, nil, &types.Commit{})}
+	config := Config{GlobalSlots: uint64(count), NumberOfWorkers: 4, WorkerCap: 100}
+	pool := NewPool(log.New(), config, chain)
+	base := runtime.NumGoroutine()
+
+	// Sample the goroutine count while the events are being added.
+	var peak int64
+	done := make(chan struct{})
+	go func() {
+		for {
+			if n := int64(runtime.NumGoroutine()); n > atomic.LoadInt64(&peak) {
+				atomic.StoreInt64(&peak, n)
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+	pool.AddEvents(evts)
+
+	deadline := time.Now().Add(time.Minute)
+	for {
+		pool.mu.RLock()
+		pending := len(pool.pending)
+		pool.mu.RUnlock()
+		if pending == count {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pending events mismatch: have %d, want %d", pending, count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(done)
+
+	// Only the sampler may run besides the goroutines started by NewPool.
+	if have := atomic.LoadInt64(&peak); have > int64(base)+1 {
+		t.Errorf("goroutines grew while adding events: have %d, base %d", have, base)
+	}
+}
+
+func TestConfigSanitize(t *testing.T) {
+	config := (&Config{}).sanitize()
+	if config.NumberOfWorkers != defaultNumberOfWorkers || config.WorkerCap != defaultWorkerCap {
+		t.Errorf("sanitized config mismatch: have %d/%d, want %d/%d", config.NumberOfWorkers, config.WorkerCap, defaultNumberOfWorkers, defaultWorkerCap)
+	}
+}
@@ -19,6 +19,7 @@
 package service
 
 import (
+	"github.com/kardiachain/go-kardia/configs"
 	"github.com/kardiachain/go-kardia/dualchain/event_pool"
 	"github.com/kardiachain/go-kardia/kai/storage"
 	"github.com/kardiachain/go-kardia/mainchain/genesis"
@@ -38,6 +39,9 @@ type DualConfig struct {
 	// Dual's event pool options
 	DualEventPool event_pool.Config
 
+	// Consensus options, DefaultConsensusConfig is used if nil
+	Consensus *configs.ConsensusConfig
+
 	// DbInfo stores configuration information to setup database
 	DBInfo storage.DbInfo
 
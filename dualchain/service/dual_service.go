@@ -19,6 +19,8 @@
 package service
 
 import (
+	"fmt"
+
 	"github.com/kardiachain/go-kardia/configs"
 	"github.com/kardiachain/go-kardia/consensus"
 	"github.com/kardiachain/go-kardia/dualchain/blockchain"
@@ -96,7 +98,13 @@ func newDualService(ctx *node.ServiceContext, config *DualConfig) (*DualService,
 		return nil, err
 	}
 
-	consensusConfig := configs.DefaultConsensusConfig()
+	consensusConfig := config.Consensus
+	if consensusConfig == nil {
+		consensusConfig = configs.DefaultConsensusConfig()
+	}
+	if err := consensusConfig.ValidateBasic(); err != nil {
+		return nil, fmt.Errorf("invalid consensus config: %v", err)
+	}
 
 	dualService.eventPool = event_pool.NewPool(logger, config.DualEventPool, dualService.blockchain)
 
@@ -167,6 +175,7 @@ func NewDualService(ctx *node.ServiceContext) (node.Service, error) {
 		ChainID:       chainConfig.ChainId,
 		DBInfo:        chainConfig.DBInfo,
 		DualEventPool: chainConfig.DualEventPool,
+		Consensus:     chainConfig.Consensus,
 		DualGenesis:   chainConfig.DualGenesis,
 		IsPrivate:     chainConfig.IsPrivate,
 		BaseAccount:   chainConfig.BaseAccount,
@@ -107,8 +107,11 @@ type BaseBlockChain interface {
 	ApplyMessage(vm KVM, msg types.Message, gp *types.GasPool) ([]byte, uint64, bool, error)
 	GetFetchNewValidatorsTime() uint64
 	GetBlockReward() *big.Int
+	BlockRewardAt(height uint64) *big.Int
 	GetConsensusMasterSmartContract() pos.MasterSmartContract
 	GetConsensusNodeAbi() string
 	GetConsensusStakerAbi() string
 	CheckCommittedStateRoot(root common.Hash) bool
+	TrieNode(hash common.Hash) ([]byte, error)
+	RefreshValidatorSet() uint64
 }
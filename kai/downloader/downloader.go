@@ -0,0 +1,431 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package downloader contains the initial block download from a single peer.
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/trie"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+var (
+	MaxBlockFetch  = 128 // Amount of blocks to be fetched per retrieval request
+	MaxHeaderFetch = 192 // Amount of block headers to be fetched per retrieval request
+	MaxBodyFetch   = 128 // Amount of block bodies to be fetched per retrieval request
+	MaxStateFetch  = 384 // Amount of state trie nodes and contract code to be fetched per retrieval request
+)
+
+var (
+	errBusy          = errors.New("busy")
+	errEmptyResponse = errors.New("empty response from peer")
+	errInvalidChain  = errors.New("retrieved hash chain is invalid")
+	errInvalidBody   = errors.New("retrieved block body is invalid")
+	errHeadMismatch  = errors.New("retrieved chain doesn't end at the announced head")
+	errInvalidCommit = errors.New("retrieved block commit is invalid")
+	errInvalidState  = errors.New("retrieved state is invalid")
+
+	errCheckpointMismatch = errors.New("peer's block at the checkpoint height doesn't match the checkpoint")
+)
+
+// Checkpoint is a trusted block a new node starts syncing from instead of the
+// genesis block. Blocks below it are neither downloaded nor verified, the state
+// after it is retrieved from the peer instead.
+type Checkpoint struct {
+	Height uint64
+	Hash   common.Hash
+}
+
+// ValidateBasic performs basic validation of the checkpoint.
+func (cp *Checkpoint) ValidateBasic() error {
+	if cp.Height == 0 {
+		return errors.New("checkpoint height must be above genesis")
+	}
+	if cp.Hash.IsZero() {
+		return errors.New("checkpoint hash must be set")
+	}
+	return nil
+}
+
+// Peer is the set of requests the downloader needs to retrieve a chain from a
+// remote node.
+type Peer interface {
+	// Head returns the hash and height of the peer's current head block.
+	Head() (common.Hash, uint64)
+
+	// RequestHeadersByNumber returns up to amount consecutive headers starting at origin.
+	RequestHeadersByNumber(origin uint64, amount int) ([]*types.Header, error)
+
+	// RequestBodies returns the bodies of the blocks with the given hashes, in order.
+	RequestBodies(hashes []common.Hash) ([]*types.Body, error)
+
+	// RequestBlocksByNumber returns up to amount consecutive blocks starting at origin.
+	RequestBlocksByNumber(origin uint64, amount int) ([]*types.Block, error)
+
+	// RequestNodeData returns the state trie nodes and contract code with the given
+	// hashes the peer has, in any order.
+	RequestNodeData(hashes []common.Hash) ([][]byte, error)
+}
+
+// BlockChain is the local chain the downloaded blocks are imported into.
+type BlockChain interface {
+	CurrentBlock() *types.Block
+
+	// DB returns the database of the chain, which the state of a checkpoint is retrieved into.
+	DB() types.StoreDB
+}
+
+// Consensus verifies and executes the downloaded blocks, keeping the consensus state
+// at the head of the local chain.
+type Consensus interface {
+	// VerifyCommit checks that commit holds +2/3 of the precommits for blockID at height
+	// by the validators in force at height.
+	VerifyCommit(blockID types.BlockID, height uint64, commit *types.Commit) error
+
+	// ImportBlock executes block on top of the local head, writes it along with its state
+	// and seenCommit, and moves consensus to the next height.
+	ImportBlock(block *types.Block, seenCommit *types.Commit) error
+
+	// ImportCheckpoint writes block as the local head on top of root, the state after
+	// executing it, and moves consensus to the next height. seenCommit must be signed by
+	// the validators in force at the block height according to that state.
+	ImportCheckpoint(block *types.Block, seenCommit *types.Commit, root common.Hash) error
+}
+
+// Downloader synchronises the local chain with the chain of a remote peer.
+type Downloader struct {
+	mode       SyncMode
+	checkpoint *Checkpoint // Trusted block to start syncing from, nil syncs from genesis
+	chain      BlockChain
+	consensus  Consensus
+	logger     log.Logger
+
+	synchronising int32 // Flag whether a synchronisation is running
+}
+
+// New creates a new downloader using the given sync mode, which must be valid.
+// A non-nil checkpoint lets a node behind it skip the blocks below it.
+func New(mode SyncMode, checkpoint *Checkpoint, chain BlockChain, consensus Consensus, logger log.Logger) *Downloader {
+	if !mode.IsValid() {
+		panic(fmt.Sprintf("invalid sync mode %d", mode))
+	}
+	return &Downloader{
+		mode:       mode,
+		checkpoint: checkpoint,
+		chain:      chain,
+		consensus:  consensus,
+		logger:     logger,
+	}
+}
+
+// Mode returns the sync mode of the downloader.
+func (d *Downloader) Mode() SyncMode {
+	return d.mode
+}
+
+// Synchronising returns whether the downloader is currently retrieving blocks.
+func (d *Downloader) Synchronising() bool {
+	return atomic.LoadInt32(&d.synchronising) > 0
+}
+
+// Synchronise downloads and imports all blocks between the local head and the
+// head announced by the peer. The peer's head block itself is retrieved but not
+// imported, as the commit for it only arrives with its successor. Nothing is
+// imported if the retrieved chain fails verification, and the import stops at
+// the first block whose commit isn't signed by the validators in force.
+func (d *Downloader) Synchronise(p Peer) error {
+	if !atomic.CompareAndSwapInt32(&d.synchronising, 0, 1) {
+		return errBusy
+	}
+	defer atomic.StoreInt32(&d.synchronising, 0)
+
+	origin := d.chain.CurrentBlock()
+	hash, height := p.Head()
+	if height <= origin.Height()+1 {
+		// Nothing to write until the peer's head has a successor
+		return nil
+	}
+	d.logger.Info("Synchronising with peer", "mode", d.mode, "local", origin.Height(), "remote", height)
+
+	// A node behind the checkpoint starts from the trusted block instead
+	if cp := d.checkpoint; cp != nil && origin.Height() < cp.Height && cp.Height < height {
+		checkpoint, err := d.syncCheckpoint(p)
+		if err != nil {
+			return err
+		}
+		d.logger.Info("Starting sync from checkpoint", "height", cp.Height, "hash", cp.Hash)
+		origin = checkpoint
+	}
+
+	var (
+		blocks []*types.Block
+		err    error
+	)
+	switch d.mode {
+	case FullSync:
+		blocks, err = d.fetchBlocks(p, origin, height)
+	case HeadersFirstSync:
+		blocks, err = d.fetchHeadersFirst(p, origin, height)
+	}
+	if err != nil {
+		return err
+	}
+	if blocks[len(blocks)-1].Hash() != hash {
+		return errHeadMismatch
+	}
+	return d.importBlocks(blocks)
+}
+
+// syncCheckpoint retrieves the checkpoint block from the peer along with the state
+// after it, and writes it as the local head. The checkpoint is refused if the peer's
+// canonical block at that height has a different hash, or if the commit carried by
+// its successor isn't signed by the validators in force at that height.
+func (d *Downloader) syncCheckpoint(p Peer) (*types.Block, error) {
+	blocks, err := p.RequestBlocksByNumber(d.checkpoint.Height, 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) != 2 {
+		return nil, errEmptyResponse
+	}
+	block, next := blocks[0], blocks[1]
+	if block.Height() != d.checkpoint.Height || block.Hash() != d.checkpoint.Hash {
+		d.logger.Warn("Peer disagrees with the checkpoint", "height", d.checkpoint.Height,
+			"want", d.checkpoint.Hash, "have", block.Hash())
+		return nil, errCheckpointMismatch
+	}
+	if err := verifyBody(block.Header(), block.Body()); err != nil {
+		return nil, err
+	}
+	if err := verifyHeader(next.Header(), block.Header()); err != nil {
+		return nil, err
+	}
+	if err := verifyBody(next.Header(), next.Body()); err != nil {
+		return nil, err
+	}
+	// The successor is built upon the state after the checkpoint, whose validators
+	// are the only ones able to tell whether the checkpoint was committed.
+	root := next.Header().AppHash
+	if err := d.syncState(p, root); err != nil {
+		return nil, err
+	}
+	if err := d.consensus.ImportCheckpoint(block, next.LastCommit(), root); err != nil {
+		return nil, fmt.Errorf("failed to import checkpoint #%d: %v", block.Height(), err)
+	}
+	return block, nil
+}
+
+// syncState retrieves the state trie rooted at root from the peer, along with the
+// storage tries and code of its accounts, and writes it to the local database. Nodes
+// the peer leaves out of a reply are requested again, until a reply brings nothing.
+func (d *Downloader) syncState(p Peer, root common.Hash) error {
+	var (
+		db      = d.chain.DB().DB()
+		sched   = state.NewStateSync(root, db)
+		pending []common.Hash // Requested nodes the peer hasn't delivered yet
+		written int
+	)
+	for sched.Pending() > 0 {
+		hashes := pending
+		if len(hashes) < MaxStateFetch {
+			hashes = append(hashes, sched.Missing(MaxStateFetch-len(hashes))...)
+		}
+		data, err := p.RequestNodeData(hashes)
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return errEmptyResponse
+		}
+		results := make([]trie.SyncResult, 0, len(data))
+		delivered := make(map[common.Hash]struct{}, len(data))
+		for _, blob := range data {
+			hash := crypto.Keccak256Hash(blob)
+			results = append(results, trie.SyncResult{Hash: hash, Data: blob})
+			delivered[hash] = struct{}{}
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			d.logger.Warn("Retrieved state node is invalid", "hash", results[index].Hash, "err", err)
+			return errInvalidState
+		}
+		pending = nil
+		for _, hash := range hashes {
+			if _, ok := delivered[hash]; !ok {
+				pending = append(pending, hash)
+			}
+		}
+
+		batch := db.NewBatch()
+		n, err := sched.Commit(batch)
+		if err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		written += n
+	}
+	d.logger.Info("Retrieved checkpoint state", "root", root, "nodes", written)
+	return nil
+}
+
+// fetchBlocks retrieves whole blocks above origin up to height, verifying each
+// batch against the already retrieved chain as it arrives.
+func (d *Downloader) fetchBlocks(p Peer, origin *types.Block, height uint64) ([]*types.Block, error) {
+	var (
+		blocks []*types.Block
+		parent = origin.Header()
+	)
+	for from := origin.Height() + 1; from <= height; from += uint64(MaxBlockFetch) {
+		batch, err := p.RequestBlocksByNumber(from, fetchAmount(from, height, MaxBlockFetch))
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			return nil, errEmptyResponse
+		}
+		for _, block := range batch {
+			header := block.Header()
+			if err := verifyHeader(header, parent); err != nil {
+				return nil, err
+			}
+			if err := verifyBody(header, block.Body()); err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, block)
+			parent = header
+		}
+	}
+	return blocks, nil
+}
+
+// fetchHeadersFirst retrieves and verifies the whole header chain above origin
+// up to height before requesting any block bodies.
+func (d *Downloader) fetchHeadersFirst(p Peer, origin *types.Block, height uint64) ([]*types.Block, error) {
+	var (
+		headers []*types.Header
+		parent  = origin.Header()
+	)
+	for from := origin.Height() + 1; from <= height; from += uint64(MaxHeaderFetch) {
+		batch, err := p.RequestHeadersByNumber(from, fetchAmount(from, height, MaxHeaderFetch))
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			return nil, errEmptyResponse
+		}
+		for _, header := range batch {
+			if err := verifyHeader(header, parent); err != nil {
+				return nil, err
+			}
+			headers = append(headers, header)
+			parent = header
+		}
+	}
+	if len(headers) != int(height-origin.Height()) {
+		return nil, errInvalidChain
+	}
+
+	blocks := make([]*types.Block, 0, len(headers))
+	for start := 0; start < len(headers); start += MaxBodyFetch {
+		end := start + MaxBodyFetch
+		if end > len(headers) {
+			end = len(headers)
+		}
+		hashes := make([]common.Hash, 0, end-start)
+		for _, header := range headers[start:end] {
+			hashes = append(hashes, header.Hash())
+		}
+		bodies, err := p.RequestBodies(hashes)
+		if err != nil {
+			return nil, err
+		}
+		if len(bodies) != len(hashes) {
+			return nil, errInvalidBody
+		}
+		for i, body := range bodies {
+			header := headers[start+i]
+			if err := verifyBody(header, body); err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, types.NewBlockWithHeader(header).WithBody(body))
+		}
+	}
+	return blocks, nil
+}
+
+// importBlocks executes and writes the verified blocks to the local chain. The seen
+// commit of each block is the last commit carried by its successor, which must be
+// signed by +2/3 of the validators in force at its height. As executing a block may
+// change the validators, each commit is only verified once its parent is imported.
+// The last block has no successor yet and is left out.
+func (d *Downloader) importBlocks(blocks []*types.Block) error {
+	for i := 0; i+1 < len(blocks); i++ {
+		block, next := blocks[i], blocks[i+1]
+		if err := d.consensus.VerifyCommit(next.Header().LastBlockID, block.Height(), next.LastCommit()); err != nil {
+			d.logger.Warn("Retrieved block has an invalid commit", "height", block.Height(), "hash", block.Hash(), "err", err)
+			return errInvalidCommit
+		}
+		if err := d.consensus.ImportBlock(block, next.LastCommit()); err != nil {
+			return fmt.Errorf("failed to import block #%d: %v", block.Height(), err)
+		}
+	}
+	d.logger.Info("Synchronisation completed", "blocks", len(blocks)-1, "head", blocks[len(blocks)-1].Height()-1)
+	return nil
+}
+
+// verifyHeader checks that header directly follows parent.
+func verifyHeader(header, parent *types.Header) error {
+	if header.Height != parent.Height+1 || header.LastBlockID.Hash != parent.Hash() {
+		return errInvalidChain
+	}
+	return nil
+}
+
+// verifyBody checks that body is the content committed to by header.
+func verifyBody(header *types.Header, body *types.Body) error {
+	if types.Transactions(body.Transactions).Hash() != header.TxHash {
+		return errInvalidBody
+	}
+	if body.LastCommit.Hash() != header.LastCommitHash {
+		return errInvalidBody
+	}
+	if len(body.DualEvents) > 0 || !header.DualEventsHash.IsZero() {
+		if !types.VerifyDualEventsHash(body.DualEvents, header.DualEventsHash) {
+			return errInvalidBody
+		}
+	}
+	return nil
+}
+
+// fetchAmount returns how many items starting at from to request without
+// going past height.
+func fetchAmount(from, height uint64, max int) int {
+	if remaining := height - from + 1; remaining < uint64(max) {
+		return int(remaining)
+	}
+	return max
+}
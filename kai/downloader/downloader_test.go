@@ -0,0 +1,463 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package downloader
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/kardiachain/go-kardia/kai/kaidb"
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/kai/storage/kvstore"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+const testChainID = "kaicon"
+
+var (
+	validatorKey, _ = crypto.GenerateKey()
+	outsiderKey, _  = crypto.GenerateKey()
+)
+
+// testValidators accepts commits signed by the validator key only.
+var testValidators = types.NewValidatorSet([]*types.Validator{types.NewValidator(validatorKey.PublicKey, 100)}, 0, 0)
+
+var (
+	testAccount   = common.HexToAddress("0x0a")
+	testStateDB   = memorydb.New()
+	testStateRoot = makeState(testStateDB)
+)
+
+// makeState writes a state holding a single contract account to db and returns its root.
+func makeState(db kaidb.Database) common.Hash {
+	st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(db))
+	st.AddBalance(testAccount, big.NewInt(1000))
+	st.SetCode(testAccount, []byte("code"))
+	st.SetState(testAccount, common.HexToHash("0x01"), common.HexToHash("0x02"))
+	root, err := st.Commit(true)
+	if err != nil {
+		panic(err)
+	}
+	if err := st.Database().TrieDB().Commit(root, false); err != nil {
+		panic(err)
+	}
+	return root
+}
+
+// testChain is an in-memory chain the downloader imports blocks into, acting as
+// the consensus of the node as well.
+type testChain struct {
+	blocks []*types.Block
+	db     types.StoreDB
+}
+
+// newTestChain creates a chain holding a copy of blocks, so that importing into it
+// leaves the chain served by peers untouched.
+func newTestChain(blocks []*types.Block) *testChain {
+	return &testChain{blocks: append([]*types.Block{}, blocks...), db: kvstore.NewStoreDB(memorydb.New())}
+}
+
+func (c *testChain) CurrentBlock() *types.Block {
+	return c.blocks[len(c.blocks)-1]
+}
+
+func (c *testChain) DB() types.StoreDB {
+	return c.db
+}
+
+func (c *testChain) VerifyCommit(blockID types.BlockID, height uint64, commit *types.Commit) error {
+	return testValidators.VerifyCommit(testChainID, blockID, int64(height), commit)
+}
+
+func (c *testChain) ImportBlock(block *types.Block, seenCommit *types.Commit) error {
+	if seenCommit.BlockID.Hash != block.Hash() {
+		return fmt.Errorf("seen commit is for block %x, not %x", seenCommit.BlockID.Hash, block.Hash())
+	}
+	c.blocks = append(c.blocks, block)
+	return nil
+}
+
+func (c *testChain) ImportCheckpoint(block *types.Block, seenCommit *types.Commit, root common.Hash) error {
+	if _, err := state.New(log.New(), root, state.NewDatabase(c.db.DB())); err != nil {
+		return err
+	}
+	blockID := types.BlockID{Hash: block.Hash()}
+	if err := testValidators.VerifyCommit(testChainID, blockID, int64(block.Height()), seenCommit); err != nil {
+		return err
+	}
+	c.blocks = append(c.blocks, block)
+	return nil
+}
+
+// testPeer serves a fixed chain along with the test state and records the requests
+// made to it. A positive maxNodes caps the state entries served per request.
+type testPeer struct {
+	blocks   []*types.Block
+	state    kaidb.KeyValueReader
+	maxNodes int
+	requests []string
+}
+
+func newTestPeer(blocks []*types.Block) *testPeer {
+	return &testPeer{blocks: blocks, state: testStateDB}
+}
+
+func (p *testPeer) Head() (common.Hash, uint64) {
+	head := p.blocks[len(p.blocks)-1]
+	return head.Hash(), head.Height()
+}
+
+func (p *testPeer) RequestHeadersByNumber(origin uint64, amount int) ([]*types.Header, error) {
+	p.requests = append(p.requests, fmt.Sprintf("headers %d %d", origin, amount))
+	var headers []*types.Header
+	for i := origin; i < origin+uint64(amount) && i < uint64(len(p.blocks)); i++ {
+		headers = append(headers, p.blocks[i].Header())
+	}
+	return headers, nil
+}
+
+func (p *testPeer) RequestBodies(hashes []common.Hash) ([]*types.Body, error) {
+	p.requests = append(p.requests, fmt.Sprintf("bodies %d", len(hashes)))
+	var bodies []*types.Body
+	for _, hash := range hashes {
+		for _, block := range p.blocks {
+			if block.Hash() == hash {
+				bodies = append(bodies, block.Body())
+				break
+			}
+		}
+	}
+	return bodies, nil
+}
+
+func (p *testPeer) RequestBlocksByNumber(origin uint64, amount int) ([]*types.Block, error) {
+	p.requests = append(p.requests, fmt.Sprintf("blocks %d %d", origin, amount))
+	var blocks []*types.Block
+	for i := origin; i < origin+uint64(amount) && i < uint64(len(p.blocks)); i++ {
+		blocks = append(blocks, p.blocks[i])
+	}
+	return blocks, nil
+}
+
+func (p *testPeer) RequestNodeData(hashes []common.Hash) ([][]byte, error) {
+	p.requests = append(p.requests, fmt.Sprintf("nodes %d", len(hashes)))
+	var data [][]byte
+	for _, hash := range hashes {
+		if p.maxNodes > 0 && len(data) == p.maxNodes {
+			break
+		}
+		if blob, err := p.state.Get(hash.Bytes()); err == nil {
+			data = append(data, blob)
+		}
+	}
+	return data, nil
+}
+
+// makeChain creates a chain of n blocks on top of a genesis block, each block
+// carrying a last commit for its parent signed by signer and the test state root.
+func makeChain(n int, signer *ecdsa.PrivateKey) []*types.Block {
+	blocks := []*types.Block{types.NewBlock(&types.Header{Time: big.NewInt(0)}, nil, nil)}
+	for i := 1; i <= n; i++ {
+		parent := blocks[i-1]
+		header := &types.Header{
+			Height:      uint64(i),
+			Time:        big.NewInt(int64(i)),
+			LastBlockID: types.BlockID{Hash: parent.Hash()},
+			AppHash:     testStateRoot,
+		}
+		blocks = append(blocks, types.NewBlock(header, nil, signCommit(signer, header.LastBlockID, parent.Height())))
+	}
+	return blocks
+}
+
+// signCommit creates a commit for blockID at height holding a single precommit of signer.
+func signCommit(signer *ecdsa.PrivateKey, blockID types.BlockID, height uint64) *types.Commit {
+	vote := &types.Vote{
+		ValidatorAddress: crypto.PubkeyToAddress(signer.PublicKey),
+		ValidatorIndex:   common.NewBigInt32(0),
+		Height:           common.NewBigUint64(height),
+		Round:            common.NewBigInt32(0),
+		Timestamp:        big.NewInt(int64(height)),
+		Type:             types.PrecommitType,
+		BlockID:          blockID,
+	}
+	if err := types.NewPrivValidator(signer).SignVote(testChainID, vote); err != nil {
+		panic(err)
+	}
+	return types.NewCommit(blockID, []*types.CommitSig{vote.CommitSig()})
+}
+
+func TestSyncModeText(t *testing.T) {
+	for _, mode := range []SyncMode{FullSync, HeadersFirstSync} {
+		text, err := mode.MarshalText()
+		if err != nil {
+			t.Fatalf("failed to marshal %v: %v", mode, err)
+		}
+		var decoded SyncMode
+		if err := decoded.UnmarshalText(text); err != nil {
+			t.Fatalf("failed to unmarshal %q: %v", text, err)
+		}
+		if decoded != mode {
+			t.Errorf("sync mode mismatch: have %v, want %v", decoded, mode)
+		}
+	}
+	var mode SyncMode
+	if err := mode.UnmarshalText([]byte("fast")); err == nil {
+		t.Error("unknown sync mode accepted")
+	}
+	if SyncMode(2).IsValid() {
+		t.Error("out of range sync mode reported valid")
+	}
+}
+
+// Tests that both sync modes converge to the parent of the peer's head, and that
+// the headers first mode retrieves the whole header chain before any bodies.
+func TestSynchroniseModes(t *testing.T) {
+	remote := makeChain(300, validatorKey)
+	tests := []struct {
+		mode     SyncMode
+		requests []string
+	}{
+		{FullSync, []string{"blocks 1 128", "blocks 129 128", "blocks 257 44"}},
+		{HeadersFirstSync, []string{"headers 1 192", "headers 193 108", "bodies 128", "bodies 128", "bodies 44"}},
+	}
+	for _, tt := range tests {
+		chain := newTestChain(remote[:1])
+		peer := newTestPeer(remote)
+		if err := New(tt.mode, nil, chain, chain, log.New()).Synchronise(peer); err != nil {
+			t.Fatalf("%v: synchronisation failed: %v", tt.mode, err)
+		}
+		if head := chain.CurrentBlock(); head.Hash() != remote[299].Hash() {
+			t.Errorf("%v: head mismatch: have #%d %x, want #%d %x", tt.mode, head.Height(), head.Hash(), 299, remote[299].Hash())
+		}
+		if !reflect.DeepEqual(peer.requests, tt.requests) {
+			t.Errorf("%v: requests mismatch:\nhave %v\nwant %v", tt.mode, peer.requests, tt.requests)
+		}
+	}
+}
+
+// Tests that a partially synced node only retrieves the missing blocks, and a
+// node not behind the parent of the peer's head requests nothing.
+func TestSynchroniseFromLocalHead(t *testing.T) {
+	remote := makeChain(10, validatorKey)
+
+	chain := newTestChain(remote[:6])
+	peer := newTestPeer(remote)
+	if err := New(HeadersFirstSync, nil, chain, chain, log.New()).Synchronise(peer); err != nil {
+		t.Fatalf("synchronisation failed: %v", err)
+	}
+	if want := []string{"headers 6 5", "bodies 5"}; !reflect.DeepEqual(peer.requests, want) {
+		t.Errorf("requests mismatch: have %v, want %v", peer.requests, want)
+	}
+
+	for _, served := range [][]*types.Block{remote[:5], remote} {
+		peer = newTestPeer(served)
+		if err := New(FullSync, nil, chain, chain, log.New()).Synchronise(peer); err != nil {
+			t.Fatalf("synchronisation failed: %v", err)
+		}
+		if len(peer.requests) != 0 {
+			t.Errorf("requests sent to a peer at head #%d: %v", len(served)-1, peer.requests)
+		}
+	}
+}
+
+// Tests that chains failing verification are rejected without writing anything.
+func TestSynchroniseInvalidChain(t *testing.T) {
+	remote := makeChain(20, validatorKey)
+
+	// A block that doesn't link to its parent breaks the chain.
+	unlinked := make([]*types.Block, len(remote))
+	copy(unlinked, remote)
+	unlinked[10] = types.NewBlock(&types.Header{Height: 10, Time: big.NewInt(10)}, nil, remote[10].LastCommit())
+
+	// A body that doesn't match its header is rejected.
+	tampered := make([]*types.Block, len(remote))
+	copy(tampered, remote)
+	tampered[10] = types.NewBlockWithHeader(remote[10].Header()).WithBody(&types.Body{LastCommit: &types.Commit{}})
+
+	// A chain committed by someone else than the validators is rejected.
+	forged := makeChain(20, outsiderKey)
+
+	tests := []struct {
+		mode   SyncMode
+		blocks []*types.Block
+		err    error
+	}{
+		{FullSync, unlinked, errInvalidChain},
+		{HeadersFirstSync, unlinked, errInvalidChain},
+		{FullSync, tampered, errInvalidBody},
+		{HeadersFirstSync, tampered, errInvalidBody},
+		{FullSync, forged, errInvalidCommit},
+		{HeadersFirstSync, forged, errInvalidCommit},
+	}
+	for i, tt := range tests {
+		chain := newTestChain(remote[:1])
+		if err := New(tt.mode, nil, chain, chain, log.New()).Synchronise(newTestPeer(tt.blocks)); err != tt.err {
+			t.Errorf("test %d (%v): error mismatch: have %v, want %v", i, tt.mode, err, tt.err)
+		}
+		if len(chain.blocks) != 1 {
+			t.Errorf("test %d (%v): %d blocks written from an invalid chain", i, tt.mode, len(chain.blocks)-1)
+		}
+	}
+}
+
+// Tests that a node behind a valid checkpoint starts syncing from it on top of the
+// state retrieved for it, skipping the retrieval and verification of the blocks
+// below it.
+func TestSynchroniseCheckpoint(t *testing.T) {
+	remote := makeChain(20, validatorKey)
+
+	// Corrupt an ancient block, which would fail verification if retrieved.
+	served := make([]*types.Block, len(remote))
+	copy(served, remote)
+	served[5] = types.NewBlock(&types.Header{Height: 5, Time: big.NewInt(5)}, nil, nil)
+
+	checkpoint := &Checkpoint{Height: 10, Hash: remote[10].Hash()}
+	tests := []struct {
+		mode     SyncMode
+		requests []string
+	}{
+		{FullSync, []string{"blocks 10 2", "nodes 1", "nodes 2", "blocks 11 10"}},
+		{HeadersFirstSync, []string{"blocks 10 2", "nodes 1", "nodes 2", "headers 11 10", "bodies 10"}},
+	}
+	for _, tt := range tests {
+		chain := newTestChain(remote[:1])
+		peer := newTestPeer(served)
+		if err := New(tt.mode, checkpoint, chain, chain, log.New()).Synchronise(peer); err != nil {
+			t.Fatalf("%v: synchronisation failed: %v", tt.mode, err)
+		}
+		if !reflect.DeepEqual(peer.requests, tt.requests) {
+			t.Errorf("%v: requests mismatch:\nhave %v\nwant %v", tt.mode, peer.requests, tt.requests)
+		}
+		if len(chain.blocks) != 11 || chain.blocks[1].Hash() != checkpoint.Hash {
+			t.Fatalf("%v: chain doesn't start at the checkpoint: %d blocks", tt.mode, len(chain.blocks))
+		}
+		if head := chain.CurrentBlock(); head.Hash() != remote[19].Hash() {
+			t.Errorf("%v: head mismatch: have #%d %x, want #%d %x", tt.mode, head.Height(), head.Hash(), 19, remote[19].Hash())
+		}
+		checkState(t, chain.db.DB())
+	}
+
+	// Without the checkpoint the corrupted block is retrieved and rejected.
+	chain := newTestChain(remote[:1])
+	if err := New(FullSync, nil, chain, chain, log.New()).Synchronise(newTestPeer(served)); err != errInvalidChain {
+		t.Errorf("error mismatch without checkpoint: have %v, want %v", err, errInvalidChain)
+	}
+}
+
+// Tests that a checkpoint the peer's chain disagrees with is refused.
+func TestSynchroniseBadCheckpoint(t *testing.T) {
+	remote := makeChain(20, validatorKey)
+	checkpoint := &Checkpoint{Height: 10, Hash: remote[9].Hash()}
+
+	for _, mode := range []SyncMode{FullSync, HeadersFirstSync} {
+		chain := newTestChain(remote[:1])
+		if err := New(mode, checkpoint, chain, chain, log.New()).Synchronise(newTestPeer(remote)); err != errCheckpointMismatch {
+			t.Errorf("%v: error mismatch: have %v, want %v", mode, err, errCheckpointMismatch)
+		}
+		if len(chain.blocks) != 1 {
+			t.Errorf("%v: %d blocks written despite a bad checkpoint", mode, len(chain.blocks)-1)
+		}
+	}
+}
+
+// Tests that the state of a checkpoint is retrieved from a peer serving it over
+// several replies, and that a checkpoint without state is refused.
+func TestSynchroniseCheckpointState(t *testing.T) {
+	remote := makeChain(20, validatorKey)
+	checkpoint := &Checkpoint{Height: 10, Hash: remote[10].Hash()}
+
+	chain := newTestChain(remote[:1])
+	peer := newTestPeer(remote)
+	peer.maxNodes = 1
+	if err := New(FullSync, checkpoint, chain, chain, log.New()).Synchronise(peer); err != nil {
+		t.Fatalf("synchronisation failed: %v", err)
+	}
+	if want := []string{"blocks 10 2", "nodes 1", "nodes 2", "nodes 1", "blocks 11 10"}; !reflect.DeepEqual(peer.requests, want) {
+		t.Errorf("requests mismatch:\nhave %v\nwant %v", peer.requests, want)
+	}
+	checkState(t, chain.db.DB())
+
+	chain = newTestChain(remote[:1])
+	peer = newTestPeer(remote)
+	peer.state = memorydb.New()
+	if err := New(FullSync, checkpoint, chain, chain, log.New()).Synchronise(peer); err != errEmptyResponse {
+		t.Errorf("error mismatch: have %v, want %v", err, errEmptyResponse)
+	}
+	if len(chain.blocks) != 1 {
+		t.Errorf("%d blocks written without the checkpoint state", len(chain.blocks)-1)
+	}
+}
+
+// checkState checks that db holds the whole test state.
+func checkState(t *testing.T, db kaidb.Database) {
+	st, err := state.New(log.New(), testStateRoot, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("checkpoint state missing: %v", err)
+	}
+	if balance := st.GetBalance(testAccount); balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 1000", balance)
+	}
+	if code := st.GetCode(testAccount); string(code) != "code" {
+		t.Errorf("code mismatch: have %q, want %q", code, "code")
+	}
+	if value := st.GetState(testAccount, common.HexToHash("0x01")); value != common.HexToHash("0x02") {
+		t.Errorf("storage mismatch: have %x, want %x", value, common.HexToHash("0x02"))
+	}
+}
+
+// Tests that a checkpoint whose commit isn't signed by the validators is refused
+// before retrieving the chain above it.
+func TestSynchroniseUncommittedCheckpoint(t *testing.T) {
+	forged := makeChain(20, outsiderKey)
+	checkpoint := &Checkpoint{Height: 10, Hash: forged[10].Hash()}
+
+	for _, mode := range []SyncMode{FullSync, HeadersFirstSync} {
+		chain := newTestChain(forged[:1])
+		peer := newTestPeer(forged)
+		if err := New(mode, checkpoint, chain, chain, log.New()).Synchronise(peer); err == nil {
+			t.Errorf("%v: uncommitted checkpoint accepted", mode)
+		}
+		if want := []string{"blocks 10 2", "nodes 1", "nodes 2"}; !reflect.DeepEqual(peer.requests, want) {
+			t.Errorf("%v: requests mismatch: have %v, want %v", mode, peer.requests, want)
+		}
+		if len(chain.blocks) != 1 {
+			t.Errorf("%v: %d blocks written despite an uncommitted checkpoint", mode, len(chain.blocks)-1)
+		}
+	}
+}
+
+func TestCheckpointValidateBasic(t *testing.T) {
+	if err := (&Checkpoint{Height: 1, Hash: common.HexToHash("0x01")}).ValidateBasic(); err != nil {
+		t.Errorf("valid checkpoint rejected: %v", err)
+	}
+	if err := (&Checkpoint{Hash: common.HexToHash("0x01")}).ValidateBasic(); err == nil {
+		t.Error("genesis checkpoint accepted")
+	}
+	if err := (&Checkpoint{Height: 1}).ValidateBasic(); err == nil {
+		t.Error("checkpoint without hash accepted")
+	}
+}
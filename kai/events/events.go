@@ -28,3 +28,50 @@ type NewTxsEvent struct{ Txs []*types.Transaction }
 
 // ChainHeadEvent is posted when a new head block is saved to the block chain.
 type ChainHeadEvent struct{ Block *types.Block }
+
+// ChainReorgEvent is posted when blocks are removed from the canonical chain,
+// either by rewinding the head or by switching to a side chain. Dropped and
+// Added are both ordered by ascending height and start right above
+// CommonAncestor.
+type ChainReorgEvent struct {
+	CommonAncestor *types.Block
+	Dropped        []*types.Block
+	Added          []*types.Block
+}
+
+// TxRemovalReason tells why transactions were dropped from the transaction pool.
+type TxRemovalReason uint8
+
+const (
+	TxRemovedUnderpriced TxRemovalReason = iota // Priced below the pool threshold or outbid while the pool is full
+	TxRemovedStale                              // Pending, or queued without account activity, for longer than the configured lifetime
+	TxRemovedUnpayable                          // Sender can't afford it or it exceeds the block gas limit
+	TxRemovedOverflow                           // Exceeded the per-account or global pool limits
+	TxRemovedMined                              // Nonce already used by a transaction included in the chain
+)
+
+// String implements fmt.Stringer.
+func (r TxRemovalReason) String() string {
+	switch r {
+	case TxRemovedUnderpriced:
+		return "underpriced"
+	case TxRemovedStale:
+		return "stale"
+	case TxRemovedUnpayable:
+		return "unpayable"
+	case TxRemovedOverflow:
+		return "overflow"
+	case TxRemovedMined:
+		return "mined"
+	default:
+		return "unknown"
+	}
+}
+
+// RemovedTxsEvent is posted when a batch of transactions is dropped from the
+// transaction pool, either evicted or, with TxRemovedMined, made obsolete by
+// the chain.
+type RemovedTxsEvent struct {
+	Txs    []*types.Transaction
+	Reason TxRemovalReason
+}
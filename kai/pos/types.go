@@ -27,6 +27,7 @@ type ConsensusInfo struct {
 	MaxViolatePercentageAllowed uint64
 	FetchNewValidatorsTime      uint64
 	BlockReward                 *big.Int
+	RewardSchedule              []RewardMilestone // RewardSchedule overrides BlockReward from the height of each milestone onwards.
 	MaxValidators               uint64
 	ConsensusPeriodInBlock      uint64
 	MinimumStakes               *big.Int
@@ -34,6 +35,15 @@ type ConsensusInfo struct {
 	Master                      MasterSmartContract
 	Nodes                       Nodes
 	Stakers                     Stakers
+
+	// validatorSet tracks validator-set refreshes, see validator_set.go.
+	validatorSet *validatorSetTracker
+}
+
+// RewardMilestone sets the block reward of every block from Height onwards.
+type RewardMilestone struct {
+	Height uint64
+	Reward *big.Int
 }
 
 type MasterSmartContract struct {
@@ -0,0 +1,71 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package pos
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/kardiachain/go-kardia/lib/event"
+)
+
+// ValidatorSetEvent is posted when the validator set collected from the master
+// smart contract is refreshed.
+type ValidatorSetEvent struct {
+	Version uint64
+}
+
+// validatorSetTracker holds the validator-set version and its subscribers.
+// It is shared by pointer so copies of a ConsensusInfo observe the same version.
+type validatorSetTracker struct {
+	version uint64 // accessed atomically
+	feed    event.Feed
+}
+
+// trackerMu guards the lazy creation of ConsensusInfo.validatorSet.
+var trackerMu sync.Mutex
+
+func (c *ConsensusInfo) tracker() *validatorSetTracker {
+	trackerMu.Lock()
+	defer trackerMu.Unlock()
+	if c.validatorSet == nil {
+		c.validatorSet = new(validatorSetTracker)
+	}
+	return c.validatorSet
+}
+
+// ValidatorSetVersion returns the current validator-set version. It starts at
+// zero and increases by one on every refresh.
+func (c *ConsensusInfo) ValidatorSetVersion() uint64 {
+	return atomic.LoadUint64(&c.tracker().version)
+}
+
+// RefreshValidatorSet bumps the validator-set version, notifies subscribers and
+// returns the new version.
+func (c *ConsensusInfo) RefreshValidatorSet() uint64 {
+	t := c.tracker()
+	version := atomic.AddUint64(&t.version, 1)
+	t.feed.Send(ValidatorSetEvent{Version: version})
+	return version
+}
+
+// SubscribeValidatorSetEvent registers a subscription of ValidatorSetEvent.
+func (c *ConsensusInfo) SubscribeValidatorSetEvent(ch chan<- ValidatorSetEvent) event.Subscription {
+	return c.tracker().feed.Subscribe(ch)
+}
@@ -0,0 +1,54 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package pos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshValidatorSet(t *testing.T) {
+	var info ConsensusInfo
+	if v := info.ValidatorSetVersion(); v != 0 {
+		t.Fatalf("initial version mismatch: have %d, want 0", v)
+	}
+
+	ch := make(chan ValidatorSetEvent, 1)
+	sub := info.SubscribeValidatorSetEvent(ch)
+	defer sub.Unsubscribe()
+
+	// Copies made after creation share the version, as BlockChain embeds a copy.
+	embedded := info
+	for want := uint64(1); want <= 3; want++ {
+		if v := embedded.RefreshValidatorSet(); v != want {
+			t.Fatalf("refresh returned version %d, want %d", v, want)
+		}
+		select {
+		case ev := <-ch:
+			if ev.Version != want {
+				t.Fatalf("event version mismatch: have %d, want %d", ev.Version, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no validator set event received")
+		}
+		if v := info.ValidatorSetVersion(); v != want {
+			t.Fatalf("version mismatch: have %d, want %d", v, want)
+		}
+	}
+}
@@ -20,14 +20,15 @@ package service
 
 // Constants to match up protocol versions and messages
 const (
-	kai1 = 1
+	KAI1 = 1
+	KAI2 = 2 // Adds transaction hash announcements and block sync
 )
 
 // ProtocolVersions are the supported versions of the protocol (first is primary).
-var ProtocolVersions = []uint{kai1}
+var ProtocolVersions = []uint{KAI2, KAI1}
 
 // ProtocolLengths are the number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{19}
+var ProtocolLengths = []uint64{31, 19}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -47,4 +48,18 @@ const (
 	CsVoteSetBitsMessage   = 0x10 // VoteSetBitsMessage message
 	CsProposalBlockPartMsg = 0x11 // CsProposalBlockPartMsg message
 	CsValidBlockMsg        = 0x12 // CsValidBlockMsg message
+
+	// Protocol messages belonging to kai2
+	NewTxHashesMsg     = 0x13 // Announcement of new transaction hashes
+	GetTxsMsg          = 0x14 // Request of announced transactions by hash
+	GetBlockHeadersMsg = 0x15 // Request of block headers by height
+	BlockHeadersMsg    = 0x16 // Reply to GetBlockHeadersMsg
+	GetBlockBodiesMsg  = 0x17 // Request of block bodies by hash
+	BlockBodiesMsg     = 0x18 // Reply to GetBlockBodiesMsg
+	GetBlocksMsg       = 0x19 // Request of blocks by height
+	BlocksMsg          = 0x1a // Reply to GetBlocksMsg
+	GetHeadMsg         = 0x1b // Request of the current head block
+	HeadMsg            = 0x1c // Reply to GetHeadMsg
+	GetNodeDataMsg     = 0x1d // Request of state trie nodes and contract code by hash
+	NodeDataMsg        = 0x1e // Reply to GetNodeDataMsg
 )
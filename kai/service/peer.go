@@ -40,16 +40,23 @@ var (
 	errAlreadyRegistered = errors.New("peer is already registered")
 	errNotRegistered     = errors.New("peer is not registered")
 	errDiffChainID       = errors.New("diff chain id")
+	errRequestTimeout    = errors.New("request timed out")
+	errUnexpectedReply   = errors.New("unexpected reply to request")
 )
 
 const (
 	handshakeTimeout = 5 * time.Second
-	maxKnownTxs      = 6144 // Maximum transactions hashes to keep in the known list (prevent DOS)
+	requestTimeout   = 10 * time.Second // Time allowance for a peer to answer a sync request
+	maxKnownTxs      = 6144             // Maximum transactions hashes to keep in the known list (prevent DOS)
 
 	// maxQueuedTxs is the maximum number of transaction lists to queue up before
 	// dropping broadcasts. This is a sensitive number as a transaction list might
 	// contain a single transaction, or thousands.
 	maxQueuedTxs = 6144
+
+	// maxQueuedTxAnns is the maximum number of transaction hash announcements to
+	// queue up before dropping them.
+	maxQueuedTxAnns = 6144
 )
 
 // PeerInfo represents a short summary of the Kai sub-protocol metadata known
@@ -71,8 +78,15 @@ type peer struct {
 
 	version int // Protocol version negotiated
 
-	knownTxs  *common.Set             // Set of transaction hashes known to be known by this peer
-	queuedTxs chan types.Transactions // Queue of transactions to broadcast to the peer
+	head   common.Hash // Hash of the peer's head block, as last reported by the peer
+	height uint64      // Height of the peer's head block
+
+	reqLock sync.Mutex       // Serialises sync requests, only one reply is awaited at a time
+	replies chan interface{} // Delivery of the decoded reply to the pending sync request
+
+	knownTxs     *knownCache             // Set of transaction hashes known to be known by this peer
+	queuedTxs    chan types.Transactions // Queue of transactions to broadcast to the peer
+	queuedTxAnns chan []common.Hash      // Queue of transaction hashes to announce to the peer
 
 	csReactor *consensus.ConsensusManager
 
@@ -99,16 +113,18 @@ func newPeer(logger log.Logger, version int, p *p2p.Peer, rw p2p.MsgReadWriter,
 	}
 
 	return &peer{
-		logger:      logger,
-		Peer:        p,
-		rw:          rw,
-		version:     version,
-		id:          fmt.Sprintf("%x", p.ID().Bytes()[:8]),
-		queuedTxs:   make(chan types.Transactions, maxQueuedTxs),
-		knownTxs:    common.NewSet(maxKnownTxs),
-		csReactor:   csReactor,
-		terminated:  make(chan struct{}),
-		IsValidator: isValidator,
+		logger:       logger,
+		Peer:         p,
+		rw:           rw,
+		version:      version,
+		id:           fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		queuedTxs:    make(chan types.Transactions, maxQueuedTxs),
+		queuedTxAnns: make(chan []common.Hash, maxQueuedTxAnns),
+		replies:      make(chan interface{}, 1),
+		knownTxs:     newKnownCache(maxKnownTxs),
+		csReactor:    csReactor,
+		terminated:   make(chan struct{}),
+		IsValidator:  isValidator,
 	}
 }
 
@@ -165,6 +181,7 @@ func (p *peer) Handshake(network uint64, chainID uint64, height uint64, head com
 			return false, p2p.DiscReadTimeout
 		}
 	}
+	p.SetHead(status.CurrentBlock, status.Height)
 	return true, nil
 }
 
@@ -204,6 +221,148 @@ func (p *peer) readStatus(network uint64, chainID uint64, status *statusData, ge
 	return nil
 }
 
+// Head returns the hash and height of the peer's head block, as last reported by the peer.
+func (p *peer) Head() (common.Hash, uint64) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.head, p.height
+}
+
+// SetHead updates the head block of the peer.
+func (p *peer) SetHead(hash common.Hash, height uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.head, p.height = hash, height
+}
+
+// RequestHead asks the peer for its current head block and updates the tracked head.
+func (p *peer) RequestHead() error {
+	reply, err := p.request(serviceconst.GetHeadMsg, []interface{}{})
+	if err != nil {
+		return err
+	}
+	head, ok := reply.(*headData)
+	if !ok {
+		return errUnexpectedReply
+	}
+	p.SetHead(head.Hash, head.Height)
+	return nil
+}
+
+// RequestHeadersByNumber fetches up to amount consecutive block headers starting at origin.
+func (p *peer) RequestHeadersByNumber(origin uint64, amount int) ([]*types.Header, error) {
+	reply, err := p.request(serviceconst.GetBlockHeadersMsg, &getBlocksData{Origin: origin, Amount: uint64(amount)})
+	if err != nil {
+		return nil, err
+	}
+	headers, ok := reply.([]*types.Header)
+	if !ok {
+		return nil, errUnexpectedReply
+	}
+	return headers, nil
+}
+
+// RequestBodies fetches the bodies of the blocks with the given hashes.
+func (p *peer) RequestBodies(hashes []common.Hash) ([]*types.Body, error) {
+	reply, err := p.request(serviceconst.GetBlockBodiesMsg, hashes)
+	if err != nil {
+		return nil, err
+	}
+	bodies, ok := reply.([]*types.Body)
+	if !ok {
+		return nil, errUnexpectedReply
+	}
+	return bodies, nil
+}
+
+// RequestBlocksByNumber fetches up to amount consecutive blocks starting at origin.
+func (p *peer) RequestBlocksByNumber(origin uint64, amount int) ([]*types.Block, error) {
+	reply, err := p.request(serviceconst.GetBlocksMsg, &getBlocksData{Origin: origin, Amount: uint64(amount)})
+	if err != nil {
+		return nil, err
+	}
+	blocks, ok := reply.([]*types.Block)
+	if !ok {
+		return nil, errUnexpectedReply
+	}
+	return blocks, nil
+}
+
+// RequestNodeData fetches a batch of state trie nodes and contract code by hash.
+func (p *peer) RequestNodeData(hashes []common.Hash) ([][]byte, error) {
+	reply, err := p.request(serviceconst.GetNodeDataMsg, hashes)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := reply.([][]byte)
+	if !ok {
+		return nil, errUnexpectedReply
+	}
+	return data, nil
+}
+
+// SendBlockHeaders sends a batch of block headers to the remote peer.
+func (p *peer) SendBlockHeaders(headers []*types.Header) error {
+	return p2p.Send(p.rw, serviceconst.BlockHeadersMsg, headers)
+}
+
+// SendBlockBodies sends a batch of block bodies to the remote peer.
+func (p *peer) SendBlockBodies(bodies []*types.Body) error {
+	return p2p.Send(p.rw, serviceconst.BlockBodiesMsg, bodies)
+}
+
+// SendBlocks sends a batch of blocks to the remote peer.
+func (p *peer) SendBlocks(blocks []*types.Block) error {
+	return p2p.Send(p.rw, serviceconst.BlocksMsg, blocks)
+}
+
+// SendNodeData sends a batch of state trie nodes and contract code to the remote peer.
+func (p *peer) SendNodeData(data [][]byte) error {
+	return p2p.Send(p.rw, serviceconst.NodeDataMsg, data)
+}
+
+// SendHead sends the local head block to the remote peer.
+func (p *peer) SendHead(hash common.Hash, height uint64) error {
+	return p2p.Send(p.rw, serviceconst.HeadMsg, &headData{Hash: hash, Height: height})
+}
+
+// request sends a sync request to the peer and waits for its reply, which the
+// message handler decodes and hands over through deliver.
+func (p *peer) request(code uint64, data interface{}) (interface{}, error) {
+	p.reqLock.Lock()
+	defer p.reqLock.Unlock()
+
+	// Drop a late reply to an earlier request that timed out
+	select {
+	case <-p.replies:
+	default:
+	}
+	if err := p2p.Send(p.rw, code, data); err != nil {
+		return nil, err
+	}
+	timeout := time.NewTimer(requestTimeout)
+	defer timeout.Stop()
+
+	select {
+	case reply := <-p.replies:
+		return reply, nil
+	case <-timeout.C:
+		return nil, errRequestTimeout
+	}
+}
+
+// deliver hands the reply to a sync request over to the waiting request. Replies
+// nobody is waiting for are dropped.
+func (p *peer) deliver(reply interface{}) {
+	select {
+	case p.replies <- reply:
+	default:
+		p.logger.Debug("Dropping unrequested sync reply", "peer", p.id)
+	}
+}
+
 // String implements fmt.Stringer.
 func (p *peer) String() string {
 	return fmt.Sprintf("Peer %s [%s]", p.id,
@@ -294,6 +453,26 @@ func (ps *peerSet) Close() {
 	ps.closed = true
 }
 
+// BestPeer retrieves the peer supporting block sync with the highest known head.
+func (ps *peerSet) BestPeer() *peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	var (
+		bestPeer   *peer
+		bestHeight uint64
+	)
+	for _, p := range ps.peers {
+		if p.version < serviceconst.KAI2 {
+			continue
+		}
+		if _, height := p.Head(); bestPeer == nil || height > bestHeight {
+			bestPeer, bestHeight = p, height
+		}
+	}
+	return bestPeer
+}
+
 // broadcast is a async write loop that send messages to remote peers.
 func (p *peer) broadcast() {
 	for {
@@ -303,6 +482,11 @@ func (p *peer) broadcast() {
 				return
 			}
 			p.Log().Trace("Broadcast transactions", "count", len(txs))
+		case hashes := <-p.queuedTxAnns:
+			if err := p.SendTxHashes(hashes); err != nil {
+				return
+			}
+			p.Log().Trace("Announced transactions", "count", len(hashes))
 		case <-p.terminated:
 			return
 		}
@@ -313,23 +497,15 @@ func (p *peer) broadcast() {
 // will never be propagated to this particular peer.
 func (p *peer) MarkTransactions(txs types.Transactions) []*types.Transaction {
 	queueTxs := make([]*types.Transaction, 0)
-	txHashes := make([]interface{}, 0)
+	txHashes := make([]common.Hash, 0)
 	for _, tx := range txs {
-		if p.knownTxs.Has(tx.Hash()) {
+		if p.knownTxs.Contains(tx.Hash()) {
 			continue
 		}
 		queueTxs = append(queueTxs, tx)
 		txHashes = append(txHashes, tx.Hash())
 	}
-
-	// If we reached the memory allowance, drop a previously known transaction hash
-	for p.knownTxs.Size() >= maxKnownTxs {
-		p.knownTxs.Pop()
-	}
-
-	if len(queueTxs) > 0 {
-		p.knownTxs.Add(txHashes...)
-	}
+	p.knownTxs.Add(txHashes...)
 	return queueTxs
 }
 
@@ -347,7 +523,7 @@ func (ps *peerSet) PeersWithoutTx(tx *types.Transaction) []*peer {
 			continue
 		}
 
-		if !p.knownTxs.Has(tx.Hash()) {
+		if !p.knownTxs.Contains(tx.Hash()) {
 			list = append(list, p)
 		}
 	}
@@ -372,7 +548,7 @@ func (ps *peerSet) PeersWithoutTxs(txs types.Transactions) map[*peer]types.Trans
 				set[p] = make(types.Transactions, 0)
 			}
 
-			if !p.knownTxs.Has(tx.Hash()) {
+			if !p.knownTxs.Contains(tx.Hash()) {
 				set[p] = append(set[p], tx)
 			}
 		}
@@ -382,21 +558,102 @@ func (ps *peerSet) PeersWithoutTxs(txs types.Transactions) map[*peer]types.Trans
 
 // SendTransactions sends transactions to the peer, adds the txn hashes to known txn set.
 func (p *peer) SendTransactions(txs types.Transactions) error {
-	// If we reached the memory allowance, drop a previously known transaction hash
-	for p.knownTxs.Size() >= maxKnownTxs {
-		p.knownTxs.Pop()
-	}
+	p.knownTxs.Add(txHashes(txs)...)
 	return p2p.Send(p.rw, serviceconst.TxMsg, txs)
 }
 
 // AsyncSendTransactions queues list of transactions propagation to a remote
 // peer. If the peer's broadcast queue is full, the event is silently dropped.
+// Queued transactions are marked as known right away so they are not queued twice.
 func (p *peer) AsyncSendTransactions(txs types.Transactions) {
 	// Tx will be actually sent in SendTransactions() trigger by broadcast() routine
 	select {
 	case p.queuedTxs <- txs:
-		go p.MarkTransactions(txs)
+		p.MarkTransactions(txs)
 	default:
 		p.logger.Debug("Dropping transaction propagation", "count", len(txs))
 	}
 }
+
+// MarkTxHashes marks a list of transaction hashes as known for the peer, ensuring that the
+// transactions will never be propagated to this particular peer.
+func (p *peer) MarkTxHashes(hashes []common.Hash) {
+	p.knownTxs.Add(hashes...)
+}
+
+// SendTxHashes announces transaction hashes to the peer, adds the hashes to known txn set.
+func (p *peer) SendTxHashes(hashes []common.Hash) error {
+	p.MarkTxHashes(hashes)
+	return p2p.Send(p.rw, serviceconst.NewTxHashesMsg, hashes)
+}
+
+// AsyncSendTxHashes queues list of transaction hashes to announce to a remote
+// peer. If the peer's announcement queue is full, the event is silently dropped.
+// Queued hashes are marked as known right away so they are not announced twice.
+func (p *peer) AsyncSendTxHashes(hashes []common.Hash) {
+	// Hashes will be actually sent in SendTxHashes() trigger by broadcast() routine
+	select {
+	case p.queuedTxAnns <- hashes:
+		p.MarkTxHashes(hashes)
+	default:
+		p.logger.Debug("Dropping transaction announcement", "count", len(hashes))
+	}
+}
+
+// RequestTxs asks the peer for the announced transactions with given hashes.
+func (p *peer) RequestTxs(hashes []common.Hash) error {
+	p.logger.Trace("Requesting announced transactions", "count", len(hashes), "peer", p.Name())
+	return p2p.Send(p.rw, serviceconst.GetTxsMsg, hashes)
+}
+
+// knownCache is a bounded set of transaction hashes known by a peer. Once full, the
+// earliest added hashes are dropped to make room for new ones.
+type knownCache struct {
+	lock   sync.RWMutex
+	hashes map[common.Hash]struct{}
+	order  []common.Hash // Hashes in insertion order, for eviction
+	max    int
+}
+
+// newKnownCache creates a known hash set holding at most max hashes.
+func newKnownCache(max int) *knownCache {
+	return &knownCache{
+		hashes: make(map[common.Hash]struct{}, max),
+		max:    max,
+	}
+}
+
+// Add marks hashes as known, dropping the earliest known hashes if the set is full.
+func (c *knownCache) Add(hashes ...common.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, hash := range hashes {
+		if _, ok := c.hashes[hash]; ok {
+			continue
+		}
+		for len(c.order) >= c.max {
+			delete(c.hashes, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.hashes[hash] = struct{}{}
+		c.order = append(c.order, hash)
+	}
+}
+
+// Contains returns whether hash is known.
+func (c *knownCache) Contains(hash common.Hash) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	_, ok := c.hashes[hash]
+	return ok
+}
+
+// Size returns the number of known hashes.
+func (c *knownCache) Size() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return len(c.hashes)
+}
@@ -0,0 +1,45 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package service
+
+import (
+	"testing"
+
+	"github.com/kardiachain/go-kardia/lib/common"
+)
+
+func TestKnownCacheBounded(t *testing.T) {
+	cache := newKnownCache(3)
+	hashes := []common.Hash{{1}, {2}, {3}, {4}, {5}}
+
+	cache.Add(hashes[:3]...)
+	cache.Add(hashes[0]) // Re-adding a known hash doesn't refresh nor evict anything
+	if cache.Size() != 3 {
+		t.Fatalf("size mismatch: have %d, want 3", cache.Size())
+	}
+	cache.Add(hashes[3:]...)
+	if cache.Size() != 3 {
+		t.Fatalf("size mismatch: have %d, want 3", cache.Size())
+	}
+	for i, hash := range hashes {
+		if want := i >= 2; cache.Contains(hash) != want {
+			t.Errorf("hash %d: contains mismatch: have %v, want %v", i, cache.Contains(hash), want)
+		}
+	}
+}
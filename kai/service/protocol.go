@@ -69,6 +69,18 @@ type statusData struct {
 	GenesisBlock    common.Hash
 }
 
+// getBlocksData is the network packet for block and header queries by height.
+type getBlocksData struct {
+	Origin uint64 // Height of the first block to retrieve
+	Amount uint64 // Maximum number of consecutive blocks to retrieve
+}
+
+// headData is the network packet for the head block announcement of a peer.
+type headData struct {
+	Hash   common.Hash
+	Height uint64
+}
+
 // hashOrNumber is a combined field for specifying an origin block.
 type hashOrNumber struct {
 	Hash   common.Hash // Block hash from which to retrieve headers (excludes Number)
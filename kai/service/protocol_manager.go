@@ -26,6 +26,7 @@ import (
 
 	"github.com/kardiachain/go-kardia/consensus"
 	"github.com/kardiachain/go-kardia/kai/base"
+	"github.com/kardiachain/go-kardia/kai/downloader"
 	"github.com/kardiachain/go-kardia/kai/events"
 	serviceconst "github.com/kardiachain/go-kardia/kai/service/const"
 	"github.com/kardiachain/go-kardia/lib/common"
@@ -74,10 +75,12 @@ type ProtocolManager struct {
 
 	peers *peerSet
 
-	txpool *tx_pool.TxPool
+	txpool      *tx_pool.TxPool
+	txBroadcast TxBroadcastPolicy // How new transactions are split between flooding and announcing
 
 	blockchain  base.BaseBlockChain
 	chainconfig *types.ChainConfig
+	downloader  *downloader.Downloader // Block sync with the best peer, nil disables syncing
 
 	SubProtocols []p2p.Protocol
 
@@ -129,6 +132,14 @@ func NewProtocolManager(
 		receivedTxsCh: make(chan receivedTxs),
 		txsyncCh:      make(chan *txsync),
 		quitSync:      make(chan struct{}),
+		txBroadcast:   DefaultTxBroadcastPolicy,
+	}
+	if txpool != nil {
+		config := txpool.Config()
+		manager.txBroadcast = TxBroadcastPolicy{
+			FloodFraction: config.BroadcastFlood,
+			MinFloodPeers: config.BroadcastMinFlood,
+		}
 	}
 
 	// Initiate a sub-protocol for every implemented version we can handle
@@ -336,6 +347,171 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			queueTxs := p.MarkTransactions(txs)
 			pm.receivedTxsCh <- receivedTxs{peer: p, txs: queueTxs}
 		}
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.NewTxHashesMsg:
+		if pm.txpool == nil {
+			pm.logger.Info("This service doesn't accept incoming transactions")
+			return nil
+		}
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			pm.logger.Trace("Skip announced txs, acceptTxs flag is false")
+			break
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Request the announced transactions missing from the pool. They are marked as known
+		// for the peer once they arrive, so they are not dropped as duplicates.
+		var known, unknown []common.Hash
+		for _, hash := range hashes {
+			if pm.txpool.Get(hash) == nil {
+				unknown = append(unknown, hash)
+			} else {
+				known = append(known, hash)
+			}
+		}
+		p.MarkTxHashes(known)
+		if len(unknown) > 0 {
+			return p.RequestTxs(unknown)
+		}
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.GetTxsMsg:
+		if pm.txpool == nil {
+			return nil
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Reply with the requested transactions still in the pool, up to the response size limit
+		var (
+			txs   types.Transactions
+			bytes common.StorageSize
+		)
+		for _, hash := range hashes {
+			if bytes >= softResponseLimit {
+				break
+			}
+			if tx := pm.txpool.Get(hash); tx != nil {
+				txs = append(txs, tx)
+				bytes += tx.Size()
+			}
+		}
+		if len(txs) > 0 {
+			return p.SendTransactions(txs)
+		}
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.GetHeadMsg:
+		head := pm.blockchain.CurrentBlock()
+		return p.SendHead(head.Hash(), head.Height())
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.HeadMsg:
+		var head headData
+		if err := msg.Decode(&head); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.deliver(&head)
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.GetBlockHeadersMsg:
+		var query getBlocksData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Gather consecutive headers until the fetch or response size limit is reached
+		var (
+			headers []*types.Header
+			bytes   common.StorageSize
+		)
+		for i := uint64(0); i < query.Amount && i < uint64(downloader.MaxHeaderFetch) && bytes < softResponseLimit; i++ {
+			block := pm.blockchain.GetBlockByHeight(query.Origin + i)
+			if block == nil {
+				break
+			}
+			headers = append(headers, block.Header())
+			bytes += estHeaderRlpSize
+		}
+		return p.SendBlockHeaders(headers)
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.BlockHeadersMsg:
+		var headers []*types.Header
+		if err := msg.Decode(&headers); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.deliver(headers)
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.GetBlockBodiesMsg:
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Gather the requested bodies until the fetch or response size limit is reached
+		var (
+			bodies []*types.Body
+			bytes  common.StorageSize
+		)
+		for i, hash := range hashes {
+			if i >= downloader.MaxBodyFetch || bytes >= softResponseLimit {
+				break
+			}
+			block := pm.blockchain.GetBlockByHash(hash)
+			if block == nil {
+				break
+			}
+			bodies = append(bodies, block.Body())
+			bytes += block.Size()
+		}
+		return p.SendBlockBodies(bodies)
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.BlockBodiesMsg:
+		var bodies []*types.Body
+		if err := msg.Decode(&bodies); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.deliver(bodies)
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.GetBlocksMsg:
+		var query getBlocksData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Gather consecutive blocks until the fetch or response size limit is reached
+		var (
+			blocks []*types.Block
+			bytes  common.StorageSize
+		)
+		for i := uint64(0); i < query.Amount && i < uint64(downloader.MaxBlockFetch) && bytes < softResponseLimit; i++ {
+			block := pm.blockchain.GetBlockByHeight(query.Origin + i)
+			if block == nil {
+				break
+			}
+			blocks = append(blocks, block)
+			bytes += block.Size()
+		}
+		return p.SendBlocks(blocks)
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.BlocksMsg:
+		var blocks []*types.Block
+		if err := msg.Decode(&blocks); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.deliver(blocks)
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.GetNodeDataMsg:
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Gather the requested state entries until the fetch or response size limit is reached
+		var (
+			data  [][]byte
+			bytes int
+		)
+		for i, hash := range hashes {
+			if i >= downloader.MaxStateFetch || bytes >= softResponseLimit {
+				break
+			}
+			if entry, err := pm.blockchain.TrieNode(hash); err == nil {
+				data = append(data, entry)
+				bytes += len(entry)
+			}
+		}
+		return p.SendNodeData(data)
+	case p.version >= serviceconst.KAI2 && msg.Code == serviceconst.NodeDataMsg:
+		var data [][]byte
+		if err := msg.Decode(&data); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.deliver(data)
 	case msg.Code == serviceconst.CsNewRoundStepMsg:
 		pm.logger.Trace("NewRoundStep message received")
 		pm.csReactor.ReceiveNewRoundStep(msg, p.Peer)
@@ -427,26 +603,34 @@ func (pm *ProtocolManager) Broadcast(msg interface{}, msgType uint64) {
 }
 
 // BroadcastTxs will propagate a batch of transactions to all peers which are not known to
-// already have the given transaction.
+// already have the given transaction. Depending on the broadcast policy, some peers are sent the
+// transactions in full and the rest are only announced their hashes. Peers running a protocol
+// version without announcements always get the transactions in full.
 func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
-	//var txset = make(map[*peer]types.Transactions)
-	//pm.logger.Info("Start broadcast txs", "number of txs", len(txs))
-	//// Broadcast transactions to a batch of peers not knowing about it
-	//for _, tx := range txs {
-	//	peers := pm.peers.PeersWithoutTx(tx)
-	//	for _, peer := range peers {
-	//		if _, ok := txset[peer]; !ok {
-	//			txset[peer] = make(types.Transactions, 0)
-	//		}
-	//		txset[peer] = append(txset[peer], tx)
-	//	}
-	//	pm.logger.Trace("Broadcast transaction", "hash", tx.Hash(), "recipients", len(peers))
-	//}
-	// FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
-	for peer, txs := range pm.peers.PeersWithoutTxs(txs) {
+	txset := pm.peers.PeersWithoutTxs(txs)
+	var peers, legacy []*peer
+	for peer, txs := range txset {
 		// only send to validators
-		peer.AsyncSendTransactions(txs)
+		if len(txs) == 0 {
+			continue
+		}
+		if peer.version >= serviceconst.KAI2 {
+			peers = append(peers, peer)
+		} else {
+			legacy = append(legacy, peer)
+		}
+	}
+	flood, announce := pm.txBroadcast.split(peers)
+	for _, peer := range legacy {
+		peer.AsyncSendTransactions(txset[peer])
+	}
+	for _, peer := range flood {
+		peer.AsyncSendTransactions(txset[peer])
 	}
+	for _, peer := range announce {
+		peer.AsyncSendTxHashes(txHashes(txset[peer]))
+	}
+	pm.logger.Trace("Broadcast transactions", "count", len(txs), "flood", len(flood)+len(legacy), "announce", len(announce))
 }
 
 // NodeInfo represents a short summary of the Kardia sub-protocol metadata
@@ -470,6 +654,11 @@ func (pm *ProtocolManager) NodeInfo() *NodeInfo {
 	}
 }
 
+// SetDownloader sets the downloader used to sync the local chain with the best peer.
+func (pm *ProtocolManager) SetDownloader(d *downloader.Downloader) {
+	pm.downloader = d
+}
+
 func (pm *ProtocolManager) AcceptTxs() uint32 {
 	return pm.acceptTxs
 }
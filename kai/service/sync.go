@@ -39,8 +39,6 @@ const (
 // syncer is responsible for periodically synchronising with the network, both
 // downloading hashes and blocks as well as handling the announcement handler.
 func syncNetwork(pm *ProtocolManager) {
-	// TODO: Start and ensure cleanup of sync mechanisms
-
 	// Wait for different events to fire synchronisation operations
 	forceSync := time.NewTicker(forceSyncCycle)
 	defer forceSync.Stop()
@@ -52,11 +50,11 @@ func syncNetwork(pm *ProtocolManager) {
 			if pm.peers.Len() < minDesiredPeerCount {
 				break
 			}
-			// TODO: sync blockchain
+			go pm.synchronise(pm.peers.BestPeer())
 
 		case <-forceSync.C:
 			// Force a sync even if not enough peers are present
-			// TODO: sync blockchain
+			go pm.synchronise(pm.peers.BestPeer())
 
 		case <-pm.noMorePeers:
 			return
@@ -64,6 +62,24 @@ func syncNetwork(pm *ProtocolManager) {
 	}
 }
 
+// synchronise tries to sync up the local chain with a remote peer.
+func (pm *ProtocolManager) synchronise(peer *peer) {
+	if peer == nil || pm.downloader == nil || pm.downloader.Synchronising() {
+		return
+	}
+	// The head is only announced on handshake, ask the peer where it is now
+	if err := peer.RequestHead(); err != nil {
+		pm.logger.Debug("Failed to retrieve peer head", "peer", peer.id, "err", err)
+		return
+	}
+	if _, height := peer.Head(); height <= pm.blockchain.CurrentBlock().Height()+1 {
+		return
+	}
+	if err := pm.downloader.Synchronise(peer); err != nil {
+		pm.logger.Warn("Synchronisation failed", "peer", peer.id, "err", err)
+	}
+}
+
 // txsyncLoop takes care of the initial transaction sync for each new
 // connection. When a new peer appears, we relay all currently pending
 // transactions. In order to minimise egress bandwidth usage, we send
@@ -0,0 +1,221 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package service
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/kardiachain/go-kardia/kai/base"
+	"github.com/kardiachain/go-kardia/kai/downloader"
+	serviceconst "github.com/kardiachain/go-kardia/kai/service/const"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/lib/p2p"
+	"github.com/kardiachain/go-kardia/lib/p2p/discover"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+const testChainID = "kaicon"
+
+// testChain is an in-memory chain, only implementing what block sync needs.
+type testChain struct {
+	base.BaseBlockChain
+	blocks []*types.Block
+	nodes  map[common.Hash][]byte // State trie nodes and contract code by hash
+}
+
+func (c *testChain) Genesis() *types.Block        { return c.blocks[0] }
+func (c *testChain) CurrentBlock() *types.Block   { return c.blocks[len(c.blocks)-1] }
+func (c *testChain) CurrentHeader() *types.Header { return c.CurrentBlock().Header() }
+
+func (c *testChain) GetBlockByHeight(height uint64) *types.Block {
+	if height >= uint64(len(c.blocks)) {
+		return nil
+	}
+	return c.blocks[height]
+}
+
+func (c *testChain) GetBlockByHash(hash common.Hash) *types.Block {
+	for _, block := range c.blocks {
+		if block.Hash() == hash {
+			return block
+		}
+	}
+	return nil
+}
+
+func (c *testChain) TrieNode(hash common.Hash) ([]byte, error) {
+	if node, ok := c.nodes[hash]; ok {
+		return node, nil
+	}
+	return nil, errors.New("not found")
+}
+
+// testConsensus verifies commits against a fixed validator set and imports blocks
+// into chain without executing them.
+type testConsensus struct {
+	set   *types.ValidatorSet
+	chain *testChain
+}
+
+func (c *testConsensus) VerifyCommit(blockID types.BlockID, height uint64, commit *types.Commit) error {
+	return c.set.VerifyCommit(testChainID, blockID, int64(height), commit)
+}
+
+func (c *testConsensus) ImportBlock(block *types.Block, seenCommit *types.Commit) error {
+	c.chain.blocks = append(c.chain.blocks, block)
+	return nil
+}
+
+func (c *testConsensus) ImportCheckpoint(block *types.Block, seenCommit *types.Commit, root common.Hash) error {
+	return errors.New("checkpoints not supported")
+}
+
+// makeSignedChain creates a chain of n blocks on top of a genesis block, each
+// block carrying a last commit for its parent signed by signer.
+func makeSignedChain(n int, signer *ecdsa.PrivateKey) []*types.Block {
+	blocks := []*types.Block{types.NewBlock(&types.Header{Time: big.NewInt(0)}, nil, nil)}
+	for i := 1; i <= n; i++ {
+		parent := blocks[i-1]
+		header := &types.Header{
+			Height: uint64(i),
+			Time:   big.NewInt(int64(i)),
+			LastBlockID: types.BlockID{
+				Hash:        parent.Hash(),
+				PartsHeader: parent.MakePartSet(types.BlockPartSizeBytes).Header(),
+			},
+		}
+		vote := &types.Vote{
+			ValidatorAddress: crypto.PubkeyToAddress(signer.PublicKey),
+			ValidatorIndex:   common.NewBigInt32(0),
+			Height:           common.NewBigUint64(parent.Height()),
+			Round:            common.NewBigInt32(0),
+			Timestamp:        big.NewInt(int64(i)),
+			Type:             types.PrecommitType,
+			BlockID:          header.LastBlockID,
+		}
+		if err := types.NewPrivValidator(signer).SignVote(testChainID, vote); err != nil {
+			panic(err)
+		}
+		commit := types.NewCommit(header.LastBlockID, []*types.CommitSig{vote.CommitSig()})
+		blocks = append(blocks, types.NewBlock(header, nil, commit))
+	}
+	return blocks
+}
+
+// newSyncPeer creates a kai2 peer talking over rw, with just enough state for block sync.
+func newSyncPeer(id byte, rw p2p.MsgReadWriter) *peer {
+	return &peer{
+		logger:   log.New(),
+		Peer:     p2p.NewPeer(discover.NodeID{id}, "test", nil),
+		id:       string(id),
+		rw:       rw,
+		version:  serviceconst.KAI2,
+		replies:  make(chan interface{}, 1),
+		knownTxs: newKnownCache(maxKnownTxs),
+	}
+}
+
+// Tests that a node behind syncs the chain of a connected node up to the parent
+// of its head, in both sync modes.
+func TestSynchronise(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	validators := types.NewValidatorSet([]*types.Validator{types.NewValidator(key.PublicKey, 100)}, 0, 0)
+	remote := makeSignedChain(300, key)
+
+	for _, mode := range []downloader.SyncMode{downloader.FullSync, downloader.HeadersFirstSync} {
+		var (
+			chain  = &testChain{blocks: remote[:1]}
+			server = &ProtocolManager{logger: log.New(), blockchain: &testChain{blocks: remote}}
+			client = &ProtocolManager{logger: log.New(), blockchain: chain}
+		)
+		client.SetDownloader(downloader.New(mode, nil, chain, &testConsensus{set: validators, chain: chain}, log.New()))
+
+		// Connect both nodes, handshake and start handling messages on both ends
+		serverRW, clientRW := p2p.MsgPipe()
+		// Each node sees the other one as a peer at its own end of the pipe
+		serverPeer, clientPeer := newSyncPeer(1, clientRW), newSyncPeer(2, serverRW)
+		errc := make(chan error, 2)
+		handshake := func(p *peer, pm *ProtocolManager) {
+			head := pm.blockchain.CurrentBlock()
+			_, err := p.Handshake(1, 1, head.Height(), head.Hash(), remote[0].Hash())
+			errc <- err
+		}
+		go handshake(serverPeer, client)
+		go handshake(clientPeer, server)
+		for i := 0; i < 2; i++ {
+			if err := <-errc; err != nil {
+				t.Fatalf("%v: handshake failed: %v", mode, err)
+			}
+		}
+		go func() {
+			for server.handleMsg(clientPeer) == nil {
+			}
+		}()
+		go func() {
+			for client.handleMsg(serverPeer) == nil {
+			}
+		}()
+
+		client.synchronise(serverPeer)
+		if head := client.blockchain.CurrentBlock(); head.Hash() != remote[299].Hash() {
+			t.Errorf("%v: head mismatch: have #%d %x, want #%d %x", mode, head.Height(), head.Hash(), 299, remote[299].Hash())
+		}
+		serverRW.Close()
+	}
+}
+
+// Tests that a node serves the state entries it has out of the requested ones.
+func TestNodeData(t *testing.T) {
+	var (
+		known   = [][]byte{[]byte("node"), []byte("code")}
+		unknown = crypto.Keccak256Hash([]byte("missing"))
+		chain   = &testChain{nodes: make(map[common.Hash][]byte)}
+		server  = &ProtocolManager{logger: log.New(), blockchain: chain}
+		client  = &ProtocolManager{logger: log.New(), blockchain: chain}
+	)
+	for _, blob := range known {
+		chain.nodes[crypto.Keccak256Hash(blob)] = blob
+	}
+	serverRW, clientRW := p2p.MsgPipe()
+	defer serverRW.Close()
+	serverPeer, clientPeer := newSyncPeer(1, clientRW), newSyncPeer(2, serverRW)
+	go func() {
+		for server.handleMsg(clientPeer) == nil {
+		}
+	}()
+	go func() {
+		for client.handleMsg(serverPeer) == nil {
+		}
+	}()
+
+	hashes := []common.Hash{crypto.Keccak256Hash(known[0]), unknown, crypto.Keccak256Hash(known[1])}
+	data, err := serverPeer.RequestNodeData(hashes)
+	if err != nil {
+		t.Fatalf("failed to request node data: %v", err)
+	}
+	if !reflect.DeepEqual(data, known) {
+		t.Errorf("node data mismatch: have %q, want %q", data, known)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package service
+
+import (
+	"math"
+
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// TxBroadcastPolicy controls how new transactions are propagated. Transactions are sent in full
+// to a subset of the peers and only their hashes are announced to the rest, which then request
+// the transactions they don't know yet.
+type TxBroadcastPolicy struct {
+	FloodFraction float64 // Fraction of peers transactions are sent to in full
+	MinFloodPeers int     // Minimum number of peers transactions are sent to in full
+}
+
+// DefaultTxBroadcastPolicy floods transactions to every peer.
+var DefaultTxBroadcastPolicy = TxBroadcastPolicy{FloodFraction: 1}
+
+// floodCount returns how many out of total peers receive transactions in full.
+func (policy TxBroadcastPolicy) floodCount(total int) int {
+	count := int(math.Ceil(policy.FloodFraction * float64(total)))
+	if count < policy.MinFloodPeers {
+		count = policy.MinFloodPeers
+	}
+	if count > total {
+		count = total
+	}
+	return count
+}
+
+// split divides peers into the ones receiving transactions in full and the ones only being announced their hashes.
+func (policy TxBroadcastPolicy) split(peers []*peer) (flood []*peer, announce []*peer) {
+	count := policy.floodCount(len(peers))
+	return peers[:count], peers[count:]
+}
+
+// txHashes returns the hashes of txs.
+func txHashes(txs types.Transactions) []common.Hash {
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	return hashes
+}
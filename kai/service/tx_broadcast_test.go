@@ -0,0 +1,200 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package service
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	serviceconst "github.com/kardiachain/go-kardia/kai/service/const"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// newTestPeerSet returns a peer set of n validator peers that only queue broadcasts, without any connection.
+func newTestPeerSet(n int) *peerSet {
+	ps := newPeerSet()
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("peer%d", i)
+		ps.peers[id] = &peer{
+			logger:       log.New(),
+			id:           id,
+			knownTxs:     newKnownCache(maxKnownTxs),
+			queuedTxs:    make(chan types.Transactions, 1),
+			queuedTxAnns: make(chan []common.Hash, 1),
+			version:      serviceconst.KAI2,
+			IsValidator:  true,
+		}
+	}
+	return ps
+}
+
+func TestTxBroadcastPolicyFloodCount(t *testing.T) {
+	tests := []struct {
+		policy TxBroadcastPolicy
+		total  int
+		want   int
+	}{
+		{DefaultTxBroadcastPolicy, 10, 10},
+		{TxBroadcastPolicy{FloodFraction: 0.25}, 10, 3},
+		{TxBroadcastPolicy{FloodFraction: 0.5}, 10, 5},
+		{TxBroadcastPolicy{FloodFraction: 0}, 10, 0},
+		{TxBroadcastPolicy{FloodFraction: 0, MinFloodPeers: 2}, 10, 2},
+		{TxBroadcastPolicy{FloodFraction: 0.1, MinFloodPeers: 4}, 10, 4},
+		{TxBroadcastPolicy{FloodFraction: 0.5, MinFloodPeers: 20}, 10, 10},
+		{TxBroadcastPolicy{FloodFraction: 0.5}, 0, 0},
+	}
+	for i, tt := range tests {
+		if have := tt.policy.floodCount(tt.total); have != tt.want {
+			t.Errorf("test %d: flood count mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+}
+
+func TestBroadcastTxsPolicy(t *testing.T) {
+	txs := types.Transactions{
+		types.NewTransaction(0, common.HexToAddress("0x01"), big.NewInt(1), 21000, big.NewInt(1), nil),
+		types.NewTransaction(1, common.HexToAddress("0x01"), big.NewInt(1), 21000, big.NewInt(1), nil),
+	}
+	tests := []struct {
+		policy   TxBroadcastPolicy
+		flood    int
+		announce int
+	}{
+		{DefaultTxBroadcastPolicy, 8, 0},
+		{TxBroadcastPolicy{FloodFraction: 0.25}, 2, 6},
+		{TxBroadcastPolicy{FloodFraction: 0, MinFloodPeers: 1}, 1, 7},
+		{TxBroadcastPolicy{FloodFraction: 0}, 0, 8},
+	}
+	for i, tt := range tests {
+		pm := &ProtocolManager{
+			logger:      log.New(),
+			peers:       newTestPeerSet(8),
+			txBroadcast: tt.policy,
+		}
+		pm.BroadcastTxs(txs)
+
+		var flood, announce int
+		for _, p := range pm.peers.peers {
+			select {
+			case queued := <-p.queuedTxs:
+				flood++
+				if len(queued) != len(txs) {
+					t.Errorf("test %d: flooded txs mismatch: have %d, want %d", i, len(queued), len(txs))
+				}
+			case hashes := <-p.queuedTxAnns:
+				announce++
+				if len(hashes) != len(txs) || hashes[0] != txs[0].Hash() || hashes[1] != txs[1].Hash() {
+					t.Errorf("test %d: announced hashes mismatch: have %x", i, hashes)
+				}
+			default:
+				t.Errorf("test %d: peer %s received nothing", i, p.id)
+			}
+		}
+		if flood != tt.flood || announce != tt.announce {
+			t.Errorf("test %d: split mismatch: have %d flooded and %d announced, want %d and %d", i, flood, announce, tt.flood, tt.announce)
+		}
+	}
+}
+
+func TestBroadcastTxsSkipsKnownPeers(t *testing.T) {
+	tx := types.NewTransaction(0, common.HexToAddress("0x01"), big.NewInt(1), 21000, big.NewInt(1), nil)
+	pm := &ProtocolManager{
+		logger:      log.New(),
+		peers:       newTestPeerSet(4),
+		txBroadcast: TxBroadcastPolicy{FloodFraction: 0.5},
+	}
+	// Peers announced a transaction already know it and must not get it again
+	known := pm.peers.peers["peer0"]
+	known.MarkTxHashes([]common.Hash{tx.Hash()})
+
+	pm.BroadcastTxs(types.Transactions{tx})
+	if len(known.queuedTxs) != 0 || len(known.queuedTxAnns) != 0 {
+		t.Error("peer knowing the transaction should not receive it")
+	}
+	var flood, announce int
+	for _, p := range pm.peers.peers {
+		flood += len(p.queuedTxs)
+		announce += len(p.queuedTxAnns)
+	}
+	// Fractions apply to the 3 peers not knowing the transaction
+	if flood != 2 || announce != 1 {
+		t.Errorf("split mismatch: have %d flooded and %d announced, want 2 and 1", flood, announce)
+	}
+}
+
+func TestBroadcastTxsAnnouncesOnce(t *testing.T) {
+	txs := types.Transactions{
+		types.NewTransaction(0, common.HexToAddress("0x01"), big.NewInt(1), 21000, big.NewInt(1), nil),
+		types.NewTransaction(1, common.HexToAddress("0x01"), big.NewInt(1), 21000, big.NewInt(1), nil),
+	}
+	pm := &ProtocolManager{
+		logger:      log.New(),
+		peers:       newTestPeerSet(3),
+		txBroadcast: TxBroadcastPolicy{FloodFraction: 0},
+	}
+	for _, p := range pm.peers.peers {
+		p.queuedTxAnns = make(chan []common.Hash, 4)
+	}
+	// The same batch is broadcast again before the first announcements went out, then with a new tx
+	pm.BroadcastTxs(txs[:1])
+	pm.BroadcastTxs(txs[:1])
+	pm.BroadcastTxs(txs)
+
+	for _, p := range pm.peers.peers {
+		announced := make(map[common.Hash]int)
+		for len(p.queuedTxAnns) > 0 {
+			for _, hash := range <-p.queuedTxAnns {
+				announced[hash]++
+			}
+		}
+		for _, tx := range txs {
+			if announced[tx.Hash()] != 1 {
+				t.Errorf("peer %s: tx %x announced %d times, want once", p.id, tx.Hash(), announced[tx.Hash()])
+			}
+		}
+	}
+}
+
+func TestBroadcastTxsFloodsLegacyPeers(t *testing.T) {
+	tx := types.NewTransaction(0, common.HexToAddress("0x01"), big.NewInt(1), 21000, big.NewInt(1), nil)
+	pm := &ProtocolManager{
+		logger:      log.New(),
+		peers:       newTestPeerSet(4),
+		txBroadcast: TxBroadcastPolicy{FloodFraction: 0},
+	}
+	// Peers on kai1 don't understand announcements and must get the transaction in full
+	legacy := pm.peers.peers["peer0"]
+	legacy.version = serviceconst.KAI1
+
+	pm.BroadcastTxs(types.Transactions{tx})
+	if len(legacy.queuedTxs) != 1 || len(legacy.queuedTxAnns) != 0 {
+		t.Error("kai1 peer should receive the transaction in full")
+	}
+	var flood, announce int
+	for _, p := range pm.peers.peers {
+		flood += len(p.queuedTxs)
+		announce += len(p.queuedTxAnns)
+	}
+	if flood != 1 || announce != 3 {
+		t.Errorf("split mismatch: have %d flooded and %d announced, want 1 and 3", flood, announce)
+	}
+}
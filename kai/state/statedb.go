@@ -162,6 +162,7 @@ func (sdb *StateDB) Copy() *StateDB {
 
 	// Copy all the basic fields, initialize the memory ones
 	state := &StateDB{
+		logger:            sdb.logger,
 		db:                sdb.db,
 		trie:              sdb.db.CopyTrie(sdb.trie),
 		stateObjects:      make(map[common.Address]*stateObject, len(sdb.journal.dirties)),
@@ -256,6 +257,14 @@ func (sdb *StateDB) SubBalance(addr common.Address, amount *big.Int) {
 	}
 }
 
+// SetBalance sets the balance of the account associated with addr.
+func (sdb *StateDB) SetBalance(addr common.Address, amount *big.Int) {
+	stateObject := sdb.GetOrNewStateObject(addr)
+	if stateObject != nil {
+		stateObject.SetBalance(amount)
+	}
+}
+
 func (sdb *StateDB) SetCode(addr common.Address, code []byte) {
 	stateObject := sdb.GetOrNewStateObject(addr)
 	if stateObject != nil {
@@ -562,6 +562,17 @@ func ReadSeenCommit(db kaidb.Reader, height uint64) *types.Commit {
 	return commit
 }
 
+// WriteSeenCommit overwrites the seen commit (seen +2/3 precommits) of the block at the given height.
+func WriteSeenCommit(db kaidb.Writer, height uint64, commit *types.Commit) {
+	data, err := rlp.EncodeToBytes(commit)
+	if err != nil {
+		log.Crit("Failed to encode seen commit", "err", err)
+	}
+	if err := db.Put(seenCommitKey(height), data); err != nil {
+		log.Crit("Failed to store seen commit", "err", err)
+	}
+}
+
 // ReadBlock returns the Block for the given height
 func ReadBlock(db kaidb.Reader, hash common.Hash, height uint64) *types.Block {
 	blockMeta := ReadBlockMeta(db, hash, height)
@@ -570,14 +581,20 @@ func ReadBlock(db kaidb.Reader, hash common.Hash, height uint64) *types.Block {
 		return nil
 	}
 
-	buf := []byte{}
-	for i := 0; i < blockMeta.BlockID.PartsHeader.Total.Int32(); i++ {
+	// Parts come from our own storage, their proofs were verified when the block was received
+	ps := types.NewTrustedPartSetFromHeader(blockMeta.BlockID.PartsHeader)
+	for i := 0; i < ps.Total(); i++ {
 		part := ReadBlockPart(db, hash, height, i)
-		buf = append(buf, part.Bytes...)
+		if part == nil {
+			return nil
+		}
+		if _, err := ps.AddPart(part); err != nil {
+			panic(errors.New("Reading block error"))
+		}
 	}
 
 	block := new(types.Block)
-	if err := rlp.DecodeBytes(buf, block); err != nil {
+	if err := rlp.Decode(ps.GetReader(), block); err != nil {
 		panic(errors.New("Reading block error"))
 	}
 	return block
@@ -178,6 +178,11 @@ func (s *StoreDB) ReadSeenCommit(height uint64) *types.Commit {
 	return ReadSeenCommit(s.db, height)
 }
 
+// WriteSeenCommit overwrites the seen commit of the block at the given height.
+func (s *StoreDB) WriteSeenCommit(height uint64, commit *types.Commit) {
+	WriteSeenCommit(s.db, height, commit)
+}
+
 // ReadHeader retrieves the block header corresponding to the hash.
 func (s *StoreDB) ReadHeader(hash common.Hash, height uint64) *types.Header {
 	return CommonReadHeader(s.db, hash, height)
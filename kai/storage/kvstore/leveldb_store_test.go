@@ -1 +1,28 @@
 package kvstore
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+func TestReadBlock(t *testing.T) {
+	db := memorydb.New()
+	block := types.NewBlock(&types.Header{Height: 1, Time: big.NewInt(1), GasLimit: 1000000}, nil, &types.Commit{})
+	// Small parts, so the block is reassembled from several of them
+	parts := block.MakePartSet(64)
+	if parts.Total() < 2 {
+		t.Fatalf("block split in %d parts, want several", parts.Total())
+	}
+	WriteBlock(db, block, parts, &types.Commit{})
+
+	if have := ReadBlock(db, block.Hash(), block.Height()); have == nil || have.Hash() != block.Hash() {
+		t.Fatalf("read block mismatch: have %v, want %x", have, block.Hash())
+	}
+	db.Delete(blockPartKey(block.Height(), parts.Total()-1))
+	if have := ReadBlock(db, block.Hash(), block.Height()); have != nil {
+		t.Errorf("block with a missing part read: have %x", have.Hash())
+	}
+}
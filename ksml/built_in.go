@@ -42,16 +42,23 @@ func init() {
 		elif: emptyFunc,
 		el: emptyFunc,
 		endForEach: emptyFunc,
+		whileFunc: whileLoop,
+		endWhile: emptyFunc,
 		addVarFunc: addVar,
 		forEachFunc: forEach,
 		splitFunc: split,
 		defineFunc: defineFunction,
 		endDefineFunc: emptyFunc,
 		callFunc: callFunction,
+		returnFunc: returnFunction,
 		getData: GetDataFromSmc,
+		getDataMap: GetDataMapFromSmc,
+		getFieldFunc: getField,
 		trigger: triggerSmc,
 		publish: publishFunc,
 		compare: cmpFunc,
+		add: Add,
+		sub: Sub,
 		mul: Mul,
 		div: Div,
 		toInt: SetInt,
@@ -60,6 +67,13 @@ func init() {
 		format: FormatFloat,
 		round: Round,
 		replaceFunc: Replace,
+		gt: Gt,
+		lt: Lt,
+		gte: Gte,
+		lte: Lte,
+		eq: Eq,
+		maxFunc: Max,
+		minFunc: Min,
 	}
 }
 
@@ -107,6 +121,30 @@ func addVar(p *Parser, extras ...interface{}) ([]interface{}, error) {
 	return nil, nil
 }
 
+// getField returns the value stored under key in a map. extras[0] is an expression resolving to a map, extras[1] is the key
+func getField(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	if len(extras) != 2 {
+		return nil, invalidGetFieldParams
+	}
+	vals, err := p.handleContent(extras[0].(string))
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 0 {
+		return nil, fmt.Errorf("returned value is empty")
+	}
+	m, err := interfaceToMap(vals[0])
+	if err != nil {
+		return nil, err
+	}
+	key := strings.TrimSpace(extras[1].(string))
+	val, ok := m[key]
+	if !ok {
+		return nil, fmt.Errorf("%v: %v", fieldNotFound, key)
+	}
+	return []interface{}{val}, nil
+}
+
 // validateFunc has 3 elements, condition, true signal and false signal.
 // if condition is true then true signal is returned otherwise false signal is returned
 func validateFunc(p *Parser, extras ...interface{}) ([]interface{}, error) {
@@ -204,8 +242,30 @@ func executeIf(p *Parser, extras ...interface{}) ([]interface{}, error) {
 }
 
 // parseBlockPatterns reads nested patterns with different parser then returns all returned params.
+// If fn:return is executed within the block, its value is returned followed by signalReturn so that
+// the enclosing function stops as well.
 func parseBlockPatterns(p *Parser, patterns []string, extrasVar map[string]interface{}) ([]interface{}, error) {
+	newParser, err := runBlockPatterns(p, patterns, extrasVar, p.inFunction)
+	if err != nil {
+		return nil, err
+	}
+	if newParser.returned {
+		p.returned, p.returnValue = true, newParser.returnValue
+		return append(append([]interface{}{}, newParser.returnValue...), signalReturn), nil
+	}
+	return newParser.GlobalParams, nil
+}
+
+// runBlockPatterns reads nested patterns with a new parser inheriting variables and functions of p
+// and returns that parser once it has been run.
+func runBlockPatterns(p *Parser, patterns []string, extrasVar map[string]interface{}, inFunction bool) (*Parser, error) {
+	if p.depth >= p.MaxRecursionDepth {
+		return nil, fmt.Errorf("%v: limit is %v", maxRecursionDepthExceeded, p.MaxRecursionDepth)
+	}
 	newParser := NewParser(p.ProxyName, p.PublishEndpoint, p.PublishFunction, p.Bc, p.TxPool, p.SmartContractAddress, patterns, p.GlobalMessage, p.CanTrigger)
+	newParser.MaxRecursionDepth, newParser.MaxLoopIterations = p.MaxRecursionDepth, p.MaxLoopIterations
+	newParser.depth, newParser.iterations = p.depth+1, p.iterations
+	defer func() { p.iterations = newParser.iterations }()
 	// add all definedVariables in p in overwrite cases.
 	for k, v := range p.UserDefinedVariables {
 		newParser.UserDefinedVariables[k] = v
@@ -222,6 +282,7 @@ func parseBlockPatterns(p *Parser, patterns []string, extrasVar map[string]inter
 		newParser.UserDefinedFunction[k] = v
 	}
 
+	newParser.inFunction = inFunction
 	err := newParser.ParseParams()
 	if err != nil {
 		return nil, err
@@ -232,7 +293,7 @@ func parseBlockPatterns(p *Parser, patterns []string, extrasVar map[string]inter
 			p.UserDefinedVariables[k] = v
 		}
 	}
-	return newParser.GlobalParams, nil
+	return newParser, nil
 }
 
 // forEach loops through a given list variables and execute all logics inside forEach(name, var, indexVar)...endForEach(name) pair.
@@ -281,12 +342,19 @@ func forEach(p *Parser, extras ...interface{}) ([]interface{}, error) {
 	}
 
 	for i, _ := range convertedArr {
+		if p.iterations++; p.iterations > p.MaxLoopIterations {
+			return nil, fmt.Errorf("%v: limit is %v", maxLoopIterationsExceeded, p.MaxLoopIterations)
+		}
 		val, err := parseBlockPatterns(p, newPatterns, map[string]interface{}{
 			index: i,
 		})
 		if err != nil {
 			return nil, err
 		}
+		if p.returned {
+			// fn:return has been called in the loop, stop looping and pass it on
+			return val, nil
+		}
 		if val != nil && len(val) > 0{
 			results = append(results, val...)
 		}
@@ -294,6 +362,53 @@ func forEach(p *Parser, extras ...interface{}) ([]interface{}, error) {
 	return results, nil
 }
 
+// whileLoop executes all logics inside while(name, condition)...endWhile(name) pair as long as condition returns true.
+// condition is evaluated before each iteration and must return a bool. Iterations count towards MaxLoopIterations.
+func whileLoop(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	if len(extras) != 2 {
+		return nil, invalidWhileParam
+	}
+	name, condition := extras[0].(string), extras[1].(string)
+	newPatterns := make([]string, 0)
+	validWhile := false
+	// loop GlobalPatterns from current position until we find endWhile(name)
+	for _, pattern := range p.GlobalPatterns[p.Pc+1:] {
+		p.Pc++
+		if strings.Contains(pattern, name) && strings.Contains(pattern, endWhile) {
+			validWhile = true
+			break
+		}
+		newPatterns = append(newPatterns, pattern)
+	}
+	if !validWhile {
+		return nil, invalidWhileStatement
+	}
+
+	results := make([]interface{}, 0)
+	for {
+		cond, err := p.handleContent(condition)
+		if err != nil {
+			return nil, err
+		}
+		if len(cond) != 1 || reflect.TypeOf(cond[0]).Kind() != reflect.Bool {
+			return nil, incorrectReturnedValueInWhileFunc
+		}
+		if !cond[0].(bool) {
+			return results, nil
+		}
+		if p.iterations++; p.iterations > p.MaxLoopIterations {
+			return nil, fmt.Errorf("%v: limit is %v", maxLoopIterationsExceeded, p.MaxLoopIterations)
+		}
+		val, err := parseBlockPatterns(p, newPatterns, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(val) > 0 {
+			results = append(results, val...)
+		}
+	}
+}
+
 // split splits given string(maybe expression) with a separator
 func split(p *Parser, extras ...interface{}) ([]interface{}, error) {
 	if len(extras) != 2 {
@@ -419,11 +534,30 @@ func callFunction(p *Parser, extras ...interface{}) ([]interface{}, error) {
 			vars[arg] = val[0]
 		}
 	}
-	results, err := parseBlockPatterns(p, f.patterns, vars)
+	newParser, err := runBlockPatterns(p, f.patterns, vars, true)
 	if err != nil {
 		return nil, err
 	}
-	return results, nil
+	if newParser.returned {
+		return newParser.returnValue, nil
+	}
+	return newParser.GlobalParams, nil
+}
+
+// returnFunction stops the defined function it is called in and makes it return its evaluated argument.
+func returnFunction(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	if !p.inFunction {
+		return nil, returnOutsideFunction
+	}
+	if len(extras) != 1 {
+		return nil, invalidReturnParam
+	}
+	val, err := p.handleContent(extras[0].(string))
+	if err != nil {
+		return nil, err
+	}
+	p.returned, p.returnValue = true, val
+	return append(append([]interface{}{}, val...), signalReturn), nil
 }
 
 func getTriggerMessage(p *Parser, input []interface{}) (*message.TriggerMessage, error){
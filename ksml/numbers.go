@@ -19,6 +19,7 @@
 package ksml
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -27,6 +28,8 @@ import (
 	"strings"
 )
 
+var errDivisionByZero = errors.New("division by zero")
+
 func isType(t string, vals ...reflect.Value) bool {
 	for _, val := range vals {
 		if !strings.Contains(val.Type().String(), t) {
@@ -44,25 +47,66 @@ func parseInt(val interface{}) (int64, error) {
 	return strconv.ParseInt(v, 10, 64)
 }
 
-func Mul(p *Parser, extras ...interface{}) ([]interface{}, error) {
+// bigOperands resolves the 2 operands of an arithmetic function and converts
+// int64, float64 and decimal string values into big.Int or big.Float.
+func bigOperands(p *Parser, extras []interface{}) (reflect.Value, reflect.Value, error) {
 	if len(extras) != 2 {
-		return nil, fmt.Errorf("invalid arguments, expect 2 got %v", len(extras))
+		return reflect.Value{}, reflect.Value{}, fmt.Errorf("invalid arguments, expect 2 got %v", len(extras))
 	}
 	vals, err := p.handleContents(extras)
 	if err != nil {
-		return nil, err
+		return reflect.Value{}, reflect.Value{}, err
 	}
 
-	// convert to big.Int or big.Float if returned vals are float64 or int64
-	for i, _ := range vals {
-		if reflect.ValueOf(vals[i]).Kind() == reflect.Float64 {
-			vals[i] = big.NewFloat(reflect.ValueOf(vals[i]).Float())
-		} else if reflect.ValueOf(vals[i]).Kind() == reflect.Int64 {
-			vals[i] = big.NewInt(reflect.ValueOf(vals[i]).Int())
+	// convert to big.Int or big.Float if returned vals are float64, int64 or numeric strings
+	for i := range vals {
+		switch v := vals[i].(type) {
+		case float64:
+			vals[i] = big.NewFloat(v)
+		case int64:
+			vals[i] = big.NewInt(v)
+		case string:
+			if n, ok := big.NewInt(0).SetString(v, 10); ok {
+				vals[i] = n
+			} else if f, ok := big.NewFloat(0).SetString(v); ok && strings.Contains(v, ".") {
+				vals[i] = f
+			}
 		}
 	}
+	return reflect.ValueOf(vals[0]), reflect.ValueOf(vals[1]), nil
+}
 
-	val1, val2 := reflect.ValueOf(vals[0]), reflect.ValueOf(vals[1])
+func Add(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	val1, val2, err := bigOperands(p, extras)
+	if err != nil {
+		return nil, err
+	}
+	if isType("big.Int", val1, val2) {
+		return []interface{}{big.NewInt(0).Add(val1.Interface().(*big.Int), val2.Interface().(*big.Int))}, nil
+	} else if isType("big.Float", val1, val2) {
+		return []interface{}{big.NewFloat(0).Add(val1.Interface().(*big.Float), val2.Interface().(*big.Float))}, nil
+	}
+	return nil, fmt.Errorf("unsupport type %v or %v in Add func, expect big.Int or big.Float", val1.Type().String(), val2.Type().String())
+}
+
+func Sub(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	val1, val2, err := bigOperands(p, extras)
+	if err != nil {
+		return nil, err
+	}
+	if isType("big.Int", val1, val2) {
+		return []interface{}{big.NewInt(0).Sub(val1.Interface().(*big.Int), val2.Interface().(*big.Int))}, nil
+	} else if isType("big.Float", val1, val2) {
+		return []interface{}{big.NewFloat(0).Sub(val1.Interface().(*big.Float), val2.Interface().(*big.Float))}, nil
+	}
+	return nil, fmt.Errorf("unsupport type %v or %v in Sub func, expect big.Int or big.Float", val1.Type().String(), val2.Type().String())
+}
+
+func Mul(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	val1, val2, err := bigOperands(p, extras)
+	if err != nil {
+		return nil, err
+	}
 	if isType("big.Int", val1, val2) {
 		return []interface{}{big.NewInt(0).Mul(val1.Interface().(*big.Int), val2.Interface().(*big.Int))}, nil
 	} else if isType("big.Float", val1, val2) {
@@ -72,27 +116,19 @@ func Mul(p *Parser, extras ...interface{}) ([]interface{}, error) {
 }
 
 func Div(p *Parser, extras ...interface{}) ([]interface{}, error) {
-	if len(extras) != 2 {
-		return nil, fmt.Errorf("invalid arguments, expect 2 got %v", len(extras))
-	}
-	vals, err := p.handleContents(extras)
+	val1, val2, err := bigOperands(p, extras)
 	if err != nil {
 		return nil, err
 	}
-
-	// convert to big.Int or big.Float if returned vals are float64 or int64
-	for i, _ := range vals {
-		if reflect.ValueOf(vals[i]).Kind() == reflect.Float64 {
-			vals[i] = big.NewFloat(reflect.ValueOf(vals[i]).Float())
-		} else if reflect.ValueOf(vals[i]).Kind() == reflect.Int64 {
-			vals[i] = big.NewInt(reflect.ValueOf(vals[i]).Int())
-		}
-	}
-
-	val1, val2 := reflect.ValueOf(vals[0]), reflect.ValueOf(vals[1])
 	if isType("big.Int", val1, val2) {
+		if val2.Interface().(*big.Int).Sign() == 0 {
+			return nil, errDivisionByZero
+		}
 		return []interface{}{big.NewInt(0).Div(val1.Interface().(*big.Int), val2.Interface().(*big.Int))}, nil
 	} else if isType("big.Float", val1, val2) {
+		if val2.Interface().(*big.Float).Sign() == 0 {
+			return nil, errDivisionByZero
+		}
 		return []interface{}{big.NewFloat(0).Quo(val1.Interface().(*big.Float), val2.Interface().(*big.Float))}, nil
 	}
 	return nil, fmt.Errorf("unsupport type %v or %v in Div func, expect big.Int or big.Float", val1.Type().String(), val2.Type().String())
@@ -215,3 +251,118 @@ func FormatFloat(p *Parser, extras ...interface{}) ([]interface{}, error) {
 	}
 	return nil, fmt.Errorf("unsupport type %v in format func, expect big.Float", val1.Type().String())
 }
+
+// toBigInt converts val to big.Int if it is an integer or a string holding a decimal integer.
+func toBigInt(val interface{}) (*big.Int, bool) {
+	if v, ok := val.(*big.Int); ok {
+		return v, true
+	}
+	str, err := InterfaceToString(val)
+	if err != nil {
+		return nil, false
+	}
+	return big.NewInt(0).SetString(str, 10)
+}
+
+// compareValues resolves 2 operands and compares them as big.Int if both are numeric, otherwise as strings.
+// It returns the resolved operands along with -1, 0 or +1 if the first one is less than, equal to or greater than the second.
+func compareValues(p *Parser, extras ...interface{}) ([]interface{}, int, error) {
+	if len(extras) != 2 {
+		return nil, 0, fmt.Errorf("invalid arguments, expect 2 got %v", len(extras))
+	}
+	vals := make([]interface{}, len(extras))
+	for i, extra := range extras {
+		str, err := InterfaceToString(extra)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, err := p.handleContent(str)
+		if err != nil {
+			return nil, 0, err
+		}
+		vals[i] = v[0]
+	}
+	v1, ok1 := toBigInt(vals[0])
+	v2, ok2 := toBigInt(vals[1])
+	if ok1 && ok2 {
+		return vals, v1.Cmp(v2), nil
+	}
+	str1, err := InterfaceToString(vals[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	str2, err := InterfaceToString(vals[1])
+	if err != nil {
+		return nil, 0, err
+	}
+	return vals, strings.Compare(str1, str2), nil
+}
+
+// Gt returns whether the first operand is greater than the second one.
+func Gt(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	_, cmp, err := compareValues(p, extras...)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{cmp > 0}, nil
+}
+
+// Lt returns whether the first operand is less than the second one.
+func Lt(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	_, cmp, err := compareValues(p, extras...)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{cmp < 0}, nil
+}
+
+// Gte returns whether the first operand is greater than or equal to the second one.
+func Gte(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	_, cmp, err := compareValues(p, extras...)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{cmp >= 0}, nil
+}
+
+// Lte returns whether the first operand is less than or equal to the second one.
+func Lte(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	_, cmp, err := compareValues(p, extras...)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{cmp <= 0}, nil
+}
+
+// Eq returns whether both operands are equal.
+func Eq(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	_, cmp, err := compareValues(p, extras...)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{cmp == 0}, nil
+}
+
+// Max returns the larger of 2 operands.
+func Max(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	vals, cmp, err := compareValues(p, extras...)
+	if err != nil {
+		return nil, err
+	}
+	if cmp < 0 {
+		return []interface{}{vals[1]}, nil
+	}
+	return []interface{}{vals[0]}, nil
+}
+
+// Min returns the smaller of 2 operands.
+func Min(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	vals, cmp, err := compareValues(p, extras...)
+	if err != nil {
+		return nil, err
+	}
+	if cmp > 0 {
+		return []interface{}{vals[1]}, nil
+	}
+	return []interface{}{vals[0]}, nil
+}
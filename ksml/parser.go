@@ -54,7 +54,15 @@ type Parser struct {
 	Pc                   int                    // program counter is used to count and get current read position in globalPatterns
 	Nonce                uint64
 	CanTrigger           bool
+	MaxRecursionDepth    int // max depth of nested blocks and function calls
+	MaxLoopIterations    int // max iterations of all forEach and while loops in one run
 	mtx                  sync.Mutex
+
+	depth       int           // number of enclosing blocks and function calls
+	iterations  int           // loop iterations done so far, shared with nested blocks
+	inFunction  bool          // whether globalPatterns belong to a defined function, fn:return is only allowed there
+	returned    bool          // set once fn:return has been executed
+	returnValue []interface{} // value returned by fn:return
 }
 
 func NewParser(proxyName, publishedEndpoint string, publishFunction func(endpoint string, topic string, msg dualMsg.TriggerMessage) error,
@@ -77,6 +85,8 @@ func NewParser(proxyName, publishedEndpoint string, publishFunction func(endpoin
 		Nonce:                0,
 		Pc:                   0,
 		CanTrigger:           canTrigger,
+		MaxRecursionDepth:    defaultMaxRecursionDepth,
+		MaxLoopIterations:    defaultMaxLoopIterations,
 	}
 }
 
@@ -393,6 +403,128 @@ func (p *Parser) ParseParams() error {
 	return nil
 }
 
+// CompileError reports the first structural error found by Compile and the position of the pattern causing it.
+type CompileError struct {
+	Line int
+	Err  error
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("error while compiling content at line %v - %v", e.Line, e.Err)
+}
+
+// block is an opened if, forEach, while or defineFunc statement which is waiting for its end.
+type block struct {
+	method string
+	name   string
+	line   int
+}
+
+// blockEnds maps every block statement to the statement closing it.
+var blockEnds = map[string]string{
+	ifFunc:      endIf,
+	forEachFunc: endForEach,
+	whileFunc:   endWhile,
+	defineFunc:  endDefineFunc,
+}
+
+// blockErrors maps every block statement to the error returned when it is malformed.
+var blockErrors = map[string]error{
+	ifFunc:      invalidIfStatement,
+	forEachFunc: invalidForEachStatement,
+	whileFunc:   invalidWhileStatement,
+	defineFunc:  invalidDefineFunc,
+}
+
+// Compile reads through globalPatterns once without executing them. It checks that every if/elif/else/endif,
+// forEach/endForEach, while/endWhile and defineFunc/endDefineFunc block is matched, that every built-in function exists
+// and that every called function is defined. The first error found is returned as a *CompileError.
+func (p *Parser) Compile() error {
+	if len(p.GlobalPatterns) == 0 {
+		return sourceIsEmpty
+	}
+	blocks := make([]block, 0)
+	defined := make(map[string]struct{})
+	calls := make([]block, 0)
+
+	for line, pattern := range p.GlobalPatterns {
+		if len(pattern) < elMinLength || !strings.HasPrefix(pattern, "${") || !strings.HasSuffix(pattern, "}") {
+			continue
+		}
+		content := pattern[2 : len(pattern)-1]
+		if !hasBuiltIn(content) {
+			continue
+		}
+		_, method, params, err := p.GetPrefix(content)
+		if err != nil {
+			return &CompileError{Line: line, Err: err}
+		}
+		if err := p.checkBuiltIns(method, params); err != nil {
+			return &CompileError{Line: line, Err: err}
+		}
+		name := ""
+		if len(params) > 0 {
+			name = params[0]
+		}
+		switch method {
+		case ifFunc, forEachFunc, whileFunc, defineFunc:
+			if name == "" {
+				return &CompileError{Line: line, Err: blockErrors[method]}
+			}
+			if method == defineFunc {
+				defined[name] = struct{}{}
+			}
+			blocks = append(blocks, block{method: method, name: name, line: line})
+		case elif, el:
+			if len(blocks) == 0 || blocks[len(blocks)-1].method != ifFunc || blocks[len(blocks)-1].name != name {
+				return &CompileError{Line: line, Err: invalidIfStatement}
+			}
+		case endIf, endForEach, endWhile, endDefineFunc:
+			if len(blocks) == 0 {
+				return &CompileError{Line: line, Err: fmt.Errorf("unexpected %v(%v)", method, name)}
+			}
+			last := blocks[len(blocks)-1]
+			if blockEnds[last.method] != method || last.name != name {
+				return &CompileError{Line: last.line, Err: blockErrors[last.method]}
+			}
+			blocks = blocks[:len(blocks)-1]
+		case callFunc:
+			calls = append(calls, block{method: method, name: name, line: line})
+		}
+	}
+	if len(blocks) > 0 {
+		// the innermost block is not closed
+		last := blocks[len(blocks)-1]
+		return &CompileError{Line: last.line, Err: blockErrors[last.method]}
+	}
+	for _, call := range calls {
+		if _, ok := defined[call.name]; !ok {
+			return &CompileError{Line: call.line, Err: fmt.Errorf("%v: %v", methodNotFound, call.name)}
+		}
+	}
+	return nil
+}
+
+// checkBuiltIns checks that method and all built-in functions nested in its params exist.
+func (p *Parser) checkBuiltIns(method string, params []string) error {
+	if _, ok := BuiltInFuncMap[method]; !ok {
+		return fmt.Errorf("%v: %v", unknownBuiltInFunc, method)
+	}
+	for _, param := range params {
+		if !hasBuiltIn(param) {
+			continue
+		}
+		_, nestedMethod, nestedParams, err := p.GetPrefix(param)
+		if err != nil {
+			return err
+		}
+		if err := p.checkBuiltIns(nestedMethod, nestedParams); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *Parser) handleContents(contents []interface{}) ([]interface{}, error) {
 	results := make([]interface{}, 0)
 	for _, content := range contents {
@@ -67,23 +67,53 @@ func generateInput(p *Parser, extras ...interface{}) (string, *abi.ABI, *common.
 
 // getDataFromSmc gets data from smc through method and params
 func GetDataFromSmc(p *Parser, extras ...interface{}) ([]interface{}, error) {
-	method, kAbi, caller, currentHeader, input, err := generateInput(p, extras...)
+	o, outputs, err := getOutputFromSmc(p, extras...)
 	if err != nil {
 		return nil, err
 	}
+	// loop for each field in output. Convert to string and add them into a list
+	return convertOutputToNative(o, outputs)
+}
+
+// GetDataMapFromSmc works like GetDataFromSmc but returns a single map whose keys are the output names.
+// Unnamed outputs are keyed by their position.
+func GetDataMapFromSmc(p *Parser, extras ...interface{}) ([]interface{}, error) {
+	o, outputs, err := getOutputFromSmc(p, extras...)
+	if err != nil {
+		return nil, err
+	}
+	args, err := convertOutputToNative(o, outputs)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	for i, arg := range args {
+		name := strconv.Itoa(i)
+		if i < len(outputs) && outputs[i].Name != "" {
+			name = outputs[i].Name
+		}
+		result[name] = arg
+	}
+	return []interface{}{result}, nil
+}
+
+// getOutputFromSmc calls method statically and unpacks the returned data into its output struct
+func getOutputFromSmc(p *Parser, extras ...interface{}) (reflect.Value, abi.Arguments, error) {
+	method, kAbi, caller, currentHeader, input, err := generateInput(p, extras...)
+	if err != nil {
+		return reflect.Value{}, nil, err
+	}
 	// get data from smc using above input
 	result, err := callStaticKardiaMasterSmc(*caller, *p.SmartContractAddress, currentHeader, p.Bc, input, p.StateDb)
 	if err != nil {
-		return nil, err
+		return reflect.Value{}, nil, err
 	}
 	// base on output convert result
 	outputResult, err := GenerateOutputStruct(*kAbi, method, result)
 	if err != nil {
-		return nil, err
+		return reflect.Value{}, nil, err
 	}
-	// loop for each field in output. Convert to string and add them into a list
-	o := reflect.ValueOf(outputResult)
-	return convertOutputToNative(o, kAbi.Methods[method].Outputs)
+	return reflect.ValueOf(outputResult), kAbi.Methods[method].Outputs, nil
 }
 
 // triggerSmc triggers an smc call by creating tx and send to txPool.
@@ -839,6 +839,34 @@ func TestGetDataFromSmc(t *testing.T) {
 	require.Equal(t, []interface{}{uint8(0)}, val)
 }
 
+func TestGetDataMapFromSmc(t *testing.T) {
+	parser, err := setup(sampleCode4, sampleDefinition4, make([]string, 0), nil)
+	require.NoError(t, err)
+	val, err := ksml.GetDataMapFromSmc(parser, "get2UintValue")
+	require.NoError(t, err)
+	expected := []interface{}{map[string]interface{}{"first": uint8(2), "second": uint8(3)}}
+	require.Equal(t, expected, val)
+}
+
+func TestGetField(t *testing.T) {
+	parser, err := setup(sampleCode4, sampleDefinition4,
+		[]string{
+			"${fn:var(result, map, smc:getDataMap(get2UintValue))}",
+			"${fn:getField(result, second)}",
+		},
+		&message.EventMessage{},
+	)
+	require.NoError(t, err)
+	err = parser.ParseParams()
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]interface{}{"first": uint8(2), "second": uint8(3)}, parser.UserDefinedVariables["result"])
+	require.Equal(t, []interface{}{uint8(3)}, parser.GetGlobalParams())
+
+	_, err = ksml.BuiltInFuncMap["getField"](parser, "smc:getDataMap(get2UintValue)", "third")
+	require.Error(t, err)
+}
+
 func TestAddVar(t *testing.T) {
 	parser, err := setup(sampleCode2, sampleDefinition2,
 		[]string{
@@ -1163,6 +1191,32 @@ func TestForEach1(t *testing.T) {
 	require.Equal(t, expectedParams, parser.GlobalParams)
 }
 
+func TestWhile(t *testing.T) {
+	parser, err := setup(sampleCode2, sampleDefinition2, []string{
+		"${fn:var(testVar,uint64,1)}",
+		"${fn:while(loop1,testVar<uint(5))}",
+		"${fn:var(testVar,uint64,testVar+uint(1))}",
+		"${fn:endWhile(loop1)}",
+		"${testVar}",
+	}, &message.EventMessage{})
+	require.NoError(t, err)
+
+	err = parser.ParseParams()
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), parser.UserDefinedVariables["testVar"])
+	require.Equal(t, []interface{}{uint64(5)}, parser.GlobalParams)
+}
+
+func TestWhileInvalidCondition(t *testing.T) {
+	parser, err := setup(sampleCode2, sampleDefinition2, []string{
+		"${fn:var(testVar,uint64,1)}",
+		"${fn:while(loop1,testVar+uint(1))}",
+		"${fn:endWhile(loop1)}",
+	}, &message.EventMessage{})
+	require.NoError(t, err)
+	require.Error(t, parser.ParseParams())
+}
+
 func TestSplit(t *testing.T) {
 	parser, err := setup(sampleCode2, sampleDefinition2, []string{
 		"${fn:split(message.params[0],';')}",
@@ -1234,6 +1288,94 @@ func TestCallFunc(t *testing.T) {
 	require.Equal(t, expectedParams, parser.GetGlobalParams())
 }
 
+func TestCallFuncWithReturn(t *testing.T) {
+	parser, err := setup(sampleCode2, sampleDefinition2, []string{
+		"${fn:defineFunc(sum,param1,param2)}",
+		"${fn:if(large,uint(param1)+uint(param2)>uint(2))}",
+		"${fn:return(uint(param1)+uint(param2))}",
+		"${fn:endif(large)}",
+		"${uint(0)}",
+		"${fn:endDefineFunc(sum)}",
+		"${fn:call(sum,message.params[0],message.params[1])}",
+		"${fn:call(sum,message.params[0],message.params[0])}",
+	}, &message.EventMessage{
+		Params: []string{"1", "2"},
+	})
+	require.NoError(t, err)
+
+	err = parser.ParseParams()
+	require.NoError(t, err)
+
+	// the first call returns the sum, the second one only the value left by the function body
+	expectedParams := []interface{}{uint64(3), uint64(0)}
+	require.Equal(t, expectedParams, parser.GetGlobalParams())
+}
+
+func TestReturnOutsideFunction(t *testing.T) {
+	parser, err := setup(sampleCode2, sampleDefinition2, []string{
+		"${fn:return(message.params[0])}",
+	}, &message.EventMessage{
+		Params: []string{"1"},
+	})
+	require.NoError(t, err)
+	require.Error(t, parser.ParseParams())
+}
+
+func TestRecursiveFuncLimit(t *testing.T) {
+	parser, err := setup(sampleCode2, sampleDefinition2, []string{
+		"${fn:defineFunc(recurse,param1)}",
+		"${fn:call(recurse,param1)}",
+		"${fn:endDefineFunc(recurse)}",
+		"${fn:call(recurse,message.params[0])}",
+	}, &message.EventMessage{
+		Params: []string{"1"},
+	})
+	require.NoError(t, err)
+
+	err = parser.ParseParams()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "max recursion depth exceeded")
+}
+
+func TestForEachIterationLimit(t *testing.T) {
+	parser, err := setup(sampleCode2, sampleDefinition2, []string{
+		"${fn:forEach(outer,message.params,i)}",
+		"${fn:forEach(inner,message.params,j)}",
+		"${message.params[j]}",
+		"${fn:endForEach(inner)}",
+		"${fn:endForEach(outer)}",
+	}, &message.EventMessage{
+		Params: []string{"1", "2", "3"},
+	})
+	require.NoError(t, err)
+
+	// 3 outer and 9 inner iterations are needed
+	parser.MaxLoopIterations = 11
+	err = parser.ParseParams()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "max loop iterations exceeded")
+}
+
+func TestWhileIterationLimit(t *testing.T) {
+	parser, err := setup(sampleCode2, sampleDefinition2, []string{
+		"${fn:var(testVar,uint64,1)}",
+		"${fn:forEach(outer,message.params,i)}",
+		"${fn:while(inner,testVar<uint(3))}",
+		"${fn:var(testVar,uint64,testVar+uint(1))}",
+		"${fn:endWhile(inner)}",
+		"${fn:endForEach(outer)}",
+	}, &message.EventMessage{
+		Params: []string{"1", "2"},
+	})
+	require.NoError(t, err)
+
+	// 2 forEach and 2 while iterations are needed, while and forEach share the limit
+	parser.MaxLoopIterations = 3
+	err = parser.ParseParams()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "max loop iterations exceeded")
+}
+
 func TestTriggerSmc(t *testing.T) {
 	parser, err := setup(sampleCode5, sampleDefinition5, []string{
 		"${smc:trigger(setData, message.params[0])}",
@@ -1288,3 +1430,75 @@ func TestReplaceFunction(t *testing.T) {
 	require.Equal(t, expectedResult, parser.UserDefinedVariables["testReplace"])
 }
 
+func TestArithmeticFunctions(t *testing.T) {
+	parser, err := setup(sampleCode1, sampleDefinition1, make([]string, 0), nil)
+	require.NoError(t, err)
+
+	amount, _ := big.NewInt(0).SetString("100000000000000000000", 10)
+	tests := []struct {
+		fn       string
+		a, b     string
+		expected interface{}
+	}{
+		// decimal strings and numbers are both converted to big.Int
+		{"add", "'100000000000000000000'", "4", big.NewInt(0).Add(amount, big.NewInt(4))},
+		{"sub", "'100000000000000000000'", "'4'", big.NewInt(0).Sub(amount, big.NewInt(4))},
+		{"mul", "fn:int('100000000000000000000')", "4", big.NewInt(0).Mul(amount, big.NewInt(4))},
+		{"div", "'100000000000000000000'", "fn:int(4)", big.NewInt(0).Div(amount, big.NewInt(4))},
+		{"sub", "4", "'10'", big.NewInt(-6)},
+		{"add", "fn:float('1.5')", "'2.25'", big.NewFloat(0).Add(big.NewFloat(1.5), big.NewFloat(2.25))},
+	}
+	for _, tt := range tests {
+		out, err := ksml.BuiltInFuncMap[tt.fn](parser, tt.a, tt.b)
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{tt.expected}, out, "%s(%s,%s)", tt.fn, tt.a, tt.b)
+	}
+
+	_, err = ksml.BuiltInFuncMap["div"](parser, "1", "'0'")
+	require.Error(t, err)
+	_, err = ksml.BuiltInFuncMap["div"](parser, "fn:float(1)", "fn:float(0)")
+	require.Error(t, err)
+	_, err = ksml.BuiltInFuncMap["add"](parser, "1")
+	require.Error(t, err)
+}
+
+func TestCompareFunctions(t *testing.T) {
+	parser, err := setup(sampleCode1, sampleDefinition1, make([]string, 0), nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		fn       string
+		a, b     string
+		expected interface{}
+	}{
+		// numbers compare numerically, including numeric strings
+		{"gt", "10", "9", true},
+		{"gt", "'10'", "9", true},
+		{"lt", "'10'", "'9'", false},
+		{"gte", "7", "7", true},
+		{"lte", "8", "7", false},
+		{"eq", "'5'", "5", true},
+		// anything else compares as strings
+		{"lt", "'abc'", "'abd'", true},
+		{"gt", "'10'", "'9a'", false},
+		{"eq", "'abc'", "'abc'", true},
+	}
+	for _, tt := range tests {
+		out, err := ksml.BuiltInFuncMap[tt.fn](parser, tt.a, tt.b)
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{tt.expected}, out, "%s(%s,%s)", tt.fn, tt.a, tt.b)
+	}
+
+	out, err := ksml.BuiltInFuncMap["max"](parser, "3", "10")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{int64(10)}, out)
+	out, err = ksml.BuiltInFuncMap["min"](parser, "3", "10")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{int64(3)}, out)
+	out, err = ksml.BuiltInFuncMap["max"](parser, "'apple'", "'banana'")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"banana"}, out)
+
+	_, err = ksml.BuiltInFuncMap["gt"](parser, "1")
+	require.Error(t, err)
+}
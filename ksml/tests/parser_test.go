@@ -241,6 +241,81 @@ func TestParseParams_withStop(t *testing.T) {
 	require.Errorf(t, err, "signal stop has been applied")
 }
 
+func TestCompile(t *testing.T) {
+	patterns := []string{
+		"${fn:defineFunc(double,x)}",
+		"${fn:mul(x,fn:int(2))}",
+		"${fn:endDefineFunc(double)}",
+		"${fn:if(check,message.params[0]=='true')}",
+		"${fn:forEach(loop,message.params,i)}",
+		"${fn:call(double,i)}",
+		"${fn:endForEach(loop)}",
+		"${fn:elif(check,message.params[0]=='false')}",
+		"hello",
+		"${fn:else(check)}",
+		"${fn:while(w,false)}",
+		"${fn:endWhile(w)}",
+		"${fn:endif(check)}",
+	}
+	parser, err := setup(sampleCode4, sampleDefinition4, patterns, &message.EventMessage{})
+	require.NoError(t, err)
+	require.NoError(t, parser.Compile())
+}
+
+func TestCompile_malformed(t *testing.T) {
+	for name, tc := range map[string]struct {
+		patterns []string
+		line     int
+	}{
+		"unterminated if": {[]string{
+			"hello",
+			"${fn:if(check,true)}",
+			"world",
+		}, 1},
+		"unterminated forEach in if": {[]string{
+			"${fn:if(check,true)}",
+			"${fn:forEach(loop,message.params,i)}",
+			"${fn:endif(check)}",
+		}, 1},
+		"else of another if": {[]string{
+			"${fn:if(check,true)}",
+			"${fn:else(other)}",
+			"${fn:endif(check)}",
+		}, 1},
+		"endForEach without forEach": {[]string{
+			"hello",
+			"hello",
+			"${fn:endForEach(loop)}",
+		}, 2},
+		"unterminated defineFunc": {[]string{
+			"${fn:var(a,int,1)}",
+			"${fn:defineFunc(double,x)}",
+			"${fn:mul(x,fn:int(2))}",
+		}, 1},
+		"unterminated while": {[]string{
+			"${fn:while(w,false)}",
+		}, 0},
+		"undefined function": {[]string{
+			"${fn:defineFunc(double,x)}",
+			"${fn:endDefineFunc(double)}",
+			"${fn:call(double,1)}",
+			"${fn:call(triple,1)}",
+		}, 3},
+		"unknown built-in": {[]string{
+			"hello",
+			"${fn:int(fn:unknown(1))}",
+		}, 1},
+	} {
+		parser, err := setup(sampleCode4, sampleDefinition4, tc.patterns, &message.EventMessage{})
+		require.NoError(t, err)
+		err = parser.Compile()
+		require.Error(t, err, name)
+		compileErr, ok := err.(*ksml.CompileError)
+		require.True(t, ok, name)
+		require.Equal(t, tc.line, compileErr.Line, name)
+	}
+}
+
 func TestSimulateDexReleaseEvent(t *testing.T) {
 	// event message is a result generated from watcherActions
 	msg := &message.EventMessage{
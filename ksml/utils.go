@@ -41,15 +41,22 @@ const (
 	ifFunc = "if"
 	forEachFunc = "forEach"
 	endForEach = "endForEach"
+	whileFunc = "while"
+	endWhile = "endWhile"
 	splitFunc = "split"
 	replaceFunc = "replace"
 	defineFunc = "defineFunc"
 	endDefineFunc = "endDefineFunc"
 	callFunc = "call"
+	returnFunc = "return"
 	getData = "getData"
+	getDataMap = "getDataMap"
+	getFieldFunc = "getField"
 	trigger = "trigger"
 	publish = "publish"
 	compare = "cmp"
+	add = "add"
+	sub = "sub"
 	mul = "mul"
 	div = "div"
 	toInt = "int"
@@ -57,6 +64,13 @@ const (
 	exp = "exp"
 	format = "format"
 	round = "round"
+	gt = "gt"
+	lt = "lt"
+	gte = "gte"
+	lte = "lte"
+	eq = "eq"
+	maxFunc = "max"
+	minFunc = "min"
 
 	MaximumGasToCallFunction = uint(5000000)
 	intType = "int"
@@ -75,6 +89,7 @@ const (
 	stringType = "string"
 	boolType = "bool"
 	listType = "list"
+	mapType = "map"
 	invalidTypeMsg = "invalid variable, expect %v got %v"
 
 	elMinLength = 8
@@ -87,6 +102,9 @@ const (
 	prefixSeparator = ":"
 	messagePackage = "protocol.EventMessage"
 
+	defaultMaxRecursionDepth = 64
+	defaultMaxLoopIterations = 100000
+
 	signalContinue = "SIGNAL_CONTINUE"
 	signalStop = "SIGNAL_STOP"                   // stop: do nothing after signal is returned
 	signalReturn = "SIGNAL_RETURN"               // return: quit params execution but keep processed params and start another process.
@@ -118,10 +136,20 @@ var (
 	variableNotFound = fmt.Errorf("variable not found")
 	invalidForEachParam = fmt.Errorf("invalid for each param")
 	invalidForEachStatement = fmt.Errorf("invalid for each statement")
+	invalidWhileParam = fmt.Errorf("invalid while param")
+	invalidWhileStatement = fmt.Errorf("invalid while statement")
+	incorrectReturnedValueInWhileFunc = fmt.Errorf("WHILE func condition must return only 1 bool value")
 	notEnoughArgsForSplit = fmt.Errorf("not enough arguments for split function")
 	notEnoughArgsForFunc = fmt.Errorf("not enough arguments for create/call Func function")
 	invalidSplitArgs = fmt.Errorf("invalid split arguments")
 	invalidDefineFunc = fmt.Errorf("invalid define function")
+	invalidReturnParam = fmt.Errorf("return function expects exactly 1 argument")
+	returnOutsideFunction = fmt.Errorf("return is used outside a defined function")
+	maxRecursionDepthExceeded = fmt.Errorf("max recursion depth exceeded")
+	maxLoopIterationsExceeded = fmt.Errorf("max loop iterations exceeded")
+	invalidGetFieldParams = fmt.Errorf("getField function expects exactly 2 arguments")
+	fieldNotFound = fmt.Errorf("field not found")
+	unknownBuiltInFunc = fmt.Errorf("unknown built-in function")
 
 	predefinedPrefix = []string{builtInFn, builtInSmc}
 	globalVars = map[string]*expr.Decl{
@@ -336,6 +364,9 @@ var (
 			}
 			return interfaceToSlice(val)
 		},
+		mapType: func(val interface{}) (interface{}, error) {
+			return interfaceToMap(val)
+		},
 	}
 )
 
@@ -405,6 +436,30 @@ func convertToNative(val reflect.Value) (interface{}, error) {
 	return "", fmt.Errorf("unsupported value type %v", val.Type().String())
 }
 
+// interfaceToMap converts a map with string keys (or a CEL value wrapping one) into map[string]interface{}
+func interfaceToMap(val interface{}) (map[string]interface{}, error) {
+	if v, ok := val.(ref.Val); ok {
+		return interfaceToMap(v.Value())
+	}
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Map {
+		return nil, fmt.Errorf(invalidTypeMsg, mapType, v.Kind().String())
+	}
+	results := make(map[string]interface{})
+	for _, key := range v.MapKeys() {
+		k, err := InterfaceToString(key.Interface())
+		if err != nil {
+			return nil, err
+		}
+		elem := v.MapIndex(key).Interface()
+		if e, ok := elem.(ref.Val); ok {
+			elem = e.Value()
+		}
+		results[k] = elem
+	}
+	return results, nil
+}
+
 func interfaceToSlice(val interface{}) ([]interface{}, error) {
 	if reflect.TypeOf(val).Kind() != reflect.Slice && reflect.TypeOf(val).Kind() != reflect.Array {
 		return nil, fmt.Errorf("invalid list type, expect slice or array, got %v", reflect.TypeOf(val).Kind().String())
@@ -185,6 +185,26 @@ func gasSha3(kvm *KVM, contract *Contract, stack *Stack, mem *Memory, memorySize
 	return gas, nil
 }
 
+// gasCreate2 charges for the memory expansion and for hashing the init code, which the
+// address of the created contract is derived from.
+func gasCreate2(kvm *KVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	gas, err := memoryGasCost(mem, memorySize)
+	if err != nil {
+		return 0, err
+	}
+	wordGas, overflow := bigUint64(stack.Back(2))
+	if overflow {
+		return 0, errGasUintOverflow
+	}
+	if wordGas, overflow = common.SafeMul(toWordSize(wordGas), Sha3WordGas); overflow {
+		return 0, errGasUintOverflow
+	}
+	if gas, overflow = common.SafeAdd(gas, wordGas); overflow {
+		return 0, errGasUintOverflow
+	}
+	return gas, nil
+}
+
 // pureMemoryGascost is used by several operations, which aside from their
 // static cost have a dynamic cost which is solely based on the memory
 // expansion
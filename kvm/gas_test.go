@@ -16,7 +16,16 @@
 
 package kvm
 
-import "testing"
+import (
+	"math/big"
+	"testing"
+
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/types"
+)
 
 func TestMemoryGasCost(t *testing.T) {
 	tests := []struct {
@@ -37,3 +46,267 @@ func TestMemoryGasCost(t *testing.T) {
 		}
 	}
 }
+
+// TestMemoryExpansionGas runs memory touching instructions at large offsets and
+// checks that the quadratic memory expansion is charged before they execute.
+func TestMemoryExpansionGas(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+		contract = common.HexToAddress("0x0a")
+	)
+	memCost := func(size uint64) uint64 {
+		words := toWordSize(size)
+		return words*MemoryGas + words*words/QuadCoeffDiv
+	}
+	push3 := func(v uint32) []byte { return []byte{byte(PUSH3), byte(v >> 16), byte(v >> 8), byte(v)} }
+
+	tests := []struct {
+		name   string
+		code   []byte
+		offset uint32
+		gas    uint64 // gas besides the memory expansion
+	}{
+		{"MSTORE", append(append([]byte{byte(PUSH1), 1}, push3(0x10000)...), byte(MSTORE)), 0x10000, 3 * GasFastestStep},
+		{"MLOAD", append(push3(0x20000), byte(MLOAD)), 0x20000, 2 * GasFastestStep},
+		{"CALLDATACOPY", append(append([]byte{byte(PUSH1), 32, byte(PUSH1), 0}, push3(0x30000)...), byte(CALLDATACOPY)),
+			0x30000, 4*GasFastestStep + CopyGas},
+	}
+	for _, tt := range tests {
+		want := tt.gas + memCost(uint64(tt.offset)+32)
+		run := func(gas uint64) (uint64, error) {
+			st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+			st.SetCode(contract, tt.code)
+			vm := NewKVM(NewGenesisKVMContext(sender, gas), st, Config{})
+			_, left, err := vm.Call(AccountRef(sender), contract, nil, gas, big.NewInt(0))
+			return gas - left, err
+		}
+		used, err := run(1000000)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if used != want {
+			t.Errorf("%s: gas used mismatch: have %d, want %d", tt.name, used, want)
+		}
+		if _, err := run(want - 1); err != ErrOutOfGas {
+			t.Errorf("%s: short of gas: have error %v, want %v", tt.name, err, ErrOutOfGas)
+		}
+	}
+}
+
+// TestMemoryExpansionGasFork checks that dynamically priced instructions pay their
+// constant gas twice before the gas fix fork and once from it on.
+func TestMemoryExpansionGasFork(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+		contract = common.HexToAddress("0x0a")
+		chain    = &forkChain{config: &types.ChainConfig{GasFixBlock: big.NewInt(10)}}
+		code     = []byte{byte(PUSH1), 1, byte(PUSH1), 0, byte(MSTORE)}
+		fixed    = 3*GasFastestStep + MemoryGas // PUSH1, PUSH1, MSTORE and one word of memory
+	)
+	tests := []struct {
+		height int64
+		want   uint64
+	}{
+		{9, fixed + GasFastestStep},
+		{10, fixed},
+	}
+	for _, tt := range tests {
+		st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+		st.SetCode(contract, code)
+
+		ctx := NewGenesisKVMContext(sender, 1000000)
+		ctx.BlockHeight = big.NewInt(tt.height)
+		ctx.Chain = chain
+		_, left, err := NewKVM(ctx, st, Config{}).Call(AccountRef(sender), contract, nil, 1000000, big.NewInt(0))
+		if err != nil {
+			t.Fatalf("height %d: unexpected error: %v", tt.height, err)
+		}
+		if used := 1000000 - left; used != tt.want {
+			t.Errorf("height %d: gas used mismatch: have %d, want %d", tt.height, used, tt.want)
+		}
+	}
+}
+
+func TestGasExp(t *testing.T) {
+	tests := []struct {
+		exponent *big.Int
+		bytes    uint64
+	}{
+		{big.NewInt(0), 0},
+		{big.NewInt(1), 1},
+		{big.NewInt(255), 1},
+		{big.NewInt(256), 2},
+		{new(big.Int).Lsh(big.NewInt(1), 64), 9},
+		{new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)), 32},
+	}
+	for i, tt := range tests {
+		stack := newstack()
+		stack.push(tt.exponent)
+		stack.push(big.NewInt(2)) // base
+		gas, err := gasExp(nil, nil, stack, nil, 0)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if want := ExpGas + tt.bytes*ExpByte; gas != want {
+			t.Errorf("test %d: gas mismatch: have %d, want %d", i, gas, want)
+		}
+	}
+}
+
+// Tests that executing EXP charges ExpByte more gas for every byte its exponent grows.
+func TestExpGasScalesWithExponent(t *testing.T) {
+	var (
+		sender    = common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+		contract  = common.HexToAddress("0x0a")
+		exponents = [][]byte{{0x01}, {0x01, 0x00}, {0x01, 0x00, 0x00, 0x00}}
+		used      = make([]uint64, len(exponents))
+	)
+	for i, exponent := range exponents {
+		// PUSHn exponent, PUSH1 base, EXP
+		code := append([]byte{byte(PUSH1) + byte(len(exponent)-1)}, exponent...)
+		code = append(code, byte(PUSH1), 3, byte(EXP))
+
+		st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+		st.SetCode(contract, code)
+		_, left, err := NewKVM(NewGenesisKVMContext(sender, maximumGasUsed), st, Config{}).Call(AccountRef(sender), contract, nil, maximumGasUsed, big.NewInt(0))
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		used[i] = maximumGasUsed - left
+		if i == 0 {
+			continue
+		}
+		// Only the PUSH opcode and the EXP gas differ between the runs, pushes are priced equally.
+		if want := uint64(len(exponent)-len(exponents[i-1])) * ExpByte; used[i]-used[i-1] != want {
+			t.Errorf("test %d: gas increase mismatch: have %d, want %d", i, used[i]-used[i-1], want)
+		}
+	}
+}
+
+func TestGasSha3(t *testing.T) {
+	tests := []struct {
+		size  uint64
+		words uint64
+	}{
+		{0, 0}, {1, 1}, {32, 1}, {33, 2}, {320, 10},
+	}
+	for i, tt := range tests {
+		stack := newstack()
+		stack.push(new(big.Int).SetUint64(tt.size))
+		stack.push(big.NewInt(0)) // offset
+		// Memory is already expanded, so only the per word cost applies.
+		gas, err := gasSha3(nil, nil, stack, NewMemory(), 0)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if want := tt.words * Sha3WordGas; gas != want {
+			t.Errorf("test %d: gas mismatch: have %d, want %d", i, gas, want)
+		}
+	}
+	stack := newstack()
+	stack.push(new(big.Int).Lsh(big.NewInt(1), 64))
+	stack.push(big.NewInt(0))
+	if _, err := gasSha3(nil, nil, stack, NewMemory(), 0); err != errGasUintOverflow {
+		t.Errorf("oversized input error mismatch: have %v, want %v", err, errGasUintOverflow)
+	}
+}
+
+// Tests that hashing more memory costs more gas, and that running out of it
+// fails the call.
+func TestSha3GasScalesWithInput(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+		contract = common.HexToAddress("0x0a")
+	)
+	// PUSH2 size, PUSH1 0, SHA3
+	sha3Code := func(size uint16) []byte {
+		return []byte{byte(PUSH2), byte(size >> 8), byte(size), byte(PUSH1), 0, byte(SHA3)}
+	}
+	run := func(code []byte, gas uint64) (uint64, error) {
+		st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+		st.SetCode(contract, code)
+		_, left, err := NewKVM(NewGenesisKVMContext(sender, gas), st, Config{}).Call(AccountRef(sender), contract, nil, gas, big.NewInt(0))
+		return gas - left, err
+	}
+	small, err := run(sha3Code(32), maximumGasUsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := run(sha3Code(320), maximumGasUsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 9 more words to hash, plus expanding memory by them.
+	if min := small + 9*Sha3WordGas + 9*MemoryGas; large < min {
+		t.Errorf("large input gas too low: have %d, want at least %d", large, min)
+	}
+	if _, err := run(sha3Code(320), large-1); err != ErrOutOfGas {
+		t.Errorf("insufficient gas error mismatch: have %v, want %v", err, ErrOutOfGas)
+	}
+}
+
+func TestCallGas(t *testing.T) {
+	tests := []struct {
+		available, base uint64
+		requested       *big.Int
+		forwarded       uint64
+	}{
+		{6400, 0, big.NewInt(1000), 1000},                    // below the cap, forward as requested
+		{6400, 0, big.NewInt(100000), 6300},                  // all but one 64th
+		{6464, 64, big.NewInt(100000), 6300},                 // base cost is paid first
+		{6400, 0, new(big.Int).Lsh(big.NewInt(1), 70), 6300}, // requests beyond uint64 are capped too
+	}
+	for i, tt := range tests {
+		gas, err := callGas(tt.available, tt.base, tt.requested)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if gas != tt.forwarded {
+			t.Errorf("test %d: forwarded gas mismatch: have %d, want %d", i, gas, tt.forwarded)
+		}
+	}
+}
+
+// Tests the gas a called contract has available, which it reports back to the
+// caller to be stored.
+func TestCallForwardedGas(t *testing.T) {
+	var (
+		sender = common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+		caller = common.HexToAddress("0x0a")
+		callee = common.HexToAddress("0x0b")
+		// Returns the gas left after the GAS opcode.
+		calleeCode = []byte{byte(GAS), byte(PUSH1), 0, byte(MSTORE), byte(PUSH1), 32, byte(PUSH1), 0, byte(RETURN)}
+	)
+	// callerCode calls the callee with the requested gas and value, and stores the returned word.
+	callerCode := func(requested []byte, value byte) []byte {
+		code := []byte{
+			byte(PUSH1), 32, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, // retSize, retOffset, inSize, inOffset
+			byte(PUSH1), value, byte(PUSH1), 0x0b,
+			byte(PUSH1) + byte(len(requested)-1),
+		}
+		code = append(code, requested...)
+		return append(code, byte(CALL), byte(POP), byte(PUSH1), 0, byte(MLOAD), byte(PUSH1), 0, byte(SSTORE))
+	}
+	run := func(code []byte, gas uint64) uint64 {
+		st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+		st.SetCode(caller, code)
+		st.SetCode(callee, calleeCode)
+		st.AddBalance(caller, big.NewInt(1))
+		if _, _, err := NewKVM(NewGenesisKVMContext(sender, gas), st, Config{}).Call(AccountRef(sender), caller, nil, gas, big.NewInt(0)); err != nil {
+			t.Fatal(err)
+		}
+		return st.GetState(caller, common.Hash{}).Big().Uint64()
+	}
+	const gas = 100000
+	maxRequest := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+	// 7 pushes and CALL itself are paid, then expanding memory by a word, before forwarding all but one 64th.
+	available := gas - 7*GasFastestStep - CallGas - MemoryGas
+	if have, want := run(callerCode(maxRequest, 0), gas), available-available/64-GasQuickStep; have != want {
+		t.Errorf("capped call gas mismatch: have %d, want %d", have, want)
+	}
+	// A value transfer requesting no gas still receives the stipend.
+	if have, want := run(callerCode([]byte{0}, 1), gas), CallStipend-GasQuickStep; have != want {
+		t.Errorf("stipend gas mismatch: have %d, want %d", have, want)
+	}
+}
@@ -18,6 +18,9 @@ package kvm
 
 import (
 	"errors"
+	"math/big"
+
+	"github.com/kardiachain/go-kardia/types"
 )
 
 type (
@@ -360,6 +363,20 @@ func newKardiaInstructionSet() JumpTable {
 			maxStack:    maxStack(0, 1),
 			valid:       true,
 		},
+		CHAINID: {
+			execute:     opChainID,
+			constantGas: GasQuickStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+			valid:       true,
+		},
+		SELFBALANCE: {
+			execute:     opSelfBalance,
+			constantGas: GasFastStep,
+			minStack:    minStack(0, 1),
+			maxStack:    maxStack(0, 1),
+			valid:       true,
+		},
 		POP: {
 			execute:     opPop,
 			constantGas: GasQuickStep,
@@ -958,6 +975,17 @@ func newKardiaInstructionSet() JumpTable {
 			writes:      true,
 			returns:     true,
 		},
+		CREATE2: {
+			execute:     opCreate2,
+			constantGas: Create2Gas,
+			dynamicGas:  gasCreate2,
+			minStack:    minStack(4, 1),
+			maxStack:    maxStack(4, 1),
+			memorySize:  memoryCreate,
+			valid:       true,
+			writes:      true,
+			returns:     true,
+		},
 		CALL: {
 			execute:     opCall,
 			constantGas: CallGas,
@@ -1065,3 +1093,21 @@ func newKardiaInstructionSet() JumpTable {
 		},
 	}
 }
+
+// disableInactiveForks marks the instructions of forks that are not active at
+// height under config as invalid.
+func disableInactiveForks(jt *JumpTable, config *types.ChainConfig, height *big.Int) {
+	if config == nil {
+		return
+	}
+	if !config.IsConstantinople(height) {
+		jt[SHL].valid = false
+		jt[SHR].valid = false
+		jt[SAR].valid = false
+		jt[CREATE2].valid = false
+	}
+	if !config.IsIstanbul(height) {
+		jt[CHAINID].valid = false
+		jt[SELFBALANCE].valid = false
+	}
+}
@@ -18,6 +18,7 @@ package kvm
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/kardiachain/go-kardia/lib/common"
@@ -35,6 +36,37 @@ var (
 	errInvalidJump           = errors.New("kvm: invalid jump destination")
 )
 
+// ErrStackUnderflow is returned if an operation requires more stack items than
+// available.
+type ErrStackUnderflow struct {
+	stackLen int
+	required int
+}
+
+func (e *ErrStackUnderflow) Error() string {
+	return fmt.Sprintf("stack underflow (%d <=> %d)", e.stackLen, e.required)
+}
+
+// ErrStackOverflow is returned if an operation would grow the stack beyond its
+// limit.
+type ErrStackOverflow struct {
+	stackLen int
+	limit    int
+}
+
+func (e *ErrStackOverflow) Error() string {
+	return fmt.Sprintf("stack limit reached %d (%d)", e.stackLen, e.limit)
+}
+
+// ErrInvalidOpCode is returned if an opcode is unknown or not active.
+type ErrInvalidOpCode struct {
+	opcode OpCode
+}
+
+func (e *ErrInvalidOpCode) Error() string {
+	return fmt.Sprintf("invalid opcode 0x%x", int(e.opcode))
+}
+
 func opAdd(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
 	common.U256(y.Add(x, y))
@@ -429,7 +461,8 @@ func opCallValue(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack
 }
 
 func opCallDataLoad(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	stack.push(kvm.interpreter.intPool.get().SetBytes(getDataBig(contract.Input, stack.pop(), big32)))
+	x := stack.peek()
+	x.SetBytes(getDataBig(contract.Input, x, big32))
 	return nil, nil
 }
 
@@ -502,7 +535,8 @@ func opCodeCopy(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack
 
 func opExtCodeCopy(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	var (
-		addr       = common.BigToAddress(stack.pop())
+		a          = stack.pop()
+		addr       = common.BigToAddress(a)
 		memOffset  = stack.pop()
 		codeOffset = stack.pop()
 		length     = stack.pop()
@@ -510,12 +544,16 @@ func opExtCodeCopy(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, sta
 	codeCopy := getDataBig(kvm.StateDB.GetCode(addr), codeOffset, length)
 	memory.Set(memOffset.Uint64(), length.Uint64(), codeCopy)
 
-	kvm.interpreter.intPool.put(memOffset, codeOffset, length)
+	kvm.interpreter.intPool.put(a, memOffset, codeOffset, length)
 	return nil, nil
 }
 
 func opGasprice(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	stack.push(kvm.interpreter.intPool.get().Set(kvm.GasPrice))
+	price := kvm.interpreter.intPool.get().Set(kvm.GasPrice)
+	if kvm.GasFeeCap != nil && kvm.GasTipCap != nil {
+		price.Set(types.EffectiveGasPrice(kvm.BaseFee, kvm.GasTipCap, kvm.GasFeeCap))
+	}
+	stack.push(price)
 	return nil, nil
 }
 
@@ -533,7 +571,11 @@ func opBlockhash(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack
 }
 
 func opCoinbase(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	stack.push(kvm.interpreter.intPool.get().SetBytes(kvm.Chain.Config().Address.Bytes()))
+	coinbase := kvm.interpreter.intPool.get().SetUint64(0)
+	if config := kvm.chainConfig(); config != nil && config.BaseAccount != nil {
+		coinbase.SetBytes(config.Address.Bytes())
+	}
+	stack.push(coinbase)
 	return nil, nil
 }
 
@@ -552,6 +594,21 @@ func opGasLimit(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack
 	return nil, nil
 }
 
+func opChainID(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	chainID := kvm.interpreter.intPool.get().SetUint64(0)
+	if config := kvm.chainConfig(); config != nil && config.ChainID != nil {
+		chainID.Set(config.ChainID)
+	}
+	stack.push(chainID)
+	return nil, nil
+}
+
+// opSelfBalance pushes the balance of the executing contract, a cheaper BALANCE of ADDRESS.
+func opSelfBalance(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	stack.push(kvm.interpreter.intPool.get().Set(kvm.StateDB.GetBalance(contract.Address())))
+	return nil, nil
+}
+
 func opPop(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	kvm.interpreter.intPool.put(stack.pop())
 	return nil, nil
@@ -576,9 +633,10 @@ func opMstore(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *S
 }
 
 func opMstore8(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	off, val := stack.pop().Int64(), stack.pop().Int64()
-	memory.store[off] = byte(val & 0xff)
+	off, val := stack.pop(), stack.pop()
+	memory.store[off.Int64()] = byte(val.Int64() & 0xff)
 
+	kvm.interpreter.intPool.put(off, val)
 	return nil, nil
 }
 
@@ -590,11 +648,10 @@ func opSload(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *St
 }
 
 func opSstore(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
-	loc := common.BigToHash(stack.pop())
-	val := stack.pop()
-	kvm.StateDB.SetState(contract.Address(), loc, common.BigToHash(val))
+	loc, val := stack.pop(), stack.pop()
+	kvm.StateDB.SetState(contract.Address(), common.BigToHash(loc), common.BigToHash(val))
 
-	kvm.interpreter.intPool.put(val)
+	kvm.interpreter.intPool.put(loc, val)
 	return nil, nil
 }
 
@@ -672,6 +729,34 @@ func opCreate(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *S
 	return nil, nil
 }
 
+func opCreate2(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	var (
+		endowment    = stack.pop()
+		offset, size = stack.pop(), stack.pop()
+		salt         = stack.pop()
+		input        = memory.Get(offset.Int64(), size.Int64())
+		gas          = contract.Gas
+	)
+	// Keep 1/64 of the gas, so the caller can still handle a failed creation.
+	gas -= gas / 64
+	contract.UseGas(gas)
+	res, addr, returnGas, suberr := kvm.Create2(contract, input, gas, endowment, salt)
+	// Push item on the stack based on the returned error.
+	if suberr != nil {
+		stack.push(kvm.interpreter.intPool.getZero())
+	} else {
+		stack.push(kvm.interpreter.intPool.get().SetBytes(addr.Bytes()))
+	}
+
+	contract.Gas += returnGas
+	kvm.interpreter.intPool.put(endowment, offset, size, salt)
+
+	if suberr == errExecutionReverted {
+		return res, nil
+	}
+	return nil, nil
+}
+
 func opCall(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	// Pop gas. The actual gas in in kvm.callGasTemp.
 	kvm.interpreter.intPool.put(stack.pop())
@@ -800,10 +885,12 @@ func opStop(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Sta
 }
 
 func opSuicide(pc *uint64, kvm *KVM, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	beneficiary := stack.pop()
 	balance := kvm.StateDB.GetBalance(contract.Address())
-	kvm.StateDB.AddBalance(common.BigToAddress(stack.pop()), balance)
+	kvm.StateDB.AddBalance(common.BigToAddress(beneficiary), balance)
 
 	kvm.StateDB.Suicide(contract.Address())
+	kvm.interpreter.intPool.put(beneficiary)
 	return nil, nil
 }
 
@@ -869,7 +956,9 @@ func makeLog(size int) executionFunc {
 		topics := make([]common.Hash, size)
 		mStart, mSize := stack.pop(), stack.pop()
 		for i := 0; i < size; i++ {
-			topics[i] = common.BigToHash(stack.pop())
+			topic := stack.pop()
+			topics[i] = common.BigToHash(topic)
+			kvm.interpreter.intPool.put(topic)
 		}
 
 		d := memory.Get(mStart.Int64(), mSize.Int64())
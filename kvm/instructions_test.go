@@ -238,6 +238,31 @@ func TestSLT(t *testing.T) {
 	testTwoOperandOp(t, tests, opSlt, "slt")
 }
 
+func TestGasprice(t *testing.T) {
+	tests := []struct {
+		ctx  Context
+		want int64
+	}{
+		{Context{GasPrice: big.NewInt(7)}, 7},
+		{Context{GasPrice: big.NewInt(2), GasFeeCap: big.NewInt(50), GasTipCap: big.NewInt(2)}, 2},
+		{Context{GasPrice: big.NewInt(2), GasFeeCap: big.NewInt(50), GasTipCap: big.NewInt(2), BaseFee: big.NewInt(10)}, 12},
+		{Context{GasPrice: big.NewInt(2), GasFeeCap: big.NewInt(50), GasTipCap: big.NewInt(2), BaseFee: big.NewInt(49)}, 50},
+	}
+	for i, tt := range tests {
+		var (
+			env   = NewKVM(tt.ctx, nil, Config{})
+			stack = newstack()
+			pc    = uint64(0)
+		)
+		env.interpreter.intPool = poolOfIntPools.get()
+		opGasprice(&pc, env, nil, nil, stack)
+		if have := stack.pop(); have.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("test %d: gas price mismatch: have %v, want %d", i, have, tt.want)
+		}
+		poolOfIntPools.put(env.interpreter.intPool)
+	}
+}
+
 // getResult is a convenience function to generate the expected values
 func getResult(args []*twoOperandParams, opFn executionFunc) []TwoOperandTestcase {
 	var (
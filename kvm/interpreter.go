@@ -17,7 +17,6 @@
 package kvm
 
 import (
-	"fmt"
 	"hash"
 	"sync/atomic"
 
@@ -37,6 +36,23 @@ type Config struct {
 
 	// IsZeroFee is true then sender will be refunded all gas spent for a transaction
 	IsZeroFee bool
+
+	// VerifyIntPool panics as soon as an instruction leaks or doubly returns
+	// an int of the interpreter's int pool. It is meant for tests only.
+	VerifyIntPool bool
+
+	// Debug enables the Tracer
+	Debug bool
+	// Tracer is the op code logger, it is only used when Debug is true
+	Tracer Tracer
+
+	// MaxCallDepth limits the depth of nested calls and creations, calls
+	// beyond it fail with ErrDepth. Zero means CallCreateDepth.
+	MaxCallDepth uint64
+
+	// Precompiles holds chain-specific precompiled contracts keyed by address.
+	// They are consulted before the default set and the contract byte code.
+	Precompiles map[common.Address]PrecompiledContract
 }
 
 // keccakState wraps sha3.state. In addition to the usual hash methods, it also supports
@@ -62,19 +78,27 @@ type Interpreter struct {
 
 	readOnly   bool   // Whether to throw on stateful modifications
 	returnData []byte // Last CALL's return data for subsequent reuse
+
+	chargeConstantOnce bool // Whether dynamically priced instructions pay their constant gas only once
 }
 
 // NewInterpreter returns a new instance of the Interpreter.
 func NewInterpreter(kvm *KVM, cfg Config) *Interpreter {
 	// We use the STOP instruction whether to see
 	// the jump table was initialised. If it was not
-	// we'll set the default jump table.
+	// we'll set the default jump table, restricted to
+	// the forks active at the current block.
 	if !cfg.JumpTable[STOP].valid {
-		cfg.JumpTable = newKardiaInstructionSet()
+		jt := newKardiaInstructionSet()
+		disableInactiveForks(&jt, kvm.chainConfig(), kvm.BlockHeight)
+		cfg.JumpTable = jt
 	}
+	// Before the gas fix, dynamically priced instructions paid their constant gas twice
+	config := kvm.chainConfig()
 	return &Interpreter{
-		kvm: kvm,
-		cfg: cfg,
+		kvm:                kvm,
+		cfg:                cfg,
+		chargeConstantOnce: config == nil || config.IsGasFix(kvm.BlockHeight),
 	}
 }
 
@@ -122,13 +146,11 @@ func (in *Interpreter) Run(contract *Contract, input []byte, readOnly bool) (ret
 		// to be uint256. Practically much less so feasible.
 		pc   = uint64(0) // program counter
 		cost uint64
-		/* TODO(huny@): Add tracer later
 		// copies used by tracer
 		pcCopy  uint64 // needed for the deferred Tracer
 		gasCopy uint64 // for Tracer to log gas remaining before execution
 		logged  bool   // deferred Tracer should ignore already logged steps
-		*/
-		res []byte // result of the opcode execution function
+		res     []byte // result of the opcode execution function
 
 	)
 	contract.Input = input
@@ -136,7 +158,6 @@ func (in *Interpreter) Run(contract *Contract, input []byte, readOnly bool) (ret
 	// Reclaim the stack as an int pool when the execution stops
 	defer func() { in.intPool.put(stack.data...) }()
 
-	/* TODO(huny@): Add tracer later
 	if in.cfg.Debug {
 		defer func() {
 			if err != nil {
@@ -148,31 +169,28 @@ func (in *Interpreter) Run(contract *Contract, input []byte, readOnly bool) (ret
 			}
 		}()
 	}
-	*/
 
 	// The Interpreter main run loop (contextual). This loop runs until either an
 	// explicit STOP, RETURN or SELFDESTRUCT is executed, an error occurred during
 	// the execution of one of the operations or until the done flag is set by the
 	// parent context.
 	for atomic.LoadInt32(&in.kvm.abort) == 0 {
-		/* TODO(huny@): Add tracer later
 		if in.cfg.Debug {
 			// Capture pre-execution values for tracing.
 			logged, pcCopy, gasCopy = false, pc, contract.Gas
 		}
-		*/
 		// Get the operation from the jump table and validate the stack to ensure there are
 		// enough stack items available to perform the operation.
 		op = contract.GetOp(pc)
 		operation := in.cfg.JumpTable[op]
 		if !operation.valid {
-			return nil, fmt.Errorf("invalid opcode 0x%x", int(op))
+			return nil, &ErrInvalidOpCode{opcode: op}
 		}
 		// Validate stack
 		if sLen := stack.len(); sLen < operation.minStack {
-			return nil, fmt.Errorf("stack underflow (%d <=> %d)", sLen, operation.minStack)
+			return nil, &ErrStackUnderflow{stackLen: sLen, required: operation.minStack}
 		} else if sLen > operation.maxStack {
-			return nil, fmt.Errorf("stack limit reached %d (%d)", sLen, operation.maxStack)
+			return nil, &ErrStackOverflow{stackLen: sLen, limit: operation.maxStack}
 		}
 		// If the operation is valid, enforce and write restrictions
 		if in.readOnly {
@@ -215,21 +233,31 @@ func (in *Interpreter) Run(contract *Contract, input []byte, readOnly bool) (ret
 			var dynamicCost uint64
 			dynamicCost, err = operation.dynamicGas(in.kvm, contract, stack, mem, memorySize)
 			cost += dynamicCost
-			if err != nil || !contract.UseGas(cost) {
+			charge := cost
+			if in.chargeConstantOnce {
+				charge = dynamicCost
+			}
+			if err != nil || !contract.UseGas(charge) {
 				return nil, ErrOutOfGas
 			}
 		}
 		if memorySize > 0 {
 			mem.Resize(memorySize)
 		}
-		/* TODO(huny@): Add tracer later
 		if in.cfg.Debug {
 			in.cfg.Tracer.CaptureState(in.kvm, pc, op, gasCopy, cost, mem, stack, contract, in.kvm.depth, err)
 			logged = true
 		}
-		*/
 		// execute the operation
+		outstanding, depth := in.intPool.outstanding(), stack.len()
 		res, err = operation.execute(&pc, in.kvm, contract, mem, stack)
+		if in.cfg.VerifyIntPool {
+			if err != nil {
+				in.intPool.forgive(outstanding, stack.len()-depth)
+			} else {
+				in.intPool.verify(op, outstanding, stack.len()-depth)
+			}
+		}
 
 		// if the operation clears the return data (e.g. it has returning data)
 		// set the last return to the result of the operation.
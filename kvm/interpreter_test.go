@@ -0,0 +1,176 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package kvm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/log"
+)
+
+// runCode calls code deployed at a fresh account and returns the result of the call.
+func runCode(t *testing.T, code []byte) ([]byte, error) {
+	return runCodeWithConfig(t, code, Config{})
+}
+
+// runCodeWithConfig is runCode using the given interpreter configuration.
+func runCodeWithConfig(t *testing.T, code []byte, cfg Config) ([]byte, error) {
+	sender := common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+	contract := common.HexToAddress("0x0a")
+	st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+	st.SetCode(contract, code)
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("code %x panicked: %v", code, r)
+		}
+	}()
+	ret, _, err := NewKVM(NewGenesisKVMContext(sender, maximumGasUsed), st, cfg).Call(AccountRef(sender), contract, nil, maximumGasUsed, big.NewInt(0))
+	return ret, err
+}
+
+func TestMalformedCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code []byte
+		err  interface{}
+	}{
+		{"empty stack", []byte{byte(ADD)}, &ErrStackUnderflow{}},
+		{"short stack", []byte{byte(PUSH1), 1, byte(ADD)}, &ErrStackUnderflow{}},
+		{"short call", []byte{byte(PUSH1), 0, byte(PUSH1), 0, byte(CALL)}, &ErrStackUnderflow{}},
+		{"deep swap", []byte{byte(PUSH1), 1, byte(SWAP2)}, &ErrStackUnderflow{}},
+		{"unassigned opcode", []byte{byte(PUSH1), 1, 0x0c}, &ErrInvalidOpCode{}},
+		{"designated invalid", []byte{0xfe}, &ErrInvalidOpCode{}},
+		// An endless loop pushing a word each iteration.
+		{"overflow", []byte{byte(JUMPDEST), byte(PUSH1), 0, byte(PUSH1), 0, byte(JUMP)}, &ErrStackOverflow{}},
+	}
+	for _, tt := range tests {
+		_, err := runCode(t, tt.code)
+		switch tt.err.(type) {
+		case *ErrStackUnderflow:
+			if _, ok := err.(*ErrStackUnderflow); !ok {
+				t.Errorf("%s: expected stack underflow, got %v", tt.name, err)
+			}
+		case *ErrStackOverflow:
+			if _, ok := err.(*ErrStackOverflow); !ok {
+				t.Errorf("%s: expected stack overflow, got %v", tt.name, err)
+			}
+		case *ErrInvalidOpCode:
+			if _, ok := err.(*ErrInvalidOpCode); !ok {
+				t.Errorf("%s: expected invalid opcode, got %v", tt.name, err)
+			}
+		}
+	}
+}
+
+func TestTruncatedPush(t *testing.T) {
+	// Missing immediate bytes of a push at the end of the code read as zero.
+	ret, err := runCode(t, []byte{byte(PUSH1), 0, byte(MLOAD), byte(PUSH2), 0x01})
+	if err != nil || len(ret) != 0 {
+		t.Fatalf("truncated push: unexpected result %x, %v", ret, err)
+	}
+}
+
+// Tests that every opcode run on an empty stack either succeeds or fails with
+// an error, but never panics.
+func TestOpcodesOnEmptyStack(t *testing.T) {
+	for op := 0; op < 256; op++ {
+		runCode(t, []byte{byte(op)})
+	}
+}
+
+// Tests that every opcode returns the ints it pops to the int pool or pushes
+// them again.
+func TestIntPoolBalance(t *testing.T) {
+	table := newKardiaInstructionSet()
+	for op := 0; op < 256; op++ {
+		if !table[op].valid {
+			continue
+		}
+		var code []byte
+		for i := 0; i < table[op].minStack; i++ {
+			code = append(code, byte(PUSH1), 1)
+		}
+		runCodeWithConfig(t, append(code, byte(op), byte(STOP)), Config{VerifyIntPool: true})
+	}
+
+	// Sum 1..10 into storage, hashing the memory and calling the identity
+	// precompile on every iteration.
+	loop := []byte{
+		byte(PUSH1), 10, // counter
+		byte(JUMPDEST),
+		byte(DUP1), byte(PUSH1), 0, byte(SLOAD), byte(ADD), byte(PUSH1), 0, byte(SSTORE),
+		byte(DUP1), byte(PUSH1), 0, byte(MSTORE),
+		byte(PUSH1), 32, byte(PUSH1), 0, byte(SHA3), byte(POP),
+		byte(PUSH1), 32, byte(PUSH1), 32, byte(PUSH1), 32, byte(PUSH1), 0, byte(PUSH1), 4, byte(GAS), byte(STATICCALL), byte(POP),
+		byte(PUSH1), 1, byte(SWAP1), byte(SUB),
+		byte(DUP1), byte(PUSH1), 2, byte(JUMPI),
+		byte(PUSH1), 0, byte(SLOAD), byte(PUSH1), 0, byte(MSTORE), byte(PUSH1), 32, byte(PUSH1), 0, byte(RETURN),
+	}
+	ret, err := runCodeWithConfig(t, loop, Config{VerifyIntPool: true})
+	if err != nil {
+		t.Fatalf("loop failed: %v", err)
+	}
+	if have := new(big.Int).SetBytes(ret); have.Cmp(big.NewInt(55)) != 0 {
+		t.Errorf("loop result mismatch: have %v, want 55", have)
+	}
+}
+
+func TestIntPoolVerify(t *testing.T) {
+	p := newIntPool()
+	p.verify(ADD, p.outstanding(), 0)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("leaked int not detected")
+		}
+	}()
+	outstanding := p.outstanding()
+	p.get()
+	p.verify(POP, outstanding, 0)
+}
+
+// Tests that a contract recursing into itself fails gracefully once it hits the
+// configured call depth limit, with every frame up to the limit executed.
+func TestCallDepthLimit(t *testing.T) {
+	sender := common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+	contract := common.HexToAddress("0x0a")
+	// Increments storage slot 0 and calls itself with all remaining gas.
+	code := []byte{
+		byte(PUSH1), 0, byte(SLOAD), byte(PUSH1), 1, byte(ADD), byte(PUSH1), 0, byte(SSTORE),
+		byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0,
+		byte(ADDRESS), byte(GAS), byte(CALL),
+		byte(POP), byte(STOP),
+	}
+	for _, limit := range []uint64{10, 20} {
+		st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+		st.SetCode(contract, code)
+
+		_, _, err := NewKVM(NewGenesisKVMContext(sender, maximumGasUsed), st, Config{MaxCallDepth: limit}).Call(AccountRef(sender), contract, nil, maximumGasUsed, big.NewInt(0))
+		if err != nil {
+			t.Fatalf("limit %d: recursion did not fail gracefully: %v", limit, err)
+		}
+		if frames := st.GetState(contract, common.Hash{}).Big().Uint64(); frames != limit+1 {
+			t.Errorf("limit %d: executed frames mismatch: have %d, want %d", limit, frames, limit+1)
+		}
+	}
+}
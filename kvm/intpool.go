@@ -17,6 +17,7 @@
 package kvm
 
 import (
+	"fmt"
 	"math/big"
 	"sync"
 )
@@ -27,6 +28,10 @@ const poolLimit = 256
 // can be reused for all big.Int operations.
 type intPool struct {
 	pool *Stack
+
+	// gets and puts count the ints handed out and returned, they are only
+	// used to verify the pool usage of the instructions.
+	gets, puts int
 }
 
 func newIntPool() *intPool {
@@ -36,6 +41,7 @@ func newIntPool() *intPool {
 // get retrieves a big int from the pool, allocating one if the pool is empty.
 // Note, the returned int's value is arbitrary and will not be zeroed!
 func (p *intPool) get() *big.Int {
+	p.gets++
 	if p.pool.len() > 0 {
 		return p.pool.pop()
 	}
@@ -45,6 +51,7 @@ func (p *intPool) get() *big.Int {
 // getZero retrieves a big int from the pool, setting it to zero or allocating
 // a new one if the pool is empty.
 func (p *intPool) getZero() *big.Int {
+	p.gets++
 	if p.pool.len() > 0 {
 		return p.pool.pop().SetUint64(0)
 	}
@@ -54,6 +61,7 @@ func (p *intPool) getZero() *big.Int {
 // put returns an allocated big int to the pool to be later reused by get calls.
 // Note, the values as saved as is; put() & get() do not modify the int values.
 func (p *intPool) put(is ...*big.Int) {
+	p.puts += len(is)
 	if len(p.pool.data) > poolLimit {
 		return
 	}
@@ -64,6 +72,27 @@ func (p *intPool) put(is ...*big.Int) {
 	}
 }
 
+// outstanding returns the number of ints handed out but not returned yet.
+func (p *intPool) outstanding() int {
+	return p.gets - p.puts
+}
+
+// verify checks that an instruction which grew the stack by stackDelta items
+// took exactly that many ints out of the pool since it had outstanding ints
+// handed out. Every int popped must either be put back or pushed again, so
+// any difference is a leaked or doubly returned int.
+func (p *intPool) verify(op OpCode, outstanding, stackDelta int) {
+	if have := p.outstanding() - outstanding; have != stackDelta {
+		panic(fmt.Sprintf("intPool imbalance after %v: %d ints outstanding, stack grew by %d", op, have, stackDelta))
+	}
+}
+
+// forgive drops the pool usage of an instruction which failed. Its frame
+// is aborted and the stack is reclaimed, so its balance isn't meaningful.
+func (p *intPool) forgive(outstanding, stackDelta int) {
+	p.puts += p.outstanding() - outstanding - stackDelta
+}
+
 // The intPool pool's default capacity
 const poolDefaultCap = 25
 
@@ -25,7 +25,9 @@ import (
 	"math/big"
 
 	"sync/atomic"
+	"time"
 
+	"github.com/kardiachain/go-kardia/lib/abi"
 	"github.com/kardiachain/go-kardia/lib/common"
 	"github.com/kardiachain/go-kardia/lib/crypto"
 	"github.com/kardiachain/go-kardia/types"
@@ -48,8 +50,7 @@ type (
 // run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
 func run(kvm *KVM, contract *Contract, input []byte, readOnly bool) ([]byte, error) {
 	if contract.CodeAddr != nil {
-		precompiles := PrecompiledContractsV0
-		if p := precompiles[*contract.CodeAddr]; p != nil {
+		if p := kvm.precompile(*contract.CodeAddr); p != nil {
 			return RunPrecompiledContract(p, input, contract, kvm.Context, kvm.StateDB)
 		}
 	}
@@ -68,13 +69,16 @@ type Context struct {
 	GetHash GetHashFunc
 
 	// Message information
-	Origin   common.Address // Provides information for ORIGIN
-	GasPrice *big.Int       // Provides information for GASPRICE
+	Origin    common.Address // Provides information for ORIGIN
+	GasPrice  *big.Int       // Provides information for GASPRICE
+	GasFeeCap *big.Int       // Fee cap of a dynamic fee message, GASPRICE is derived from it when set
+	GasTipCap *big.Int       // Tip cap of a dynamic fee message, GASPRICE is derived from it when set
 
 	// Block information
 	GasLimit    uint64         // Provides information for GASLIMIT
 	BlockHeight *big.Int       // Provides information for HEIGHT
 	Time        *big.Int       // Provides information for TIME
+	BaseFee     *big.Int       // Base fee per gas, nil when the block carries none
 	Chain  		base.BaseBlockChain
 }
 
@@ -138,6 +142,33 @@ func (kvm *KVM) IsZeroFee() bool {
 	return kvm.vmConfig.IsZeroFee
 }
 
+// chainConfig returns the configuration of the chain the KVM runs on, or nil
+// if it runs detached from a chain.
+func (kvm *KVM) chainConfig() *types.ChainConfig {
+	if kvm.Chain == nil {
+		return nil
+	}
+	return kvm.Chain.Config()
+}
+
+// maxCallDepth returns the configured call depth limit, defaulting to
+// CallCreateDepth.
+func (kvm *KVM) maxCallDepth() int {
+	if kvm.vmConfig.MaxCallDepth > 0 {
+		return int(kvm.vmConfig.MaxCallDepth)
+	}
+	return int(CallCreateDepth)
+}
+
+// precompile returns the precompiled contract at addr, preferring the ones
+// registered on the config over the default set, or nil if there is none.
+func (kvm *KVM) precompile(addr common.Address) PrecompiledContract {
+	if p := kvm.vmConfig.Precompiles[addr]; p != nil {
+		return p
+	}
+	return PrecompiledContractsV0[addr]
+}
+
 // Call executes the contract associated with the addr with the given input as
 // parameters. It also handles any necessary value transfer required and takes
 // the necessary steps to create accounts and reverses the state in case of an
@@ -148,7 +179,7 @@ func (kvm *KVM) Call(caller base.ContractRef, addr common.Address, input []byte,
 	}
 
 	// Fail if we're trying to execute above the call depth limit
-	if kvm.depth > int(CallCreateDepth) {
+	if kvm.depth > kvm.maxCallDepth() {
 		return nil, gas, ErrDepth
 	}
 	// Fail if we're trying to transfer more than the available balance
@@ -161,15 +192,12 @@ func (kvm *KVM) Call(caller base.ContractRef, addr common.Address, input []byte,
 		snapshot = kvm.GetStateDB().Snapshot()
 	)
 	if !kvm.GetStateDB().Exist(addr) {
-		precompiles := PrecompiledContractsV0
-		if precompiles[addr] == nil && value.Sign() == 0 {
-			/* TODO(huny@): Add tracer later
-			// Calling a non existing account, don't do antything, but ping the tracer
+		if kvm.precompile(addr) == nil && value.Sign() == 0 {
+			// Calling a non existing account, don't do anything, but ping the tracer
 			if kvm.vmConfig.Debug && kvm.depth == 0 {
 				kvm.vmConfig.Tracer.CaptureStart(caller.Address(), addr, false, input, gas, value)
 				kvm.vmConfig.Tracer.CaptureEnd(ret, 0, 0, nil)
 			}
-			*/
 			return nil, gas, nil
 		}
 		kvm.GetStateDB().CreateAccount(addr)
@@ -181,7 +209,6 @@ func (kvm *KVM) Call(caller base.ContractRef, addr common.Address, input []byte,
 	contract := NewContract(caller, to, value, gas)
 	contract.SetCallCode(&addr, kvm.GetStateDB().GetCodeHash(addr), kvm.GetStateDB().GetCode(addr))
 
-	/* TODO(huny@): Add tracer later
 	start := time.Now()
 
 	// Capture the tracer start/end events in debug mode
@@ -192,7 +219,6 @@ func (kvm *KVM) Call(caller base.ContractRef, addr common.Address, input []byte,
 			kvm.vmConfig.Tracer.CaptureEnd(ret, gas-contract.Gas, time.Since(start), err)
 		}()
 	}
-	*/
 	ret, err = run(kvm, contract, input, false)
 
 	// When an error was returned by the KVM or when setting the creation code
@@ -205,6 +231,9 @@ func (kvm *KVM) Call(caller base.ContractRef, addr common.Address, input []byte,
 		}
 		log.Error(fmt.Sprintf("%v: %v", err.Error(), string(ret)))
 	}
+	if err == errExecutionReverted && kvm.depth == 0 {
+		err = newRevertError(ret)
+	}
 	return ret, contract.Gas, err
 }
 
@@ -221,7 +250,7 @@ func (kvm *KVM) CallCode(caller base.ContractRef, addr common.Address, input []b
 	}
 
 	// Fail if we're trying to execute above the call depth limit
-	if kvm.depth > int(CallCreateDepth) {
+	if kvm.depth > kvm.maxCallDepth() {
 		return nil, gas, ErrDepth
 	}
 	// Fail if we're trying to transfer more than the available balance
@@ -259,7 +288,7 @@ func (kvm *KVM) DelegateCall(caller base.ContractRef, addr common.Address, input
 		return nil, gas, nil
 	}
 	// Fail if we're trying to execute above the call depth limit
-	if kvm.depth > int(CallCreateDepth) {
+	if kvm.depth > kvm.maxCallDepth() {
 		return nil, gas, ErrDepth
 	}
 
@@ -291,7 +320,7 @@ func (kvm *KVM) StaticCall(caller base.ContractRef, addr common.Address, input [
 		return nil, gas, nil
 	}
 	// Fail if we're trying to execute above the call depth limit
-	if kvm.depth > int(CallCreateDepth) {
+	if kvm.depth > kvm.maxCallDepth() {
 		return nil, gas, ErrDepth
 	}
 
@@ -320,6 +349,9 @@ func (kvm *KVM) StaticCall(caller base.ContractRef, addr common.Address, input [
 			contract.UseGas(contract.Gas)
 		}
 	}
+	if err == errExecutionReverted && kvm.depth == 0 {
+		err = newRevertError(ret)
+	}
 	return ret, contract.Gas, err
 }
 
@@ -339,7 +371,7 @@ func (kvm *KVM) createContract (contract *Contract, codeAndHash *codeAndHash) (r
 	contractAddress := contract.Address()
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
-	if kvm.depth > int(CallCreateDepth) {
+	if kvm.depth > kvm.maxCallDepth() {
 		return nil, ErrDepth
 	}
 	if !kvm.CanTransfer(kvm.StateDB, contract.caller.Address(), contract.Value()) {
@@ -370,16 +402,10 @@ func (kvm *KVM) createContract (contract *Contract, codeAndHash *codeAndHash) (r
 		return nil, fmt.Errorf("depth is not allowed when no recursion is enabled")
 	}
 
-	/* TODO(huny@): Adding tracer later
-	if kvm.vmConfig.Debug && kvm.depth == 0 {
-		kvm.vmConfig.Tracer.CaptureStart(caller.Address(), contractAddr, true, code, gas, value)
-	}
-
-	start := time.Now()
-	*/
 	ret, err = run(kvm, contract, nil, false)
 	if err != nil {
-		return nil, err
+		// ret holds the revert reason if the constructor reverted.
+		return ret, err
 	}
 
 	// if the contract creation ran successfully and no errors were returned
@@ -400,6 +426,11 @@ func (kvm *KVM) createContract (contract *Contract, codeAndHash *codeAndHash) (r
 func (kvm *KVM) create(caller base.ContractRef, codeAndHash *codeAndHash, gas uint64, value *big.Int, address common.Address) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
 	snapshot := kvm.GetStateDB().Snapshot()
 	contract := NewContract(caller, AccountRef(address), value, gas)
+
+	start := time.Now()
+	if kvm.vmConfig.Debug && kvm.depth == 0 {
+		kvm.vmConfig.Tracer.CaptureStart(caller.Address(), address, true, codeAndHash.code, gas, value)
+	}
 	ret, err = kvm.createContract(contract, codeAndHash)
 	// check whether the max code size has been exceeded
 	maxCodeSizeExceeded := len(ret) > MaxCodeSize
@@ -416,14 +447,49 @@ func (kvm *KVM) create(caller base.ContractRef, codeAndHash *codeAndHash, gas ui
 	if maxCodeSizeExceeded && err == nil {
 		err = errMaxCodeSizeExceeded
 	}
-	/* TODO(huny@): Add tracer later
+	// Surface the revert reason to the top level caller, nested creations keep errExecutionReverted
+	// since opCreate relies on it.
+	if err == errExecutionReverted && kvm.depth == 0 {
+		err = newRevertError(ret)
+	}
 	if kvm.vmConfig.Debug && kvm.depth == 0 {
 		kvm.vmConfig.Tracer.CaptureEnd(ret, gas-contract.Gas, time.Since(start), err)
 	}
-	*/
 	return ret, address, contract.Gas, err
 }
 
+// RevertError is returned when a top level call or contract creation is reverted, it carries the
+// data returned by the REVERT and the revert reason if the contract provided one.
+type RevertError struct {
+	reason string
+	data   []byte
+}
+
+func newRevertError(ret []byte) *RevertError {
+	reason, err := abi.UnpackRevert(ret)
+	if err != nil {
+		reason = ""
+	}
+	return &RevertError{reason: reason, data: common.CopyBytes(ret)}
+}
+
+// Reason returns the revert reason, empty if the contract did not provide one.
+func (e *RevertError) Reason() string {
+	return e.reason
+}
+
+// Data returns the raw data returned by the REVERT.
+func (e *RevertError) Data() []byte {
+	return e.data
+}
+
+func (e *RevertError) Error() string {
+	if e.reason == "" {
+		return errExecutionReverted.Error()
+	}
+	return fmt.Sprintf("%v: %v", errExecutionReverted, e.reason)
+}
+
 func (kvm *KVM) GetStateDB() base.StateDB {
 	return kvm.StateDB
 }
@@ -434,6 +500,15 @@ func (kvm *KVM) Create(caller base.ContractRef, code []byte, gas uint64, value *
 	return kvm.create(caller, &codeAndHash{code: code}, gas, value, contractAddr)
 }
 
+// Create2 creates a new contract using code as deployment code. Unlike Create, the contract
+// address is derived from keccak256(0xff ++ caller ++ salt ++ keccak256(code))[12:] instead
+// of the caller and its nonce, so it is known before deployment.
+func (kvm *KVM) Create2(caller base.ContractRef, code []byte, gas uint64, endowment *big.Int, salt *big.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
+	codeAndHash := &codeAndHash{code: code}
+	contractAddr = crypto.CreateAddress2(caller.Address(), common.BigToHash(salt), codeAndHash.Hash().Bytes())
+	return kvm.create(caller, codeAndHash, gas, endowment, contractAddr)
+}
+
 // CreateGenesisContract creates contractAddr with given contractAddr
 // Note: this function is only used when creating genesis contract
 func (kvm *KVM) CreateGenesisContract(caller base.ContractRef, contractAddr *common.Address, code []byte, gas uint64, value *big.Int) (ret []byte, newContractAddr common.Address, leftOverGas uint64, err error) {
@@ -445,8 +520,10 @@ func (kvm *KVM) CreateGenesisContract(caller base.ContractRef, contractAddr *com
 	contract := NewContract(caller, AccountRef(*contractAddr), big.NewInt(0), gas)
 	ret, err = kvm.createContract(contract, &codeAndHash{code: code})
 	if err != nil {
-		retStr := string(ret)
-		log.Error("err", "ret", retStr, "err", err)
+		if err == errExecutionReverted {
+			err = newRevertError(ret)
+		}
+		log.Error("fail to create genesis contract", "address", contractAddr.Hex(), "err", err)
 		return ret, *contractAddr, contract.Gas, err
 	}
 	kvm.StateDB.AddBalance(*contractAddr, value)
@@ -554,6 +631,40 @@ func InternalCreate(vm *KVM, to *common.Address, input []byte, value *big.Int) (
 	return vm.CreateGenesisContract(sender, to, input, maximumGasUsed, value)
 }
 
+// CallContract packs method and args with contractAbi, calls contract at given address from sender and unpacks
+// the result into out. out can be nil if the method's result is not needed.
+func CallContract(chain base.BaseBlockChain, statedb base.StateDB, from, to common.Address, contractAbi abi.ABI, method string, out interface{}, args ...interface{}) error {
+	input, err := contractAbi.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	result, err := InternalCall(newInternalKVM(from, chain, statedb), to, input, big.NewInt(0))
+	if err != nil {
+		return err
+	}
+	return unpackContractResult(contractAbi, method, out, result)
+}
+
+// StaticCallContract is the same as CallContract except that state modifications are not allowed.
+func StaticCallContract(chain base.BaseBlockChain, statedb base.StateDB, from, to common.Address, contractAbi abi.ABI, method string, out interface{}, args ...interface{}) error {
+	input, err := contractAbi.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	result, err := StaticCall(newInternalKVM(from, chain, statedb), to, input)
+	if err != nil {
+		return err
+	}
+	return unpackContractResult(contractAbi, method, out, result)
+}
+
+func unpackContractResult(contractAbi abi.ABI, method string, out interface{}, result []byte) error {
+	if out == nil {
+		return nil
+	}
+	return contractAbi.Unpack(out, method, result)
+}
+
 // EstimateGas estimates spent in order to
 func EstimateGas(vm *KVM, to common.Address, input []byte) (uint64, error){
 	// Create new call message
@@ -0,0 +1,224 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package kvm
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/kardiachain/go-kardia/kai/base"
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// revertingConstructor copies the payload appended after its 12 bytes of code into memory and reverts with it.
+// The payload is Error("revert reason") abi-encoded.
+var revertingConstructor = common.Hex2Bytes("6064600c60003960646000fd" +
+	"08c379a0" +
+	"0000000000000000000000000000000000000000000000000000000000000020" +
+	"000000000000000000000000000000000000000000000000000000000000000d" +
+	"72657665727420726561736f6e00000000000000000000000000000000000000")
+
+func TestCreateRevertReason(t *testing.T) {
+	sender := common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+	st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+	vm := newGenesisVM(sender, maximumGasUsed, st)
+
+	_, addr, _, err := vm.Create(AccountRef(sender), revertingConstructor, maximumGasUsed, big.NewInt(0))
+	revertErr, ok := err.(*RevertError)
+	if !ok {
+		t.Fatalf("expected RevertError, got %v", err)
+	}
+	if revertErr.Reason() != "revert reason" {
+		t.Errorf("expected reason %q, got %q", "revert reason", revertErr.Reason())
+	}
+	if revertErr.Error() != "kvm: execution reverted: revert reason" {
+		t.Errorf("unexpected error message %q", revertErr.Error())
+	}
+	if len(st.GetCode(addr)) != 0 {
+		t.Errorf("reverted contract must not be deployed")
+	}
+
+	genesisAddress := common.HexToAddress("0x0000000000000000000000000000000000000009")
+	_, _, _, err = InternalCreate(vm, &genesisAddress, revertingConstructor, big.NewInt(0))
+	if revertErr, ok = err.(*RevertError); !ok || revertErr.Reason() != "revert reason" {
+		t.Fatalf("expected revert reason from genesis contract creation, got %v", err)
+	}
+}
+
+func TestCallRevertReason(t *testing.T) {
+	sender := common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+	contract := common.HexToAddress("0x0b")
+	st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+	// As runtime code, the constructor reverts every call with its payload.
+	st.SetCode(contract, revertingConstructor)
+	vm := newGenesisVM(sender, maximumGasUsed, st)
+	payload := revertingConstructor[12:]
+
+	_, _, callErr := vm.Call(AccountRef(sender), contract, nil, maximumGasUsed, big.NewInt(0))
+	_, _, staticErr := vm.StaticCall(AccountRef(sender), contract, nil, maximumGasUsed)
+	for name, err := range map[string]error{"call": callErr, "static call": staticErr} {
+		revertErr, ok := err.(*RevertError)
+		if !ok {
+			t.Fatalf("%s: expected RevertError, got %v", name, err)
+		}
+		if revertErr.Reason() != "revert reason" {
+			t.Errorf("%s: expected reason %q, got %q", name, "revert reason", revertErr.Reason())
+		}
+		if !bytes.Equal(revertErr.Data(), payload) {
+			t.Errorf("%s: revert data mismatch: have %x, want %x", name, revertErr.Data(), payload)
+		}
+	}
+}
+
+func TestRevertErrorWithoutReason(t *testing.T) {
+	err := newRevertError(nil)
+	if err.Reason() != "" {
+		t.Errorf("expected empty reason, got %q", err.Reason())
+	}
+	if err.Error() != errExecutionReverted.Error() {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestCreate2(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+		contract = common.HexToAddress("0x0a")
+		salt     = big.NewInt(42)
+		initCode = []byte{byte(STOP)}
+	)
+	// The program stores the init code at memory 0, runs CREATE2 on it and returns the address.
+	code := []byte{
+		byte(PUSH1), initCode[0], byte(PUSH1), 0, byte(MSTORE8),
+		byte(PUSH1), byte(salt.Int64()), byte(PUSH1), byte(len(initCode)), byte(PUSH1), 0, byte(PUSH1), 0, byte(CREATE2),
+		byte(PUSH1), 0, byte(MSTORE), byte(PUSH1), 32, byte(PUSH1), 0, byte(RETURN),
+	}
+	st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+	st.SetCode(contract, code)
+	vm := newGenesisVM(sender, maximumGasUsed, st)
+
+	want := crypto.CreateAddress2(contract, common.BigToHash(salt), crypto.Keccak256(initCode))
+	ret, _, err := vm.Call(AccountRef(sender), contract, nil, maximumGasUsed, big.NewInt(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := common.BytesToAddress(ret); have != want {
+		t.Fatalf("created address mismatch: have %x, want %x", have, want)
+	}
+	if !st.Exist(want) {
+		t.Errorf("contract not created at %x", want)
+	}
+
+	// The address only depends on the salt and init code, so redeploying collides.
+	ret, _, err = vm.Call(AccountRef(sender), contract, nil, maximumGasUsed, big.NewInt(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := new(big.Int).SetBytes(ret); have.Sign() != 0 {
+		t.Errorf("colliding CREATE2 returned %x, want zero", have)
+	}
+}
+
+// forkChain is a chain only providing a chain config to the KVM.
+type forkChain struct {
+	base.BaseBlockChain
+	config *types.ChainConfig
+}
+
+func (c *forkChain) Config() *types.ChainConfig { return c.config }
+
+func TestForkActivation(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")
+		contract = common.HexToAddress("0x0a")
+		chain    = &forkChain{config: &types.ChainConfig{
+			ChainID:             big.NewInt(7),
+			ConstantinopleBlock: big.NewInt(10),
+			IstanbulBlock:       big.NewInt(20),
+		}}
+	)
+	// Both programs return the first word of memory after storing the result of the tested opcode in it.
+	returnTop := []byte{byte(PUSH1), 0, byte(MSTORE), byte(PUSH1), 32, byte(PUSH1), 0, byte(RETURN)}
+	shl := append([]byte{byte(PUSH1), 1, byte(PUSH1), 1, byte(SHL)}, returnTop...)
+	chainID := append([]byte{byte(CHAINID)}, returnTop...)
+	selfBalance := append([]byte{byte(SELFBALANCE)}, returnTop...)
+	create2 := append([]byte{byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(CREATE2)}, returnTop...)
+
+	tests := []struct {
+		code   []byte
+		height int64
+		want   int64 // -1 for an invalid opcode
+	}{
+		{shl, 9, -1},
+		{shl, 10, 2},
+		{create2, 9, -1},
+		{chainID, 10, -1},
+		{chainID, 19, -1},
+		{chainID, 20, 7},
+		{selfBalance, 19, -1},
+		{selfBalance, 20, 1000},
+	}
+	for i, tt := range tests {
+		st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+		st.SetCode(contract, tt.code)
+		st.AddBalance(contract, big.NewInt(1000))
+
+		ctx := NewGenesisKVMContext(sender, maximumGasUsed)
+		ctx.BlockHeight = big.NewInt(tt.height)
+		ctx.Chain = chain
+		ret, _, err := NewKVM(ctx, st, Config{}).Call(AccountRef(sender), contract, nil, maximumGasUsed, big.NewInt(0))
+		if tt.want < 0 {
+			if err == nil || !strings.Contains(err.Error(), "invalid opcode") {
+				t.Errorf("test %d: expected invalid opcode at height %d, got %v", i, tt.height, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: unexpected error at height %d: %v", i, tt.height, err)
+		}
+		if have := new(big.Int).SetBytes(ret); have.Int64() != tt.want {
+			t.Errorf("test %d: result mismatch at height %d: have %v, want %d", i, tt.height, have, tt.want)
+		}
+	}
+	// Forks without a scheduled height stay disabled.
+	for i, code := range [][]byte{shl, create2, chainID, selfBalance} {
+		st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+		st.SetCode(contract, code)
+
+		ctx := NewGenesisKVMContext(sender, maximumGasUsed)
+		ctx.BlockHeight = big.NewInt(100)
+		ctx.Chain = &forkChain{config: &types.ChainConfig{}}
+		if _, _, err := NewKVM(ctx, st, Config{}).Call(AccountRef(sender), contract, nil, maximumGasUsed, big.NewInt(0)); err == nil || !strings.Contains(err.Error(), "invalid opcode") {
+			t.Errorf("unscheduled fork %d: expected invalid opcode, got %v", i, err)
+		}
+	}
+	// Without a chain the KVM runs with all forks active.
+	st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+	st.SetCode(contract, chainID)
+	if ret, _, err := NewKVM(NewGenesisKVMContext(sender, maximumGasUsed), st, Config{}).Call(AccountRef(sender), contract, nil, maximumGasUsed, big.NewInt(0)); err != nil || new(big.Int).SetBytes(ret).Sign() != 0 {
+		t.Errorf("expected chain id 0 without a chain, got %x, %v", ret, err)
+	}
+}
@@ -19,6 +19,7 @@
 package kvm
 
 import (
+	"fmt"
 	"github.com/kardiachain/go-kardia/kai/base"
 	"github.com/kardiachain/go-kardia/kai/pos"
 	"github.com/kardiachain/go-kardia/kai/state"
@@ -33,11 +34,25 @@ func newGenesisVM(from common.Address, gasLimit uint64, st base.StateDB) *KVM {
 	return NewKVM(ctx, st, Config{})
 }
 
+// InitGenesisContract deploys a contract at address by running its creation code, then gives it value.
+// The zero address is used as the sender.
+func InitGenesisContract(st base.StateDB, gasLimit uint64, address common.Address, code []byte, value *big.Int) error {
+	if value == nil {
+		value = big.NewInt(0)
+	}
+	vm := newGenesisVM(common.Address{}, gasLimit, st)
+	_, _, _, err := InternalCreate(vm, &address, code, value)
+	return err
+}
+
 func InitGenesisConsensus(st *state.StateDB, gasLimit uint64, consensusInfo pos.ConsensusInfo) error {
 	var (
 		err error
 		masterAbi abi.ABI
 	)
+	if len(consensusInfo.Nodes.GenesisInfo) == 0 {
+		return fmt.Errorf("consensus genesis requires at least one node")
+	}
 	// get first node owner to be the sender
 	sender := consensusInfo.Nodes.GenesisInfo[0].Owner
 	// create master smart contract
@@ -65,6 +80,9 @@ func createMaster(gasLimit uint64, st *state.StateDB, master pos.MasterSmartCont
 		err error
 		input []byte
 	)
+	if err = validateMasterParams(maxValidators, maxViolatePercentageAllowed, consensusPeriod); err != nil {
+		return err
+	}
 	vm := newGenesisVM(sender, gasLimit, st)
 	if masterAbi, err = abi.JSON(strings.NewReader(master.ABI)); err != nil {
 		return err
@@ -79,6 +97,20 @@ func createMaster(gasLimit uint64, st *state.StateDB, master pos.MasterSmartCont
 	return err
 }
 
+// validateMasterParams checks Master's constructor params before it is deployed, the contract itself does not validate them.
+func validateMasterParams(maxValidators, maxViolatePercentageAllowed, consensusPeriod uint64) error {
+	if maxValidators == 0 {
+		return fmt.Errorf("invalid maxValidators:%v, it must be greater than 0", maxValidators)
+	}
+	if maxViolatePercentageAllowed > 100 {
+		return fmt.Errorf("invalid maxViolatePercentageAllowed:%v, it must not exceed 100", maxViolatePercentageAllowed)
+	}
+	if consensusPeriod == 0 {
+		return fmt.Errorf("invalid consensusPeriod:%v, it must be greater than 0", consensusPeriod)
+	}
+	return nil
+}
+
 func createGenesisNodes(gasLimit uint64, st *state.StateDB, nodes pos.Nodes, minimumStakes *big.Int, lockedPeriod uint64, masterAbi abi.ABI, masterAddress common.Address) error {
 	nodeAbi, err := abi.JSON(strings.NewReader(nodes.ABI))
 	if err != nil {
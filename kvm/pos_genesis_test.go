@@ -0,0 +1,76 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package kvm
+
+import (
+	"testing"
+
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/kai/pos"
+	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/log"
+)
+
+func TestInitGenesisConsensusInvalidParams(t *testing.T) {
+	masterAddress := common.HexToAddress("0x0000000000000000000000000000000000000009")
+	tests := []struct {
+		name                 string
+		maxValidators        uint64
+		maxViolatePercentage uint64
+		consensusPeriod      uint64
+		nodes                []pos.GenesisNodeInfo
+	}{
+		{"zero maxValidators", 0, 50, 20, nil},
+		{"percentage over 100", 10, 101, 20, nil},
+		{"zero consensusPeriod", 10, 50, 0, nil},
+		{"all invalid", 0, 200, 0, nil},
+		{"no genesis nodes", 10, 50, 20, []pos.GenesisNodeInfo{}},
+	}
+	for _, test := range tests {
+		nodes := test.nodes
+		if nodes == nil {
+			nodes = []pos.GenesisNodeInfo{{Owner: common.HexToAddress("0xc1fe56E3F58D3244F606306611a5d10c8333f1f6")}}
+		}
+		st, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+		err := InitGenesisConsensus(st, 16777216, pos.ConsensusInfo{
+			MaxValidators:               test.maxValidators,
+			MaxViolatePercentageAllowed: test.maxViolatePercentage,
+			ConsensusPeriodInBlock:      test.consensusPeriod,
+			Master:                      pos.MasterSmartContract{Address: masterAddress},
+			Nodes:                       pos.Nodes{GenesisInfo: nodes},
+		})
+		if err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+		// params must be rejected before Master is deployed.
+		if st.Exist(masterAddress) {
+			t.Errorf("%s: master must not be deployed", test.name)
+		}
+	}
+}
+
+func TestValidateMasterParams(t *testing.T) {
+	if err := validateMasterParams(10, 100, 20); err != nil {
+		t.Errorf("expected valid params, got %v", err)
+	}
+	if err := validateMasterParams(1, 0, 1); err != nil {
+		t.Errorf("expected valid params, got %v", err)
+	}
+}
@@ -129,6 +129,7 @@ const (
 	methodGetAvailableNodeIndex = "getAvailableNodeIndex"
 	methodGetAvailableNode = "getAvailableNode"
 	methodGetStakerInfo = "getStakerInfo"
+	methodGetTotalStakes = "getTotalStakes"
 	methodNewConsensusPeriod = "newConsensusPeriod"
 	methodGetLatestValidatorsInfo = "getLatestValidatorsInfo"
 	methodGetLatestValidatorByIndex = "getLatestValidatorByIndex"
@@ -162,6 +163,7 @@ type (
 		NodeId string `abi:"nodeId"`
 		NodeName string `abi:"nodeName"`
 		RewardPercentage uint16 `abi:"rewardPercentage"`
+		MinimumStakes *big.Int `abi:"minimumStakes"`
 		Balance *big.Int `abi:"balance"`
 	}
 	node struct {
@@ -267,7 +269,7 @@ func handleClaimReward(method *abi.Method, input []byte, contract *Contract, ctx
 	}
 
 	// get reward from previous block gasUsed + blockReward
-	blockReward, _ := big.NewInt(0).SetString(ctx.Chain.GetBlockReward().String(), 10)
+	blockReward, _ := big.NewInt(0).SetString(ctx.Chain.BlockRewardAt(n.BlockHeight).String(), 10)
 
 	if claimedBlock.Header().GasUsed > 0 {
 		blockReward = big.NewInt(0).Add(blockReward, big.NewInt(0).SetUint64(claimedBlock.Header().GasUsed))
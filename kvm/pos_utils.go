@@ -113,6 +113,15 @@ func NewConsensusPeriod(height uint64, bc base.BaseBlockChain, state *state.Stat
 
 // CollectValidatorSet collects new validators list based on current available nodes and start new consensus period
 func CollectValidatorSet(bc base.BaseBlockChain) (*types.ValidatorSet, error) {
+	st, err := bc.State()
+	if err != nil {
+		return nil, err
+	}
+	return CollectValidatorSetAt(bc, bc.CurrentHeader(), st)
+}
+
+// CollectValidatorSetAt collects the validators list from the given state after the block with the given header.
+func CollectValidatorSetAt(bc base.BaseBlockChain, header *types.Header, st base.StateDB) (*types.ValidatorSet, error) {
 	var (
 		err error
 		n nodeInfo
@@ -122,12 +131,8 @@ func CollectValidatorSet(bc base.BaseBlockChain) (*types.ValidatorSet, error) {
 		pubKey *ecdsa.PublicKey
 	)
 	masterAddress := bc.GetConsensusMasterSmartContract().Address
-	st, err := bc.State()
-	if err != nil {
-		return nil, err
-	}
 	sender := bc.Config().BaseAccount.Address
-	ctx := NewInternalKVMContext(sender, bc.CurrentHeader(), bc)
+	ctx := NewInternalKVMContext(sender, header, bc)
 	vm := NewKVM(ctx, st, Config{})
 
 	if masterAbi, err = abi.JSON(strings.NewReader(bc.GetConsensusMasterSmartContract().ABI)); err != nil {
@@ -173,6 +178,122 @@ func CollectValidatorSet(bc base.BaseBlockChain) (*types.ValidatorSet, error) {
 	return types.NewValidatorSet(validators, int64(startBlock), int64(endBlock)), nil
 }
 
+// ValidatorInfo is a validator of the latest consensus period stored in Master smart contract.
+type ValidatorInfo struct {
+	Node   common.Address
+	Owner  common.Address
+	Stakes *big.Int
+}
+
+// GetValidators returns the validators of the latest consensus period from Master smart contract in the given state.
+func GetValidators(bc base.BaseBlockChain, st base.StateDB) ([]ValidatorInfo, error) {
+	var (
+		err error
+		masterAbi abi.ABI
+		info latestValidatorsInfo
+	)
+	master := bc.GetConsensusMasterSmartContract()
+	if masterAbi, err = abi.JSON(strings.NewReader(master.ABI)); err != nil {
+		return nil, err
+	}
+	// validators are read by static calls only, so no sender account is required.
+	if err = StaticCallContract(bc, st, common.Address{}, master.Address, masterAbi, methodGetLatestValidatorsInfo, &info); err != nil {
+		return nil, err
+	}
+	validators := make([]ValidatorInfo, 0, info.TotalNodes)
+	for i := uint64(1); i <= info.TotalNodes; i++ {
+		var val validator
+		if err = StaticCallContract(bc, st, common.Address{}, master.Address, masterAbi, methodGetLatestValidatorByIndex, &val, i); err != nil {
+			return nil, err
+		}
+		validators = append(validators, ValidatorInfo{Node: val.Node, Owner: val.Owner, Stakes: val.Stakes})
+	}
+	return validators, nil
+}
+
+// NodeInfo is the information of a node stored in its Node smart contract together with its total stakes in Master smart contract.
+type NodeInfo struct {
+	Owner            common.Address
+	NodeId           string
+	NodeName         string
+	RewardPercentage uint16
+	Balance          *big.Int
+	TotalStakes      *big.Int
+}
+
+// GetNodeInfo returns information and total stakes of the node deployed at given address.
+func GetNodeInfo(bc base.BaseBlockChain, st base.StateDB, node common.Address) (*NodeInfo, error) {
+	var (
+		err error
+		nInfo *nodeInfo
+		totalStakes *big.Int
+	)
+	if nInfo, err = getNodeInfo(bc, st, common.Address{}, node); err != nil {
+		return nil, err
+	}
+	if totalStakes, err = getTotalStakes(bc, st, node); err != nil {
+		return nil, err
+	}
+	return &NodeInfo{
+		Owner:            nInfo.Owner,
+		NodeId:           nInfo.NodeId,
+		NodeName:         nInfo.NodeName,
+		RewardPercentage: nInfo.RewardPercentage,
+		Balance:          nInfo.Balance,
+		TotalStakes:      totalStakes,
+	}, nil
+}
+
+// WithdrawPreview is the result of withdrawing an amount of stakes from a node, computed without changing state.
+type WithdrawPreview struct {
+	Stakes       *big.Int
+	BelowMinimum bool
+}
+
+// PreviewWithdraw computes node's total stakes after withdrawing given amount and whether it would drop below
+// node's minimum stakes, in which case the node is no longer eligible to be an available node.
+func PreviewWithdraw(bc base.BaseBlockChain, st base.StateDB, node common.Address, amount *big.Int) (*WithdrawPreview, error) {
+	var (
+		err error
+		nInfo *nodeInfo
+		totalStakes *big.Int
+	)
+	if amount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid withdraw amount:%v", amount)
+	}
+	if nInfo, err = getNodeInfo(bc, st, common.Address{}, node); err != nil {
+		return nil, err
+	}
+	if totalStakes, err = getTotalStakes(bc, st, node); err != nil {
+		return nil, err
+	}
+	if totalStakes.Cmp(amount) < 0 {
+		return nil, fmt.Errorf("withdraw amount:%v exceeds node:%v stakes:%v", amount, node.Hex(), totalStakes)
+	}
+	stakes := new(big.Int).Sub(totalStakes, amount)
+	return &WithdrawPreview{
+		Stakes:       stakes,
+		BelowMinimum: stakes.Cmp(nInfo.MinimumStakes) < 0,
+	}, nil
+}
+
+// getTotalStakes returns total stakes of node stored in Master smart contract
+func getTotalStakes(bc base.BaseBlockChain, st base.StateDB, node common.Address) (*big.Int, error) {
+	var (
+		err error
+		masterAbi abi.ABI
+		totalStakes *big.Int
+	)
+	master := bc.GetConsensusMasterSmartContract()
+	if masterAbi, err = abi.JSON(strings.NewReader(master.ABI)); err != nil {
+		return nil, err
+	}
+	if err = StaticCallContract(bc, st, common.Address{}, master.Address, masterAbi, methodGetTotalStakes, &totalStakes, node); err != nil {
+		return nil, err
+	}
+	return totalStakes, nil
+}
+
 // getLatestValidatorsInfo is used after collect validators process is done, node calls this function to get new validators set
 func getLatestValidatorsInfo(vm *KVM, masterAbi abi.ABI, masterAddress common.Address) (uint64, uint64, uint64, error) {
 	method := "getLatestValidatorsInfo"
@@ -209,6 +330,12 @@ func generateTransaction(nonce uint64, input []byte, privateKey *ecdsa.PrivateKe
 	), privateKey)
 }
 
+// IsPosHandlerCall returns whether a message to the given recipient calls the PoS handler, like
+// the zero priced system transactions of the proposer do.
+func IsPosHandlerCall(to *common.Address) bool {
+	return to != nil && *to == posHandlerAddress
+}
+
 // calculateGas calculates intrinsic gas used for every byte in input data
 func calculateGas(data []byte) uint64 {
 	gas := TxGas
@@ -366,22 +493,14 @@ func getAvailableNodeInfo(bc base.BaseBlockChain, st base.StateDB, sender, node
 
 func getNodeInfo(bc base.BaseBlockChain, st base.StateDB, sender, node common.Address) (*nodeInfo, error) {
 	var (
-		input, output []byte
 		nodeAbi abi.ABI
 		nInfo nodeInfo
 		err error
 	)
-	vm := newInternalKVM(sender, bc, st)
 	if nodeAbi, err = abi.JSON(strings.NewReader(bc.GetConsensusNodeAbi())); err != nil {
 		return nil, err
 	}
-	if input, err = nodeAbi.Pack(methodGetNodeInfo); err != nil {
-		return nil, err
-	}
-	if output, err = StaticCall(vm, node, input); err != nil {
-		return nil, err
-	}
-	if err = nodeAbi.Unpack(&nInfo, methodGetNodeInfo, output); err != nil {
+	if err = StaticCallContract(bc, st, sender, node, nodeAbi, methodGetNodeInfo, &nInfo); err != nil {
 		return nil, err
 	}
 	return &nInfo, nil
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/kardiachain/go-kardia/kai/base"
 	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
 	"github.com/kardiachain/go-kardia/kai/state"
 	"github.com/kardiachain/go-kardia/kvm"
@@ -757,3 +758,53 @@ func TestDecentralizedExchangeContract(t *testing.T) {
 		t.Error("Expect 3rd matchable amount to be 0, got ", matchableAmounts.Amounts[0].String())
 	}
 }
+
+// doubler is a precompiled contract returning its 32 byte input multiplied by two.
+type doubler struct{}
+
+func (doubler) RequiredGas(input []byte) uint64 { return 15 }
+
+func (doubler) Run(input []byte, contract *kvm.Contract, ctx kvm.Context, state base.StateDB) ([]byte, error) {
+	return common.LeftPadBytes(new(big.Int).Lsh(new(big.Int).SetBytes(input), 1).Bytes(), 32), nil
+}
+
+func TestCustomPrecompile(t *testing.T) {
+	precompile := common.BytesToAddress([]byte{0x01, 0x00})
+	// Stores 21 in memory, calls the precompile with it, writing the result over
+	// the input, and returns the first word of memory.
+	code := []byte{
+		byte(kvm.PUSH1), 21,
+		byte(kvm.PUSH1), 0,
+		byte(kvm.MSTORE),
+		byte(kvm.PUSH1), 32, // retSize
+		byte(kvm.PUSH1), 0, // retOffset
+		byte(kvm.PUSH1), 32, // inSize
+		byte(kvm.PUSH1), 0, // inOffset
+		byte(kvm.PUSH1), 0, // value
+		byte(kvm.PUSH2), 0x01, 0x00, // address
+		byte(kvm.GAS),
+		byte(kvm.CALL),
+		byte(kvm.POP),
+		byte(kvm.PUSH1), 32,
+		byte(kvm.PUSH1), 0,
+		byte(kvm.RETURN),
+	}
+	ret, _, err := Execute(code, nil, &Config{KVMConfig: kvm.Config{
+		Precompiles: map[common.Address]kvm.PrecompiledContract{precompile: doubler{}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := new(big.Int).SetBytes(ret); have.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("registered precompile result mismatch: have %v, want 42", have)
+	}
+
+	// Without the registration the call hits an empty account and memory is left untouched.
+	ret, _, err = Execute(code, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := new(big.Int).SetBytes(ret); have.Cmp(big.NewInt(21)) != 0 {
+		t.Errorf("unregistered precompile result mismatch: have %v, want 21", have)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"github.com/kardiachain/go-kardia/kai/pos"
 	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/kvm"
 	"github.com/kardiachain/go-kardia/lib/abi"
 	"github.com/kardiachain/go-kardia/lib/common"
 	"github.com/kardiachain/go-kardia/mainchain/blockchain"
@@ -243,14 +244,8 @@ func testCreateMaster(t *testing.T, masterAbi abi.ABI, bc *blockchain.BlockChain
 
 func testGetTotalStakes(t *testing.T, kAbi abi.ABI, bc *blockchain.BlockChain, st *state.StateDB, expected *big.Int) {
 	for _, node := range genesisNodes {
-		getTotalStakes, err := kAbi.Pack("getTotalStakes", common.HexToAddress(node["address"].(string)))
-		require.NoError(t, err)
-
-		result, err := staticCall(common.HexToAddress(node["owner"].(string)), masterAddress, bc.CurrentHeader(), bc, getTotalStakes, st)
-		require.NoError(t, err)
-
 		var actual *big.Int
-		err = kAbi.Unpack(&actual, "getTotalStakes", result)
+		err := kvm.StaticCallContract(bc, st, common.HexToAddress(node["owner"].(string)), masterAddress, kAbi, "getTotalStakes", &actual, common.HexToAddress(node["address"].(string)))
 		require.NoError(t, err)
 		require.Equal(t, expected, actual)
 	}
@@ -749,6 +744,153 @@ func TestMaster(t *testing.T) {
 	testRejectBlock(t, masterAbi, rejectedAddress, common.HexToAddress(normalNodes[0]["owner"].(string)), 0, 1, bc, st)
 }
 
+func testGetValidators(t *testing.T, bc *blockchain.BlockChain, st *state.StateDB, expectedNodes []map[string]interface{}) {
+	vals, err := kvm.GetValidators(bc, st)
+	require.NoError(t, err)
+	require.Equal(t, len(expectedNodes), len(vals))
+	for i, node := range expectedNodes {
+		require.Equal(t, node["address"].(string), vals[i].Node.Hex())
+		require.Equal(t, node["owner"].(string), vals[i].Owner.Hex())
+		require.Equal(t, minimumStakes.String(), vals[i].Stakes.String())
+	}
+}
+
+func TestGetValidators(t *testing.T) {
+	bc, masterAbi, st := setup(t)
+	testCreateMaster(t, masterAbi, bc, st, uint64(10), uint64(4), uint64(50))
+	testDeployNodesAndStakes(t, bc, st, genesisNodes, true)
+	testCollectValidators(t, masterAbi, bc, st)
+	// keep the state of the first consensus period
+	firstPeriod := st.Copy()
+
+	testDeployNodesAndStakes(t, bc, st, normalNodes, false)
+	testAddPendingNode(t, masterAbi, bc, st, normalNodes[0], common.HexToAddress(genesisNodes[0]["owner"].(string)))
+	testVotePending(t, masterAbi, bc, st, []map[string]interface{}{genesisNodes[1]}, uint64(len(genesisNodes)))
+	testVotePending(t, masterAbi, bc, st, []map[string]interface{}{genesisNodes[2]}, uint64(len(genesisNodes) + 1))
+	testStake(t, bc, st, normalNodes[0], nil, minimumStakes, minimumStakes)
+	testCollectValidators(t, masterAbi, bc, st)
+
+	testGetValidators(t, bc, firstPeriod, genesisNodes)
+	testGetValidators(t, bc, st, append(genesisNodes, normalNodes[0]))
+}
+
+func TestCollectValidatorSetAt(t *testing.T) {
+	bc, masterAbi, st := setup(t)
+	testCreateMaster(t, masterAbi, bc, st, uint64(10), uint64(4), uint64(50))
+	testDeployNodesAndStakes(t, bc, st, genesisNodes, true)
+	testCollectValidators(t, masterAbi, bc, st)
+	// keep the state of the first consensus period
+	firstPeriod := st.Copy()
+
+	testDeployNodesAndStakes(t, bc, st, normalNodes, false)
+	testAddPendingNode(t, masterAbi, bc, st, normalNodes[0], common.HexToAddress(genesisNodes[0]["owner"].(string)))
+	testVotePending(t, masterAbi, bc, st, []map[string]interface{}{genesisNodes[1]}, uint64(len(genesisNodes)))
+	testVotePending(t, masterAbi, bc, st, []map[string]interface{}{genesisNodes[2]}, uint64(len(genesisNodes) + 1))
+	testStake(t, bc, st, normalNodes[0], nil, minimumStakes, minimumStakes)
+	testCollectValidators(t, masterAbi, bc, st)
+
+	// validators are read from the given state rather than the head one
+	vals, err := kvm.CollectValidatorSetAt(bc, bc.CurrentHeader(), firstPeriod)
+	require.NoError(t, err)
+	require.Equal(t, len(genesisNodes), vals.Size())
+	vals, err = kvm.CollectValidatorSetAt(bc, bc.CurrentHeader(), st)
+	require.NoError(t, err)
+	require.Equal(t, len(genesisNodes)+1, vals.Size())
+}
+
+func TestGetNodeInfo(t *testing.T) {
+	bc, masterAbi, st := setup(t)
+	testCreateMaster(t, masterAbi, bc, st, uint64(10), uint64(4), uint64(50))
+	testDeployNodesAndStakes(t, bc, st, genesisNodes, true)
+
+	node := genesisNodes[0]
+	address := common.HexToAddress(node["address"].(string))
+	info, err := kvm.GetNodeInfo(bc, st, address)
+	require.NoError(t, err)
+	require.Equal(t, node["owner"].(string), info.Owner.Hex())
+	require.Equal(t, node["id"].(string), info.NodeId)
+	require.Equal(t, node["name"].(string), info.NodeName)
+	require.Equal(t, node["percentageReward"].(uint16), info.RewardPercentage)
+	require.Equal(t, st.GetBalance(address).String(), info.Balance.String())
+	require.Equal(t, minimumStakes.String(), info.TotalStakes.String())
+}
+
+func TestPreviewWithdraw(t *testing.T) {
+	bc, masterAbi, st := setup(t)
+	testCreateMaster(t, masterAbi, bc, st, uint64(10), uint64(4), uint64(50))
+	testDeployNodesAndStakes(t, bc, st, genesisNodes, true)
+
+	node := common.HexToAddress(genesisNodes[0]["address"].(string))
+	staker := common.HexToAddress(genesisNodes[0]["staker"].(string))
+
+	preview, err := kvm.PreviewWithdraw(bc, st, node, big.NewInt(0))
+	require.NoError(t, err)
+	require.Equal(t, minimumStakes.String(), preview.Stakes.String())
+	require.False(t, preview.BelowMinimum)
+
+	_, err = kvm.PreviewWithdraw(bc, st, node, new(big.Int).Add(minimumStakes, big.NewInt(1)))
+	require.Error(t, err)
+
+	withdraw := new(big.Int).Div(minimumStakes, big.NewInt(2))
+	preview, err = kvm.PreviewWithdraw(bc, st, node, withdraw)
+	require.NoError(t, err)
+	require.True(t, preview.BelowMinimum)
+
+	// preview must not change state
+	info, err := kvm.GetNodeInfo(bc, st, node)
+	require.NoError(t, err)
+	require.Equal(t, minimumStakes.String(), info.TotalStakes.String())
+
+	input, err := masterAbi.Pack("withdraw", node, withdraw)
+	require.NoError(t, err)
+	_, err = call(staker, masterAddress, bc.CurrentHeader(), bc, input, big.NewInt(0), st)
+	require.NoError(t, err)
+
+	info, err = kvm.GetNodeInfo(bc, st, node)
+	require.NoError(t, err)
+	require.Equal(t, preview.Stakes.String(), info.TotalStakes.String())
+}
+
+func TestCallContract(t *testing.T) {
+	bc, masterAbi, st := setup(t)
+	testCreateMaster(t, masterAbi, bc, st, uint64(10), uint64(4), uint64(50))
+	testDeployNodesAndStakes(t, bc, st, genesisNodes, true)
+	sender := common.HexToAddress(genesisNodes[0]["owner"].(string))
+
+	// static call
+	input, err := masterAbi.Pack("getTotalAvailableNodes")
+	require.NoError(t, err)
+	output, err := staticCall(sender, masterAddress, bc.CurrentHeader(), bc, input, st)
+	require.NoError(t, err)
+	var expected, actual *big.Int
+	require.NoError(t, masterAbi.Unpack(&expected, "getTotalAvailableNodes", output))
+	require.NoError(t, kvm.StaticCallContract(bc, st, sender, masterAddress, masterAbi, "getTotalAvailableNodes", &actual))
+	require.Equal(t, expected.String(), actual.String())
+
+	// state changing call, one state is updated by a hand-written call and the other one by CallContract.
+	type info struct {
+		TotalNodes uint64 `abi:"totalNodes"`
+		StartAtBlock uint64 `abi:"startAtBlock"`
+		EndAtBlock uint64 `abi:"endAtBlock"`
+	}
+	wrapped := st.Copy()
+	input, err = masterAbi.Pack("collectValidators")
+	require.NoError(t, err)
+	_, err = call(sender, masterAddress, bc.CurrentHeader(), bc, input, big.NewInt(0), st)
+	require.NoError(t, err)
+	require.NoError(t, kvm.CallContract(bc, wrapped, sender, masterAddress, masterAbi, "collectValidators", nil))
+
+	var expectedInfo, actualInfo info
+	require.NoError(t, kvm.StaticCallContract(bc, st, sender, masterAddress, masterAbi, "getLatestValidatorsInfo", &expectedInfo))
+	require.NoError(t, kvm.StaticCallContract(bc, wrapped, sender, masterAddress, masterAbi, "getLatestValidatorsInfo", &actualInfo))
+	require.Equal(t, uint64(len(genesisNodes)), actualInfo.TotalNodes)
+	require.Equal(t, expectedInfo, actualInfo)
+
+	// invalid arguments are reported before calling
+	require.Error(t, kvm.StaticCallContract(bc, st, sender, masterAddress, masterAbi, "getTotalStakes", &actual))
+	require.Error(t, kvm.StaticCallContract(bc, st, sender, masterAddress, masterAbi, "unknownMethod", nil))
+}
+
 func TestNode(t *testing.T) {
 	kAbi, err := abi.JSON(strings.NewReader(NodeAbi))
 	require.NoError(t, err)
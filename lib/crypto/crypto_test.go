@@ -48,6 +48,34 @@ func TestNewContractAddress(t *testing.T) {
 
 }
 
+func TestCreateAddressVectors(t *testing.T) {
+	sender := common.HexToAddress("6ac7ea33f8831ea9dcc53393aaa88b25a785dbf0")
+	for nonce, expected := range []string{
+		"cd234a471b72ba2f1ccf0a70fcaba648a5eecd8d",
+		"343c43a37d37dff08ae8c4a11544c718abb4fcf8",
+		"f778b86fa74e846c4f0a1fbd1335fe81c00a0c91",
+		"fffd933a0bc612844eaf0c6fe3e5b8e9b6c1d19c",
+	} {
+		verifyAddr(t, common.HexToAddress(expected), CreateAddress(sender, uint64(nonce)))
+	}
+}
+
+// Examples from EIP-1014.
+func TestCreateAddress2Vectors(t *testing.T) {
+	for _, tt := range []struct {
+		sender, salt, code, expected string
+	}{
+		{"0000000000000000000000000000000000000000", "00", "00", "4d1a2e2bb4f88f0250f26ffff098b0b30b26bf38"},
+		{"deadbeef00000000000000000000000000000000", "00", "00", "b928f69bb1d91cd65274e3c79d8986362984fda3"},
+		{"deadbeef00000000000000000000000000000000", "000000000000000000000000feed000000000000000000000000000000000000", "00", "d04116cdd17bebe565eb2422f2497e06cc1c9833"},
+		{"0000000000000000000000000000000000000000", "00", "deadbeef", "70f2b2914a2a4b783faefb75f459a580616fcb5e"},
+	} {
+		code, _ := hex.DecodeString(tt.code)
+		salt := common.HexToHash(tt.salt)
+		verifyAddr(t, common.HexToAddress(tt.expected), CreateAddress2(common.HexToAddress(tt.sender), salt, Keccak256(code)))
+	}
+}
+
 func verifyHash(t *testing.T, name string, f func([]byte) []byte, msg, exp []byte) {
 	sum := f(msg)
 	if !bytes.Equal(exp, sum) {
@@ -28,6 +28,7 @@ import (
 	"github.com/kardiachain/go-kardia/kai/state"
 	"github.com/kardiachain/go-kardia/kvm"
 	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
 	"github.com/kardiachain/go-kardia/lib/log"
 	"github.com/kardiachain/go-kardia/lib/rlp"
 	"github.com/kardiachain/go-kardia/mainchain/blockchain"
@@ -201,6 +202,11 @@ func (s *PublicKaiAPI) BlockNumber() uint64 {
 	return s.kaiService.blockchain.CurrentBlock().Height()
 }
 
+// GasPrice returns a suggested gas price based on the transactions of recent blocks
+func (s *PublicKaiAPI) GasPrice() string {
+	return s.kaiService.gpo.SuggestPrice().String()
+}
+
 // GetHeaderBlockByNumber returns blockHeader by block number
 func (s *PublicKaiAPI) GetBlockHeaderByNumber(blockNumber uint64) *BlockHeaderJSON {
 	block := s.kaiService.blockchain.GetBlockByHeight(blockNumber)
@@ -276,8 +282,12 @@ func (s *PublicKaiAPI) Validator() map[string]interface{} {
 	return nil
 }
 
-// Validators returns a list of validator
-func (s *PublicKaiAPI) Validators() []map[string]interface{} {
+// Validators returns a list of validator. If height is given, it returns the validator set
+// stored in Master smart contract at that block instead of the current consensus validators.
+func (s *PublicKaiAPI) Validators(height *uint64) ([]map[string]interface{}, error) {
+	if height != nil {
+		return s.validatorsAt(*height)
+	}
 	if vals := s.kaiService.csManager.Validators(); vals != nil && len(vals) > 0 {
 		results := make([]map[string]interface{}, len(vals))
 		for i, val := range vals {
@@ -286,9 +296,180 @@ func (s *PublicKaiAPI) Validators() []map[string]interface{} {
 				"votingPower": val.VotingPower,
 			}
 		}
-		return results
+		return results, nil
+	}
+	return nil, nil
+}
+
+// validatorsAt returns node address, owner and stakes of validators derived from Master smart contract state at given height
+func (s *PublicKaiAPI) validatorsAt(height uint64) ([]map[string]interface{}, error) {
+	block := s.kaiService.blockchain.GetBlockByHeight(height)
+	if block == nil {
+		return nil, fmt.Errorf("block %v not found", height)
+	}
+	statedb, err := s.kaiService.blockchain.StateAt(block.Height())
+	if err != nil {
+		return nil, err
+	}
+	vals, err := kvm.GetValidators(s.kaiService.blockchain, statedb)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]map[string]interface{}, len(vals))
+	for i, val := range vals {
+		results[i] = map[string]interface{}{
+			"node":   val.Node.Hex(),
+			"owner":  val.Owner.Hex(),
+			"stakes": val.Stakes.String(),
+		}
+	}
+	return results, nil
+}
+
+// NodeInfo returns owner, node id, name, reward percentage, balance and total stakes of the node at given address
+func (s *PublicKaiAPI) NodeInfo(address string) (map[string]interface{}, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid node address %v", address)
+	}
+	statedb, err := s.kaiService.blockchain.State()
+	if err != nil {
+		return nil, err
+	}
+	info, err := kvm.GetNodeInfo(s.kaiService.blockchain, statedb, common.HexToAddress(address))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"owner":            info.Owner.Hex(),
+		"nodeId":           info.NodeId,
+		"name":             info.NodeName,
+		"rewardPercentage": info.RewardPercentage,
+		"balance":          info.Balance.String(),
+		"totalStakes":      info.TotalStakes.String(),
+	}, nil
+}
+
+// PreviewWithdraw returns node's stakes after withdrawing amount and whether it would drop below node's minimum stakes.
+// The withdrawal is not executed.
+func (s *PublicKaiAPI) PreviewWithdraw(node string, amount string) (map[string]interface{}, error) {
+	if !common.IsHexAddress(node) {
+		return nil, fmt.Errorf("invalid node address %v", node)
+	}
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %v", amount)
+	}
+	statedb, err := s.kaiService.blockchain.State()
+	if err != nil {
+		return nil, err
+	}
+	preview, err := kvm.PreviewWithdraw(s.kaiService.blockchain, statedb, common.HexToAddress(node), value)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"stakes":       preview.Stakes.String(),
+		"belowMinimum": preview.BelowMinimum,
+	}, nil
+}
+
+// Resend replaces the pending transaction oldHash with the signed replacement tx, which must use
+// the same nonce and newGasPrice. The price must exceed the old one by at least the pool's price bump.
+func (s *PublicKaiAPI) Resend(ctx context.Context, oldHash string, newGasPrice string, txs string) (string, error) {
+	gasPrice, ok := new(big.Int).SetString(newGasPrice, 10)
+	if !ok {
+		return common.Hash{}.Hex(), fmt.Errorf("invalid gas price %v", newGasPrice)
+	}
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(txs), tx); err != nil {
+		return common.Hash{}.Hex(), err
+	}
+	if tx.GasPrice().Cmp(gasPrice) != 0 {
+		return common.Hash{}.Hex(), fmt.Errorf("replacement gas price %v does not match %v", tx.GasPrice(), gasPrice)
+	}
+	return tx.Hash().Hex(), s.kaiService.TxPool().Resend(common.HexToHash(oldHash), tx)
+}
+
+// ComputeContractAddress returns the address of the contract that sender would create with given nonce.
+func (s *PublicKaiAPI) ComputeContractAddress(sender string, nonce uint64) (string, error) {
+	if !common.IsHexAddress(sender) {
+		return "", fmt.Errorf("invalid sender address %v", sender)
+	}
+	return crypto.CreateAddress(common.HexToAddress(sender), nonce).Hex(), nil
+}
+
+// TraceTransaction re-executes the transaction with given hash against the state at its block
+// and returns the gas used, return value, revert reason and the executed opcodes.
+func (s *PublicKaiAPI) TraceTransaction(hash string) (map[string]interface{}, error) {
+	tx, blockHash, height, index := s.kaiService.kaiDb.ReadTransaction(common.HexToHash(hash))
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %v not found", hash)
+	}
+	block := s.kaiService.blockchain.GetBlock(blockHash, height)
+	if block == nil {
+		return nil, fmt.Errorf("block %v not found", height)
+	}
+	trace, err := s.kaiService.blockchain.TraceTransaction(block, int(index), nil)
+	if err != nil {
+		return nil, err
+	}
+	return newTraceJSON(trace), nil
+}
+
+// TraceBlock re-executes all transactions of the block at given height in order against the state before the block
+// and returns their traces.
+func (s *PublicKaiAPI) TraceBlock(height uint64) ([]map[string]interface{}, error) {
+	block := s.kaiService.blockchain.GetBlockByHeight(height)
+	if block == nil {
+		return nil, fmt.Errorf("block %v not found", height)
+	}
+	traces, err := s.kaiService.blockchain.TraceBlock(block, nil)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]map[string]interface{}, len(traces))
+	for i, trace := range traces {
+		results[i] = newTraceJSON(trace)
+	}
+	return results, nil
+}
+
+// newTraceJSON formats a transaction trace with hex encoded stack and memory words.
+func newTraceJSON(trace *blockchain.TxTrace) map[string]interface{} {
+	structLogs := make([]map[string]interface{}, len(trace.StructLogs))
+	for i, sl := range trace.StructLogs {
+		stack := make([]string, len(sl.Stack))
+		for j, item := range sl.Stack {
+			stack[j] = common.EncodeBig(item)
+		}
+		memory := make([]string, 0, (len(sl.Memory)+31)/32)
+		for j := 0; j+32 <= len(sl.Memory); j += 32 {
+			memory = append(memory, common.Encode(sl.Memory[j:j+32]))
+		}
+		storage := make(map[string]string, len(sl.Storage))
+		for key, value := range sl.Storage {
+			storage[key.Hex()] = value.Hex()
+		}
+		structLogs[i] = map[string]interface{}{
+			"pc":      sl.Pc,
+			"op":      sl.OpName(),
+			"gas":     sl.Gas,
+			"gasCost": sl.GasCost,
+			"depth":   sl.Depth,
+			"error":   sl.ErrorString(),
+			"stack":   stack,
+			"memory":  memory,
+			"storage": storage,
+		}
+	}
+	return map[string]interface{}{
+		"hash":         trace.TxHash.Hex(),
+		"gas":          trace.Gas,
+		"failed":       trace.Failed,
+		"returnValue":  common.Encode(trace.ReturnValue),
+		"revertReason": trace.RevertReason,
+		"structLogs":   structLogs,
 	}
-	return nil
 }
 
 type PublicTransaction struct {
@@ -557,6 +738,33 @@ func (a *PublicAccountAPI) Nonce(address string) (uint64, error) {
 	return nonce, nil
 }
 
+// PrivateAdminAPI provides APIs for operators to put the node into maintenance mode
+type PrivateAdminAPI struct {
+	kaiService *KardiaService
+}
+
+// NewPrivateAdminAPI is a constructor that init new PrivateAdminAPI
+func NewPrivateAdminAPI(kaiService *KardiaService) *PrivateAdminAPI {
+	return &PrivateAdminAPI{kaiService}
+}
+
+// Halt stops proposing blocks and accepting transactions, P2P and read APIs keep working
+func (a *PrivateAdminAPI) Halt() bool {
+	a.kaiService.Halt()
+	return true
+}
+
+// Resume resumes proposing blocks and accepting transactions after Halt
+func (a *PrivateAdminAPI) Resume() bool {
+	a.kaiService.Resume()
+	return true
+}
+
+// Halted returns whether the node is halted
+func (a *PrivateAdminAPI) Halted() bool {
+	return a.kaiService.IsHalted()
+}
+
 // doCall is an interface to make smart contract call against the state of local node
 // No tx is generated or submitted to the blockchain
 func (s *PublicKaiAPI) doCall(ctx context.Context, args *types.CallArgs, blockNr uint64, vmCfg kvm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
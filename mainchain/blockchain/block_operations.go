@@ -76,6 +76,9 @@ func (bo *BlockOperations) CreateProposalBlock(
 
 	header := bo.newHeader(height, uint64(len(txs)), lastState.LastBlockID, proposerAddr, lastState.LastValidators.Hash())
 	header.AppHash = lastState.AppHash
+	if parent := bo.blockchain.GetBlockByHeight(uint64(height) - 1); parent != nil {
+		header.BaseFee = bo.blockchain.CalcBaseFee(parent)
+	}
 
 	block = bo.newBlock(header, txs, commit)
 	bo.logger.Info("Make block to propose", "height", block.Height(), "AppHash", block.AppHash(), "hash", block.Hash())
@@ -138,7 +141,10 @@ func (bo *BlockOperations) claimReward(height uint64) error {
 // New calculated state root is validated against the root field in block.
 // Transactions, new state and receipts are saved to storage.
 func (bo *BlockOperations) CommitAndValidateBlockTxs(block *types.Block) (common.Hash, error) {
-	root, receipts, _, err := bo.commitTransactions(block.Transactions(), block.Header())
+	if err := bo.validateBaseFee(block); err != nil {
+		return common.Hash{}, err
+	}
+	root, receipts, err := bo.blockchain.commitTransactions(block.Transactions(), block.Header())
 	if err != nil {
 		return common.Hash{}, err
 	}
@@ -147,6 +153,19 @@ func (bo *BlockOperations) CommitAndValidateBlockTxs(block *types.Block) (common
 	return root, nil
 }
 
+// validateBaseFee checks the base fee of block against the one derived from its parent.
+func (bo *BlockOperations) validateBaseFee(block *types.Block) error {
+	parent := bo.blockchain.GetBlockByHeight(block.Height() - 1)
+	if parent == nil {
+		return fmt.Errorf("parent of block %d not found", block.Height())
+	}
+	have, want := block.BaseFee(), bo.blockchain.CalcBaseFee(parent)
+	if (have == nil) != (want == nil) || (want != nil && have.Cmp(want) != 0) {
+		return fmt.Errorf("invalid base fee: have %v, want %v", have, want)
+	}
+	return nil
+}
+
 // SaveBlock saves the given block, blockParts, and seenCommit to the underlying storage.
 // seenCommit: The +2/3 precommits that were seen which committed at height.
 //             If all the nodes restart after committing a block,
@@ -178,6 +197,34 @@ func (bo *BlockOperations) SaveBlock(block *types.Block, blockParts *types.PartS
 	bo.mtx.Lock()
 	bo.height = height
 	bo.mtx.Unlock()
+
+	// drop the state which is out of the retention window, and persist the head state once per window
+	// as recent state is only kept in memory. The blocks above the last persisted state are replayed
+	// on restart, see BlockChain.ReplayBlocks.
+	if bc := bo.blockchain; !bc.Archive {
+		if height > bc.StateRetention {
+			if err := bc.PruneStateBelow(height - bc.StateRetention); err != nil {
+				bo.logger.Error("Fail to prune state", "height", height, "err", err)
+			}
+		}
+		if bc.StateRetention > 0 && height%bc.StateRetention == 0 {
+			if err := bc.FlushState(); err != nil {
+				bo.logger.Error("Fail to flush state", "height", height, "err", err)
+			}
+		}
+	}
+}
+
+// SaveCheckpoint saves a trusted block above the current height along with the state root after it,
+// which must already be in the database, and moves the height up to it.
+func (bo *BlockOperations) SaveCheckpoint(block *types.Block, blockParts *types.PartSet, seenCommit *types.Commit, root common.Hash) error {
+	if err := bo.blockchain.WriteCheckpoint(block, blockParts, seenCommit, root); err != nil {
+		return err
+	}
+	bo.mtx.Lock()
+	bo.height = block.Height()
+	bo.mtx.Unlock()
+	return nil
 }
 
 // LoadBlock returns the Block for the given height.
@@ -237,64 +284,6 @@ func (bo *BlockOperations) newBlock(header *types.Header, txs []*types.Transacti
 	return block
 }
 
-// commitTransactions executes the given transactions and commits the result stateDB to disk.
-func (bo *BlockOperations) commitTransactions(txs types.Transactions, header *types.Header) (common.Hash, types.Receipts,
-	types.Transactions, error) {
-	var (
-		newTxs   = types.Transactions{}
-		receipts = types.Receipts{}
-		usedGas  = new(uint64)
-	)
-	counter := 0
-
-	// Blockchain state at head block.
-	state, err := bo.blockchain.State()
-	if err != nil {
-		bo.logger.Error("Fail to get blockchain head state", "err", err)
-		return common.Hash{}, nil, nil, err
-	}
-
-	// GasPool
-	bo.logger.Info("header gas limit", "limit", header.GasLimit)
-	gasPool := new(types.GasPool).AddGas(header.GasLimit)
-
-	// TODO(thientn): verifies the list is sorted by nonce so tx with lower nonce is execute first.
-LOOP:
-	for _, tx := range txs {
-		state.Prepare(tx.Hash(), common.Hash{}, counter)
-		snap := state.Snapshot()
-		// TODO(thientn): confirms nil coinbase is acceptable.
-		receipt, _, err := ApplyTransaction(bo.logger, bo.blockchain, gasPool, state, header, tx, usedGas, kvm.Config{
-			IsZeroFee: bo.blockchain.IsZeroFee,
-		})
-		if err != nil {
-			bo.logger.Error("ApplyTransaction failed", "tx", tx.Hash().Hex(), "nonce", tx.Nonce(), "err", err)
-			state.RevertToSnapshot(snap)
-			// TODO(thientn): check error type and jump to next tx if possible
-			// kiendn: instead of return nil and err, jump to next tx
-			//return common.Hash{}, nil, nil, err
-			continue LOOP
-		}
-		counter++
-		receipts = append(receipts, receipt)
-		newTxs = append(newTxs, tx)
-	}
-
-	root, err := state.Commit(true)
-
-	if err != nil {
-		bo.logger.Error("Fail to commit new statedb after txs", "err", err)
-		return common.Hash{}, nil, nil, err
-	}
-	err = bo.blockchain.CommitTrie(root)
-	if err != nil {
-		bo.logger.Error("Fail to write statedb trie to disk", "err", err)
-		return common.Hash{}, nil, nil, err
-	}
-
-	return root, receipts, newTxs, nil
-}
-
 // saveReceipts saves receipts of block transactions to storage.
 func (bo *BlockOperations) saveReceipts(receipts types.Receipts, block *types.Block) {
 	bo.blockchain.WriteReceipts(receipts, block)
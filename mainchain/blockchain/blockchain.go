@@ -20,6 +20,7 @@ package blockchain
 
 import (
 	"errors"
+	"fmt"
 	"github.com/kardiachain/go-kardia/kai/base"
 	"github.com/kardiachain/go-kardia/kai/pos"
 	"math/big"
@@ -29,23 +30,57 @@ import (
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/kardiachain/go-kardia/kai/events"
 	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/kvm"
 	"github.com/kardiachain/go-kardia/lib/common"
 	"github.com/kardiachain/go-kardia/lib/event"
 	"github.com/kardiachain/go-kardia/lib/log"
+	vm "github.com/kardiachain/go-kardia/mainchain/kvm"
 	"github.com/kardiachain/go-kardia/types"
 )
 
 const (
-	blockCacheLimit = 256
+	blockCacheLimit     = 256
+	blockMetaCacheLimit = 256
 
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
+
+	// DefaultMaxReorgDepth is the default maximum number of blocks SetHead may rewind.
+	DefaultMaxReorgDepth = 1024
+
+	// DefaultStateRetention is the default number of recent blocks whose state is available when not in archive mode.
+	DefaultStateRetention = 128
+
+	// DefaultStateSnapshotLimit is the default number of recently opened states cached by StateAt.
+	DefaultStateSnapshotLimit = 4
 )
 
 var (
-	ErrNoGenesis = errors.New("Genesis not found in chain")
+	ErrNoGenesis       = errors.New("Genesis not found in chain")
+	ErrReorgTooDeep    = errors.New("reorg exceeds the maximum depth")
+	ErrArchivePrune    = errors.New("state cannot be pruned in archive mode")
+	ErrReceiptNotFound = errors.New("receipt not found")
+	ErrNotCanonical    = errors.New("block is not on the canonical chain")
 )
 
+// StatePrunedError is returned by StateAt for a height whose state is no longer available
+// because the chain runs in pruned mode.
+type StatePrunedError struct {
+	Height uint64 // Height the state was requested at
+	Oldest uint64 // Lowest height whose state is still available, besides genesis
+}
+
+func (e *StatePrunedError) Error() string {
+	return fmt.Sprintf("state at height %v is pruned, the oldest available state is at height %v, use archive mode to query it", e.Height, e.Oldest)
+}
+
+// liveRoot is a state root kept in the trie database memory, along with the height of the block
+// it was committed for.
+type liveRoot struct {
+	root   common.Hash
+	height uint64
+}
+
 // TODO(huny@): Add detailed description for Kardia blockchain
 type BlockChain struct {
 	logger log.Logger
@@ -56,6 +91,7 @@ type BlockChain struct {
 	hc *HeaderChain
 
 	chainHeadFeed event.Feed
+	reorgFeed     event.Feed
 	scope         event.SubscriptionScope
 
 	genesisBlock *types.Block
@@ -68,6 +104,11 @@ type BlockChain struct {
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
 	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
 
+	blockMetaCache *lru.Cache // Cache for the most recent verified block metas keyed by hash
+
+	stateSnapshots   *lru.Cache // Cache for recently opened states keyed by app hash, nil if disabled
+	stateSnapshotsMu sync.RWMutex
+
 	quit chan struct{} // blockchain quit channel
 
 	processor *StateProcessor // block processor
@@ -75,6 +116,20 @@ type BlockChain struct {
 	// IsZeroFee is true then sender will be refunded all gas spent for a transaction
 	IsZeroFee bool
 
+	// MaxReorgDepth is the maximum number of blocks SetHead may rewind, 0 disables the limit
+	MaxReorgDepth uint64
+
+	// Archive retains the state of every block for historical queries, which is the default. Otherwise
+	// only the state of the latest StateRetention blocks is available
+	Archive bool
+
+	// StateRetention is the number of recent blocks whose state is retained when Archive is false
+	StateRetention uint64
+
+	pruneMu      sync.Mutex // protects liveRoots and serializes PruneStateBelow
+	liveRoots    []liveRoot // state roots referenced in the trie database memory, flushed or not
+	prunedHeight uint64     // state of the blocks below this height, except genesis, has been pruned (atomic)
+
 	pos.ConsensusInfo
 }
 
@@ -111,6 +166,8 @@ func (bc *BlockChain) Config() *types.ChainConfig { return bc.chainConfig }
 func NewBlockChain(logger log.Logger, db types.StoreDB, chainConfig *types.ChainConfig) (*BlockChain, error) {
 	blockCache, _ := lru.New(blockCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
+	blockMetaCache, _ := lru.New(blockMetaCacheLimit)
+	stateSnapshots, _ := lru.New(DefaultStateSnapshotLimit)
 
 	bc := &BlockChain{
 		logger:       logger,
@@ -120,6 +177,13 @@ func NewBlockChain(logger log.Logger, db types.StoreDB, chainConfig *types.Chain
 		blockCache:   blockCache,
 		futureBlocks: futureBlocks,
 		quit:         make(chan struct{}),
+
+		blockMetaCache: blockMetaCache,
+		stateSnapshots: stateSnapshots,
+
+		MaxReorgDepth:  DefaultMaxReorgDepth,
+		Archive:        true,
+		StateRetention: DefaultStateRetention,
 	}
 
 	var err error
@@ -153,6 +217,27 @@ func (bc *BlockChain) GetBlockByHeight(height uint64) *types.Block {
 	return bc.GetBlock(hash, height)
 }
 
+// GetBlocksByRange retrieves the canonical blocks from height from to height to inclusive, in order.
+// to is capped at the current head and the result stops at the first missing height.
+// Blocks are looked up through the block cache and bc.mu is never held.
+func (bc *BlockChain) GetBlocksByRange(from, to uint64) []*types.Block {
+	if head := bc.CurrentBlock().Height(); to > head {
+		to = head
+	}
+	if from > to {
+		return nil
+	}
+	blocks := make([]*types.Block, 0, to-from+1)
+	for height := from; height <= to; height++ {
+		block := bc.GetBlockByHeight(height)
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
 func (bc *BlockChain) LoadBlockPart(height uint64, index int) *types.Part {
 	hash := bc.db.ReadCanonicalHash(height)
 	part := bc.db.ReadBlockPart(hash, height, index)
@@ -167,6 +252,36 @@ func (bc *BlockChain) LoadBlockMeta(height uint64) *types.BlockMeta {
 	return bc.db.ReadBlockMeta(hash, height)
 }
 
+// BlockMetaAt returns the header, block id and part set header of the canonical
+// block at the given height, caching it if found. The stored meta is checked
+// against the stored block before use, nil is returned if they disagree.
+func (bc *BlockChain) BlockMetaAt(height uint64) *types.BlockMeta {
+	hash := bc.db.ReadCanonicalHash(height)
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	if meta, ok := bc.blockMetaCache.Get(hash); ok {
+		return meta.(*types.BlockMeta)
+	}
+	meta := bc.db.ReadBlockMeta(hash, height)
+	if meta == nil || meta.Header == nil {
+		return nil
+	}
+	block := bc.GetBlock(hash, height)
+	if block == nil {
+		bc.logger.Error("Block meta without block", "height", height, "hash", hash)
+		return nil
+	}
+	if partsHeader := block.MakePartSet(types.BlockPartSizeBytes).Header(); meta.BlockID.Hash != hash ||
+		meta.Header.Hash() != hash || !meta.BlockID.PartsHeader.Equals(partsHeader) {
+		bc.logger.Error("Block meta does not match stored block", "height", height, "hash", hash,
+			"metaHash", meta.BlockID.Hash, "headerHash", meta.Header.Hash(), "partsHeader", meta.BlockID.PartsHeader, "want", partsHeader)
+		return nil
+	}
+	bc.blockMetaCache.Add(hash, meta)
+	return meta
+}
+
 func (bc *BlockChain) LoadBlockCommit(height uint64) *types.Commit {
 	return bc.db.ReadCommit(height)
 }
@@ -203,9 +318,65 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 }
 
 // StateAt returns a new mutable state based on a particular point in time.
+// Unless the chain is in archive mode, state older than StateRetention blocks is not available.
 func (bc *BlockChain) StateAt(height uint64) (*state.StateDB, error) {
+	if err := bc.checkStateRetention(height); err != nil {
+		return nil, err
+	}
 	appHash := bc.db.ReadAppHash(height)
-	return state.New(bc.logger, appHash, bc.stateCache)
+
+	bc.stateSnapshotsMu.RLock()
+	snapshots := bc.stateSnapshots
+	bc.stateSnapshotsMu.RUnlock()
+	if snapshots == nil {
+		return state.New(bc.logger, appHash, bc.stateCache)
+	}
+	// Hand out copies so callers never mutate the cached instance
+	if cached, ok := snapshots.Get(appHash); ok {
+		return cached.(*state.StateDB).Copy(), nil
+	}
+	statedb, err := state.New(bc.logger, appHash, bc.stateCache)
+	if err != nil {
+		return nil, err
+	}
+	snapshots.Add(appHash, statedb)
+	return statedb.Copy(), nil
+}
+
+// SetStateSnapshotLimit sets the number of recently opened states cached by StateAt, 0 disables the cache.
+func (bc *BlockChain) SetStateSnapshotLimit(limit int) {
+	bc.stateSnapshotsMu.Lock()
+	defer bc.stateSnapshotsMu.Unlock()
+
+	bc.stateSnapshots = nil
+	if limit > 0 {
+		bc.stateSnapshots, _ = lru.New(limit)
+	}
+}
+
+// purgeStateSnapshots drops all states cached by StateAt.
+func (bc *BlockChain) purgeStateSnapshots() {
+	bc.stateSnapshotsMu.RLock()
+	defer bc.stateSnapshotsMu.RUnlock()
+
+	if bc.stateSnapshots != nil {
+		bc.stateSnapshots.Purge()
+	}
+}
+
+// checkStateRetention returns a *StatePrunedError if state at height is out of the retention window or has been pruned.
+func (bc *BlockChain) checkStateRetention(height uint64) error {
+	if pruned := atomic.LoadUint64(&bc.prunedHeight); height > 0 && height < pruned {
+		return &StatePrunedError{Height: height, Oldest: pruned}
+	}
+	if bc.Archive {
+		return nil
+	}
+	current, ok := bc.currentBlock.Load().(*types.Block)
+	if !ok || current.Height() <= bc.StateRetention || height >= current.Height()-bc.StateRetention {
+		return nil
+	}
+	return &StatePrunedError{Height: height, Oldest: current.Height() - bc.StateRetention}
 }
 
 // CheckCommittedStateRoot returns true if the given state root is already committed and existed on trie database.
@@ -216,11 +387,21 @@ func (bc *BlockChain) CheckCommittedStateRoot(root common.Hash) bool {
 	return err == nil
 }
 
+// TrieNode retrieves a state trie node or contract code by hash, from memory or disk.
+func (bc *BlockChain) TrieNode(hash common.Hash) ([]byte, error) {
+	return bc.stateCache.TrieDB().Node(hash)
+}
+
 // SubscribeChainHeadEvent registers a subscription of ChainHeadEvent.
 func (bc *BlockChain) SubscribeChainHeadEvent(ch chan<- events.ChainHeadEvent) event.Subscription {
 	return bc.scope.Track(bc.chainHeadFeed.Subscribe(ch))
 }
 
+// SubscribeChainReorgEvent registers a subscription of ChainReorgEvent.
+func (bc *BlockChain) SubscribeChainReorgEvent(ch chan<- events.ChainReorgEvent) event.Subscription {
+	return bc.scope.Track(bc.reorgFeed.Subscribe(ch))
+}
+
 // loadLastState loads the last known chain state from the database. This method
 // assumes that the chain manager mutex is held.
 func (bc *BlockChain) loadLastState() error {
@@ -261,9 +442,38 @@ func (bc *BlockChain) loadLastState() error {
 	bc.logger.Info("Loaded most recent local header", "height", currentHeader.Height, "hash", currentHeader.Hash())
 	bc.logger.Info("Loaded most recent local full block", "height", currentBlock.Height(), "hash", currentBlock.Hash())
 
+	bc.reconcileSeenCommit(currentBlock)
 	return nil
 }
 
+// reconcileSeenCommit checks that the locally seen commit of the parent of head is a
+// well-formed commit for the block head builds on, and replaces it with the canonical
+// commit carried by head otherwise. The seen commit holds the precommits this node
+// collected, so it may differ from the canonical one while committing the same block.
+// Stores which don't keep seen commits are skipped. It returns whether a repair was made.
+func (bc *BlockChain) reconcileSeenCommit(head *types.Block) bool {
+	store, ok := bc.db.(types.SeenCommitStore)
+	// the first block carries no commit for the genesis block
+	if !ok || head.Height() <= 1 || head.LastCommit() == nil {
+		return false
+	}
+	height := head.Height() - 1
+	canonical := head.LastCommit()
+	seen := store.ReadSeenCommit(height)
+	if seen != nil && seen.ValidateBasic() == nil && seen.BlockID.Equal(canonical.BlockID) {
+		return false
+	}
+	if err := canonical.ValidateBasic(); err != nil || canonical.Hash() != head.Header().LastCommitHash ||
+		!canonical.BlockID.Equal(head.Header().LastBlockID) {
+		bc.logger.Error("Head last commit is invalid, cannot repair seen commit", "height", head.Height(), "hash", head.Hash(), "err", err)
+		return false
+	}
+	bc.logger.Warn("Seen commit is not a commit for the canonical block, repairing", "height", height,
+		"seen", seen.Hash(), "canonical", canonical.BlockID)
+	store.WriteSeenCommit(height, canonical)
+	return true
+}
+
 // Reset purges the entire blockchain, restoring it to its genesis state.
 func (bc *BlockChain) Reset() error {
 	return bc.ResetWithGenesisBlock(bc.genesisBlock)
@@ -273,7 +483,7 @@ func (bc *BlockChain) Reset() error {
 // specified genesis state.
 func (bc *BlockChain) ResetWithGenesisBlock(genesis *types.Block) error {
 	// Dump the entire block chain and purge the caches
-	if err := bc.SetHead(0); err != nil {
+	if err := bc.setHead(0); err != nil {
 		return err
 	}
 	bc.mu.Lock()
@@ -304,10 +514,42 @@ func (bc *BlockChain) repair(head **types.Block) error {
 			return nil
 		}
 		// Otherwise rewind one block and recheck state availability there
-		(*head) = bc.GetBlock((*head).LastCommitHash(), (*head).Height()-1)
+		(*head) = bc.GetBlock((*head).Header().LastBlockID.Hash, (*head).Height()-1)
 	}
 }
 
+// ReplayBlocks re-executes the canonical blocks stored above the head block. Unless in archive mode,
+// recent state only lives in memory until FlushState, so after a crash loadLastState rewinds the head
+// to the last flushed state while the blocks above it are still stored. Each replayed block must
+// reproduce the state root recorded when it was first committed. The chain has to be configured
+// (IsZeroFee, Archive, ConsensusInfo) beforehand, as they affect the execution.
+func (bc *BlockChain) ReplayBlocks() error {
+	from := bc.CurrentBlock().Height()
+	for {
+		current := bc.CurrentBlock()
+		height := current.Height() + 1
+		block := bc.GetBlock(bc.db.ReadCanonicalHash(height), height)
+		if block == nil || block.Header().LastBlockID.Hash != current.Hash() {
+			break
+		}
+		root, _, err := bc.commitTransactions(block.Transactions(), block.Header())
+		if err != nil {
+			return fmt.Errorf("failed to replay block #%d: %v", height, err)
+		}
+		if want := bc.ReadAppHash(height); root != want {
+			return fmt.Errorf("replayed state of block #%d mismatch: have %v, want %v", height, root.Hex(), want.Hex())
+		}
+		bc.mu.Lock()
+		bc.insert(block)
+		bc.mu.Unlock()
+	}
+	if head := bc.CurrentBlock().Height(); head > from {
+		bc.logger.Info("Replayed blocks above the last flushed state", "from", from+1, "to", head)
+		return bc.FlushState()
+	}
+	return nil
+}
+
 // GetBlockByHash retrieves a block from the database by hash, caching it if found.
 func (bc *BlockChain) GetBlockByHash(hash common.Hash) *types.Block {
 	height := bc.hc.GetBlockHeight(hash)
@@ -317,6 +559,34 @@ func (bc *BlockChain) GetBlockByHash(hash common.Hash) *types.Block {
 	return bc.GetBlock(hash, *height)
 }
 
+// CalcBaseFee returns the base fee of the child of parent, or nil if the London fork
+// is not active at its height. The gas used by parent is taken from its receipts.
+func (bc *BlockChain) CalcBaseFee(parent *types.Block) *big.Int {
+	if !bc.chainConfig.IsLondon(new(big.Int).SetUint64(parent.Height() + 1)) {
+		return nil
+	}
+	var gasUsed uint64
+	if receipts := bc.db.ReadReceipts(parent.Hash(), parent.Height()); len(receipts) > 0 {
+		gasUsed = receipts[len(receipts)-1].CumulativeGasUsed
+	}
+	return types.CalcBaseFee(parent.Header(), gasUsed)
+}
+
+// Confirmations returns how many blocks have been built on top of the canonical block
+// with given hash, 0 for the current head. Unknown and side chain blocks return
+// ErrNotCanonical.
+func (bc *BlockChain) Confirmations(hash common.Hash) (uint64, error) {
+	block := bc.GetBlockByHash(hash)
+	if block == nil || bc.db.ReadCanonicalHash(block.Height()) != hash {
+		return 0, ErrNotCanonical
+	}
+	head := bc.CurrentBlock().Height()
+	if head < block.Height() {
+		return 0, ErrNotCanonical
+	}
+	return head - block.Height(), nil
+}
+
 // GetHeaderByHash retrieves a block header from the database by hash, caching it if
 // found.
 func (bc *BlockChain) GetHeaderByHash(hash common.Hash) *types.Header {
@@ -327,12 +597,35 @@ func (bc *BlockChain) GetHeaderByHash(hash common.Hash) *types.Header {
 // above the new head will be deleted and the new one set. In the case of blocks
 // though, the head may be further rewound if block bodies are missing (non-archive
 // nodes after a fast sync).
+//
+// Rewinding more than MaxReorgDepth blocks is refused with ErrReorgTooDeep.
 func (bc *BlockChain) SetHead(head uint64) error {
+	if currentBlock := bc.CurrentBlock(); currentBlock != nil && bc.MaxReorgDepth > 0 &&
+		currentBlock.Height() > head && currentBlock.Height()-head > bc.MaxReorgDepth {
+		bc.logger.Error("Refusing to rewind blockchain beyond the maximum reorg depth",
+			"current", currentBlock.Height(), "target", head, "maxDepth", bc.MaxReorgDepth)
+		return ErrReorgTooDeep
+	}
+	return bc.setHead(head)
+}
+
+// setHead rewinds the local chain to a new head without checking the reorg depth.
+func (bc *BlockChain) setHead(head uint64) error {
 	bc.logger.Warn("Rewinding blockchain", "target", head)
 
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
+	// Collect the blocks about to be dropped before their bodies are deleted
+	var dropped []*types.Block
+	if currentBlock := bc.CurrentBlock(); currentBlock != nil {
+		for height := head + 1; height <= currentBlock.Height(); height++ {
+			if block := bc.GetBlockByHeight(height); block != nil {
+				dropped = append(dropped, block)
+			}
+		}
+	}
+
 	// Rewind the header chain, deleting all block bodies until then
 	delFn := func(db types.StoreDB, hash common.Hash, height uint64) {
 		db.DeleteBlockPart(hash, height)
@@ -342,7 +635,9 @@ func (bc *BlockChain) SetHead(head uint64) error {
 
 	// Clear out any stale content from the caches
 	bc.blockCache.Purge()
+	bc.blockMetaCache.Purge()
 	bc.futureBlocks.Purge()
+	bc.purgeStateSnapshots()
 
 	// Rewind the block chain, ensuring we don't end up with a stateless head block
 	if currentBlock := bc.CurrentBlock(); currentBlock != nil && currentHeader.Height < currentBlock.Height() {
@@ -364,7 +659,13 @@ func (bc *BlockChain) SetHead(head uint64) error {
 
 	bc.db.WriteHeadBlockHash(currentBlock.Hash())
 
-	return bc.loadLastState()
+	if err := bc.loadLastState(); err != nil {
+		return err
+	}
+	if len(dropped) > 0 {
+		bc.reorgFeed.Send(events.ChainReorgEvent{CommonAncestor: bc.CurrentBlock(), Dropped: dropped})
+	}
+	return nil
 }
 
 // WriteBlockWithoutState writes only new block to database.
@@ -372,6 +673,20 @@ func (bc *BlockChain) WriteBlockWithoutState(block *types.Block, blockParts *typ
 	// Makes sure no inconsistent state is leaked during insertion
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
+
+	// If the block doesn't extend the current head, work out the reorg before
+	// the canonical chain is overwritten.
+	var reorg *events.ChainReorgEvent
+	if currentBlock := bc.CurrentBlock(); currentBlock != nil && block.Height() > 0 &&
+		block.Header().LastBlockID.Hash != currentBlock.Hash() && block.Hash() != currentBlock.Hash() {
+		ancestor, dropped, added, err := bc.findReorg(currentBlock, block)
+		if err != nil {
+			bc.logger.Warn("Failed to compute chain reorg", "height", block.Height(), "hash", block.Hash(), "err", err)
+		} else if len(dropped) > 0 {
+			reorg = &events.ChainReorgEvent{CommonAncestor: ancestor, Dropped: dropped, Added: added}
+		}
+	}
+
 	// Write block data in batch
 	bc.db.WriteBlock(block, blockParts, seenCommit)
 
@@ -382,12 +697,96 @@ func (bc *BlockChain) WriteBlockWithoutState(block *types.Block, blockParts *typ
 
 	bc.insert(block)
 	bc.futureBlocks.Remove(block.Hash())
+	bc.purgeStateSnapshots()
+
+	if reorg != nil {
+		// Drop the canonical mappings of the old chain above the new head
+		for _, dropped := range reorg.Dropped {
+			if dropped.Height() > block.Height() {
+				bc.db.DeleteCanonicalHash(dropped.Height())
+			}
+		}
+		bc.logger.Warn("Chain reorg detected", "ancestor", reorg.CommonAncestor.Height(),
+			"dropped", len(reorg.Dropped), "added", len(reorg.Added))
+		bc.reorgFeed.Send(*reorg)
+	}
 
 	// Sends new head event
 	bc.chainHeadFeed.Send(events.ChainHeadEvent{Block: block})
 	return nil
 }
 
+// WriteCheckpoint writes a trusted block as the new head of the chain without the blocks
+// below it. root is the state after executing the block, which must already be in the
+// database as the block isn't executed.
+func (bc *BlockChain) WriteCheckpoint(block *types.Block, blockParts *types.PartSet, seenCommit *types.Commit, root common.Hash) error {
+	if !bc.CheckCommittedStateRoot(root) {
+		return fmt.Errorf("state %v of checkpoint #%d is missing", root.Hex(), block.Height())
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if current := bc.CurrentBlock(); block.Height() <= current.Height() {
+		return fmt.Errorf("checkpoint #%d is not above the head #%d", block.Height(), current.Height())
+	}
+	bc.db.WriteBlock(block, blockParts, seenCommit)
+	bc.db.WriteTxLookupEntries(block)
+	bc.db.WriteAppHash(block.Height(), root)
+
+	// The state below the checkpoint was never retrieved
+	atomic.StoreUint64(&bc.prunedHeight, block.Height())
+	bc.insert(block)
+	bc.purgeStateSnapshots()
+
+	bc.chainHeadFeed.Send(events.ChainHeadEvent{Block: block})
+	return nil
+}
+
+// findReorg walks the old and new chains back to their common ancestor and
+// returns it together with the blocks leaving and joining the canonical chain,
+// both ordered by ascending height.
+func (bc *BlockChain) findReorg(oldBlock, newBlock *types.Block) (*types.Block, []*types.Block, []*types.Block, error) {
+	var dropped, added []*types.Block
+	parent := func(block *types.Block) *types.Block {
+		return bc.GetBlock(block.Header().LastBlockID.Hash, block.Height()-1)
+	}
+
+	// Reduce the longer chain to the same height as the shorter one
+	for oldBlock != nil && newBlock != nil && oldBlock.Height() > newBlock.Height() {
+		dropped = append(dropped, oldBlock)
+		oldBlock = parent(oldBlock)
+	}
+	for oldBlock != nil && newBlock != nil && newBlock.Height() > oldBlock.Height() {
+		added = append(added, newBlock)
+		newBlock = parent(newBlock)
+	}
+	// Both sides are reduced, step them back in lockstep until they meet
+	for oldBlock != nil && newBlock != nil && oldBlock.Hash() != newBlock.Hash() {
+		if oldBlock.Height() == 0 {
+			return nil, nil, nil, errors.New("no common ancestor")
+		}
+		dropped = append(dropped, oldBlock)
+		added = append(added, newBlock)
+		oldBlock, newBlock = parent(oldBlock), parent(newBlock)
+	}
+	if oldBlock == nil {
+		return nil, nil, nil, errors.New("invalid old chain")
+	}
+	if newBlock == nil {
+		return nil, nil, nil, errors.New("invalid new chain")
+	}
+	reverseBlocks(dropped)
+	reverseBlocks(added)
+	return oldBlock, dropped, added, nil
+}
+
+// reverseBlocks reverses the order of blocks in place.
+func reverseBlocks(blocks []*types.Block) {
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+}
+
 // WriteReceipts writes the transactions receipt from execution of the transactions in the given block.
 func (bc *BlockChain) WriteReceipts(receipts types.Receipts, block *types.Block) {
 	bc.mu.Lock()
@@ -396,10 +795,152 @@ func (bc *BlockChain) WriteReceipts(receipts types.Receipts, block *types.Block)
 	bc.db.WriteReceipts(block.Hash(), block.Header().Height, receipts)
 }
 
-// CommitTrie commits trie node such as statedb forcefully to disk.
+// GetReceipt retrieves the receipt of the transaction with given hash, along with the hash
+// and height of its block and its index within the block, using the tx lookup entries.
+func (bc *BlockChain) GetReceipt(txHash common.Hash) (*types.Receipt, common.Hash, uint64, uint64, error) {
+	blockHash, height, index := bc.db.ReadTxLookupEntry(txHash)
+	if blockHash == (common.Hash{}) {
+		return nil, common.Hash{}, 0, 0, ErrReceiptNotFound
+	}
+	receipts := bc.db.ReadReceipts(blockHash, height)
+	if uint64(len(receipts)) <= index {
+		return nil, common.Hash{}, 0, 0, ErrReceiptNotFound
+	}
+	return receipts[index], blockHash, height, index, nil
+}
+
+// GetLogs retrieves the logs of every receipt of the block with given hash, ordered by
+// transaction index. It returns nil if the block is unknown.
+func (bc *BlockChain) GetLogs(blockHash common.Hash) [][]*types.Log {
+	height := bc.db.ReadHeaderNumber(blockHash)
+	if height == nil {
+		return nil
+	}
+	receipts := bc.db.ReadReceipts(blockHash, *height)
+	logs := make([][]*types.Log, len(receipts))
+	for i, receipt := range receipts {
+		logs[i] = receipt.Logs
+	}
+	return logs
+}
+
+// commitTransactions executes the given transactions on top of the head state and commits the resulting
+// stateDB. Transactions which fail are reverted and skipped. It returns the new state root along with the
+// receipts of the applied transactions.
+func (bc *BlockChain) commitTransactions(txs types.Transactions, header *types.Header) (common.Hash, types.Receipts, error) {
+	var (
+		receipts = types.Receipts{}
+		usedGas  = new(uint64)
+	)
+	counter := 0
+
+	// Blockchain state at head block.
+	state, err := bc.State()
+	if err != nil {
+		bc.logger.Error("Fail to get blockchain head state", "err", err)
+		return common.Hash{}, nil, err
+	}
+
+	// GasPool
+	bc.logger.Info("header gas limit", "limit", header.GasLimit)
+	gasPool := new(types.GasPool).AddGas(header.GasLimit)
+
+	// TODO(thientn): verifies the list is sorted by nonce so tx with lower nonce is execute first.
+LOOP:
+	for _, tx := range txs {
+		state.Prepare(tx.Hash(), common.Hash{}, counter)
+		snap := state.Snapshot()
+		// TODO(thientn): confirms nil coinbase is acceptable.
+		receipt, _, err := ApplyTransaction(bc.logger, bc, gasPool, state, header, tx, usedGas, kvm.Config{
+			IsZeroFee: bc.IsZeroFee,
+		})
+		if err != nil {
+			bc.logger.Error("ApplyTransaction failed", "tx", tx.Hash().Hex(), "nonce", tx.Nonce(), "err", err)
+			state.RevertToSnapshot(snap)
+			// TODO(thientn): check error type and jump to next tx if possible
+			// kiendn: instead of return nil and err, jump to next tx
+			continue LOOP
+		}
+		counter++
+		receipts = append(receipts, receipt)
+	}
+
+	root, err := state.Commit(true)
+	if err != nil {
+		bc.logger.Error("Fail to commit new statedb after txs", "err", err)
+		return common.Hash{}, nil, err
+	}
+	if err = bc.CommitTrie(root); err != nil {
+		bc.logger.Error("Fail to write statedb trie to disk", "err", err)
+		return common.Hash{}, nil, err
+	}
+	return root, receipts, nil
+}
+
+// CommitTrie commits trie node such as statedb forcefully to disk. Unless in archive mode, the trie
+// is only referenced in memory, until PruneStateBelow drops it or FlushState persists it.
 func (bc *BlockChain) CommitTrie(root common.Hash) error {
 	triedb := bc.stateCache.TrieDB()
-	return triedb.Commit(root, false)
+	if bc.Archive {
+		return triedb.Commit(root, false)
+	}
+	bc.pruneMu.Lock()
+	defer bc.pruneMu.Unlock()
+
+	triedb.Reference(root, common.Hash{})
+	bc.liveRoots = append(bc.liveRoots, liveRoot{root: root, height: bc.CurrentBlock().Height() + 1})
+	return nil
+}
+
+// FlushState commits the state of the head block from the trie database memory to disk, so that it
+// survives a restart. Archive mode commits every state to disk right away, so there is nothing to flush.
+func (bc *BlockChain) FlushState() error {
+	if bc.Archive {
+		return nil
+	}
+	return bc.stateCache.TrieDB().Commit(bc.db.ReadAppHash(bc.CurrentBlock().Height()), false)
+}
+
+// PruneStateBelow dereferences the state tries committed for the blocks below height from the trie
+// database, so that their nodes which no other state uses are garbage collected from memory before
+// ever reaching the disk. The genesis state and every state root still used at or above height are
+// kept. Once pruned, StateAt returns an error for these heights.
+func (bc *BlockChain) PruneStateBelow(height uint64) error {
+	if bc.Archive {
+		return ErrArchivePrune
+	}
+	current := bc.CurrentBlock()
+	if height > current.Height() {
+		return fmt.Errorf("cannot prune state below height %v, head is %v", height, current.Height())
+	}
+
+	bc.pruneMu.Lock()
+	defer bc.pruneMu.Unlock()
+
+	from := atomic.LoadUint64(&bc.prunedHeight)
+	if from >= height {
+		return nil
+	}
+	// blocks without state changes share their parent's root, do not drop roots which are still in use
+	keep := map[common.Hash]struct{}{bc.db.ReadAppHash(0): {}}
+	for h := height; h <= current.Height(); h++ {
+		keep[bc.db.ReadAppHash(h)] = struct{}{}
+	}
+	var (
+		triedb = bc.stateCache.TrieDB()
+		live   = bc.liveRoots[:0]
+	)
+	for _, lr := range bc.liveRoots {
+		if _, ok := keep[lr.root]; ok || lr.height >= height {
+			live = append(live, lr)
+			continue
+		}
+		triedb.Dereference(lr.root)
+	}
+	bc.liveRoots = live
+	atomic.StoreUint64(&bc.prunedHeight, height)
+	bc.logger.Debug("Pruned state", "from", from, "to", height, "live", len(live))
+	return nil
 }
 
 // insert injects a new head block into the current block chain. This method
@@ -449,10 +990,121 @@ func (bc *BlockChain)ApplyMessage(vm base.KVM, msg types.Message, gp *types.GasP
 	return ApplyMessage(vm, msg, gp)
 }
 
+// EstimateGas returns the lowest gas limit under which a call of input with value from
+// from to to, or a contract creation if to is nil, executes successfully on top of
+// the current state. It binary searches between the intrinsic gas of the call and the
+// gas limit of the current block, capped by what from can pay for unless fees are off.
+func (bc *BlockChain) EstimateGas(from common.Address, to *common.Address, input []byte, value *big.Int) (uint64, error) {
+	if value == nil {
+		value = new(big.Int)
+	}
+	intrinsic, err := IntrinsicGas(input, to == nil)
+	if err != nil {
+		return 0, err
+	}
+	statedb, err := bc.State()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		header   = bc.CurrentHeader()
+		gasPrice = big.NewInt(1)
+		lo       = intrinsic - 1
+		hi       = header.GasLimit
+	)
+	if bc.ZeroFee() {
+		// Gas is refunded in full, so do not require from to afford it.
+		gasPrice.SetUint64(0)
+	} else {
+		balance := statedb.GetBalance(from)
+		if value.Cmp(balance) > 0 {
+			return 0, fmt.Errorf("insufficient balance for transfer")
+		}
+		allowance := new(big.Int).Sub(balance, value)
+		allowance.Div(allowance, gasPrice)
+		if allowance.IsUint64() && allowance.Uint64() < hi {
+			hi = allowance.Uint64()
+		}
+	}
+	if hi < intrinsic {
+		return 0, fmt.Errorf("gas allowance %d is below intrinsic gas %d", hi, intrinsic)
+	}
+	limit := hi
+
+	// executable runs the call on a copy of the current state under gas.
+	executable := func(gas uint64) (bool, error) {
+		statedb, err := bc.State()
+		if err != nil {
+			return false, err
+		}
+		msg := types.NewMessage(from, to, 0, value, gas, gasPrice, input, false)
+		kaiVm := kvm.NewKVM(vm.NewKVMContext(msg, header, bc), statedb, kvm.Config{IsZeroFee: bc.ZeroFee()})
+		_, _, failed, err := ApplyMessage(kaiVm, msg, new(types.GasPool).AddGas(gas))
+		return err == nil && !failed, nil
+	}
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		ok, err := executable(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	if hi == limit {
+		ok, err := executable(hi)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, fmt.Errorf("gas required exceeds allowance %d or always failing transaction", limit)
+		}
+	}
+	return hi, nil
+}
+
+// CallContract runs a read-only call of input from from to the contract at to on top of the
+// state of the block at height and returns its output. State modifications are disallowed
+// and discarded. If the contract reverts, the error is a *kvm.RevertError.
+func (bc *BlockChain) CallContract(from, to common.Address, input []byte, height uint64) ([]byte, error) {
+	block := bc.GetBlockByHeight(height)
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	statedb, err := bc.StateAt(height)
+	if err != nil {
+		return nil, err
+	}
+	header := block.Header()
+	kaiVm := kvm.NewKVM(vm.NewKVMContextFromDualNodeCall(from, header, bc), statedb, kvm.Config{})
+	ret, _, err := kaiVm.StaticCall(kvm.AccountRef(from), to, input, header.GasLimit)
+	return ret, err
+}
+
 func (bc *BlockChain) GetBlockReward() *big.Int {
 	return bc.BlockReward
 }
 
+// BlockRewardAt returns the reward of the block at height, set by the highest reward
+// milestone not above height, or BlockReward before the first milestone.
+func (bc *BlockChain) BlockRewardAt(height uint64) *big.Int {
+	reward := bc.BlockReward
+	var last *pos.RewardMilestone
+	for i := range bc.RewardSchedule {
+		m := &bc.RewardSchedule[i]
+		if m.Height <= height && (last == nil || m.Height >= last.Height) {
+			last = m
+		}
+	}
+	if last != nil {
+		reward = last.Reward
+	}
+	return reward
+}
+
 func (bc *BlockChain) GetConsensusMasterSmartContract() pos.MasterSmartContract {
 	return bc.ConsensusInfo.Master
 }
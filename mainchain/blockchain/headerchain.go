@@ -166,7 +166,7 @@ func (hc *HeaderChain) SetHead(head uint64, delFn DeleteCallback) {
 		}
 		hc.kaiDb.DeleteBlockPart(hash, height)
 
-		hc.currentHeader.Store(hc.GetHeader(hdr.LastCommitHash, hdr.Height-1))
+		hc.currentHeader.Store(hc.GetHeader(hdr.LastBlockID.Hash, hdr.Height-1))
 	}
 	// Roll back the canonical chain numbering
 	for i := height; i > head; i-- {
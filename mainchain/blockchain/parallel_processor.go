@@ -0,0 +1,153 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package blockchain
+
+import (
+	"sync"
+
+	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/kvm"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// parallelTx is a transaction of a block scheduled for parallel execution.
+type parallelTx struct {
+	index   int
+	tx      *types.Transaction
+	from    common.Address
+	to      common.Address
+	receipt *types.Receipt
+	err     error
+}
+
+// independentTxs returns the transactions of block if all of them are plain
+// transfers between externally owned accounts and no account is touched by more
+// than one of them. Otherwise the transactions conflict and nil is returned.
+func independentTxs(block *types.Block, statedb *state.StateDB, cfg kvm.Config) []*parallelTx {
+	var (
+		txs     = make([]*parallelTx, 0, len(block.Transactions()))
+		touched = make(map[common.Address]struct{})
+		gas     uint64
+	)
+	// Once a base fee is charged every transaction tips the block validator.
+	if block.BaseFee() != nil {
+		return nil
+	}
+	for i, tx := range block.Transactions() {
+		msg, err := tx.AsMessage(types.HomesteadSigner{}, nil)
+		if err != nil || msg.To() == nil {
+			return nil
+		}
+		to := *msg.To()
+		if kvm.PrecompiledContractsV0[to] != nil || cfg.Precompiles[to] != nil || statedb.GetCodeSize(to) > 0 {
+			return nil
+		}
+		if _, ok := touched[msg.From()]; ok {
+			return nil
+		}
+		touched[msg.From()] = struct{}{}
+		if to != msg.From() {
+			if _, ok := touched[to]; ok {
+				return nil
+			}
+			touched[to] = struct{}{}
+		}
+		// Leave running out of block gas to the sequential path.
+		if gas += msg.Gas(); gas > block.GasLimit() {
+			return nil
+		}
+		txs = append(txs, &parallelTx{index: i, tx: tx, from: msg.From(), to: to})
+	}
+	return txs
+}
+
+// processParallel applies the transactions of block on copies of statedb using
+// the configured number of workers and merges the touched accounts back into
+// statedb. It returns false without modifying statedb if the transactions are
+// not independent or any of them fails to apply, in which case the block has
+// to be processed sequentially.
+func (p *StateProcessor) processParallel(block *types.Block, statedb *state.StateDB, cfg kvm.Config) (types.Receipts, []*types.Log, uint64, bool) {
+	txs := independentTxs(block, statedb, cfg)
+	if len(txs) < 2 {
+		return nil, nil, 0, false
+	}
+	workers := p.concurrency
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	// Copy the state up front, copying is not safe while workers run on a copy.
+	copies := make([]*state.StateDB, workers)
+	for w := range copies {
+		copies[w] = statedb.Copy()
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(txs); i += workers {
+				var (
+					ptx     = txs[i]
+					header  = block.Header()
+					usedGas = new(uint64)
+					gp      = new(types.GasPool).AddGas(block.GasLimit())
+				)
+				copies[w].Prepare(ptx.tx.Hash(), block.Hash(), ptx.index)
+				ptx.receipt, _, ptx.err = ApplyTransaction(p.logger, p.bc, gp, copies[w], header, ptx.tx, usedGas, cfg)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for _, ptx := range txs {
+		if ptx.err != nil {
+			p.logger.Debug("Parallel transaction failed, processing sequentially", "hash", ptx.tx.Hash(), "err", ptx.err)
+			return nil, nil, 0, false
+		}
+	}
+	var (
+		receipts = make(types.Receipts, 0, len(txs))
+		allLogs  []*types.Log
+		usedGas  uint64
+	)
+	for i, ptx := range txs {
+		result := copies[i%workers]
+		mergeAccount(statedb, result, ptx.from)
+		mergeAccount(statedb, result, ptx.to)
+
+		usedGas += ptx.receipt.GasUsed
+		ptx.receipt.CumulativeGasUsed = usedGas
+		receipts = append(receipts, ptx.receipt)
+		allLogs = append(allLogs, ptx.receipt.Logs...)
+	}
+	statedb.Finalise(true)
+	return receipts, allLogs, usedGas, true
+}
+
+// mergeAccount copies the balance and nonce of addr from src into dst. Accounts
+// deleted from src are left empty in dst, so they are deleted on finalisation.
+func mergeAccount(dst, src *state.StateDB, addr common.Address) {
+	dst.SetBalance(addr, src.GetBalance(addr))
+	// GetNonce reports the stored nonce plus one for existing accounts.
+	var nonce uint64
+	if src.Exist(addr) {
+		nonce = src.GetNonce(addr) - 1
+	}
+	dst.SetNonce(addr, nonce)
+}
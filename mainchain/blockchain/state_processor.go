@@ -40,6 +40,10 @@ var (
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
 
+	// ErrFeeCapTooLow is returned if the gas price of a transaction is below the
+	// base fee of the block.
+	ErrFeeCapTooLow = errors.New("max fee per gas less than block base fee")
+
 	errInsufficientBalanceForGas = errors.New("insufficient balance to pay for gas")
 )
 
@@ -48,8 +52,9 @@ var (
 //
 // StateProcessor implements Processor.
 type StateProcessor struct {
-	logger log.Logger
-	bc     *BlockChain // Canonical block chain
+	logger      log.Logger
+	bc          *BlockChain // Canonical block chain
+	concurrency int         // Number of workers applying independent transactions, sequential if below 2
 }
 
 // NewStateProcessor initialises a new StateProcessor.
@@ -60,13 +65,28 @@ func NewStateProcessor(logger log.Logger, bc *BlockChain) *StateProcessor {
 	}
 }
 
+// SetConcurrency sets the number of workers used to apply the transactions of
+// a block in parallel when they are independent of each other. Values below 2
+// disable parallel processing.
+func (p *StateProcessor) SetConcurrency(workers int) {
+	p.concurrency = workers
+}
+
 // Process processes the state changes according to the Kardia rules by running
 // the transaction messages using the statedb.
 //
 // Process returns the receipts and logs accumulated during the process and
 // returns the amount of gas that was used in the process. If any of the
 // transactions failed to execute due to insufficient gas it will return an error.
+//
+// If concurrency is enabled and all transactions of the block are independent
+// transfers, they are applied in parallel, otherwise sequentially.
 func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg kvm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	if p.concurrency > 1 {
+		if receipts, allLogs, usedGas, ok := p.processParallel(block, statedb, cfg); ok {
+			return receipts, allLogs, usedGas, nil
+		}
+	}
 	var (
 		receipts types.Receipts
 		usedGas  = new(uint64)
@@ -93,7 +113,10 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 // for the transaction, gas used and an error if the transaction failed,
 // indicating the block was invalid.
 func ApplyTransaction(logger log.Logger, bc base.BaseBlockChain, gp *types.GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg kvm.Config) (*types.Receipt, uint64, error) {
-	msg, err := tx.AsMessage(types.HomesteadSigner{})
+	if err := types.CheckTxType(bc.Config(), new(big.Int).SetUint64(header.Height), tx); err != nil {
+		return nil, 0, err
+	}
+	msg, err := tx.AsMessage(types.HomesteadSigner{}, header.BaseFee)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -104,7 +127,7 @@ func ApplyTransaction(logger log.Logger, bc base.BaseBlockChain, gp *types.GasPo
 	// about the transaction and calling mechanisms.
 	vmenv := kvm.NewKVM(context, statedb, cfg)
 	// Apply the transaction to the current state (included in the env)
-	_, gas, failed, err := ApplyMessage(vmenv, msg, gp)
+	_, gas, failed, err := applyMessage(vmenv, msg, gp, header)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -157,6 +180,11 @@ type StateTransition struct {
 	data       []byte
 	state      base.StateDB
 	vm         base.KVM
+
+	// Set when the block charges a base fee: the base fee part of the gas price
+	// is burned and only the rest is paid to coinbase.
+	baseFee  *big.Int
+	coinbase common.Address
 }
 
 // Message represents a message sent to a contract.
@@ -230,6 +258,16 @@ func ApplyMessage(vm base.KVM, msg Message, gp *types.GasPool) ([]byte, uint64,
 	return NewStateTransition(vm, msg, gp).TransitionDb()
 }
 
+// applyMessage applies msg like ApplyMessage, charging the base fee of header if
+// it carries one.
+func applyMessage(vm base.KVM, msg Message, gp *types.GasPool, header *types.Header) ([]byte, uint64, bool, error) {
+	st := NewStateTransition(vm, msg, gp)
+	if header.BaseFee != nil {
+		st.baseFee, st.coinbase = header.BaseFee, header.Validator
+	}
+	return st.TransitionDb()
+}
+
 // to returns the recipient of the message.
 func (st *StateTransition) to() common.Address {
 	if st.msg == nil || st.msg.To() == nil /* contract creation */ {
@@ -277,6 +315,11 @@ func (st *StateTransition) preCheck() error {
 			return fmt.Errorf("nonce too low - current nonce is %v sender %v sender's nonce %v", nonce, st.msg.From().Hex(), st.msg.Nonce())
 		}
 	}
+	// Make sure the transaction pays the base fee. The zero priced system transactions calling
+	// the PoS handler are exempt.
+	if st.baseFee != nil && st.gasPrice.Cmp(st.baseFee) < 0 && !kvm.IsPosHandlerCall(st.msg.To()) {
+		return ErrFeeCapTooLow
+	}
 	return st.buyGas()
 }
 
@@ -329,9 +372,13 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 
 	// If IsZeroFee is true then refund all gas that sender spend in current transaction
 	st.refundGas(st.vm.IsZeroFee())
+	st.payTip()
 	return ret, st.gasUsed(), vmerr != nil, err
 }
 
+// refundGas returns the remaining gas to the sender and the block gas pool. If
+// refundAll is set (zero fee mode) all gas bought in buyGas is returned, so the
+// sender's balance is left unchanged by gas and the transaction reports no gas used.
 func (st *StateTransition) refundGas(refundAll bool) {
 	if refundAll {
 		st.gas = st.initialGas
@@ -352,6 +399,20 @@ func (st *StateTransition) refundGas(refundAll bool) {
 	st.gp.AddGas(st.gas)
 }
 
+// payTip pays coinbase the part of the gas price above the base fee for the gas used.
+// The base fee part is burned, as it was taken from the sender and is paid to no one.
+// A gas price below the base fee is burned entirely.
+func (st *StateTransition) payTip() {
+	if st.baseFee == nil {
+		return
+	}
+	tip := new(big.Int).Sub(st.gasPrice, st.baseFee)
+	if tip.Sign() <= 0 {
+		return
+	}
+	st.state.AddBalance(st.coinbase, tip.Mul(tip, new(big.Int).SetUint64(st.gasUsed())))
+}
+
 // gasUsed returns the amount of gas used up by the state transition.
 func (st *StateTransition) gasUsed() uint64 {
 	return st.initialGas - st.gas
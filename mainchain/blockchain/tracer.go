@@ -0,0 +1,130 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/kvm"
+	"github.com/kardiachain/go-kardia/lib/abi"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/log"
+	vm "github.com/kardiachain/go-kardia/mainchain/kvm"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// TxTrace is the result of re-executing a transaction with the struct logger attached.
+type TxTrace struct {
+	TxHash       common.Hash
+	Gas          uint64
+	Failed       bool
+	ReturnValue  []byte
+	RevertReason string
+	StructLogs   []kvm.StructLog
+}
+
+// TraceTransaction re-executes the transaction at txIndex of block against the state the
+// block was built on and returns its opcode trace. Transactions preceding txIndex are
+// replayed without tracing so that the traced one sees the same state it did originally.
+func (bc *BlockChain) TraceTransaction(block *types.Block, txIndex int, cfg *kvm.LogConfig) (*TxTrace, error) {
+	txs := block.Transactions()
+	if txIndex < 0 || txIndex >= len(txs) {
+		return nil, fmt.Errorf("transaction index %v out of range, block %v has %v transactions", txIndex, block.Height(), len(txs))
+	}
+	statedb, header, gp, err := bc.traceState(block)
+	if err != nil {
+		return nil, err
+	}
+	usedGas := new(uint64)
+	for i, tx := range txs[:txIndex] {
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		snap := statedb.Snapshot()
+		if _, _, err := ApplyTransaction(log.New(), bc, gp, statedb, header, tx, usedGas, kvm.Config{IsZeroFee: bc.IsZeroFee}); err != nil {
+			statedb.RevertToSnapshot(snap)
+		}
+	}
+	return bc.traceTx(statedb, header, gp, block.Hash(), txIndex, txs[txIndex], cfg)
+}
+
+// TraceBlock re-executes every transaction of block in order against the state the block
+// was built on and returns their opcode traces, each transaction seeing the state left by the previous ones.
+func (bc *BlockChain) TraceBlock(block *types.Block, cfg *kvm.LogConfig) ([]*TxTrace, error) {
+	statedb, header, gp, err := bc.traceState(block)
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	traces := make([]*TxTrace, len(txs))
+	for i, tx := range txs {
+		if traces[i], err = bc.traceTx(statedb, header, gp, block.Hash(), i, tx, cfg); err != nil {
+			return nil, fmt.Errorf("failed to trace transaction %v: %v", tx.Hash().Hex(), err)
+		}
+	}
+	return traces, nil
+}
+
+// traceState returns the state, header and gas pool a block's transactions are executed with.
+func (bc *BlockChain) traceState(block *types.Block) (*state.StateDB, *types.Header, *types.GasPool, error) {
+	if block.Height() == 0 {
+		return nil, nil, nil, fmt.Errorf("genesis block is not traceable")
+	}
+	statedb, err := bc.StateAt(block.Height() - 1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// ApplyTransaction accumulates gas into the header, keep the stored block untouched.
+	header := types.CopyHeader(block.Header())
+	header.GasUsed = 0
+	return statedb, header, new(types.GasPool).AddGas(header.GasLimit), nil
+}
+
+// traceTx executes tx on statedb with a struct logger attached and collects the trace.
+func (bc *BlockChain) traceTx(statedb *state.StateDB, header *types.Header, gp *types.GasPool, blockHash common.Hash, index int, tx *types.Transaction, cfg *kvm.LogConfig) (*TxTrace, error) {
+	msg, err := tx.AsMessage(types.HomesteadSigner{}, header.BaseFee)
+	if err != nil {
+		return nil, err
+	}
+	tracer := kvm.NewStructLogger(cfg)
+	statedb.Prepare(tx.Hash(), blockHash, index)
+	vmenv := kvm.NewKVM(vm.NewKVMContext(msg, header, bc), statedb, kvm.Config{
+		IsZeroFee: bc.IsZeroFee,
+		Debug:     true,
+		Tracer:    tracer,
+	})
+	ret, gas, failed, err := applyMessage(vmenv, msg, gp, header)
+	if err != nil {
+		return nil, err
+	}
+	statedb.Finalise(true)
+
+	trace := &TxTrace{
+		TxHash:      tx.Hash(),
+		Gas:         gas,
+		Failed:      failed,
+		ReturnValue: ret,
+		StructLogs:  tracer.StructLogs(),
+	}
+	if failed {
+		if reason, err := abi.UnpackRevert(ret); err == nil {
+			trace.RevertReason = reason
+		}
+	}
+	return trace, nil
+}
@@ -0,0 +1,129 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// VerifyDB checks the chain stored in db without loading it. It walks from the head block back
+// to genesis verifying that every block is the canonical one of its height, has its app hash
+// recorded and links to its parent by hash, then that the state of the resulting head is present.
+// It returns the highest block passing all checks along with the first inconsistency found, nil if
+// there is none. If repair is set and an inconsistency was found, the head pointers are moved to
+// that block, the same way loadLastState and repair recover at runtime.
+func VerifyDB(logger log.Logger, db types.StoreDB, repair bool) (*types.Block, error) {
+	var firstErr error
+	report := func(err error) {
+		logger.Error("Database inconsistency", "err", err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// Restore the last known head block, falling back to the highest canonical one
+	block := readBlockByHash(db, db.ReadHeadBlockHash())
+	if block == nil {
+		report(fmt.Errorf("head block %x missing", db.ReadHeadBlockHash()))
+		if block = highestCanonicalBlock(db); block == nil {
+			return nil, ErrNoGenesis
+		}
+	}
+
+	// Walk back to genesis, the head is the top of the lowest unbroken segment
+	var head *types.Block
+	for block != nil {
+		height := block.Height()
+		var (
+			err    error
+			parent *types.Block
+		)
+		if hash := db.ReadCanonicalHash(height); hash != block.Hash() {
+			err = fmt.Errorf("block #%d %x is not canonical, have %x", height, block.Hash(), hash)
+		} else if db.ReadAppHash(height) == (common.Hash{}) {
+			err = fmt.Errorf("app hash of block #%d %x missing", height, block.Hash())
+		}
+		if height > 0 {
+			if parent = db.ReadBlock(block.Header().LastBlockID.Hash, height-1); parent == nil && err == nil {
+				err = fmt.Errorf("parent %x of block #%d %x missing", block.Header().LastBlockID.Hash, height, block.Hash())
+			}
+			if parent == nil {
+				parent = db.ReadBlock(db.ReadCanonicalHash(height-1), height-1)
+			}
+		}
+		if err != nil {
+			report(err)
+			head = nil
+		} else if head == nil {
+			head = block
+		}
+		block = parent
+	}
+	if head == nil {
+		return nil, firstErr
+	}
+
+	// Rewind the head until its state is available
+	stateCache := state.NewDatabase(db.DB())
+	for head.Height() > 0 {
+		if _, err := state.New(logger, db.ReadAppHash(head.Height()), stateCache); err == nil {
+			break
+		}
+		report(fmt.Errorf("state of block #%d %x missing", head.Height(), head.Hash()))
+		if head = db.ReadBlock(head.Header().LastBlockID.Hash, head.Height()-1); head == nil {
+			return nil, firstErr
+		}
+	}
+
+	if firstErr != nil && repair {
+		db.WriteHeadBlockHash(head.Hash())
+		db.WriteHeadHeaderHash(head.Hash())
+		logger.Warn("Repaired head pointer", "height", head.Height(), "hash", head.Hash())
+	}
+	return head, firstErr
+}
+
+// readBlockByHash reads the block with given hash from db, nil if it is unknown.
+func readBlockByHash(db types.StoreDB, hash common.Hash) *types.Block {
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	height := db.ReadHeaderNumber(hash)
+	if height == nil {
+		return nil
+	}
+	return db.ReadBlock(hash, *height)
+}
+
+// highestCanonicalBlock returns the highest block of the contiguous canonical chain starting at genesis.
+func highestCanonicalBlock(db types.StoreDB) *types.Block {
+	var highest *types.Block
+	for height := uint64(0); ; height++ {
+		block := db.ReadBlock(db.ReadCanonicalHash(height), height)
+		if block == nil {
+			return highest
+		}
+		highest = block
+	}
+}
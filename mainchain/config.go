@@ -19,7 +19,10 @@
 package kai
 
 import (
+	"github.com/kardiachain/go-kardia/configs"
+	"github.com/kardiachain/go-kardia/kai/downloader"
 	"github.com/kardiachain/go-kardia/kai/storage"
+	"github.com/kardiachain/go-kardia/mainchain/gasprice"
 	"github.com/kardiachain/go-kardia/mainchain/genesis"
 	"github.com/kardiachain/go-kardia/mainchain/tx_pool"
 	"github.com/kardiachain/go-kardia/types"
@@ -31,6 +34,10 @@ var DefaultConfig = Config{
 	NetworkId: 1,
 
 	TxPool: tx_pool.DefaultTxPoolConfig,
+
+	GasPrice: gasprice.DefaultConfig,
+
+	SyncMode: downloader.FullSync,
 }
 
 //go:generate gencodec -type Config -field-override configMarshaling -formats toml -out gen_config.go
@@ -48,6 +55,12 @@ type Config struct {
 	// Transaction pool options
 	TxPool tx_pool.TxPoolConfig
 
+	// Gas price oracle options
+	GasPrice gasprice.Config
+
+	// Consensus options, DefaultConsensusConfig is used if nil
+	Consensus *configs.ConsensusConfig
+
 	// DbInfo stores configuration information to setup database
 	DBInfo storage.DbInfo
 
@@ -57,6 +70,27 @@ type Config struct {
 	// IsZeroFee is true then sender will be refunded all gas spent for a transaction
 	IsZeroFee bool
 
+	// MaxReorgDepth is the maximum number of blocks the chain may be rewound, 0 keeps the default
+	MaxReorgDepth uint64
+
+	// Archive retains the state of every block for historical queries, otherwise old state is pruned
+	Archive bool
+
+	// StateRetention is the number of recent blocks whose state is retained when not in archive mode, 0 keeps the default
+	StateRetention uint64
+
+	// TxExecutionWorkers is the number of workers applying the independent transactions of a block, below 2 is sequential
+	TxExecutionWorkers int
+
+	// StateSnapshotLimit is the number of recently opened states cached, 0 keeps the default and negative disables the cache
+	StateSnapshotLimit int
+
+	// SyncMode is how the chain is downloaded from peers
+	SyncMode downloader.SyncMode
+
+	// Checkpoint is a trusted block a new node starts syncing from, nil syncs from genesis
+	Checkpoint *downloader.Checkpoint
+
 	// isPrivate is true then peerId will be checked through smc to make sure that it has permission to access the chain
 	IsPrivate bool
 
@@ -0,0 +1,156 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package gasprice suggests gas prices based on the transactions of recent blocks.
+package gasprice
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// Config are the configuration parameters of the gas price oracle.
+type Config struct {
+	Blocks     int      // Number of recent blocks to sample
+	Percentile int      // Percentile of the sampled prices to suggest
+	Default    *big.Int // Price suggested when no transactions have been sampled yet
+}
+
+// DefaultConfig contains the default settings of the gas price oracle.
+var DefaultConfig = Config{
+	Blocks:     20,
+	Percentile: 60,
+	Default:    big.NewInt(1),
+}
+
+// Backend is the chain access needed by the oracle.
+type Backend interface {
+	CurrentBlock() *types.Block
+	GetBlockByHeight(height uint64) *types.Block
+}
+
+// Oracle recommends gas prices based on the lowest gas price of the
+// transactions included in recent blocks.
+type Oracle struct {
+	backend    Backend
+	blocks     int
+	percentile int
+
+	cacheLock sync.RWMutex
+	lastHead  common.Hash
+	lastPrice *big.Int
+}
+
+// NewOracle returns a new gas price oracle which samples the given backend.
+func NewOracle(backend Backend, params Config) *Oracle {
+	blocks := params.Blocks
+	if blocks < 1 {
+		log.Warn("Sanitizing invalid gasprice oracle sample blocks", "provided", params.Blocks, "updated", 1)
+		blocks = 1
+	}
+	percent := params.Percentile
+	if percent < 0 {
+		log.Warn("Sanitizing invalid gasprice oracle percentile", "provided", params.Percentile, "updated", 0)
+		percent = 0
+	}
+	if percent > 100 {
+		log.Warn("Sanitizing invalid gasprice oracle percentile", "provided", params.Percentile, "updated", 100)
+		percent = 100
+	}
+	lastPrice := params.Default
+	if lastPrice == nil {
+		lastPrice = DefaultConfig.Default
+	}
+	return &Oracle{
+		backend:    backend,
+		blocks:     blocks,
+		percentile: percent,
+		lastPrice:  new(big.Int).Set(lastPrice),
+	}
+}
+
+// SuggestPrice returns the configured percentile of the lowest gas prices of
+// the last sampled blocks. Blocks without transactions are skipped; if none of
+// them contains a transaction the previous suggestion is returned.
+func (gpo *Oracle) SuggestPrice() *big.Int {
+	head := gpo.backend.CurrentBlock()
+	if head == nil {
+		gpo.cacheLock.RLock()
+		defer gpo.cacheLock.RUnlock()
+		return new(big.Int).Set(gpo.lastPrice)
+	}
+	headHash := head.Hash()
+
+	gpo.cacheLock.RLock()
+	lastHead, lastPrice := gpo.lastHead, gpo.lastPrice
+	gpo.cacheLock.RUnlock()
+	if headHash == lastHead {
+		return new(big.Int).Set(lastPrice)
+	}
+
+	var prices []*big.Int
+	height := head.Height()
+	for i := 0; i < gpo.blocks; i++ {
+		block := head
+		if i > 0 {
+			if height < uint64(i) {
+				break
+			}
+			if block = gpo.backend.GetBlockByHeight(height - uint64(i)); block == nil {
+				break
+			}
+		}
+		if price := minGasPrice(block.Transactions()); price != nil {
+			prices = append(prices, price)
+		}
+	}
+
+	price := lastPrice
+	if len(prices) > 0 {
+		sort.Sort(bigIntArray(prices))
+		price = prices[(len(prices)-1)*gpo.percentile/100]
+	}
+
+	gpo.cacheLock.Lock()
+	gpo.lastHead = headHash
+	gpo.lastPrice = price
+	gpo.cacheLock.Unlock()
+	return new(big.Int).Set(price)
+}
+
+// minGasPrice returns the lowest gas price of txs, or nil if txs is empty.
+func minGasPrice(txs types.Transactions) *big.Int {
+	var min *big.Int
+	for _, tx := range txs {
+		if price := tx.GasPrice(); min == nil || price.Cmp(min) < 0 {
+			min = price
+		}
+	}
+	return min
+}
+
+type bigIntArray []*big.Int
+
+func (s bigIntArray) Len() int           { return len(s) }
+func (s bigIntArray) Less(i, j int) bool { return s[i].Cmp(s[j]) < 0 }
+func (s bigIntArray) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
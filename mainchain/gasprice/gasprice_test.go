@@ -0,0 +1,121 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package gasprice
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+type testBackend struct {
+	blocks []*types.Block
+}
+
+func (b *testBackend) CurrentBlock() *types.Block { return b.blocks[len(b.blocks)-1] }
+
+func (b *testBackend) GetBlockByHeight(height uint64) *types.Block {
+	if height >= uint64(len(b.blocks)) {
+		return nil
+	}
+	return b.blocks[height]
+}
+
+// newTestBackend creates a chain with one block per entry of prices, each
+// block holding one transaction per gas price. Block 0 is an empty genesis.
+func newTestBackend(prices ...[]int64) *testBackend {
+	backend := &testBackend{blocks: []*types.Block{types.NewBlockWithHeader(&types.Header{Height: 0})}}
+	for i, blockPrices := range prices {
+		header := &types.Header{Height: uint64(i + 1), Time: big.NewInt(int64(i + 1))}
+		txs := make([]*types.Transaction, len(blockPrices))
+		for j, price := range blockPrices {
+			txs[j] = types.NewTransaction(uint64(j), common.Address{}, big.NewInt(0), 21000, big.NewInt(price), nil)
+		}
+		backend.blocks = append(backend.blocks, types.NewBlockWithHeader(header).WithBody(&types.Body{Transactions: txs}))
+	}
+	return backend
+}
+
+func TestSuggestPricePercentile(t *testing.T) {
+	// The lowest prices of the blocks are 10, 20, ..., 100.
+	prices := make([][]int64, 10)
+	for i := range prices {
+		low := int64(i+1) * 10
+		prices[i] = []int64{low + 5, low, low + 1000}
+	}
+	backend := newTestBackend(prices...)
+
+	tests := []struct {
+		percentile int
+		want       int64
+	}{
+		{0, 10},
+		{50, 50},
+		{60, 60},
+		{100, 100},
+	}
+	for _, tt := range tests {
+		gpo := NewOracle(backend, Config{Blocks: 10, Percentile: tt.percentile, Default: big.NewInt(1)})
+		if have := gpo.SuggestPrice(); have.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("percentile %d: suggested price mismatch: have %v, want %v", tt.percentile, have, tt.want)
+		}
+	}
+
+	// Only the last 3 blocks are sampled: 80, 90, 100.
+	gpo := NewOracle(backend, Config{Blocks: 3, Percentile: 0, Default: big.NewInt(1)})
+	if have := gpo.SuggestPrice(); have.Cmp(big.NewInt(80)) != 0 {
+		t.Errorf("suggested price over 3 blocks mismatch: have %v, want %v", have, 80)
+	}
+}
+
+func TestSuggestPriceEmptyBlocks(t *testing.T) {
+	backend := newTestBackend(nil, nil)
+	gpo := NewOracle(backend, Config{Blocks: 10, Percentile: 60, Default: big.NewInt(7)})
+	if have := gpo.SuggestPrice(); have.Cmp(big.NewInt(7)) != 0 {
+		t.Errorf("suggested price without transactions mismatch: have %v, want %v", have, 7)
+	}
+
+	// Empty blocks are skipped rather than counted as zero prices.
+	backend = newTestBackend([]int64{30}, nil, []int64{50}, nil)
+	gpo = NewOracle(backend, Config{Blocks: 10, Percentile: 0, Default: big.NewInt(7)})
+	if have := gpo.SuggestPrice(); have.Cmp(big.NewInt(30)) != 0 {
+		t.Errorf("suggested price with empty blocks mismatch: have %v, want %v", have, 30)
+	}
+}
+
+func TestSuggestPriceTracksHead(t *testing.T) {
+	backend := newTestBackend([]int64{10}, []int64{10})
+	gpo := NewOracle(backend, Config{Blocks: 2, Percentile: 100, Default: big.NewInt(1)})
+	if have := gpo.SuggestPrice(); have.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("suggested price mismatch: have %v, want %v", have, 10)
+	}
+	// Mutating the returned price must not affect the cached suggestion.
+	gpo.SuggestPrice().SetInt64(0)
+	if have := gpo.SuggestPrice(); have.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("cached price was modified: have %v, want %v", have, 10)
+	}
+
+	next := newTestBackend([]int64{10}, []int64{10}, []int64{40})
+	backend.blocks = next.blocks
+	if have := gpo.SuggestPrice(); have.Cmp(big.NewInt(40)) != 0 {
+		t.Errorf("suggested price after new head mismatch: have %v, want %v", have, 40)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package genesis
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/kardiachain/go-kardia/kai/pos"
+	"github.com/kardiachain/go-kardia/lib/common"
+)
+
+// ConsensusConfig is the PoS genesis deployment as written in the Consensus section of a node's
+// config file.
+type (
+	ConsensusConfig struct {
+		MaxViolatePercentageAllowed uint64            `yaml:"MaxViolatePercentageAllowed"`
+		FetchNewValidatorsTime      uint64            `yaml:"FetchNewValidatorsTime"`
+		MaxValidators               uint64            `yaml:"MaxValidators"`
+		ConsensusPeriodInBlock      uint64            `yaml:"ConsensusPeriod"`
+		BlockReward                 string            `yaml:"BlockReward"`
+		RewardSchedule              []RewardMilestone `yaml:"RewardSchedule,omitempty"` // RewardSchedule overrides BlockReward from the height of each milestone onwards
+		MinimumStakes               string            `yaml:"MinimumStakes"`            // MinimumStakes defines the minimum amount that a user stakes to a node.
+		LockedPeriod                uint64            `yaml:"LockedPeriod"`             // LockedPeriod defines the period in block that user cannot withdraw staked KAI.
+		Compilation                 Compilation       `yaml:"Compilation"`
+		Deployment                  Deployment        `yaml:"Deployment"`
+	}
+	RewardMilestone struct { // RewardMilestone sets the block reward of every block from Height onwards
+		Height uint64 `yaml:"Height"`
+		Reward string `yaml:"Reward"`
+	}
+	Compilation struct { // Compilation contains compiled bytecodes and abi for Master.sol, Node.sol and Staker.sol
+		Master CompilationInfo `yaml:"Master"`
+		Staker CompilationInfo `yaml:"Staker"`
+		Node   CompilationInfo `yaml:"Node"`
+	}
+	CompilationInfo struct {
+		ByteCode string `yaml:"ByteCode"`
+		ABI      string `yaml:"ABI"`
+	}
+	Deployment struct { // Deployment contains consensus genesis information that will be created at the beginning
+		Master  MasterInfo   `yaml:"Master"`
+		Stakers []StakerInfo `yaml:"Stakers"`
+		Nodes   []NodeInfo   `yaml:"Nodes"`
+	}
+	MasterInfo struct { // MasterInfo contains master contract address and its genesis amount
+		Address       string `yaml:"Address"`
+		GenesisAmount string `yaml:"GenesisAmount"`
+	}
+	NodeInfo struct {
+		Address          string `yaml:"Address"`
+		Owner            string `yaml:"Owner"`
+		PubKey           string `yaml:"PubKey"`
+		Name             string `yaml:"Name"`
+		RewardPercentage uint16 `yaml:"RewardPercentage"` // RewardPercentage defines total KAI that all stakers receive every validated block.
+	}
+	StakerInfo struct { // StakerInfo contains genesis staker address, node that it will stake to from the beginning and stakeAmount
+		Address     string `yaml:"Address"`    // Address is predefined staker's contract address
+		Owner       string `yaml:"Owner"`      // Owner is owner's address for staker contract address
+		StakedNode  string `yaml:"StakedNode"` // StakedNode is genesis node's address that user will stake to
+		StakeAmount string `yaml:"StakeAmount"`
+	}
+)
+
+// ConsensusInfo converts the config to the consensus info of the genesis.
+func (c *ConsensusConfig) ConsensusInfo() (pos.ConsensusInfo, error) {
+	genesisAmount, _ := big.NewInt(0).SetString(c.Deployment.Master.GenesisAmount, 10)
+	minimumStakes, _ := big.NewInt(0).SetString(c.MinimumStakes, 10)
+	blockReward, _ := big.NewInt(0).SetString(c.BlockReward, 10)
+	rewardSchedule, err := c.rewardSchedule()
+	if err != nil {
+		return pos.ConsensusInfo{}, err
+	}
+	info := pos.ConsensusInfo{
+		BlockReward:                 blockReward,
+		RewardSchedule:              rewardSchedule,
+		FetchNewValidatorsTime:      c.FetchNewValidatorsTime,
+		MaxValidators:               c.MaxValidators,
+		ConsensusPeriodInBlock:      c.ConsensusPeriodInBlock,
+		MinimumStakes:               minimumStakes,
+		MaxViolatePercentageAllowed: c.MaxViolatePercentageAllowed,
+		LockedPeriod:                c.LockedPeriod,
+		Master: pos.MasterSmartContract{
+			Address:       common.HexToAddress(c.Deployment.Master.Address),
+			ByteCode:      common.Hex2Bytes(c.Compilation.Master.ByteCode),
+			ABI:           strings.Replace(c.Compilation.Master.ABI, "'", "\"", -1),
+			GenesisAmount: ToCell(genesisAmount.Int64()),
+		},
+		Nodes: pos.Nodes{
+			ABI:         strings.Replace(c.Compilation.Node.ABI, "'", "\"", -1),
+			ByteCode:    common.Hex2Bytes(c.Compilation.Node.ByteCode),
+			GenesisInfo: make([]pos.GenesisNodeInfo, 0),
+		},
+		Stakers: pos.Stakers{
+			ABI:         strings.Replace(c.Compilation.Staker.ABI, "'", "\"", -1),
+			ByteCode:    common.Hex2Bytes(c.Compilation.Staker.ByteCode),
+			GenesisInfo: make([]pos.GenesisStakeInfo, 0),
+		},
+	}
+	for _, n := range c.Deployment.Nodes {
+		info.Nodes.GenesisInfo = append(info.Nodes.GenesisInfo, pos.GenesisNodeInfo{
+			Address:          common.HexToAddress(n.Address),
+			Owner:            common.HexToAddress(n.Owner),
+			PubKey:           n.PubKey,
+			Name:             n.Name,
+			RewardPercentage: n.RewardPercentage,
+		})
+	}
+	for _, s := range c.Deployment.Stakers {
+		stakeAmount, _ := big.NewInt(0).SetString(s.StakeAmount, 10)
+		info.Stakers.GenesisInfo = append(info.Stakers.GenesisInfo, pos.GenesisStakeInfo{
+			Address:     common.HexToAddress(s.Address),
+			Owner:       common.HexToAddress(s.Owner),
+			StakedNode:  common.HexToAddress(s.StakedNode),
+			StakeAmount: ToCell(stakeAmount.Int64()),
+		})
+	}
+	return info, nil
+}
+
+// rewardSchedule gets the block reward milestones, nil if unset. Milestones must be in strictly
+// increasing height order.
+func (c *ConsensusConfig) rewardSchedule() ([]pos.RewardMilestone, error) {
+	if len(c.RewardSchedule) == 0 {
+		return nil, nil
+	}
+	schedule := make([]pos.RewardMilestone, 0, len(c.RewardSchedule))
+	for i, m := range c.RewardSchedule {
+		reward, ok := new(big.Int).SetString(m.Reward, 10)
+		if !ok || reward.Sign() < 0 {
+			return nil, fmt.Errorf("invalid block reward %q at height %d", m.Reward, m.Height)
+		}
+		if i > 0 && m.Height <= schedule[i-1].Height {
+			return nil, fmt.Errorf("reward milestone at height %d does not follow height %d", m.Height, schedule[i-1].Height)
+		}
+		schedule = append(schedule, pos.RewardMilestone{Height: m.Height, Reward: reward})
+	}
+	return schedule, nil
+}
@@ -23,6 +23,9 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
 
@@ -32,6 +35,7 @@ import (
 	"github.com/kardiachain/go-kardia/kai/pos"
 	"github.com/kardiachain/go-kardia/kai/state"
 	"github.com/kardiachain/go-kardia/kvm"
+	"github.com/kardiachain/go-kardia/lib/abi"
 	"github.com/kardiachain/go-kardia/lib/common"
 	"github.com/kardiachain/go-kardia/lib/log"
 	"github.com/kardiachain/go-kardia/types"
@@ -50,6 +54,17 @@ type Genesis struct {
 	Alloc     GenesisAlloc         `json:"alloc"      gencodec:"required"`
 	ConsensusInfo pos.ConsensusInfo
 	KardiaSmartContracts    []*types.KardiaSmartcontract `json:"kardiaSmartContracts"`
+	Contracts               []GenesisContract            `json:"contracts,omitempty"`
+}
+
+// GenesisContract is a contract which is deployed in the genesis block by running its creation code,
+// so that its constructor can initialize its state.
+type GenesisContract struct {
+	Address         common.Address `json:"address"`
+	ByteCode        []byte         `json:"byteCode"` // creation code, constructor args are appended to it
+	Abi             string         `json:"abi,omitempty"`
+	ConstructorArgs []string       `json:"constructorArgs,omitempty"` // converted to the constructor's input types defined in Abi
+	Balance         *big.Int       `json:"balance,omitempty"`
 }
 
 // GenesisAlloc specifies the initial state that is part of the genesis block.
@@ -180,6 +195,15 @@ func (g *Genesis) ToBlock(logger log.Logger, db kaidb.Database) *types.Block {
 	if g.GasLimit == 0 {
 		g.GasLimit = GenesisGasLimit
 	}
+	for _, contract := range g.Contracts {
+		code, err := contract.CreationCode()
+		if err != nil {
+			panic(err)
+		}
+		if err := kvm.InitGenesisContract(statedb, g.GasLimit, contract.Address, code, contract.Balance); err != nil {
+			panic(err)
+		}
+	}
 	// init pos genesis here
 	if !statedb.Exist(g.ConsensusInfo.Master.Address) && g.ConsensusInfo.Master.Address.Hex() != (common.Address{}).Hex() {
 		if err := kvm.InitGenesisConsensus(statedb, g.GasLimit, g.ConsensusInfo); err != nil {
@@ -210,6 +234,11 @@ func (g *Genesis) Commit(logger log.Logger, db types.StoreDB) (*types.Block, err
 		return nil, fmt.Errorf("can't commit genesis block with height > 0")
 	}
 
+	// store contracts' abi so that they can be called by method name
+	for _, smc := range g.KardiaSmartContracts {
+		db.WriteEvent(smc)
+	}
+
 	partsSet := block.MakePartSet(types.BlockPartSizeBytes)
 	db.WriteBlock(block, partsSet, &types.Commit{})
 	db.WriteReceipts(block.Hash(), block.Height(), nil)
@@ -309,6 +338,73 @@ func GenesisAllocFromAccountAndContract(accountData map[string]*big.Int, contrac
 	return ga, nil
 }
 
+// CreationCode returns contract's bytecode followed by its packed constructor args.
+func (c *GenesisContract) CreationCode() ([]byte, error) {
+	if len(c.ConstructorArgs) == 0 {
+		return c.ByteCode, nil
+	}
+	contractAbi, err := abi.JSON(strings.NewReader(strings.Replace(c.Abi, "'", "\"", -1)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid abi of genesis contract %v: %v", c.Address.Hex(), err)
+	}
+	inputs := contractAbi.Constructor.Inputs
+	if len(inputs) != len(c.ConstructorArgs) {
+		return nil, fmt.Errorf("genesis contract %v expects %v constructor args, got %v", c.Address.Hex(), len(inputs), len(c.ConstructorArgs))
+	}
+	args := make([]interface{}, len(inputs))
+	for i, input := range inputs {
+		if args[i], err = convertArg(input.Type, c.ConstructorArgs[i]); err != nil {
+			return nil, fmt.Errorf("invalid constructor arg %v of genesis contract %v: %v", input.Name, c.Address.Hex(), err)
+		}
+	}
+	packed, err := contractAbi.Pack("", args...)
+	if err != nil {
+		return nil, err
+	}
+	return append(common.CopyBytes(c.ByteCode), packed...), nil
+}
+
+// convertArg converts a string into the go type used by abi to pack t.
+func convertArg(t abi.Type, arg string) (interface{}, error) {
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		if t.Type == reflect.TypeOf(&big.Int{}) {
+			v, ok := new(big.Int).SetString(arg, 10)
+			if !ok {
+				return nil, fmt.Errorf("invalid number %v", arg)
+			}
+			return v, nil
+		}
+		v := reflect.New(t.Type).Elem()
+		if t.T == abi.IntTy {
+			i, err := strconv.ParseInt(arg, 10, t.Size)
+			if err != nil {
+				return nil, err
+			}
+			v.SetInt(i)
+		} else {
+			u, err := strconv.ParseUint(arg, 10, t.Size)
+			if err != nil {
+				return nil, err
+			}
+			v.SetUint(u)
+		}
+		return v.Interface(), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(arg)
+	case abi.StringTy:
+		return arg, nil
+	case abi.AddressTy:
+		if !common.IsHexAddress(arg) {
+			return nil, fmt.Errorf("invalid address %v", arg)
+		}
+		return common.HexToAddress(arg), nil
+	case abi.BytesTy:
+		return common.FromHex(arg), nil
+	}
+	return nil, fmt.Errorf("unsupported type %v", t.String())
+}
+
 // ToCell converts KAI to CELL. eg: amount * 10^18
 func ToCell(amount int64) *big.Int {
 	cell := big.NewInt(amount)
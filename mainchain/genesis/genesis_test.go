@@ -2,12 +2,113 @@ package genesis
 
 import (
 	"math"
+	"math/big"
 	"testing"
 
+	"github.com/kardiachain/go-kardia/configs"
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/kai/storage/kvstore"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestToCell(t *testing.T) {
 	cell := ToCell(int64(math.Pow(10, 6)))
 	assert.Equal(t, len(cell.String()), 25)
 }
+
+// genesisContractCode stores its constructor arg (the last 32 bytes of the creation code) in slot 0,
+// then deploys a runtime code which returns slot 0.
+var genesisContractCode = common.Hex2Bytes("6020803803600039600051600055600b601a600039600b6000f360005460005260206000f3")
+
+const genesisContractAbi = `[
+	{"inputs": [{"name": "value", "type": "uint256"}], "payable": false, "stateMutability": "nonpayable", "type": "constructor"},
+	{"constant": true, "inputs": [], "name": "value", "outputs": [{"name": "", "type": "uint256"}], "payable": false, "stateMutability": "view", "type": "function"}
+]`
+
+func TestGenesisContractConstructorArgs(t *testing.T) {
+	address := common.HexToAddress("0x0B")
+	g := &Genesis{
+		Config:   configs.TestnetChainConfig,
+		GasLimit: 16777216,
+		Contracts: []GenesisContract{{
+			Address:         address,
+			ByteCode:        genesisContractCode,
+			Abi:             genesisContractAbi,
+			ConstructorArgs: []string{"42"},
+			Balance:         ToCell(100),
+		}},
+		KardiaSmartContracts: []*types.KardiaSmartcontract{{
+			SmcAddress: address.Hex(),
+			SmcAbi:     genesisContractAbi,
+			MasterSmc:  address.Hex(),
+			MasterAbi:  genesisContractAbi,
+		}},
+	}
+	db := kvstore.NewStoreDB(memorydb.New())
+	block, err := g.Commit(log.New(), db)
+	require.NoError(t, err)
+
+	statedb, err := state.New(log.New(), block.AppHash(), state.NewDatabase(db.DB()))
+	require.NoError(t, err)
+	assert.Equal(t, common.BigToHash(big.NewInt(42)), statedb.GetState(address, common.Hash{}))
+	assert.Equal(t, common.Hex2Bytes("60005460005260206000f3"), statedb.GetCode(address))
+	assert.Equal(t, ToCell(100), statedb.GetBalance(address))
+	assert.NotNil(t, db.ReadSmartContractAbi(address.Hex()))
+
+	// the contract is deployed again with the same state, so the genesis hash is stable
+	assert.Equal(t, block.Hash(), g.ToBlock(log.New(), nil).Hash())
+}
+
+func TestGenesisContractInvalidConstructorArgs(t *testing.T) {
+	for _, args := range [][]string{{}, {"42", "43"}, {"abc"}} {
+		c := &GenesisContract{Address: common.HexToAddress("0x0B"), ByteCode: genesisContractCode, Abi: genesisContractAbi, ConstructorArgs: args}
+		code, err := c.CreationCode()
+		if len(args) == 0 {
+			require.NoError(t, err)
+			assert.Equal(t, genesisContractCode, code)
+			continue
+		}
+		assert.Error(t, err, "args %v", args)
+	}
+}
+
+// testConsensusConfig returns a consensus config with the given reward schedule.
+func testConsensusConfig(schedule []RewardMilestone) *ConsensusConfig {
+	return &ConsensusConfig{
+		BlockReward:    "100",
+		MinimumStakes:  "1",
+		RewardSchedule: schedule,
+		Deployment:     Deployment{Master: MasterInfo{GenesisAmount: "1"}},
+	}
+}
+
+func TestConsensusConfigRewardSchedule(t *testing.T) {
+	if info, err := testConsensusConfig(nil).ConsensusInfo(); err != nil || info.RewardSchedule != nil {
+		t.Fatalf("unset reward schedule mismatch: have %v, %v", info.RewardSchedule, err)
+	}
+	info, err := testConsensusConfig([]RewardMilestone{
+		{Height: 10, Reward: "50"},
+		{Height: 20, Reward: "25"},
+	}).ConsensusInfo()
+	if err != nil {
+		t.Fatalf("failed to get reward schedule: %v", err)
+	}
+	if schedule := info.RewardSchedule; len(schedule) != 2 || schedule[0].Height != 10 || schedule[0].Reward.Int64() != 50 || schedule[1].Height != 20 || schedule[1].Reward.Int64() != 25 {
+		t.Errorf("reward schedule mismatch: have %+v", schedule)
+	}
+	for _, bad := range [][]RewardMilestone{
+		{{Height: 10, Reward: "bad"}},
+		{{Height: 10, Reward: "-1"}},
+		{{Height: 20, Reward: "50"}, {Height: 10, Reward: "25"}},
+		{{Height: 10, Reward: "50"}, {Height: 10, Reward: "25"}},
+	} {
+		if _, err := testConsensusConfig(bad).ConsensusInfo(); err == nil {
+			t.Errorf("invalid reward schedule %+v accepted", bad)
+		}
+	}
+}
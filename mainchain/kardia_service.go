@@ -20,8 +20,11 @@
 package kai
 
 import (
+	"fmt"
+
 	"github.com/kardiachain/go-kardia/configs"
 	"github.com/kardiachain/go-kardia/consensus"
+	"github.com/kardiachain/go-kardia/kai/downloader"
 	"github.com/kardiachain/go-kardia/kai/service"
 	serviceconst "github.com/kardiachain/go-kardia/kai/service/const"
 	"github.com/kardiachain/go-kardia/kvm"
@@ -29,6 +32,7 @@ import (
 	"github.com/kardiachain/go-kardia/lib/log"
 	"github.com/kardiachain/go-kardia/lib/p2p"
 	"github.com/kardiachain/go-kardia/mainchain/blockchain"
+	"github.com/kardiachain/go-kardia/mainchain/gasprice"
 	"github.com/kardiachain/go-kardia/mainchain/genesis"
 	"github.com/kardiachain/go-kardia/mainchain/tx_pool"
 	"github.com/kardiachain/go-kardia/node"
@@ -66,6 +70,8 @@ type KardiaService struct {
 	protocolManager *service.ProtocolManager
 	blockchain      *blockchain.BlockChain
 	csManager       *consensus.ConsensusManager
+	gpo             *gasprice.Oracle
+	downloader      *downloader.Downloader
 
 	subService KardiaSubService
 
@@ -113,15 +119,46 @@ func newKardiaService(ctx *node.ServiceContext, config *Config) (*KardiaService,
 		return nil, err
 	}
 
-	consensusConfig := configs.DefaultConsensusConfig()
+	consensusConfig := config.Consensus
+	if consensusConfig == nil {
+		consensusConfig = configs.DefaultConsensusConfig()
+	}
+	if err := consensusConfig.ValidateBasic(); err != nil {
+		return nil, fmt.Errorf("invalid consensus config: %v", err)
+	}
 
 	// Set zeroFee to blockchain
 	kai.blockchain.IsZeroFee = config.IsZeroFee
+	if config.MaxReorgDepth > 0 {
+		kai.blockchain.MaxReorgDepth = config.MaxReorgDepth
+	}
+	kai.blockchain.Archive = config.Archive
+	if config.StateRetention > 0 {
+		kai.blockchain.StateRetention = config.StateRetention
+	}
+	kai.blockchain.Processor().SetConcurrency(config.TxExecutionWorkers)
+	if config.StateSnapshotLimit != 0 {
+		kai.blockchain.SetStateSnapshotLimit(config.StateSnapshotLimit)
+	}
+	kai.blockchain.ConsensusInfo = config.Genesis.ConsensusInfo
+	// Recover the state lost above the last flushed one when the node went down
+	if err := kai.blockchain.ReplayBlocks(); err != nil {
+		return nil, err
+	}
 	kai.txPool = tx_pool.NewTxPool(config.TxPool, kai.chainConfig, kai.blockchain)
+	kai.txPool.SetAcceptTxs(config.AcceptTxs)
+	kai.gpo = gasprice.NewOracle(kai.blockchain, config.GasPrice)
+	if !config.SyncMode.IsValid() {
+		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
+	}
+	if config.Checkpoint != nil {
+		if err := config.Checkpoint.ValidateBasic(); err != nil {
+			return nil, fmt.Errorf("invalid checkpoint: %v", err)
+		}
+	}
 	if consensusConfig.WaitForTxs() {
 		kai.txPool.EnableTxsAvailable()
 	}
-	kai.blockchain.ConsensusInfo = config.Genesis.ConsensusInfo
 
 	// Initialization for consensus.
 	block := kai.blockchain.CurrentBlock()
@@ -179,6 +216,8 @@ func newKardiaService(ctx *node.ServiceContext, config *Config) (*KardiaService,
 		return nil, err
 	}
 	kai.protocolManager.SetAcceptTxs(config.AcceptTxs)
+	kai.downloader = downloader.New(config.SyncMode, config.Checkpoint, kai.blockchain, kai.csManager, logger)
+	kai.protocolManager.SetDownloader(kai.downloader)
 	kai.csManager.SetProtocol(kai.protocolManager)
 
 	return kai, nil
@@ -188,17 +227,30 @@ func newKardiaService(ctx *node.ServiceContext, config *Config) (*KardiaService,
 // TODO: move this outside of kai package to customize kai.Config
 func NewKardiaService(ctx *node.ServiceContext) (node.Service, error) {
 	chainConfig := ctx.Config.MainChainConfig
+	gpoConfig := gasprice.DefaultConfig
+	if chainConfig.GasPrice != nil {
+		gpoConfig = *chainConfig.GasPrice
+	}
 	kai, err := newKardiaService(ctx, &Config{
-		NetworkId:   chainConfig.NetworkId,
-		ServiceName: chainConfig.ServiceName,
-		ChainId:     chainConfig.ChainId,
-		DBInfo:      chainConfig.DBInfo,
-		Genesis:     chainConfig.Genesis,
-		TxPool:      chainConfig.TxPool,
-		AcceptTxs:   chainConfig.AcceptTxs,
-		IsZeroFee:   chainConfig.IsZeroFee,
-		IsPrivate:   chainConfig.IsPrivate,
-		BaseAccount: chainConfig.BaseAccount,
+		NetworkId:          chainConfig.NetworkId,
+		ServiceName:        chainConfig.ServiceName,
+		ChainId:            chainConfig.ChainId,
+		DBInfo:             chainConfig.DBInfo,
+		Genesis:            chainConfig.Genesis,
+		TxPool:             chainConfig.TxPool,
+		GasPrice:           gpoConfig,
+		Consensus:          chainConfig.Consensus,
+		AcceptTxs:          chainConfig.AcceptTxs,
+		IsZeroFee:          chainConfig.IsZeroFee,
+		MaxReorgDepth:      chainConfig.MaxReorgDepth,
+		Archive:            chainConfig.Archive,
+		StateRetention:     chainConfig.StateRetention,
+		TxExecutionWorkers: chainConfig.TxExecutionWorkers,
+		StateSnapshotLimit: chainConfig.StateSnapshotLimit,
+		SyncMode:           chainConfig.SyncMode,
+		Checkpoint:         chainConfig.Checkpoint,
+		IsPrivate:          chainConfig.IsPrivate,
+		BaseAccount:        chainConfig.BaseAccount,
 	})
 
 	if err != nil {
@@ -248,6 +300,9 @@ func (s *KardiaService) Stop() error {
 	if s.subService != nil {
 		s.subService.Stop()
 	}
+	if err := s.blockchain.FlushState(); err != nil {
+		s.logger.Error("Fail to flush state", "err", err)
+	}
 
 	close(s.shutdownChan)
 
@@ -274,10 +329,37 @@ func (s *KardiaService) APIs() []rpc.API {
 			Service:   NewPublicAccountAPI(s),
 			Public:    true,
 		},
+		{
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   NewPrivateAdminAPI(s),
+			Public:    false,
+		},
 	}
 }
 
+// Halt puts the node into maintenance mode: it stops proposing blocks and accepting new
+// transactions while P2P and read APIs stay alive.
+func (s *KardiaService) Halt() {
+	s.csManager.Halt()
+	s.txPool.SetAcceptTxs(0)
+}
+
+// Resume takes the node out of maintenance mode, accepting transactions again only if the
+// node is configured to.
+func (s *KardiaService) Resume() {
+	s.txPool.SetAcceptTxs(s.config.AcceptTxs)
+	s.csManager.Resume()
+}
+
+// IsHalted returns whether the node is in maintenance mode.
+func (s *KardiaService) IsHalted() bool {
+	return s.csManager.IsHalted()
+}
+
 func (s *KardiaService) TxPool() *tx_pool.TxPool            { return s.txPool }
+func (s *KardiaService) GasPriceOracle() *gasprice.Oracle   { return s.gpo }
+func (s *KardiaService) Downloader() *downloader.Downloader { return s.downloader }
 func (s *KardiaService) BlockChain() *blockchain.BlockChain { return s.blockchain }
 func (s *KardiaService) ChainConfig() *types.ChainConfig    { return s.chainConfig }
 func (s *KardiaService) DB() types.StoreDB                  { return s.kaiDb }
@@ -97,6 +97,9 @@ func NewKVMContext(msg types.Message, header *types.Header, chain base.BaseBlock
 		Time:        new(big.Int).Set(header.Time),
 		GasLimit:    header.GasLimit,
 		GasPrice:    new(big.Int).Set(msg.GasPrice()),
+		GasFeeCap:   msg.GasFeeCap(),
+		GasTipCap:   msg.GasTipCap(),
+		BaseFee:     header.BaseFee,
 		Chain: chain,
 	}
 }
@@ -0,0 +1,46 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/kardiachain/go-kardia/lib/common"
+	kai "github.com/kardiachain/go-kardia/mainchain"
+)
+
+func TestComputeContractAddress(t *testing.T) {
+	api := kai.NewPublicKaiAPI(nil)
+	for nonce, expected := range []string{
+		"0xcd234a471b72ba2f1ccf0a70fcaba648a5eecd8d",
+		"0x343c43a37d37dff08ae8c4a11544c718abb4fcf8",
+	} {
+		addr, err := api.ComputeContractAddress("0x6ac7ea33f8831ea9dcc53393aaa88b25a785dbf0", uint64(nonce))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr != common.HexToAddress(expected).Hex() {
+			t.Errorf("nonce %d: expected %v, got %v", nonce, common.HexToAddress(expected).Hex(), addr)
+		}
+	}
+
+	if _, err := api.ComputeContractAddress("not an address", 0); err == nil {
+		t.Error("expected error for invalid sender address")
+	}
+}
@@ -0,0 +1,827 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package tests
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/kardiachain/go-kardia/kai/events"
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/kai/pos"
+	"github.com/kardiachain/go-kardia/kai/storage/kvstore"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/mainchain/blockchain"
+	"github.com/kardiachain/go-kardia/mainchain/tx_pool"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// extendChain writes n empty blocks on top of the current head of bc.
+func extendChain(t *testing.T, bc *blockchain.BlockChain, n int) {
+	for i := 0; i < n; i++ {
+		writeChildBlock(t, bc, bc.CurrentBlock(), 1)
+	}
+}
+
+// writeChildBlock writes an empty block on top of parent, using timeOffset to
+// tell apart siblings of the same parent, and returns it.
+func writeChildBlock(t *testing.T, bc *blockchain.BlockChain, parent *types.Block, timeOffset int64) *types.Block {
+	header := &types.Header{
+		Height:      parent.Height() + 1,
+		Time:        big.NewInt(parent.Time().Int64() + timeOffset),
+		GasLimit:    parent.GasLimit(),
+		LastBlockID: types.BlockID{Hash: parent.Hash()},
+		AppHash:     parent.AppHash(),
+	}
+	block := types.NewBlock(header, nil, &types.Commit{})
+	if err := bc.WriteBlockWithoutState(block, block.MakePartSet(types.BlockPartSizeBytes), &types.Commit{}); err != nil {
+		t.Fatal(err)
+	}
+	bc.WriteAppHash(block.Height(), bc.ReadAppHash(parent.Height()))
+	return block
+}
+
+// writeStateBlock writes a block on top of the head whose state credits one more account, committing
+// that state as processing the block would, and returns it.
+func writeStateBlock(t *testing.T, bc *blockchain.BlockChain) *types.Block {
+	statedb, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := bc.CurrentBlock()
+	statedb.AddBalance(common.BigToAddress(new(big.Int).SetUint64(parent.Height()+1)), big.NewInt(1))
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.CommitTrie(root); err != nil {
+		t.Fatal(err)
+	}
+	block := writeChildBlock(t, bc, parent, 1)
+	bc.WriteAppHash(block.Height(), root)
+	return block
+}
+
+// checkReorgBlocks compares the heights and hashes of two block lists.
+func checkReorgBlocks(t *testing.T, kind string, have, want []*types.Block) {
+	if len(have) != len(want) {
+		t.Fatalf("%s blocks count mismatch: have %d, want %d", kind, len(have), len(want))
+	}
+	for i := range want {
+		if have[i].Hash() != want[i].Hash() {
+			t.Errorf("%s block %d mismatch: have #%d %x, want #%d %x", kind, i, have[i].Height(), have[i].Hash(), want[i].Height(), want[i].Hash())
+		}
+	}
+}
+
+func TestSetHeadMaxReorgDepth(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	bc.MaxReorgDepth = 3
+	extendChain(t, bc, 10)
+	if height := bc.CurrentBlock().Height(); height != 10 {
+		t.Fatalf("chain height mismatch: have %d, want %d", height, 10)
+	}
+
+	// Rewinding 4 blocks exceeds the limit and must leave the chain untouched.
+	if err := bc.SetHead(6); err != blockchain.ErrReorgTooDeep {
+		t.Fatalf("deep reorg error mismatch: have %v, want %v", err, blockchain.ErrReorgTooDeep)
+	}
+	if height := bc.CurrentBlock().Height(); height != 10 {
+		t.Fatalf("chain was rewound by a refused reorg: height %d", height)
+	}
+	if block := bc.GetBlockByHeight(10); block == nil {
+		t.Fatal("head block deleted by a refused reorg")
+	}
+
+	// Rewinding within the limit is allowed.
+	if err := bc.SetHead(7); err != nil {
+		t.Fatalf("reorg within the limit failed: %v", err)
+	}
+	if height := bc.CurrentBlock().Height(); height != 7 {
+		t.Errorf("chain height after reorg mismatch: have %d, want %d", height, 7)
+	}
+
+	// A zero limit disables the check.
+	bc.MaxReorgDepth = 0
+	if err := bc.SetHead(1); err != nil {
+		t.Fatalf("unlimited reorg failed: %v", err)
+	}
+	if height := bc.CurrentBlock().Height(); height != 1 {
+		t.Errorf("chain height after unlimited reorg mismatch: have %d, want %d", height, 1)
+	}
+}
+
+func TestChainReorgEvent(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	extendChain(t, bc, 5)
+
+	var oldChain []*types.Block
+	for height := uint64(4); height <= 5; height++ {
+		oldChain = append(oldChain, bc.GetBlockByHeight(height))
+	}
+	ancestor := bc.GetBlockByHeight(3)
+
+	reorgCh := make(chan events.ChainReorgEvent, 2)
+	sub := bc.SubscribeChainReorgEvent(reorgCh)
+	defer sub.Unsubscribe()
+
+	// Extending the head must not be reported as a reorg.
+	extendChain(t, bc, 1)
+	oldChain = append(oldChain, bc.CurrentBlock())
+	select {
+	case ev := <-reorgCh:
+		t.Fatalf("unexpected reorg event when extending the head: %v", ev)
+	default:
+	}
+
+	// Switching to a side chain forking off block 3 drops blocks 4-6.
+	side := writeChildBlock(t, bc, ancestor, 2)
+	select {
+	case ev := <-reorgCh:
+		if ev.CommonAncestor.Hash() != ancestor.Hash() {
+			t.Errorf("common ancestor mismatch: have %x, want %x", ev.CommonAncestor.Hash(), ancestor.Hash())
+		}
+		checkReorgBlocks(t, "dropped", ev.Dropped, oldChain)
+		checkReorgBlocks(t, "added", ev.Added, []*types.Block{side})
+	case <-time.After(time.Second):
+		t.Fatal("side chain reorg event not fired")
+	}
+	if head := bc.CurrentBlock(); head.Hash() != side.Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head.Hash(), side.Hash())
+	}
+	if block := bc.GetBlockByHeight(5); block != nil {
+		t.Errorf("dropped block #5 still canonical")
+	}
+
+	// Rewinding the head drops the blocks above the new head.
+	extendChain(t, bc, 2)
+	var rewound []*types.Block
+	for height := uint64(4); height <= 6; height++ {
+		rewound = append(rewound, bc.GetBlockByHeight(height))
+	}
+	if err := bc.SetHead(3); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	select {
+	case ev := <-reorgCh:
+		if ev.CommonAncestor.Hash() != ancestor.Hash() {
+			t.Errorf("common ancestor mismatch: have %x, want %x", ev.CommonAncestor.Hash(), ancestor.Hash())
+		}
+		checkReorgBlocks(t, "dropped", ev.Dropped, rewound)
+		checkReorgBlocks(t, "added", ev.Added, nil)
+	case <-time.After(time.Second):
+		t.Fatal("rewind reorg event not fired")
+	}
+}
+
+func TestStateAtArchiveMode(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	if !bc.Archive {
+		t.Fatal("archive mode must be the default")
+	}
+	bc.Archive, bc.StateRetention = false, 3
+	extendChain(t, bc, 10)
+
+	// Pruned mode only serves the retention window.
+	for _, height := range []uint64{7, 8, 10} {
+		if _, err := bc.StateAt(height); err != nil {
+			t.Errorf("state at height %d within retention window: %v", height, err)
+		}
+	}
+	for _, height := range []uint64{0, 1, 6} {
+		_, err := bc.StateAt(height)
+		if err == nil {
+			t.Fatalf("state at height %d below retention window must fail", height)
+		}
+		if perr, ok := err.(*blockchain.StatePrunedError); !ok || perr.Height != height || perr.Oldest != 7 {
+			t.Errorf("unexpected error for height %d: %v", height, err)
+		}
+	}
+
+	// Archive mode serves every height.
+	bc.Archive = true
+	for height := uint64(0); height <= 10; height++ {
+		if _, err := bc.StateAt(height); err != nil {
+			t.Errorf("state at height %d in archive mode: %v", height, err)
+		}
+	}
+}
+
+func TestPruneStateBelow(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	bc.Archive = false
+	extendChain(t, bc, 10)
+
+	if err := bc.PruneStateBelow(11); err == nil {
+		t.Fatal("pruning above the head must fail")
+	}
+	if err := bc.PruneStateBelow(6); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	for _, height := range []uint64{1, 3, 5} {
+		_, err := bc.StateAt(height)
+		if err == nil {
+			t.Fatalf("state at pruned height %d must fail", height)
+		}
+		if _, ok := err.(*blockchain.StatePrunedError); !ok {
+			t.Errorf("unexpected error for height %d: %v", height, err)
+		}
+	}
+	// The genesis and recent states are kept.
+	for _, height := range []uint64{0, 6, 8, 10} {
+		if _, err := bc.StateAt(height); err != nil {
+			t.Errorf("state at height %d: %v", height, err)
+		}
+	}
+	// Pruning is idempotent and never moves backwards.
+	if err := bc.PruneStateBelow(4); err != nil {
+		t.Fatalf("failed to prune state again: %v", err)
+	}
+	if _, err := bc.StateAt(5); err == nil {
+		t.Error("state at height 5 must stay pruned")
+	}
+
+	bc.Archive = true
+	if err := bc.PruneStateBelow(8); err != blockchain.ErrArchivePrune {
+		t.Errorf("pruning in archive mode: have %v, want %v", err, blockchain.ErrArchivePrune)
+	}
+}
+
+// Tests that pruned state never reaches the disk, while the head state survives a restart once flushed.
+func TestPruneStateDiskUsage(t *testing.T) {
+	stored := func(archive bool) int {
+		diskdb := memorydb.New()
+		bc := setupStateTransitionTestDB(t, kvstore.NewStoreDB(diskdb))
+		bc.Archive, bc.StateRetention = archive, 3
+
+		before := diskdb.Len()
+		for i := 0; i < 10; i++ {
+			block := writeStateBlock(t, bc)
+			if !archive && block.Height() > bc.StateRetention {
+				if err := bc.PruneStateBelow(block.Height() - bc.StateRetention); err != nil {
+					t.Fatalf("failed to prune state: %v", err)
+				}
+			}
+		}
+		for _, height := range []uint64{7, 8, 10} {
+			if _, err := bc.StateAt(height); err != nil {
+				t.Errorf("state at height %d (archive %v): %v", height, archive, err)
+			}
+		}
+		return diskdb.Len() - before
+	}
+	if archived, pruned := stored(true), stored(false); pruned >= archived {
+		t.Errorf("pruned chain stores as many entries as an archive one: have %d, archive %d", pruned, archived)
+	}
+
+	kaiDb := kvstore.NewStoreDB(memorydb.New())
+	bc := setupStateTransitionTestDB(t, kaiDb)
+	bc.Archive = false
+	head := writeStateBlock(t, bc)
+	if err := bc.FlushState(); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	restarted, err := blockchain.NewBlockChain(log.New(), kaiDb, bc.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.CurrentBlock().Hash() != head.Hash() {
+		t.Fatalf("head mismatch after restart: have #%d, want #%d", restarted.CurrentBlock().Height(), head.Height())
+	}
+	if _, err := restarted.State(); err != nil {
+		t.Errorf("head state lost on restart: %v", err)
+	}
+}
+
+// Tests that the blocks whose state was only kept in memory when the node went down are replayed on restart.
+func TestReplayBlocks(t *testing.T) {
+	kaiDb := kvstore.NewStoreDB(memorydb.New())
+	bc := setupStateTransitionTestDB(t, kaiDb)
+	bc.Archive = false
+
+	receiver := common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+	var head *types.Block
+	for i := 0; i < 3; i++ {
+		head = writeBlockWithTxs(t, bc, types.Transactions{
+			signTx(t, types.NewTransaction(senderNonce(t, bc), receiver, big.NewInt(1000), 21000, big.NewInt(1), nil)),
+		})
+	}
+	statedb, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := statedb.GetBalance(receiver)
+
+	// Restart without flushing the state, as a crash would
+	restarted, err := blockchain.NewBlockChain(log.New(), kaiDb, bc.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.Archive = false
+	if height := restarted.CurrentBlock().Height(); height != 0 {
+		t.Fatalf("head not rewound to the flushed state: have #%d, want #0", height)
+	}
+	if err := restarted.ReplayBlocks(); err != nil {
+		t.Fatalf("failed to replay blocks: %v", err)
+	}
+	if restarted.CurrentBlock().Hash() != head.Hash() {
+		t.Fatalf("head mismatch after replay: have #%d, want #%d", restarted.CurrentBlock().Height(), head.Height())
+	}
+	if statedb, err = restarted.State(); err != nil {
+		t.Fatalf("head state missing after replay: %v", err)
+	}
+	if have := statedb.GetBalance(receiver); have.Cmp(want) != 0 {
+		t.Errorf("receiver balance mismatch: have %v, want %v", have, want)
+	}
+
+	// The replayed state was flushed, a further restart keeps the head
+	restarted, err = blockchain.NewBlockChain(log.New(), kaiDb, bc.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.CurrentBlock().Hash() != head.Hash() {
+		t.Errorf("head mismatch after second restart: have #%d, want #%d", restarted.CurrentBlock().Height(), head.Height())
+	}
+}
+
+// Tests that replaying a block which doesn't reproduce its recorded state root fails.
+func TestReplayBlocksRootMismatch(t *testing.T) {
+	kaiDb := kvstore.NewStoreDB(memorydb.New())
+	bc := setupStateTransitionTestDB(t, kaiDb)
+	bc.Archive = false
+
+	receiver := common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+	block := writeBlockWithTxs(t, bc, types.Transactions{
+		signTx(t, types.NewTransaction(senderNonce(t, bc), receiver, big.NewInt(1000), 21000, big.NewInt(1), nil)),
+	})
+	bc.WriteAppHash(block.Height(), common.HexToHash("0x01"))
+
+	restarted, err := blockchain.NewBlockChain(log.New(), kaiDb, bc.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.Archive = false
+	if err := restarted.ReplayBlocks(); err == nil {
+		t.Fatal("replayed a block with a mismatching state root")
+	}
+	if height := restarted.CurrentBlock().Height(); height != 0 {
+		t.Errorf("head moved past the mismatching block: have #%d, want #0", height)
+	}
+}
+
+// Tests that a checkpoint is written as the head on top of its state, leaving the
+// state below it unavailable.
+func TestWriteCheckpoint(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	root := bc.ReadAppHash(0)
+	header := &types.Header{
+		Height:      10,
+		Time:        big.NewInt(10),
+		LastBlockID: types.BlockID{Hash: common.HexToHash("0x09")},
+		AppHash:     root,
+	}
+	checkpoint := types.NewBlock(header, nil, &types.Commit{})
+	parts := checkpoint.MakePartSet(types.BlockPartSizeBytes)
+	seenCommit := testCommit(t, types.BlockID{Hash: checkpoint.Hash(), PartsHeader: parts.Header()}, 10)
+
+	if err := bc.WriteCheckpoint(checkpoint, parts, seenCommit, common.HexToHash("0x01")); err == nil {
+		t.Fatal("checkpoint written without its state")
+	}
+	if head := bc.CurrentBlock().Height(); head != 0 {
+		t.Fatalf("head moved to #%d by a refused checkpoint", head)
+	}
+	if err := bc.WriteCheckpoint(checkpoint, parts, seenCommit, root); err != nil {
+		t.Fatalf("failed to write checkpoint: %v", err)
+	}
+	if head := bc.CurrentBlock(); head.Hash() != checkpoint.Hash() {
+		t.Fatalf("head mismatch: have #%d %x, want #%d %x", head.Height(), head.Hash(), 10, checkpoint.Hash())
+	}
+	if commit := bc.LoadSeenCommit(10); commit == nil || commit.Hash() != seenCommit.Hash() {
+		t.Error("seen commit of the checkpoint not written")
+	}
+	if _, err := bc.StateAt(10); err != nil {
+		t.Errorf("state of the checkpoint unavailable: %v", err)
+	}
+	if _, err := bc.StateAt(5); err == nil {
+		t.Error("state below the checkpoint available")
+	} else if _, ok := err.(*blockchain.StatePrunedError); !ok {
+		t.Errorf("unexpected error for the state below the checkpoint: %v", err)
+	}
+
+	old := types.NewBlock(&types.Header{Height: 5, Time: big.NewInt(5)}, nil, &types.Commit{})
+	if err := bc.WriteCheckpoint(old, old.MakePartSet(types.BlockPartSizeBytes), seenCommit, root); err == nil {
+		t.Error("checkpoint below the head written")
+	}
+}
+
+func TestGetBlocksByRange(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	extendChain(t, bc, 5)
+
+	want := make([]*types.Block, 0)
+	for height := uint64(1); height <= 3; height++ {
+		want = append(want, bc.GetBlockByHeight(height))
+	}
+	checkReorgBlocks(t, "range", bc.GetBlocksByRange(1, 3), want)
+
+	// to is capped at the current head.
+	want = append(want, bc.GetBlockByHeight(4), bc.GetBlockByHeight(5))
+	checkReorgBlocks(t, "capped range", bc.GetBlocksByRange(1, 100), want)
+
+	if blocks := bc.GetBlocksByRange(4, 2); len(blocks) != 0 {
+		t.Errorf("inverted range returned %d blocks", len(blocks))
+	}
+	if blocks := bc.GetBlocksByRange(6, 8); len(blocks) != 0 {
+		t.Errorf("range beyond head returned %d blocks", len(blocks))
+	}
+
+	// The range stops at the first missing height.
+	bc.DB().DeleteCanonicalHash(4)
+	checkReorgBlocks(t, "truncated range", bc.GetBlocksByRange(1, 5), want[:3])
+}
+
+// Tests that the transactions of a block dropped by a reorg of a real chain
+// return to the transaction pool.
+func TestReorgReinjectsTxs(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	ancestor := bc.CurrentBlock()
+	receiver := common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+	tx := signTx(t, types.NewTransaction(senderNonce(t, bc), receiver, big.NewInt(1000), 21000, big.NewInt(1), nil))
+	writeBlockWithTxs(t, bc, types.Transactions{tx})
+
+	config := tx_pool.DefaultTxPoolConfig
+	config.Journal = ""
+	pool := tx_pool.NewTxPool(config, bc.Config(), bc)
+	defer pool.Stop()
+
+	// Replace the block holding tx by an empty sibling. Its state is the
+	// ancestor's, so it is written before the block to avoid racing the pool.
+	header := &types.Header{
+		Height:      ancestor.Height() + 1,
+		Time:        big.NewInt(ancestor.Time().Int64() + 2),
+		GasLimit:    ancestor.GasLimit(),
+		LastBlockID: types.BlockID{Hash: ancestor.Hash()},
+		AppHash:     ancestor.AppHash(),
+	}
+	side := types.NewBlock(header, nil, &types.Commit{})
+	bc.WriteAppHash(side.Height(), bc.ReadAppHash(ancestor.Height()))
+	if err := bc.WriteBlockWithoutState(side, side.MakePartSet(types.BlockPartSizeBytes), &types.Commit{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(time.Second); pool.Get(tx.Hash()) == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("transaction of the dropped block not re-injected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Errorf("pending transactions mismatch: have %d, want %d", pending, 1)
+	}
+}
+
+func TestGetReceiptAndLogs(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	receiver := common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+	nonce := senderNonce(t, bc)
+	txs := types.Transactions{
+		signTx(t, types.NewTransaction(nonce, receiver, big.NewInt(1000), 21000, big.NewInt(1), nil)),
+		signTx(t, types.NewTransaction(nonce+1, receiver, big.NewInt(1000), 21000, big.NewInt(1), nil)),
+	}
+	block := writeBlockWithTxs(t, bc, txs)
+
+	receipts := make(types.Receipts, len(txs))
+	for i, tx := range txs {
+		receipts[i] = types.NewReceipt(nil, false, uint64(i+1)*21000)
+		receipts[i].TxHash = tx.Hash()
+		receipts[i].GasUsed = 21000
+	}
+	receipts[1].Logs = []*types.Log{{Address: receiver, Topics: []common.Hash{common.HexToHash("0x01")}, Data: []byte{1}}}
+	bc.WriteReceipts(receipts, block)
+
+	for i, tx := range txs {
+		receipt, blockHash, height, index, err := bc.GetReceipt(tx.Hash())
+		if err != nil {
+			t.Fatalf("failed to get receipt of tx %d: %v", i, err)
+		}
+		if receipt.TxHash != tx.Hash() || receipt.CumulativeGasUsed != receipts[i].CumulativeGasUsed {
+			t.Errorf("receipt %d mismatch: have %x %d, want %x %d", i, receipt.TxHash, receipt.CumulativeGasUsed, tx.Hash(), receipts[i].CumulativeGasUsed)
+		}
+		if blockHash != block.Hash() || height != block.Height() || index != uint64(i) {
+			t.Errorf("receipt %d position mismatch: have %x #%d [%d], want %x #%d [%d]", i, blockHash, height, index, block.Hash(), block.Height(), i)
+		}
+	}
+	if _, _, _, _, err := bc.GetReceipt(common.HexToHash("0xdead")); err != blockchain.ErrReceiptNotFound {
+		t.Errorf("unknown tx hash error mismatch: have %v, want %v", err, blockchain.ErrReceiptNotFound)
+	}
+
+	logs := bc.GetLogs(block.Hash())
+	if len(logs) != 2 || len(logs[0]) != 0 || len(logs[1]) != 1 {
+		t.Fatalf("logs mismatch: have %v", logs)
+	}
+	if logs[1][0].Address != receiver || logs[1][0].Topics[0] != common.HexToHash("0x01") {
+		t.Errorf("log mismatch: have %+v", logs[1][0])
+	}
+	if logs := bc.GetLogs(common.HexToHash("0xdead")); logs != nil {
+		t.Errorf("logs of unknown block: have %v", logs)
+	}
+}
+
+func TestVerifyDB(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	extendChain(t, bc, 3)
+	db := bc.DB()
+	head := bc.CurrentBlock()
+
+	if verified, err := blockchain.VerifyDB(log.New(), db, false); err != nil || verified.Hash() != head.Hash() {
+		t.Fatalf("consistent database mismatch: have %v, %v, want #%d", verified, err, head.Height())
+	}
+
+	// A dangling head pointer is reported and left alone unless repairing.
+	db.WriteHeadBlockHash(common.HexToHash("0xdead"))
+	if _, err := blockchain.VerifyDB(log.New(), db, false); err == nil {
+		t.Fatal("dangling head pointer not detected")
+	}
+	if hash := db.ReadHeadBlockHash(); hash != common.HexToHash("0xdead") {
+		t.Fatalf("head pointer changed without repair: have %x", hash)
+	}
+	verified, err := blockchain.VerifyDB(log.New(), db, true)
+	if err == nil {
+		t.Fatal("dangling head pointer not reported when repairing")
+	}
+	if verified.Hash() != head.Hash() || db.ReadHeadBlockHash() != head.Hash() {
+		t.Fatalf("repaired head mismatch: have %x, want %x", db.ReadHeadBlockHash(), head.Hash())
+	}
+	if _, err := blockchain.VerifyDB(log.New(), db, false); err != nil {
+		t.Fatalf("repaired database still inconsistent: %v", err)
+	}
+
+	// A missing app hash moves the head below the broken block.
+	bc.WriteAppHash(head.Height(), common.Hash{})
+	if verified, err = blockchain.VerifyDB(log.New(), db, true); err == nil {
+		t.Fatal("missing app hash not detected")
+	}
+	if verified.Height() != head.Height()-1 || db.ReadHeadBlockHash() != verified.Hash() {
+		t.Errorf("repaired head mismatch: have #%d %x, want #%d", verified.Height(), db.ReadHeadBlockHash(), head.Height()-1)
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	extendChain(t, bc, 5)
+	ancestor := bc.GetBlockByHeight(3)
+	dropped := bc.GetBlockByHeight(5)
+
+	// Switching to a side chain forking off block 3 leaves block 5 known but not canonical.
+	side := writeChildBlock(t, bc, ancestor, 2)
+	extendChain(t, bc, 2)
+	head := bc.CurrentBlock()
+
+	for i, test := range []struct {
+		hash common.Hash
+		want uint64
+		err  error
+	}{
+		{head.Hash(), 0, nil},
+		{side.Hash(), 2, nil},
+		{ancestor.Hash(), 3, nil},
+		{bc.Genesis().Hash(), head.Height(), nil},
+		{dropped.Hash(), 0, blockchain.ErrNotCanonical},
+		{common.HexToHash("0xdead"), 0, blockchain.ErrNotCanonical},
+	} {
+		have, err := bc.Confirmations(test.hash)
+		if err != test.err || have != test.want {
+			t.Errorf("test %d: confirmations mismatch: have %d, %v, want %d, %v", i, have, err, test.want, test.err)
+		}
+	}
+}
+
+func TestStateAtSnapshots(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	receiver := common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+
+	first, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance := first.GetBalance(receiver)
+	first.AddBalance(receiver, big.NewInt(1000))
+
+	// Mutating a returned state must not leak into the cached one.
+	second, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := second.GetBalance(receiver); have.Cmp(balance) != 0 {
+		t.Errorf("cached state mutated: have %v, want %v", have, balance)
+	}
+}
+
+func BenchmarkStateAt(b *testing.B) {
+	for _, limit := range []int{0, blockchain.DefaultStateSnapshotLimit} {
+		b.Run(fmt.Sprintf("limit-%d", limit), func(b *testing.B) {
+			bc := setupStateTransitionTest(b)
+			bc.SetStateSnapshotLimit(limit)
+			height := bc.CurrentBlock().Height()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := bc.StateAt(height); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockRewardAt(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	bc.BlockReward = big.NewInt(100)
+	bc.RewardSchedule = []pos.RewardMilestone{
+		{Height: 10, Reward: big.NewInt(50)},
+		{Height: 20, Reward: big.NewInt(25)},
+	}
+	for _, test := range []struct {
+		height uint64
+		want   int64
+	}{
+		{0, 100}, {9, 100}, {10, 50}, {19, 50}, {20, 25}, {1000, 25},
+	} {
+		if have := bc.BlockRewardAt(test.height); have.Cmp(big.NewInt(test.want)) != 0 {
+			t.Errorf("block reward at %d mismatch: have %v, want %d", test.height, have, test.want)
+		}
+	}
+
+	// Without a schedule every block gets the static reward.
+	bc.RewardSchedule = nil
+	if have := bc.BlockRewardAt(1000); have.Cmp(bc.GetBlockReward()) != 0 {
+		t.Errorf("unscheduled block reward mismatch: have %v, want %v", have, bc.GetBlockReward())
+	}
+}
+
+func TestCallContract(t *testing.T) {
+	bc, counter := setupTraceChain(t)
+	nonce := senderNonce(t, bc)
+	past := writeBlockWithTxs(t, bc, types.Transactions{
+		signTx(t, types.NewTransaction(nonce, counter, big.NewInt(0), 100000, big.NewInt(1), packCounter(t, "set", uint8(7)))),
+	})
+	head := writeBlockWithTxs(t, bc, types.Transactions{
+		signTx(t, types.NewTransaction(nonce+1, counter, big.NewInt(0), 100000, big.NewInt(1), packCounter(t, "set", uint8(9)))),
+	})
+
+	get := packCounter(t, "get")
+	for _, test := range []struct {
+		height uint64
+		want   byte
+	}{{past.Height(), 7}, {head.Height(), 9}} {
+		ret, err := bc.CallContract(address, counter, get, test.height)
+		if err != nil {
+			t.Fatalf("get at height %d: %v", test.height, err)
+		}
+		if len(ret) != 32 || ret[31] != test.want {
+			t.Errorf("get at height %d mismatch: have %x, want %d", test.height, ret, test.want)
+		}
+	}
+
+	// Calls cannot modify the state, nor run against unknown blocks.
+	if _, err := bc.CallContract(address, counter, packCounter(t, "set", uint8(1)), head.Height()); err == nil {
+		t.Error("expected state modifying call to fail")
+	}
+	if ret, _ := bc.CallContract(address, counter, get, head.Height()); len(ret) != 32 || ret[31] != 9 {
+		t.Errorf("state modified by call: have %x, want 9", ret)
+	}
+	if _, err := bc.CallContract(address, counter, get, head.Height()+1); err == nil {
+		t.Error("expected call at unknown height to fail")
+	}
+}
+
+func TestBlockMetaAt(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	extendChain(t, bc, 2)
+
+	head := bc.CurrentBlock()
+	meta := bc.BlockMetaAt(head.Height())
+	if meta == nil {
+		t.Fatal("missing block meta of the head block")
+	}
+	if meta.BlockID.Hash != head.Hash() || meta.Header.Hash() != head.Hash() {
+		t.Errorf("block meta hash mismatch: have %x, want %x", meta.BlockID.Hash, head.Hash())
+	}
+	if want := head.MakePartSet(types.BlockPartSizeBytes).Header(); !meta.BlockID.PartsHeader.Equals(want) {
+		t.Errorf("part set header mismatch: have %v, want %v", meta.BlockID.PartsHeader, want)
+	}
+	if cached := bc.BlockMetaAt(head.Height()); cached != meta {
+		t.Error("block meta not served from the cache")
+	}
+	if bc.BlockMetaAt(head.Height()+1) != nil {
+		t.Error("block meta returned above the head")
+	}
+
+	// A stored meta that disagrees with the stored block is rejected.
+	parent := bc.GetBlockByHeight(head.Height() - 1)
+	bc.DB().WriteBlock(parent, parent.MakePartSet(64), &types.Commit{})
+	if meta := bc.BlockMetaAt(parent.Height()); meta != nil {
+		t.Errorf("inconsistent block meta accepted: %v", meta.BlockID)
+	}
+}
+
+// testCommit returns a commit for blockID at height, signed by a new validator.
+func testCommit(t *testing.T, blockID types.BlockID, height uint64) *types.Commit {
+	key, _ := crypto.GenerateKey()
+	vote := &types.Vote{
+		ValidatorAddress: crypto.PubkeyToAddress(key.PublicKey),
+		ValidatorIndex:   common.NewBigInt32(0),
+		Height:           common.NewBigUint64(height),
+		Round:            common.NewBigInt32(0),
+		Timestamp:        big.NewInt(int64(height)),
+		Type:             types.PrecommitType,
+		BlockID:          blockID,
+	}
+	if err := types.NewPrivValidator(key).SignVote("kaicon", vote); err != nil {
+		t.Fatal(err)
+	}
+	return types.NewCommit(blockID, []*types.CommitSig{vote.CommitSig()})
+}
+
+// seenCommitlessStore is a store which doesn't keep seen commits.
+type seenCommitlessStore struct {
+	types.StoreDB
+}
+
+func (s seenCommitlessStore) ReadSeenCommit(height uint64) *types.Commit {
+	panic("seen commits not supported")
+}
+
+func TestReconcileSeenCommitOnRestart(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	extendChain(t, bc, 1)
+	parent := bc.CurrentBlock()
+	parentID := types.BlockID{Hash: parent.Hash(), PartsHeader: parent.MakePartSet(types.BlockPartSizeBytes).Header()}
+
+	// The head carries the commit of the proposer, while this node saw other precommits.
+	canonical := testCommit(t, parentID, parent.Height())
+	head := types.NewBlock(&types.Header{
+		Height:      parent.Height() + 1,
+		Time:        big.NewInt(parent.Time().Int64() + 1),
+		GasLimit:    parent.GasLimit(),
+		LastBlockID: parentID,
+		AppHash:     parent.AppHash(),
+	}, nil, canonical)
+	if err := bc.WriteBlockWithoutState(head, head.MakePartSet(types.BlockPartSizeBytes), &types.Commit{}); err != nil {
+		t.Fatal(err)
+	}
+	bc.WriteAppHash(head.Height(), bc.ReadAppHash(parent.Height()))
+	db := bc.DB()
+	store := db.(types.SeenCommitStore)
+	restart := func(db types.StoreDB) {
+		if _, err := blockchain.NewBlockChain(log.New(), db, bc.Config()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A seen commit for the same block is kept, even though it differs from the canonical one.
+	seen := testCommit(t, parentID, parent.Height())
+	store.WriteSeenCommit(parent.Height(), seen)
+	restart(db)
+	if have := store.ReadSeenCommit(parent.Height()); have.Hash() != seen.Hash() {
+		t.Errorf("valid seen commit replaced: have %x, want %x", have.Hash(), seen.Hash())
+	}
+
+	// A seen commit for another block is replaced with the canonical one.
+	store.WriteSeenCommit(parent.Height(), testCommit(t, types.BlockID{Hash: common.HexToHash("0xdead"), PartsHeader: parentID.PartsHeader}, parent.Height()))
+	restart(db)
+	if have := store.ReadSeenCommit(parent.Height()); have.Hash() != canonical.Hash() {
+		t.Errorf("seen commit not repaired: have %x, want %x", have.Hash(), canonical.Hash())
+	}
+
+	// So is a malformed one.
+	store.WriteSeenCommit(parent.Height(), &types.Commit{BlockID: parentID})
+	restart(db)
+	if have := store.ReadSeenCommit(parent.Height()); have.Hash() != canonical.Hash() {
+		t.Errorf("malformed seen commit not repaired: have %x, want %x", have.Hash(), canonical.Hash())
+	}
+
+	// Stores without seen commits are not checked.
+	restart(seenCommitlessStore{db})
+}
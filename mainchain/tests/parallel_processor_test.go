@@ -0,0 +1,150 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+package tests
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/kai/storage/kvstore"
+	"github.com/kardiachain/go-kardia/kvm"
+	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/mainchain/blockchain"
+	"github.com/kardiachain/go-kardia/mainchain/genesis"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// setupTransferChain returns a blockchain whose genesis funds the accounts of privKeys, along with their keys.
+func setupTransferChain(t *testing.T) (*blockchain.BlockChain, []*ecdsa.PrivateKey) {
+	var (
+		keys     = make([]*ecdsa.PrivateKey, len(privKeys))
+		accounts = make(map[string]*big.Int, len(privKeys))
+	)
+	for i, pk := range privKeys {
+		keys[i], _ = crypto.HexToECDSA(pk)
+		accounts[crypto.PubkeyToAddress(keys[i].PublicKey).Hex()] = initValue
+	}
+	kaiDb := kvstore.NewStoreDB(memorydb.New())
+	chainConfig, _, err := genesis.SetupGenesisBlock(log.New(), kaiDb, genesis.DefaulTestnetFullGenesisBlock(accounts, map[string]string{}), &types.BaseAccount{
+		Address:    crypto.PubkeyToAddress(keys[0].PublicKey),
+		PrivateKey: *keys[0],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := blockchain.NewBlockChain(log.New(), kaiDb, chainConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bc, keys
+}
+
+// transferBlock builds a block on top of the head of bc sending value from keys[senders[i]] to recipients[i].
+func transferBlock(t *testing.T, bc *blockchain.BlockChain, keys []*ecdsa.PrivateKey, senders []int, recipients []*ecdsa.PrivateKey, value *big.Int) *types.Block {
+	statedb, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		parent = bc.CurrentBlock()
+		nonces = make(map[int]uint64)
+		txs    types.Transactions
+	)
+	for i, sender := range senders {
+		if _, ok := nonces[sender]; !ok {
+			nonces[sender] = statedb.GetNonce(crypto.PubkeyToAddress(keys[sender].PublicKey))
+		}
+		tx := types.NewTransaction(nonces[sender], crypto.PubkeyToAddress(recipients[i].PublicKey), value, 21000, big.NewInt(1), nil)
+		signed, err := types.SignTx(types.HomesteadSigner{}, tx, keys[sender])
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, signed)
+		nonces[sender]++
+	}
+	header := &types.Header{
+		Height:      parent.Height() + 1,
+		Time:        big.NewInt(parent.Time().Int64() + 1),
+		GasLimit:    parent.GasLimit(),
+		LastBlockID: types.BlockID{Hash: parent.Hash()},
+		AppHash:     parent.AppHash(),
+	}
+	return types.NewBlock(header, txs, &types.Commit{})
+}
+
+// processBlock processes block on top of the head state of bc with the given concurrency and returns the
+// resulting state root, receipts and gas used.
+func processBlock(t *testing.T, bc *blockchain.BlockChain, block *types.Block, concurrency int) (string, types.Receipts, uint64) {
+	statedb, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	processor := blockchain.NewStateProcessor(log.New(), bc)
+	processor.SetConcurrency(concurrency)
+	receipts, _, usedGas, err := processor.Process(block, statedb, kvm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return statedb.IntermediateRoot(true).Hex(), receipts, usedGas
+}
+
+// Tests that processing a block in parallel yields the same state and receipts as processing it sequentially,
+// both for independent transfers and for conflicting ones that have to fall back to sequential processing.
+func TestParallelProcessing(t *testing.T) {
+	bc, keys := setupTransferChain(t)
+
+	fresh := make([]*ecdsa.PrivateKey, len(keys))
+	for i := range fresh {
+		fresh[i], _ = crypto.GenerateKey()
+	}
+	tests := []struct {
+		name       string
+		senders    []int
+		recipients []*ecdsa.PrivateKey
+		value      *big.Int
+	}{
+		{"independent", []int{0, 1, 2, 3, 4, 5}, fresh[:6], big.NewInt(1000)},
+		{"independent zero value", []int{0, 1, 2, 3}, fresh[:4], big.NewInt(0)},
+		{"same sender", []int{0, 1, 0, 2}, fresh[:4], big.NewInt(1000)},
+		{"sender receives", []int{0, 1, 2}, []*ecdsa.PrivateKey{fresh[0], keys[0], fresh[2]}, big.NewInt(1000)},
+	}
+	for _, tt := range tests {
+		block := transferBlock(t, bc, keys, tt.senders, tt.recipients, tt.value)
+
+		seqRoot, seqReceipts, seqGas := processBlock(t, bc, block, 1)
+		parRoot, parReceipts, parGas := processBlock(t, bc, block, 4)
+		if parRoot != seqRoot {
+			t.Errorf("%s: state root mismatch: have %s, want %s", tt.name, parRoot, seqRoot)
+		}
+		if parGas != seqGas {
+			t.Errorf("%s: used gas mismatch: have %d, want %d", tt.name, parGas, seqGas)
+		}
+		if len(parReceipts) != len(seqReceipts) {
+			t.Fatalf("%s: receipt count mismatch: have %d, want %d", tt.name, len(parReceipts), len(seqReceipts))
+		}
+		for i := range seqReceipts {
+			have, want := parReceipts[i], seqReceipts[i]
+			if have.TxHash != want.TxHash || have.Status != want.Status || have.GasUsed != want.GasUsed || have.CumulativeGasUsed != want.CumulativeGasUsed {
+				t.Errorf("%s: receipt %d mismatch: have %+v, want %+v", tt.name, i, have, want)
+			}
+		}
+	}
+}
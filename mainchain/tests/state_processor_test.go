@@ -314,3 +314,286 @@ func TestStateTransition_TransitionDb_withFee(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func setupStateTransitionTest(t testing.TB) *blockchain.BlockChain {
+	return setupStateTransitionTestDB(t, kvstore.NewStoreDB(memorydb.New()))
+}
+
+// setupStateTransitionTestDB sets up the chain of setupStateTransitionTest on top of kaiDb.
+func setupStateTransitionTestDB(t testing.TB, kaiDb types.StoreDB) *blockchain.BlockChain {
+	g := genesis.DefaulTestnetFullGenesisBlock(genesisAccounts, map[string]string{})
+	privateKey, _ := crypto.HexToECDSA("8843ebcb1021b00ae9a644db6617f9c6d870e5fd53624cefe374c1d2d710fd06")
+
+	chainConfig, _, genesisErr := genesis.SetupGenesisBlock(log.New(), kaiDb, g, &types.BaseAccount{
+		Address:    address,
+		PrivateKey: *privateKey,
+	})
+	if genesisErr != nil {
+		t.Fatal(genesisErr)
+	}
+	bc, err := blockchain.NewBlockChain(log.New(), kaiDb, chainConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bc
+}
+
+// Under zero fee a value transfer only moves the value: the sender pays no gas
+// and all gas is returned to the block gas pool.
+func TestStateTransition_TransitionDb_zeroFeeTransfer(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	stateDb, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+	senderBalance := stateDb.GetBalance(address)
+	receiverBalance := stateDb.GetBalance(receiver)
+
+	value := big.NewInt(1000)
+	msg := types.NewMessage(address, &receiver, 2, value, 21000, big.NewInt(100), nil, true)
+	gasLimit := bc.CurrentBlock().Header().GasLimit
+	gasPool := new(types.GasPool).AddGas(gasLimit)
+	vmenv := kvm.NewKVM(vm.NewKVMContext(msg, bc.CurrentBlock().Header(), bc), stateDb, kvm.Config{IsZeroFee: true})
+
+	_, usedGas, failed, err := blockchain.ApplyMessage(vmenv, msg, gasPool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed {
+		t.Fatal("transaction failed")
+	}
+	if usedGas != 0 {
+		t.Errorf("used gas mismatch: have %d, want 0", usedGas)
+	}
+	if want := new(big.Int).Sub(senderBalance, value); stateDb.GetBalance(address).Cmp(want) != 0 {
+		t.Errorf("sender balance mismatch: have %v, want %v", stateDb.GetBalance(address), want)
+	}
+	if want := new(big.Int).Add(receiverBalance, value); stateDb.GetBalance(receiver).Cmp(want) != 0 {
+		t.Errorf("receiver balance mismatch: have %v, want %v", stateDb.GetBalance(receiver), want)
+	}
+	if gasPool.Gas() != gasLimit {
+		t.Errorf("gas pool mismatch: have %d, want %d", gasPool.Gas(), gasLimit)
+	}
+}
+
+// Under zero fee a transaction that fails in the KVM still costs the sender
+// nothing, even though all of its gas was consumed.
+func TestStateTransition_TransitionDb_zeroFeeFailedTx(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	for _, zeroFee := range []bool{true, false} {
+		stateDb, err := bc.State()
+		if err != nil {
+			t.Fatal(err)
+		}
+		senderBalance := stateDb.GetBalance(address)
+
+		// Enough gas for the intrinsic cost but not for deploying the contract.
+		msg := types.NewMessage(address, nil, 2, big.NewInt(0), 100000, big.NewInt(100), contractCode, true)
+		gasPool := new(types.GasPool).AddGas(bc.CurrentBlock().Header().GasLimit)
+		vmenv := kvm.NewKVM(vm.NewKVMContext(msg, bc.CurrentBlock().Header(), bc), stateDb, kvm.Config{IsZeroFee: zeroFee})
+
+		_, usedGas, failed, err := blockchain.ApplyMessage(vmenv, msg, gasPool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !failed {
+			t.Fatalf("zeroFee=%v: expected contract creation to run out of gas", zeroFee)
+		}
+		cost := new(big.Int).Sub(senderBalance, stateDb.GetBalance(address))
+		if zeroFee {
+			if usedGas != 0 || cost.Sign() != 0 {
+				t.Errorf("zero fee: sender paid %v for %d gas, want nothing", cost, usedGas)
+			}
+		} else if want := new(big.Int).Mul(new(big.Int).SetUint64(usedGas), big.NewInt(100)); usedGas != 100000 || cost.Cmp(want) != 0 {
+			t.Errorf("with fee: sender paid %v for %d gas, want %v for 100000 gas", cost, usedGas, want)
+		}
+	}
+}
+
+// Tests that a transaction failing in the KVM yields a receipt with a failed
+// status, which changes the receipt root.
+func TestApplyTransaction_receiptStatus(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	privateKey, _ := crypto.HexToECDSA("8843ebcb1021b00ae9a644db6617f9c6d870e5fd53624cefe374c1d2d710fd06")
+
+	receipts := make(types.Receipts, 0)
+	// Enough gas to deploy the contract and not enough, the latter fails.
+	for _, gas := range []uint64{1000000, 100000} {
+		stateDb, err := bc.State()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx, err := types.SignTx(types.HomesteadSigner{}, types.NewContractCreation(stateDb.GetNonce(address), big.NewInt(0), gas, big.NewInt(1), contractCode), privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := types.CopyHeader(bc.CurrentBlock().Header())
+		gasPool := new(types.GasPool).AddGas(header.GasLimit)
+		var usedGas uint64
+		receipt, _, err := blockchain.ApplyTransaction(log.New(), bc, gasPool, stateDb, header, tx, &usedGas, kvm.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		receipts = append(receipts, receipt)
+	}
+	if receipts[0].Status != types.ReceiptStatusSuccessful {
+		t.Errorf("successful receipt status mismatch: have %d, want %d", receipts[0].Status, types.ReceiptStatusSuccessful)
+	}
+	if receipts[1].Status != types.ReceiptStatusFailed {
+		t.Errorf("failed receipt status mismatch: have %d, want %d", receipts[1].Status, types.ReceiptStatusFailed)
+	}
+
+	// Only the status tells the two receipts apart once their gas matches.
+	failed := *receipts[0]
+	failed.Status = types.ReceiptStatusFailed
+	if types.DeriveSha(types.Receipts{receipts[0]}) == types.DeriveSha(types.Receipts{&failed}) {
+		t.Error("receipt root does not depend on the receipt status")
+	}
+}
+
+// Tests that under a base fee only the tip reaches the block validator, while
+// the base fee is burned and so reduces the total supply.
+func TestApplyTransaction_burnBaseFee(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	privateKey, _ := crypto.HexToECDSA("8843ebcb1021b00ae9a644db6617f9c6d870e5fd53624cefe374c1d2d710fd06")
+	stateDb, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+	validator := common.HexToAddress("0x000000000000000000000000000000000000beef")
+	baseFee, tip := big.NewInt(100), big.NewInt(10)
+
+	supply := func() *big.Int {
+		total := new(big.Int)
+		for _, addr := range []common.Address{address, receiver, validator} {
+			total.Add(total, stateDb.GetBalance(addr))
+		}
+		return total
+	}
+	before := supply()
+
+	tx, err := types.SignTx(types.HomesteadSigner{}, types.NewTransaction(stateDb.GetNonce(address), receiver, big.NewInt(1), 21000, new(big.Int).Add(baseFee, tip), nil), privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := types.CopyHeader(bc.CurrentBlock().Header())
+	header.BaseFee = baseFee
+	header.Validator = validator
+	gasPool := new(types.GasPool).AddGas(header.GasLimit)
+	var usedGas uint64
+	if _, _, err := blockchain.ApplyTransaction(log.New(), bc, gasPool, stateDb, header, tx, &usedGas, kvm.Config{}); err != nil {
+		t.Fatal(err)
+	}
+
+	gas := new(big.Int).SetUint64(usedGas)
+	if want, have := new(big.Int).Mul(gas, tip), stateDb.GetBalance(validator); have.Cmp(want) != 0 {
+		t.Errorf("validator tip mismatch: have %v, want %v", have, want)
+	}
+	if want, have := new(big.Int).Mul(gas, baseFee), new(big.Int).Sub(before, supply()); have.Cmp(want) != 0 {
+		t.Errorf("burned amount mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that a transaction priced below the base fee is rejected, except for the
+// zero priced calls to the PoS handler made as system transactions.
+func TestApplyTransaction_belowBaseFee(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	privateKey, _ := crypto.HexToECDSA("8843ebcb1021b00ae9a644db6617f9c6d870e5fd53624cefe374c1d2d710fd06")
+	stateDb, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := types.CopyHeader(bc.CurrentBlock().Header())
+	header.BaseFee = big.NewInt(100)
+	// The data is an unknown method selector, which the PoS handler rejects without failing the transaction.
+	apply := func(to common.Address, gasPrice int64) error {
+		tx, err := types.SignTx(types.HomesteadSigner{}, types.NewTransaction(stateDb.GetNonce(address), to, big.NewInt(0), 100000, big.NewInt(gasPrice), []byte{0, 0, 0, 0}), privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gasPool := new(types.GasPool).AddGas(header.GasLimit)
+		var usedGas uint64
+		_, _, err = blockchain.ApplyTransaction(log.New(), bc, gasPool, stateDb, header, tx, &usedGas, kvm.Config{})
+		return err
+	}
+	receiver := common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+	if err := apply(receiver, 99); err != blockchain.ErrFeeCapTooLow {
+		t.Errorf("transaction below the base fee: have %v, want %v", err, blockchain.ErrFeeCapTooLow)
+	}
+	if err := apply(receiver, 100); err != nil {
+		t.Errorf("transaction paying the base fee: %v", err)
+	}
+	if err := apply(common.BytesToAddress([]byte{5}), 0); err != nil {
+		t.Errorf("system transaction below the base fee: %v", err)
+	}
+}
+
+// Tests that blocks before the London fork can't include dynamic fee transactions.
+func TestApplyTransaction_dynamicFeeBeforeLondon(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	privateKey, _ := crypto.HexToECDSA("8843ebcb1021b00ae9a644db6617f9c6d870e5fd53624cefe374c1d2d710fd06")
+	stateDb, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+	tx, err := types.SignTx(types.HomesteadSigner{}, types.NewDynamicFeeTransaction(bc.Config().ChainID, stateDb.GetNonce(address), receiver, big.NewInt(1), 21000, big.NewInt(1), big.NewInt(1), nil), privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := types.CopyHeader(bc.CurrentBlock().Header())
+	gasPool := new(types.GasPool).AddGas(header.GasLimit)
+	var usedGas uint64
+	if _, _, err := blockchain.ApplyTransaction(log.New(), bc, gasPool, stateDb, header, tx, &usedGas, kvm.Config{}); err != types.ErrTxTypeNotSupported {
+		t.Fatalf("dynamic fee transaction applied before London: %v", err)
+	}
+}
+
+func TestBlockChain_EstimateGas(t *testing.T) {
+	bc := setupStateTransitionTest(t)
+	receiver := common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+
+	gas, err := bc.EstimateGas(address, &receiver, nil, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gas != kvm.TxGas {
+		t.Errorf("transfer gas mismatch: have %d, want %d", gas, kvm.TxGas)
+	}
+
+	gas, err = bc.EstimateGas(address, nil, contractCode, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The estimate is the exact boundary: one gas less fails the deploy.
+	for _, test := range []struct {
+		gas    uint64
+		failed bool
+	}{{gas, false}, {gas - 1, true}} {
+		stateDb, err := bc.State()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := types.NewMessage(address, nil, 0, big.NewInt(0), test.gas, big.NewInt(1), contractCode, false)
+		kaiVm := kvm.NewKVM(vm.NewKVMContext(msg, bc.CurrentHeader(), bc), stateDb, kvm.Config{})
+		_, _, failed, err := blockchain.ApplyMessage(kaiVm, msg, new(types.GasPool).AddGas(test.gas))
+		if err == nil && failed != test.failed {
+			t.Errorf("deploy with %d gas: failed mismatch: have %v, want %v", test.gas, failed, test.failed)
+		}
+		if err != nil && !test.failed {
+			t.Errorf("deploy with %d gas: %v", test.gas, err)
+		}
+	}
+
+	// A sender without funds cannot pay for any gas.
+	if _, err := bc.EstimateGas(common.HexToAddress("0x0a"), &receiver, nil, nil); err == nil {
+		t.Error("expected estimate for a sender without funds to fail")
+	}
+	// Unless the chain charges no fees.
+	bc.IsZeroFee = true
+	if gas, err := bc.EstimateGas(common.HexToAddress("0x0a"), &receiver, nil, nil); err != nil || gas != kvm.TxGas {
+		t.Errorf("zero fee transfer gas mismatch: have %d, %v, want %d", gas, err, kvm.TxGas)
+	}
+}
@@ -0,0 +1,240 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package tests
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/kai/storage/kvstore"
+	"github.com/kardiachain/go-kardia/kvm"
+	"github.com/kardiachain/go-kardia/lib/abi"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/mainchain/blockchain"
+	"github.com/kardiachain/go-kardia/mainchain/genesis"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// writeBlockWithTxs executes txs on top of the current head of bc and writes the resulting block and state.
+func writeBlockWithTxs(t *testing.T, bc *blockchain.BlockChain, txs types.Transactions) *types.Block {
+	parent := bc.CurrentBlock()
+	statedb, err := bc.StateAt(parent.Height())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{
+		Height:      parent.Height() + 1,
+		Time:        big.NewInt(parent.Time().Int64() + 1),
+		GasLimit:    parent.GasLimit(),
+		LastBlockID: types.BlockID{Hash: parent.Hash()},
+		AppHash:     parent.AppHash(),
+	}
+	var (
+		usedGas = new(uint64)
+		gp      = new(types.GasPool).AddGas(header.GasLimit)
+	)
+	for i, tx := range txs {
+		statedb.Prepare(tx.Hash(), common.Hash{}, i)
+		if _, _, err := blockchain.ApplyTransaction(log.New(), bc, gp, statedb, header, tx, usedGas, kvm.Config{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.CommitTrie(root); err != nil {
+		t.Fatal(err)
+	}
+	header.GasUsed = 0
+	block := types.NewBlock(header, txs, &types.Commit{})
+	if err := bc.WriteBlockWithoutState(block, block.MakePartSet(types.BlockPartSizeBytes), &types.Commit{}); err != nil {
+		t.Fatal(err)
+	}
+	bc.WriteAppHash(block.Height(), root)
+	return block
+}
+
+// setupTraceChain returns a blockchain with a Counter contract deployed by address, along with the contract address.
+func setupTraceChain(t *testing.T) (*blockchain.BlockChain, common.Address) {
+	kaiDb := kvstore.NewStoreDB(memorydb.New())
+	g := genesis.DefaulTestnetFullGenesisBlock(genesisAccounts, map[string]string{})
+	privateKey, _ := crypto.HexToECDSA(privKeys[0])
+	chainConfig, _, err := genesis.SetupGenesisBlock(log.New(), kaiDb, g, &types.BaseAccount{
+		Address:    address,
+		PrivateKey: *privateKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := blockchain.NewBlockChain(log.New(), kaiDb, chainConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := senderNonce(t, bc)
+	writeBlockWithTxs(t, bc, types.Transactions{
+		signTx(t, types.NewContractCreation(nonce, big.NewInt(0), 500000, big.NewInt(1), contractCode)),
+	})
+	return bc, crypto.CreateAddress(address, nonce)
+}
+
+// senderNonce returns the nonce of address at the head of bc.
+func senderNonce(t *testing.T, bc *blockchain.BlockChain) uint64 {
+	statedb, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return statedb.GetNonce(address)
+}
+
+// signTx signs tx with the private key of address.
+func signTx(t *testing.T, tx *types.Transaction) *types.Transaction {
+	privateKey, _ := crypto.HexToECDSA(privKeys[0])
+	signed, err := types.SignTx(types.HomesteadSigner{}, tx, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// packCounter packs the input calling method of the Counter contract.
+func packCounter(t *testing.T, method string, args ...interface{}) []byte {
+	counterAbi, err := abi.JSON(strings.NewReader(abiInterface))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, err := counterAbi.Pack(method, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input
+}
+
+// findOp returns the last struct log executing op, or nil if there is none.
+func findOp(logs []kvm.StructLog, op kvm.OpCode) *kvm.StructLog {
+	var found *kvm.StructLog
+	for i := range logs {
+		if logs[i].Op == op {
+			found = &logs[i]
+		}
+	}
+	return found
+}
+
+func TestTraceTransaction(t *testing.T) {
+	bc, counter := setupTraceChain(t)
+	receiver := common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+	next := senderNonce(t, bc)
+	block := writeBlockWithTxs(t, bc, types.Transactions{
+		signTx(t, types.NewTransaction(next, receiver, big.NewInt(1000), 21000, big.NewInt(1), nil)),
+		signTx(t, types.NewTransaction(next+1, counter, big.NewInt(0), 100000, big.NewInt(1), packCounter(t, "set", uint8(7)))),
+	})
+	head := senderNonce(t, bc)
+
+	// A plain transfer doesn't run any code.
+	trace, err := bc.TraceTransaction(block, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace.Failed || trace.Gas != 21000 || len(trace.StructLogs) != 0 {
+		t.Errorf("transfer trace mismatch: failed %v, gas %v, %d struct logs", trace.Failed, trace.Gas, len(trace.StructLogs))
+	}
+
+	// The contract call is traced after replaying the transfer before it.
+	trace, err = bc.TraceTransaction(block, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace.Failed {
+		t.Fatal("contract call trace should not fail")
+	}
+	if trace.TxHash != block.Transactions()[1].Hash() {
+		t.Errorf("trace hash mismatch: have %v, want %v", trace.TxHash.Hex(), block.Transactions()[1].Hash().Hex())
+	}
+	sstore := findOp(trace.StructLogs, kvm.SSTORE)
+	if sstore == nil {
+		t.Fatal("contract call trace should contain SSTORE")
+	}
+	if value := sstore.Storage[common.Hash{}]; value != common.BigToHash(big.NewInt(7)) {
+		t.Errorf("stored value mismatch: have %v, want 7", value.Hex())
+	}
+	last := trace.StructLogs[len(trace.StructLogs)-1]
+	if last.Op != kvm.STOP {
+		t.Errorf("last opcode mismatch: have %v, want STOP", last.OpName())
+	}
+
+	// Tracing must not touch the stored chain state.
+	if have := senderNonce(t, bc); have != head {
+		t.Errorf("nonce changed by tracing: have %v, want %v", have, head)
+	}
+	if _, err := bc.TraceTransaction(block, 2, nil); err == nil {
+		t.Error("expected error tracing out of range transaction index")
+	}
+}
+
+func TestTraceBlock(t *testing.T) {
+	bc, counter := setupTraceChain(t)
+	next := senderNonce(t, bc)
+	block := writeBlockWithTxs(t, bc, types.Transactions{
+		signTx(t, types.NewTransaction(next, counter, big.NewInt(0), 100000, big.NewInt(1), packCounter(t, "set", uint8(7)))),
+		signTx(t, types.NewTransaction(next+1, counter, big.NewInt(0), 100000, big.NewInt(1), packCounter(t, "get"))),
+	})
+
+	traces, err := bc.TraceBlock(block, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("traces count mismatch: have %d, want 2", len(traces))
+	}
+	for i, trace := range traces {
+		if trace.Failed {
+			t.Errorf("trace %d should not fail", i)
+		}
+		if trace.TxHash != block.Transactions()[i].Hash() {
+			t.Errorf("trace %d hash mismatch: have %v, want %v", i, trace.TxHash.Hex(), block.Transactions()[i].Hash().Hex())
+		}
+	}
+	if findOp(traces[0].StructLogs, kvm.SSTORE) == nil {
+		t.Error("set trace should contain SSTORE")
+	}
+	// get must read the value stored by set earlier in the same block.
+	if findOp(traces[1].StructLogs, kvm.SLOAD) == nil {
+		t.Error("get trace should contain SLOAD")
+	}
+	if have, want := common.BytesToHash(traces[1].ReturnValue), common.BigToHash(big.NewInt(7)); have != want {
+		t.Errorf("get return value mismatch: have %v, want %v", have.Hex(), want.Hex())
+	}
+
+	// Tracing the block must agree with tracing its transactions one by one.
+	for i := range traces {
+		trace, err := bc.TraceTransaction(block, i, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trace.Gas != traces[i].Gas || len(trace.StructLogs) != len(traces[i].StructLogs) {
+			t.Errorf("trace %d mismatch: have gas %v with %d struct logs, want gas %v with %d struct logs",
+				i, traces[i].Gas, len(traces[i].StructLogs), trace.Gas, len(trace.StructLogs))
+		}
+	}
+}
@@ -0,0 +1,49 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package tx_pool
+
+import (
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// InclusionFilter decides which pending transactions the proposer may include
+// into a block. Excluded transactions stay in the pool.
+type InclusionFilter interface {
+	Include(sender common.Address, tx *types.Transaction) bool
+}
+
+// AddressAllowlist is an InclusionFilter only including transactions sent by
+// its addresses, e.g. for permissioned deployments.
+type AddressAllowlist map[common.Address]struct{}
+
+// NewAddressAllowlist creates an AddressAllowlist of the given addresses.
+func NewAddressAllowlist(addrs []common.Address) AddressAllowlist {
+	allowlist := make(AddressAllowlist, len(addrs))
+	for _, addr := range addrs {
+		allowlist[addr] = struct{}{}
+	}
+	return allowlist
+}
+
+// Include implements InclusionFilter.
+func (l AddressAllowlist) Include(sender common.Address, tx *types.Transaction) bool {
+	_, ok := l[sender]
+	return ok
+}
@@ -78,3 +78,18 @@ func (txn *txNoncer) setIfLower(addr common.Address, nonce uint64) {
 	}
 	txn.nonces[addr] = nonce
 }
+
+// setIfNext bumps the virtual nonce of an account past the given one if it is
+// exactly the next nonce expected, atomically allocating it to the caller.
+func (txn *txNoncer) setIfNext(addr common.Address, nonce uint64) {
+	txn.lock.Lock()
+	defer txn.lock.Unlock()
+
+	if _, ok := txn.nonces[addr]; !ok {
+		txn.nonces[addr] = txn.fallback.GetNonce(addr)
+	}
+	if txn.nonces[addr] != nonce {
+		return
+	}
+	txn.nonces[addr] = nonce + 1
+}
@@ -19,11 +19,14 @@
 package tx_pool
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common/prque"
@@ -39,6 +42,9 @@ import (
 const (
 	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
 	chainHeadChanSize = 10
+
+	// chainReorgChanSize is the size of channel listening to ChainReorgEvent.
+	chainReorgChanSize = 10
 )
 
 var (
@@ -66,17 +72,39 @@ var (
 	ErrIntrinsicGas = errors.New("intrinsic gas too low")
 
 	// ErrGasLimit is returned if a transaction's requested gas limit exceeds the
-	// maximum allowance of the current block.
+	// maximum allowance of the current block, or the share of it a single
+	// transaction may take.
 	ErrGasLimit = errors.New("exceeds block gas limit")
 
 	// ErrNegativeValue is a sanity error to ensure noone is able to specify a
 	// transaction with a negative value.
 	ErrNegativeValue = errors.New("negative value")
 
+	// ErrInvalidRecipient is returned if a transaction is sent to the zero address,
+	// which is most likely a recipient left unset. Contract creations have no
+	// recipient at all.
+	ErrInvalidRecipient = errors.New("invalid recipient")
+
 	// ErrOversizedData is returned if the input data of a transaction is greater
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrTxsNotAccepted is returned if the transaction pool has been configured to
+	// stop accepting new transactions.
+	ErrTxsNotAccepted = errors.New("transaction pool is not accepting transactions")
+
+	// ErrTxNotPending is returned if a transaction is attempted to be resent while
+	// the one it should replace is not in the pending pool.
+	ErrTxNotPending = errors.New("transaction not pending")
+
+	// ErrResendMismatch is returned if a resent transaction is not signed by the
+	// same account at the same nonce as the transaction it should replace.
+	ErrResendMismatch = errors.New("replacement does not match sender and nonce")
+
+	// ErrFeeCapTooLow is returned if a remote transaction's fee cap is below the
+	// base fee of the next block.
+	ErrFeeCapTooLow = errors.New("max fee per gas less than block base fee")
 )
 
 var (
@@ -126,6 +154,8 @@ type blockChain interface {
 	StateAt(height uint64) (*state.StateDB, error)
 	DB() types.StoreDB
 	SubscribeChainHeadEvent(ch chan<- events.ChainHeadEvent) event.Subscription
+	SubscribeChainReorgEvent(ch chan<- events.ChainReorgEvent) event.Subscription
+	CalcBaseFee(parent *types.Block) *big.Int
 }
 
 // TxPoolConfig are the configuration parameters of the transaction pool.
@@ -138,12 +168,22 @@ type TxPoolConfig struct {
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
+	CreatePriceLimit uint64 // Minimum gas price to enforce for remote contract creations, on top of PriceLimit
+	CallPriceLimit   uint64 // Minimum gas price to enforce for remote transfers and calls, on top of PriceLimit
+
 	AccountSlots uint64 // Number of executable transaction slots guaranteed per account
 	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
-	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+	Lifetime time.Duration // Maximum amount of time non-local transactions are kept pending, or queued by an inactive account
+
+	MaxTxGasFraction float64 // Maximum fraction of the block gas limit a single transaction may request
+
+	BroadcastFlood    float64 // Fraction of peers new transactions are sent to in full, the rest are only announced their hashes
+	BroadcastMinFlood int     // Minimum number of peers new transactions are sent to in full
+
+	ProposerAllowlist []common.Address // Senders whose transactions are proposed into blocks, empty proposes everyone's
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -161,6 +201,10 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  4096,
 
 	Lifetime: 3 * time.Hour,
+
+	MaxTxGasFraction: 1,
+
+	BroadcastFlood: 1,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -199,12 +243,28 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
 	}
+	if conf.MaxTxGasFraction <= 0 || conf.MaxTxGasFraction > 1 {
+		log.Warn("Sanitizing invalid txpool max tx gas fraction", "provided", conf.MaxTxGasFraction, "updated", DefaultTxPoolConfig.MaxTxGasFraction)
+		conf.MaxTxGasFraction = DefaultTxPoolConfig.MaxTxGasFraction
+	}
+	if conf.BroadcastFlood < 0 || conf.BroadcastFlood > 1 {
+		log.Warn("Sanitizing invalid txpool broadcast flood fraction", "provided", conf.BroadcastFlood, "updated", DefaultTxPoolConfig.BroadcastFlood)
+		conf.BroadcastFlood = DefaultTxPoolConfig.BroadcastFlood
+	}
+	if conf.BroadcastMinFlood < 0 {
+		log.Warn("Sanitizing invalid txpool broadcast min flood peers", "provided", conf.BroadcastMinFlood, "updated", DefaultTxPoolConfig.BroadcastMinFlood)
+		conf.BroadcastMinFlood = DefaultTxPoolConfig.BroadcastMinFlood
+	}
 	return conf
 }
 
-// GetDefaultTxPoolConfig returns default txPoolConfig with given dir path
+// GetDefaultTxPoolConfig returns default txPoolConfig with the journal placed
+// under the given dir path
 func GetDefaultTxPoolConfig(path string) *TxPoolConfig {
 	conf := DefaultTxPoolConfig
+	if path != "" {
+		conf.Journal = filepath.Join(path, conf.Journal)
+	}
 	return &conf
 }
 
@@ -221,25 +281,38 @@ type TxPool struct {
 	chain       blockChain
 	gasPrice    *big.Int
 	txFeed      event.Feed
+	removedFeed event.Feed
 	scope       event.SubscriptionScope
 	signer      types.Signer
 	mu          sync.RWMutex
+	acceptTxs   uint32 // Flag whether new transactions are accepted into the pool (1 is yes and 0 is no)
 
-	currentState  *state.StateDB // Current state in the blockchain head
-	pendingNonces *txNoncer      // Pending state tracking virtual nonces
-	currentMaxGas uint64         // Current gas limit for transaction caps
+	currentHead    *types.Header  // Chain head the pool state is derived from, transactions are validated for the block after it
+	currentState   *state.StateDB // Current state in the blockchain head
+	pendingNonces  *txNoncer      // Pending state tracking virtual nonces
+	currentMaxGas  uint64         // Current gas limit for transaction caps
+	currentTxGas   uint64         // Current gas limit a single transaction may request, at most currentMaxGas
+	currentBaseFee *big.Int       // Base fee of the block on top of the current head, nil before London
 
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
 
+	inclusionFilter InclusionFilter // Transactions ProposeTransactions may include, nil includes all
+
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
 	beats   map[common.Address]time.Time // Last heartbeat from each known account
 	all     *txLookup                    // All transactions to allow lookups
 	priced  *txPricedList                // All transactions sorted by price
 
+	arrivals map[common.Hash]time.Time // Time each transaction was added to the pool, for lifetime eviction
+
+	removals []events.RemovedTxsEvent // Dropped transactions waiting to be announced
+
 	chainHeadCh     chan events.ChainHeadEvent
 	chainHeadSub    event.Subscription
+	chainReorgCh    chan events.ChainReorgEvent
+	chainReorgSub   event.Subscription
 	reqResetCh      chan *txpoolResetRequest
 	reqPromoteCh    chan *accountSet
 	queueTxEventCh  chan *types.Transaction
@@ -254,6 +327,7 @@ type TxPool struct {
 
 type txpoolResetRequest struct {
 	oldHead, newHead *types.Header
+	reinject         types.Transactions // Transactions dropped from the chain by a reorg
 }
 
 // NewTxPool creates a new transaction pool to gather, sort and filter inbound
@@ -272,19 +346,25 @@ func NewTxPool(config TxPoolConfig, chainconfig *types.ChainConfig, chain blockC
 		queue:           make(map[common.Address]*txList),
 		beats:           make(map[common.Address]time.Time),
 		all:             newTxLookup(),
+		arrivals:        make(map[common.Hash]time.Time),
 		chainHeadCh:     make(chan events.ChainHeadEvent, chainHeadChanSize),
+		chainReorgCh:    make(chan events.ChainReorgEvent, chainReorgChanSize),
 		reqResetCh:      make(chan *txpoolResetRequest),
 		reqPromoteCh:    make(chan *accountSet),
 		queueTxEventCh:  make(chan *types.Transaction),
 		reorgDoneCh:     make(chan chan struct{}),
 		reorgShutdownCh: make(chan struct{}),
 		gasPrice:        new(big.Int).SetUint64(config.PriceLimit),
+		acceptTxs:       1,
 	}
 	pool.locals = newAccountSet(pool.signer)
 	for _, addr := range config.Locals {
 		log.Info("Setting new local account", "address", addr)
 		pool.locals.add(addr)
 	}
+	if len(config.ProposerAllowlist) > 0 {
+		pool.inclusionFilter = NewAddressAllowlist(config.ProposerAllowlist)
+	}
 	pool.priced = newTxPricedList(pool.all)
 	pool.reset(nil, chain.CurrentBlock().Header())
 
@@ -306,6 +386,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *types.ChainConfig, chain blockC
 
 	// Subscribe events from blockchain and start the main event loop.
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
+	pool.chainReorgSub = pool.chain.SubscribeChainReorgEvent(pool.chainReorgCh)
 	pool.wg.Add(1)
 	go pool.loop()
 
@@ -332,7 +413,16 @@ func (pool *TxPool) notifyTxsAvailable() {
 	}
 }
 
+// Config returns the sanitized configuration of the pool.
+func (pool *TxPool) Config() TxPoolConfig {
+	return pool.config
+}
+
+// State returns the state of the chain head the pool was last reset to.
 func (pool *TxPool) State() *state.StateDB {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
 	return pool.currentState
 }
 
@@ -349,21 +439,83 @@ func (pool *TxPool) PendingSize() int {
 	return pendingSize
 }
 
-// ProposeTransactions collects transactions from pending and remove them.
+// QueuedSize returns the number of non-executable transactions waiting for a
+// nonce gap to fill before they get promoted to pending.
+func (pool *TxPool) QueuedSize() int {
+	_, queued := pool.Stats()
+	return queued
+}
+
+// SetInclusionFilter sets the filter deciding which pending transactions
+// ProposeTransactions may include, nil includes all of them.
+func (pool *TxPool) SetInclusionFilter(filter InclusionFilter) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.inclusionFilter = filter
+}
+
+// ProposeTransactions collects executable transactions for the next block. Accounts
+// are visited by descending gas price of their next transaction, ties broken by
+// ascending address so the proposal is deterministic. Each account's
+// transactions are packed in nonce order until adding the next one would exceed the
+// current block gas limit or is rejected by the inclusion filter, at which point the
+// account is skipped.
 func (pool *TxPool) ProposeTransactions() []*types.Transaction {
-	return pool.GetPendingData()
+	pending, _ := pool.Pending()
+
+	pool.mu.RLock()
+	gasLimit := pool.currentMaxGas
+	filter := pool.inclusionFilter
+	pool.mu.RUnlock()
+
+	var (
+		txs     = []*types.Transaction{}
+		gasUsed uint64
+	)
+	for _, addr := range accountsByPrice(pending) {
+		for _, tx := range pending[addr] {
+			// Later nonces of this account can't be included without this one
+			if tx.Gas() > gasLimit-gasUsed || (filter != nil && !filter.Include(addr, tx)) {
+				break
+			}
+			gasUsed += tx.Gas()
+			txs = append(txs, tx)
+		}
+	}
+	return txs
 }
 
 // ProposeTransactions collects transactions from pending and remove them.
 func (pool *TxPool) GetPendingData() []*types.Transaction {
 	txs := []*types.Transaction{}
 	pending, _ := pool.Pending()
-	for _, batch := range pending {
-		txs = append(txs, batch...)
+	for _, addr := range sortedAccounts(pending) {
+		txs = append(txs, pending[addr]...)
 	}
 	return txs
 }
 
+// sortedAccounts returns the accounts of txs ordered by ascending address.
+func sortedAccounts(txs map[common.Address]types.Transactions) []common.Address {
+	addrs := make([]common.Address, 0, len(txs))
+	for addr := range txs {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+	return addrs
+}
+
+// accountsByPrice returns the accounts of txs ordered by descending gas price of their
+// first transaction, then by ascending address.
+func accountsByPrice(txs map[common.Address]types.Transactions) []common.Address {
+	addrs := sortedAccounts(txs)
+	sort.SliceStable(addrs, func(i, j int) bool {
+		return txs[addrs[i]][0].GasPrice().Cmp(txs[addrs[j]][0].GasPrice()) > 0
+	})
+	return addrs
+}
+
 // loop is the transaction pool's main event loop, waiting for and reacting to
 // outside blockchain events as well as for various reporting and transaction
 // eviction events.
@@ -375,12 +527,14 @@ func (pool *TxPool) loop() {
 		// Start the stats reporting and transaction eviction tickers
 		report  = time.NewTicker(statsReportInterval)
 		evict   = time.NewTicker(evictionInterval)
+		expire  = time.NewTicker(pool.config.Lifetime/4 + 1)
 		journal = time.NewTicker(pool.config.Rejournal)
 		// Track the previous head headers for transaction reorgs
 		head = pool.chain.CurrentBlock()
 	)
 	defer report.Stop()
 	defer evict.Stop()
+	defer expire.Stop()
 	defer journal.Stop()
 
 	for {
@@ -392,6 +546,22 @@ func (pool *TxPool) loop() {
 				head = ev.Block
 			}
 
+		// Handle ChainReorgEvent, re-injecting transactions of the dropped blocks
+		case ev := <-pool.chainReorgCh:
+			newHead := ev.CommonAncestor
+			if len(ev.Added) > 0 {
+				newHead = ev.Added[len(ev.Added)-1]
+			}
+			var discarded, included types.Transactions
+			for _, block := range ev.Dropped {
+				discarded = append(discarded, block.Transactions()...)
+			}
+			for _, block := range ev.Added {
+				included = append(included, block.Transactions()...)
+			}
+			pool.requestReorg(head.Header(), newHead.Header(), types.TxDifference(discarded, included))
+			head = newHead
+
 		// System shutdown.
 		case <-pool.chainHeadSub.Err():
 			close(pool.reorgShutdownCh)
@@ -419,12 +589,22 @@ func (pool *TxPool) loop() {
 				}
 				// Any non-locals old enough should be removed
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
-					for _, tx := range pool.queue[addr].Flatten() {
+					expired := pool.queue[addr].Flatten()
+					for _, tx := range expired {
 						pool.removeTx(tx.Hash(), true)
 					}
+					pool.dropTxs(expired, events.TxRemovedStale)
 				}
 			}
 			pool.mu.Unlock()
+			pool.sendRemovedTxs()
+
+		// Handle pending transaction lifetime eviction
+		case <-expire.C:
+			pool.mu.Lock()
+			pool.evictExpiredPending()
+			pool.mu.Unlock()
+			pool.sendRemovedTxs()
 
 		// Handle local transaction journal rotation
 		case <-journal.C:
@@ -446,6 +626,7 @@ func (pool *TxPool) Stop() {
 
 	// Unsubscribe subscriptions registered from blockchain
 	pool.chainHeadSub.Unsubscribe()
+	pool.chainReorgSub.Unsubscribe()
 	pool.wg.Wait()
 
 	if pool.journal != nil {
@@ -460,6 +641,26 @@ func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- events.NewTxsEvent) event.Sub
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// AcceptTxs returns whether the pool currently accepts new transactions (1 is
+// yes and 0 is no).
+func (pool *TxPool) AcceptTxs() uint32 {
+	return atomic.LoadUint32(&pool.acceptTxs)
+}
+
+// SetAcceptTxs toggles at runtime whether new transactions are accepted into
+// the pool. Transactions already pooled are not affected.
+func (pool *TxPool) SetAcceptTxs(acceptTxs uint32) {
+	atomic.StoreUint32(&pool.acceptTxs, acceptTxs)
+	log.Info("Transaction pool acceptance updated", "acceptTxs", acceptTxs)
+}
+
+// SubscribeRemovedTxsEvent registers a subscription of RemovedTxsEvent and
+// starts sending event to the given channel whenever transactions are dropped
+// from the pool without being included in a block.
+func (pool *TxPool) SubscribeRemovedTxsEvent(ch chan<- events.RemovedTxsEvent) event.Subscription {
+	return pool.scope.Track(pool.removedFeed.Subscribe(ch))
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -472,12 +673,16 @@ func (pool *TxPool) GasPrice() *big.Int {
 // new transaction, and drops all transactions below this threshold.
 func (pool *TxPool) SetGasPrice(price *big.Int) {
 	pool.mu.Lock()
-	defer pool.mu.Unlock()
 
 	pool.gasPrice = price
-	for _, tx := range pool.priced.Cap(price, pool.locals) {
+	drop := pool.priced.Cap(price, pool.locals)
+	for _, tx := range drop {
 		pool.removeTx(tx.Hash(), false)
 	}
+	pool.dropTxs(drop, events.TxRemovedUnderpriced)
+	pool.mu.Unlock()
+
+	pool.sendRemovedTxs()
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
@@ -544,6 +749,20 @@ func (pool *TxPool) Pending() (map[common.Address]types.Transactions, error) {
 	return pending, nil
 }
 
+// Queued retrieves all currently non-executable transactions, grouped by origin
+// account and sorted by nonce. The returned transaction set is a copy and can be
+// freely modified by calling code.
+func (pool *TxPool) Queued() map[common.Address]types.Transactions {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	queued := make(map[common.Address]types.Transactions)
+	for addr, list := range pool.queue {
+		queued[addr] = list.Flatten()
+	}
+	return queued
+}
+
 // Locals retrieves the accounts currently considered local by the pool.
 func (pool *TxPool) Locals() []common.Address {
 	pool.mu.Lock()
@@ -580,8 +799,16 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if tx.Value().Sign() < 0 {
 		return ErrNegativeValue
 	}
-	// Ensure the transaction doesn't exceed the current block limit gas.
-	if pool.currentMaxGas < tx.Gas() {
+	if to := tx.To(); to != nil && *to == (common.Address{}) {
+		return ErrInvalidRecipient
+	}
+	// Typed transactions are only accepted once the next block can include them.
+	if err := types.CheckTxType(pool.chainconfig, new(big.Int).SetUint64(pool.currentHead.Height+1), tx); err != nil {
+		return err
+	}
+	// Ensure the transaction doesn't exceed the share of the current block limit gas
+	// a single transaction may take.
+	if pool.currentTxGas < tx.Gas() {
 		return ErrGasLimit
 	}
 	// Make sure the transaction is signed properly
@@ -591,9 +818,22 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	}
 	// Drop non-local transactions under our own minimal accepted gas price
 	local = local || pool.locals.contains(from) // account may be local even if the transaction arrived from the network
-	if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
+	if !local && pool.gasPrice.Cmp(tx.GasTipCap()) > 0 {
 		return ErrUnderpriced
 	}
+	// Contract creations and calls may be priced differently by the node operator
+	floor := pool.config.CallPriceLimit
+	if tx.To() == nil {
+		floor = pool.config.CreatePriceLimit
+	}
+	if !local && tx.GasTipCap().Cmp(new(big.Int).SetUint64(floor)) < 0 {
+		return ErrUnderpriced
+	}
+	// Drop non-local transactions which can't pay the base fee of the next block. Local ones, like
+	// the zero priced system transactions, are left to block processing.
+	if !local && pool.currentBaseFee != nil && tx.GasFeeCap().Cmp(pool.currentBaseFee) < 0 {
+		return ErrFeeCapTooLow
+	}
 	// Ensure the transaction adheres to nonce ordering
 	if pool.currentState.GetNonce(from) > tx.Nonce() {
 		return ErrNonceTooLow
@@ -650,6 +890,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 			underpricedTxMeter.Mark(1)
 			pool.removeTx(tx.Hash(), false)
 		}
+		pool.dropTxs(drop, events.TxRemovedUnderpriced)
 	}
 	// Try to replace an existing transaction in the pending pool
 	from, _ := types.Sender(pool.signer, tx) // already validated
@@ -690,6 +931,10 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 	}
 	pool.journalTx(from, tx)
 
+	// Allocate the nonce right away so concurrent callers relying on Nonce don't
+	// reuse it before the next reorg promotes the transaction.
+	pool.pendingNonces.setIfNext(from, tx.Nonce())
+
 	log.Trace("Pooled new future transaction", "hash", hash, "from", from, "to", tx.To())
 	return replaced, nil
 }
@@ -737,6 +982,30 @@ func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
 	}
 }
 
+// dropTxs records a batch of transactions removed from the pool so that they can
+// be announced to RemovedTxsEvent subscribers once the pool lock is released.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) dropTxs(txs types.Transactions, reason events.TxRemovalReason) {
+	if len(txs) == 0 {
+		return
+	}
+	pool.removals = append(pool.removals, events.RemovedTxsEvent{Txs: txs, Reason: reason})
+}
+
+// sendRemovedTxs announces all the removals recorded since the last call. It
+// must not be called while holding the pool lock.
+func (pool *TxPool) sendRemovedTxs() {
+	pool.mu.Lock()
+	removals := pool.removals
+	pool.removals = nil
+	pool.mu.Unlock()
+
+	for _, ev := range removals {
+		pool.removedFeed.Send(ev)
+	}
+}
+
 // promoteTx adds a transaction to the pending (processable) list of transactions
 // and returns whether it was inserted or an older was better.
 //
@@ -795,6 +1064,25 @@ func (pool *TxPool) AddLocal(tx *types.Transaction) error {
 	return errs[0]
 }
 
+// Resend replaces the pending transaction identified by oldHash with tx. The
+// replacement must be signed by the same account at the same nonce and has to
+// meet the configured price bump, in which case it is added as a local one.
+func (pool *TxPool) Resend(oldHash common.Hash, tx *types.Transaction) error {
+	old := pool.Get(oldHash)
+	if old == nil || pool.Status([]common.Hash{oldHash})[0] != TxStatusPending {
+		return ErrTxNotPending
+	}
+	oldFrom, _ := types.Sender(pool.signer, old) // already validated
+	from, err := types.Sender(pool.signer, tx)
+	if err != nil {
+		return ErrInvalidSender
+	}
+	if from != oldFrom || tx.Nonce() != old.Nonce() {
+		return ErrResendMismatch
+	}
+	return pool.AddLocal(tx)
+}
+
 // AddRemotes enqueues a batch of transactions into the pool if they are valid. If the
 // senders are not among the locally tracked ones, full pricing constraints will apply.
 //
@@ -831,6 +1119,13 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 		errs = make([]error, len(txs))
 		news = make([]*types.Transaction, 0, len(txs))
 	)
+	// Reject everything if the pool was told not to accept transactions
+	if atomic.LoadUint32(&pool.acceptTxs) == 0 {
+		for i := range errs {
+			errs[i] = ErrTxsNotAccepted
+		}
+		return errs
+	}
 	for i, tx := range txs {
 		// If the transaction is known, pre-set the error slot
 		if pool.all.Get(tx.Hash()) != nil {
@@ -851,6 +1146,7 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 	pool.mu.Lock()
 	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
 	pool.mu.Unlock()
+	pool.sendRemovedTxs()
 
 	var nilSlot = 0
 	for _, err := range newErrs {
@@ -878,6 +1174,9 @@ func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) ([]error,
 	for i, tx := range txs {
 		replaced, err := pool.add(tx, local)
 		errs[i] = err
+		if err == nil {
+			pool.arrivals[tx.Hash()] = time.Now()
+		}
 		if err == nil && !replaced {
 			dirty.addTx(tx)
 		}
@@ -926,6 +1225,7 @@ func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
 
 	// Remove it from the list of known transactions
 	pool.all.Remove(hash)
+	delete(pool.arrivals, hash)
 	if outofbound {
 		pool.priced.Removed(1)
 	}
@@ -966,8 +1266,15 @@ func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
 // requestPromoteExecutables requests a pool reset to the new head block.
 // The returned channel is closed when the reset has occurred.
 func (pool *TxPool) requestReset(oldHead *types.Header, newHead *types.Header) chan struct{} {
+	return pool.requestReorg(oldHead, newHead, nil)
+}
+
+// requestReorg requests a pool reset to the new head block, re-injecting the
+// given transactions dropped from the chain. The returned channel is closed
+// when the reset has occurred.
+func (pool *TxPool) requestReorg(oldHead *types.Header, newHead *types.Header, reinject types.Transactions) chan struct{} {
 	select {
-	case pool.reqResetCh <- &txpoolResetRequest{oldHead, newHead}:
+	case pool.reqResetCh <- &txpoolResetRequest{oldHead, newHead, reinject}:
 		return <-pool.reorgDoneCh
 	case <-pool.reorgShutdownCh:
 		return pool.reorgShutdownCh
@@ -1028,6 +1335,7 @@ func (pool *TxPool) scheduleReorgLoop() {
 				reset = req
 			} else {
 				reset.newHead = req.newHead
+				reset.reinject = append(reset.reinject, req.reinject...)
 			}
 			launchNextRun = true
 			pool.reorgDoneCh <- nextDone
@@ -1078,6 +1386,13 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 		// Reset from the old head to the new, rescheduling any reorged transactions
 		pool.reset(reset.oldHead, reset.newHead)
 
+		// Inject any transactions discarded due to reorgs
+		if len(reset.reinject) > 0 {
+			log.Debug("Reinjecting stale transactions", "count", len(reset.reinject))
+			senderCacher.recover(pool.signer, reset.reinject)
+			pool.addTxsLocked(reset.reinject, false)
+		}
+
 		// Nonces were reset, discard any events that became stale
 		for addr := range eventsPool {
 			eventsPool[addr].Forward(pool.pendingNonces.get(addr))
@@ -1116,6 +1431,7 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 		pool.pendingNonces.set(addr, txs[len(txs)-1].Nonce()+1)
 	}
 	pool.mu.Unlock()
+	pool.sendRemovedTxs()
 
 	// Notify subsystems for newly added transactions
 	if len(eventsPool) > 0 {
@@ -1195,7 +1511,8 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// 		reinject = types.TxDifference(discarded, included)
 	// 	}
 	// }
-	// Initialize the internal state to the current head
+	// Initialize the internal state to the current head. The head is resolved once, so
+	// the chain moving on meanwhile can't mix the state of one head with the limits of another.
 	if newHead == nil {
 		newHead = pool.chain.CurrentBlock().Header() // Special case during testing
 	}
@@ -1204,9 +1521,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 		log.Error("Failed to reset txpool state", "err", err)
 		return
 	}
-	pool.currentState = statedb
-	pool.pendingNonces = newTxNoncer(statedb)
-	pool.currentMaxGas = newHead.GasLimit
+	pool.applyHead(newHead, statedb)
 
 	// Inject any transactions discarded due to reorgs
 	// log.Debug("Reinjecting stale transactions", "count", len(reinject))
@@ -1214,6 +1529,19 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// pool.addTxsLocked(reinject, false)
 }
 
+// applyHead switches the pool over to head and its state, replacing everything derived
+// from the chain head at once so readers holding the pool lock never see a mix of heads.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) applyHead(head *types.Header, statedb *state.StateDB) {
+	pool.currentHead = head
+	pool.currentState = statedb
+	pool.pendingNonces = newTxNoncer(statedb)
+	pool.currentMaxGas = head.GasLimit
+	pool.currentTxGas = uint64(float64(head.GasLimit) * pool.config.MaxTxGasFraction)
+	pool.currentBaseFee = pool.chain.CalcBaseFee(types.NewBlockWithHeader(head))
+}
+
 // promoteExecutables moves transactions that have become processable from the
 // future queue to the set of pending transactions. During this process, all
 // invalidated transactions (low nonce, low balance) are deleted.
@@ -1234,6 +1562,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			pool.all.Remove(hash)
 			log.Trace("Removed old queued transaction", "hash", hash)
 		}
+		pool.dropTxs(forwards, events.TxRemovedMined)
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
@@ -1241,6 +1570,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			pool.all.Remove(hash)
 			log.Trace("Removed unpayable queued transaction", "hash", hash)
 		}
+		pool.dropTxs(drops, events.TxRemovedUnpayable)
 		queuedNofundsMeter.Mark(int64(len(drops)))
 
 		// Gather all executable transactions and promote them
@@ -1263,6 +1593,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 				pool.all.Remove(hash)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
+			pool.dropTxs(caps, events.TxRemovedOverflow)
 			queuedRateLimitMeter.Mark(int64(len(caps)))
 		}
 		// Mark all the items dropped as removed
@@ -1327,6 +1658,7 @@ func (pool *TxPool) truncatePending() {
 						pool.pendingNonces.setIfLower(offenders[i], tx.Nonce())
 						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 					}
+					pool.dropTxs(caps, events.TxRemovedOverflow)
 					pool.priced.Removed(len(caps))
 					pendingGauge.Dec(int64(len(caps)))
 					if pool.locals.contains(offenders[i]) {
@@ -1354,6 +1686,7 @@ func (pool *TxPool) truncatePending() {
 					pool.pendingNonces.setIfLower(addr, tx.Nonce())
 					log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 				}
+				pool.dropTxs(caps, events.TxRemovedOverflow)
 				pool.priced.Removed(len(caps))
 				pendingGauge.Dec(int64(len(caps)))
 				if pool.locals.contains(addr) {
@@ -1394,20 +1727,24 @@ func (pool *TxPool) truncateQueue() {
 
 		// Drop all transactions if they are less than the overflow
 		if size := uint64(list.Len()); size <= drop {
-			for _, tx := range list.Flatten() {
+			txs := list.Flatten()
+			for _, tx := range txs {
 				pool.removeTx(tx.Hash(), true)
 			}
+			pool.dropTxs(txs, events.TxRemovedOverflow)
 			drop -= size
 			queuedRateLimitMeter.Mark(int64(size))
 			continue
 		}
 		// Otherwise drop only last few transactions
 		txs := list.Flatten()
-		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
+		i := len(txs) - 1
+		for ; i >= 0 && drop > 0; i-- {
 			pool.removeTx(txs[i].Hash(), true)
 			drop--
 			queuedRateLimitMeter.Mark(1)
 		}
+		pool.dropTxs(txs[i+1:], events.TxRemovedOverflow)
 	}
 }
 
@@ -1426,6 +1763,11 @@ func (pool *TxPool) demoteUnexecutables() {
 			pool.all.Remove(hash)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
+		pool.dropTxs(olds, events.TxRemovedMined)
+		// Included transactions count as account activity, restart its eviction timer
+		if len(olds) > 0 {
+			pool.beats[addr] = time.Now()
+		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
@@ -1433,6 +1775,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
 		}
+		pool.dropTxs(drops, events.TxRemovedUnpayable)
 		pool.priced.Removed(len(olds) + len(drops))
 		pendingNofundsMeter.Mark(int64(len(drops)))
 
@@ -1463,6 +1806,40 @@ func (pool *TxPool) demoteUnexecutables() {
 	}
 }
 
+// evictExpiredPending drops the non-local pending transactions added to the pool longer than
+// the configured lifetime ago, e.g. stuck behind a transaction that never gets mined. Higher
+// nonce transactions of the same account are demoted to the queue.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) evictExpiredPending() {
+	// Forget the arrival of transactions which left the pool without going through removeTx
+	for hash := range pool.arrivals {
+		if pool.all.Get(hash) == nil {
+			delete(pool.arrivals, hash)
+		}
+	}
+	for addr, list := range pool.pending {
+		// Skip local transactions from the eviction mechanism
+		if pool.locals.contains(addr) {
+			continue
+		}
+		var expired types.Transactions
+		for _, tx := range list.Flatten() {
+			if arrival, ok := pool.arrivals[tx.Hash()]; ok && time.Since(arrival) > pool.config.Lifetime {
+				expired = append(expired, tx)
+			}
+		}
+		if len(expired) == 0 {
+			continue
+		}
+		for _, tx := range expired {
+			pool.removeTx(tx.Hash(), true)
+		}
+		log.Debug("Evicted expired pending transactions", "account", addr, "count", len(expired))
+		pool.dropTxs(expired, events.TxRemovedStale)
+	}
+}
+
 // addressByHeartbeat is an account address tagged with its last activity timestamp.
 type addressByHeartbeat struct {
 	address   common.Address
@@ -253,11 +253,11 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 	// If there's an older better transaction, abort
 	old := l.txs.Get(tx.Nonce())
 	if old != nil {
-		threshold := new(big.Int).Div(new(big.Int).Mul(old.GasPrice(), big.NewInt(100+int64(priceBump))), big.NewInt(100))
-		// Have to ensure that the new gas price is higher than the old gas
-		// price as well as checking the percentage threshold to ensure that
-		// this is accurate for low (Wei-level) gas price replacements
-		if old.GasPrice().Cmp(tx.GasPrice()) >= 0 || threshold.Cmp(tx.GasPrice()) > 0 {
+		// Have to ensure that both the fee cap and the tip cap are higher than
+		// the old ones as well as checking the percentage threshold to ensure
+		// that this is accurate for low (Wei-level) gas price replacements.
+		// Both equal the gas price for legacy transactions.
+		if !priceBumped(old.GasFeeCap(), tx.GasFeeCap(), priceBump) || !priceBumped(old.GasTipCap(), tx.GasTipCap(), priceBump) {
 			return false, nil
 		}
 	}
@@ -373,7 +373,7 @@ func (h priceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
 
 func (h priceHeap) Less(i, j int) bool {
 	// Sort primarily by price, returning the cheaper one
-	switch h[i].GasPrice().Cmp(h[j].GasPrice()) {
+	switch cmpPrice(h[i], h[j]) {
 	case -1:
 		return true
 	case 1:
@@ -383,6 +383,21 @@ func (h priceHeap) Less(i, j int) bool {
 	return h[i].Nonce() > h[j].Nonce()
 }
 
+// cmpPrice compares the prices of two transactions by their fee caps, falling
+// back to their tip caps. Legacy transactions compare by gas price.
+func cmpPrice(a, b *types.Transaction) int {
+	if c := a.GasFeeCap().Cmp(b.GasFeeCap()); c != 0 {
+		return c
+	}
+	return a.GasTipCap().Cmp(b.GasTipCap())
+}
+
+// priceBumped reports whether price exceeds old by at least bump percent.
+func priceBumped(old, price *big.Int, bump uint64) bool {
+	threshold := new(big.Int).Div(new(big.Int).Mul(old, big.NewInt(100+int64(bump))), big.NewInt(100))
+	return old.Cmp(price) < 0 && threshold.Cmp(price) <= 0
+}
+
 func (h *priceHeap) Push(x interface{}) {
 	*h = append(*h, x.(*types.Transaction))
 }
@@ -450,7 +465,7 @@ func (l *txPricedList) Cap(threshold *big.Int, local *accountSet) types.Transact
 			continue
 		}
 		// Stop the discards if we've reached the threshold
-		if tx.GasPrice().Cmp(threshold) >= 0 {
+		if tx.GasFeeCap().Cmp(threshold) >= 0 {
 			save = append(save, tx)
 			break
 		}
@@ -490,7 +505,7 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 		return false
 	}
 	cheapest := []*types.Transaction(*l.items)[0]
-	return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
+	return cmpPrice(cheapest, tx) >= 0
 }
 
 // Discard finds a number of most underpriced transactions, removes them from the
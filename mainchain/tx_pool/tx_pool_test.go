@@ -1 +1,1191 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
 package tx_pool
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kardiachain/go-kardia/kai/events"
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/kai/state"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/event"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+// testTxPoolConfig is a transaction pool configuration without stateful disk
+// sideeffects used during testing.
+var testTxPoolConfig TxPoolConfig
+
+// testRecipient receives the value of test transactions.
+var testRecipient = common.HexToAddress("0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5")
+
+func init() {
+	testTxPoolConfig = DefaultTxPoolConfig
+	testTxPoolConfig.Journal = ""
+}
+
+type testBlockChain struct {
+	statedb        *state.StateDB
+	gasLimit       uint64
+	chainHeadFeed  *event.Feed
+	chainReorgFeed *event.Feed
+}
+
+func (bc *testBlockChain) CurrentBlock() *types.Block {
+	return types.NewBlockWithHeader(&types.Header{
+		GasLimit: bc.gasLimit,
+		Time:     big.NewInt(0),
+	})
+}
+
+func (bc *testBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.CurrentBlock()
+}
+
+func (bc *testBlockChain) StateAt(height uint64) (*state.StateDB, error) {
+	return bc.statedb, nil
+}
+
+func (bc *testBlockChain) DB() types.StoreDB {
+	return nil
+}
+
+func (bc *testBlockChain) CalcBaseFee(parent *types.Block) *big.Int {
+	return nil
+}
+
+func (bc *testBlockChain) SubscribeChainHeadEvent(ch chan<- events.ChainHeadEvent) event.Subscription {
+	return bc.chainHeadFeed.Subscribe(ch)
+}
+
+func (bc *testBlockChain) SubscribeChainReorgEvent(ch chan<- events.ChainReorgEvent) event.Subscription {
+	return bc.chainReorgFeed.Subscribe(ch)
+}
+
+func transaction(nonce uint64, gaslimit uint64, key *ecdsa.PrivateKey) *types.Transaction {
+	return pricedTransaction(nonce, gaslimit, big.NewInt(1), key)
+}
+
+func pricedTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignTx(types.HomesteadSigner{}, types.NewTransaction(nonce, testRecipient, big.NewInt(100), gaslimit, gasprice, nil), key)
+	return tx
+}
+
+func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
+	return setupTxPoolWithConfig(testTxPoolConfig, 1000000)
+}
+
+func setupTxPoolWithConfig(config TxPoolConfig, gasLimit uint64) (*TxPool, *ecdsa.PrivateKey) {
+	statedb, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+	blockchain := &testBlockChain{statedb, gasLimit, new(event.Feed), new(event.Feed)}
+
+	key, _ := crypto.GenerateKey()
+	pool := NewTxPool(config, nil, blockchain)
+
+	return pool, key
+}
+
+// testAddBalance adds a balance to an account through the pool's current state
+// and resets the pool so that the virtual nonces pick up the new account.
+func testAddBalance(pool *TxPool, addr common.Address, amount *big.Int) {
+	pool.mu.Lock()
+	pool.currentState.AddBalance(addr, amount)
+	pool.mu.Unlock()
+
+	<-pool.requestReset(nil, nil)
+}
+
+// Tests that the proposed transactions never exceed the block gas limit, and
+// that an account's transactions are only packed in contiguous nonce order.
+func TestProposeTransactionsGasLimit(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPoolWithConfig(testTxPoolConfig, 100000)
+	defer pool.Stop()
+
+	other, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000000))
+
+	nonce := pool.Nonce(crypto.PubkeyToAddress(key.PublicKey))
+	txs := []*types.Transaction{
+		transaction(nonce, 40000, key),
+		transaction(nonce+1, 50000, key),
+		transaction(nonce+2, 30000, key),
+		transaction(pool.Nonce(crypto.PubkeyToAddress(other.PublicKey)), 60000, other),
+	}
+	for i, err := range pool.AddRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	if pending, _ := pool.Stats(); pending != len(txs) {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", pending, len(txs))
+	}
+	proposed := pool.ProposeTransactions()
+
+	var gas uint64
+	nonces := make(map[common.Address]uint64)
+	for _, tx := range proposed {
+		gas += tx.Gas()
+
+		from, _ := types.Sender(pool.signer, tx)
+		if _, ok := nonces[from]; !ok {
+			nonces[from] = pool.currentState.GetNonce(from)
+		}
+		if tx.Nonce() != nonces[from] {
+			t.Errorf("proposed transaction nonce gap for %x: have %d, want %d", from, tx.Nonce(), nonces[from])
+		}
+		nonces[from] = tx.Nonce() + 1
+	}
+	if gas > 100000 {
+		t.Errorf("proposed transactions exceed block gas limit: have %d, limit %d", gas, 100000)
+	}
+	if len(proposed) == 0 {
+		t.Errorf("no transactions proposed")
+	}
+}
+
+// Tests that equally priced transactions of different senders are proposed in a
+// stable order, by ascending sender address.
+func TestProposeTransactionsDeterministic(t *testing.T) {
+	t.Parallel()
+
+	pool, _ := setupTxPool()
+	defer pool.Stop()
+
+	for i := 0; i < 5; i++ {
+		key, _ := crypto.GenerateKey()
+		from := crypto.PubkeyToAddress(key.PublicKey)
+		testAddBalance(pool, from, big.NewInt(1000000000))
+		if err := pool.addRemoteSync(transaction(pool.Nonce(from), 100000, key)); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	proposed := pool.ProposeTransactions()
+	if len(proposed) != 5 {
+		t.Fatalf("proposed transactions mismatch: have %d, want %d", len(proposed), 5)
+	}
+	for i := 1; i < len(proposed); i++ {
+		prev, _ := types.Sender(pool.signer, proposed[i-1])
+		from, _ := types.Sender(pool.signer, proposed[i])
+		if bytes.Compare(prev[:], from[:]) >= 0 {
+			t.Errorf("proposed transaction %d out of order: %x after %x", i, from, prev)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		again := pool.ProposeTransactions()
+		for j := range proposed {
+			if again[j].Hash() != proposed[j].Hash() {
+				t.Fatalf("proposal %d unstable at %d: have %x, want %x", i, j, again[j].Hash(), proposed[j].Hash())
+			}
+		}
+	}
+}
+
+// Tests that accounts are proposed by descending gas price when the block gas limit
+// can't fit them all, regardless of their addresses.
+func TestProposeTransactionsByPrice(t *testing.T) {
+	t.Parallel()
+
+	pool, low := setupTxPoolWithConfig(testTxPoolConfig, 100000)
+	defer pool.Stop()
+
+	// Let the cheap account have the lowest address, which used to win
+	high, _ := crypto.GenerateKey()
+	if bytes.Compare(crypto.PubkeyToAddress(low.PublicKey).Bytes(), crypto.PubkeyToAddress(high.PublicKey).Bytes()) > 0 {
+		low, high = high, low
+	}
+	for _, key := range []*ecdsa.PrivateKey{low, high} {
+		testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	}
+	cheap := pricedTransaction(pool.Nonce(crypto.PubkeyToAddress(low.PublicKey)), 60000, big.NewInt(1), low)
+	pricey := pricedTransaction(pool.Nonce(crypto.PubkeyToAddress(high.PublicKey)), 60000, big.NewInt(10), high)
+	for i, err := range pool.AddRemotesSync([]*types.Transaction{cheap, pricey}) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	proposed := pool.ProposeTransactions()
+	if len(proposed) != 1 || proposed[0].Hash() != pricey.Hash() {
+		t.Errorf("proposed transactions mismatch: have %v, want %x", proposed, pricey.Hash())
+	}
+}
+
+// Tests that a single transaction using the whole block gas is still proposed.
+func TestProposeTransactionsExactGasLimit(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPoolWithConfig(testTxPoolConfig, 100000)
+	defer pool.Stop()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	nonce := pool.Nonce(crypto.PubkeyToAddress(key.PublicKey))
+	if err := pool.addRemoteSync(transaction(nonce, 100000, key)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(transaction(nonce+1, 21000, key)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	proposed := pool.ProposeTransactions()
+	if len(proposed) != 1 {
+		t.Fatalf("proposed transactions mismatch: have %d, want %d", len(proposed), 1)
+	}
+	if proposed[0].Nonce() != nonce {
+		t.Errorf("proposed transaction nonce mismatch: have %d, want %d", proposed[0].Nonce(), nonce)
+	}
+}
+
+// Tests that a pending transaction can be replaced by one with the same nonce
+// only if the new one pays at least PriceBump percent more.
+func TestTransactionReplacement(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	nonce := pool.Nonce(from)
+	price := int64(100)
+	threshold := (price * (100 + int64(testTxPoolConfig.PriceBump))) / 100
+
+	if err := pool.addRemoteSync(pricedTransaction(nonce, 100000, big.NewInt(price), key)); err != nil {
+		t.Fatalf("failed to add original pending transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(nonce, 100001, big.NewInt(price), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("original pending transaction replaced with same price: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(nonce, 100000, big.NewInt(threshold-1), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("original pending transaction replaced without required price bump: %v", err)
+	}
+	replacement := pricedTransaction(nonce, 100000, big.NewInt(threshold), key)
+	if err := pool.addRemoteSync(replacement); err != nil {
+		t.Fatalf("failed to replace original pending transaction: %v", err)
+	}
+	pending, queued := pool.Stats()
+	if pending != 1 {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", pending, 1)
+	}
+	if queued != 0 {
+		t.Fatalf("queued transactions mismatch: have %d, want %d", queued, 0)
+	}
+	if pool.Get(replacement.Hash()) == nil {
+		t.Fatalf("replacement transaction missing from the pool")
+	}
+}
+
+// Tests that a pending transaction can be resent with a replacement only if the
+// replacement matches its sender and nonce and meets the required price bump.
+func TestTransactionResend(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	nonce := pool.Nonce(from)
+	price := int64(100)
+	threshold := (price * (100 + int64(testTxPoolConfig.PriceBump))) / 100
+
+	original := pricedTransaction(nonce, 100000, big.NewInt(price), key)
+	if err := pool.Resend(original.Hash(), original); err != ErrTxNotPending {
+		t.Fatalf("resend of unknown transaction error mismatch: have %v, want %v", err, ErrTxNotPending)
+	}
+	if err := pool.addRemoteSync(original); err != nil {
+		t.Fatalf("failed to add original pending transaction: %v", err)
+	}
+	if err := pool.Resend(original.Hash(), pricedTransaction(nonce+1, 100000, big.NewInt(threshold), key)); err != ErrResendMismatch {
+		t.Fatalf("resend at different nonce error mismatch: have %v, want %v", err, ErrResendMismatch)
+	}
+	other, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000000))
+	if err := pool.Resend(original.Hash(), pricedTransaction(nonce, 100000, big.NewInt(threshold), other)); err != ErrResendMismatch {
+		t.Fatalf("resend from different sender error mismatch: have %v, want %v", err, ErrResendMismatch)
+	}
+	if err := pool.Resend(original.Hash(), pricedTransaction(nonce, 100000, big.NewInt(threshold-1), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("resend without required price bump error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if pool.Get(original.Hash()) == nil {
+		t.Fatalf("original transaction missing after rejected resend")
+	}
+	replacement := pricedTransaction(nonce, 100000, big.NewInt(threshold), key)
+	if err := pool.Resend(original.Hash(), replacement); err != nil {
+		t.Fatalf("failed to resend with required price bump: %v", err)
+	}
+	if pool.Get(original.Hash()) != nil {
+		t.Fatalf("original transaction still in the pool after resend")
+	}
+	if status := pool.Status([]common.Hash{replacement.Hash()})[0]; status != TxStatusPending {
+		t.Fatalf("replacement status mismatch: have %v, want %v", status, TxStatusPending)
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want 1/0", pending, queued)
+	}
+}
+
+// Tests that the virtual nonce of an account is allocated as soon as a
+// transaction is added, so that callers building transactions from Nonce never
+// reuse one even if the pool hasn't been reorganised yet.
+func TestNonceAllocationOnAdd(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	// Concurrently hammer the nonce reader to surface data races
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-quit:
+				return
+			default:
+				pool.Nonce(from)
+			}
+		}
+	}()
+	var last uint64
+	for i := 0; i < 16; i++ {
+		nonce := pool.Nonce(from)
+		if i > 0 && nonce <= last {
+			t.Errorf("nonce %d not strictly increasing: have %d, previous %d", i, nonce, last)
+		}
+		last = nonce
+
+		if err := pool.AddRemote(transaction(nonce, 100000, key)); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	close(quit)
+	<-done
+}
+
+// Tests that toggling the accept-txs flag at runtime rejects and re-admits new
+// transactions, both local and remote.
+func TestAcceptTxsToggle(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+	nonce := pool.Nonce(from)
+
+	pool.SetAcceptTxs(0)
+	if err := pool.AddLocal(transaction(nonce, 100000, key)); err != ErrTxsNotAccepted {
+		t.Fatalf("local transaction error mismatch: have %v, want %v", err, ErrTxsNotAccepted)
+	}
+	if err := pool.AddRemote(transaction(nonce, 100000, key)); err != ErrTxsNotAccepted {
+		t.Fatalf("remote transaction error mismatch: have %v, want %v", err, ErrTxsNotAccepted)
+	}
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("rejected transactions pooled: pending %d, queued %d", pending, queued)
+	}
+
+	pool.SetAcceptTxs(1)
+	if err := pool.AddLocal(transaction(nonce, 100000, key)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(transaction(nonce+1, 100000, key)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", pending, 2)
+	}
+}
+
+// Tests that transactions evicted by raising the pool's price threshold are
+// announced to RemovedTxsEvent subscribers with the underpriced reason.
+func TestRemovedTxsEventOnPriceEviction(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	removedCh := make(chan events.RemovedTxsEvent, 1)
+	sub := pool.SubscribeRemovedTxsEvent(removedCh)
+	defer sub.Unsubscribe()
+
+	nonce := pool.Nonce(from)
+	cheap := pricedTransaction(nonce+1, 100000, big.NewInt(1), key)
+	if err := pool.addRemoteSync(pricedTransaction(nonce, 100000, big.NewInt(10), key)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(cheap); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	pool.SetGasPrice(big.NewInt(5))
+
+	select {
+	case ev := <-removedCh:
+		if ev.Reason != events.TxRemovedUnderpriced {
+			t.Errorf("removal reason mismatch: have %v, want %v", ev.Reason, events.TxRemovedUnderpriced)
+		}
+		if len(ev.Txs) != 1 || ev.Txs[0].Hash() != cheap.Hash() {
+			t.Errorf("removed transactions mismatch: have %v, want %x", ev.Txs, cheap.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("removal event not fired")
+	}
+	if pool.Get(cheap.Hash()) != nil {
+		t.Errorf("underpriced transaction still in the pool")
+	}
+}
+
+// Tests that transactions whose nonce got used by the chain are announced to
+// RemovedTxsEvent subscribers with the mined reason.
+func TestRemovedTxsEventOnMined(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	removedCh := make(chan events.RemovedTxsEvent, 1)
+	sub := pool.SubscribeRemovedTxsEvent(removedCh)
+	defer sub.Unsubscribe()
+
+	nonce := pool.Nonce(from)
+	mined := transaction(nonce, 100000, key)
+	if err := pool.addRemoteSync(mined); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	pool.mu.Lock()
+	// Let the chain expect nonce+1, the state reports the stored nonce plus one
+	pool.currentState.SetNonce(from, nonce)
+	pool.mu.Unlock()
+	<-pool.requestReset(nil, nil)
+
+	select {
+	case ev := <-removedCh:
+		if ev.Reason != events.TxRemovedMined {
+			t.Errorf("removal reason mismatch: have %v, want %v", ev.Reason, events.TxRemovedMined)
+		}
+		if len(ev.Txs) != 1 || ev.Txs[0].Hash() != mined.Hash() {
+			t.Errorf("removed transactions mismatch: have %v, want %x", ev.Txs, mined.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("removal event not fired")
+	}
+}
+
+// Tests that transactions of blocks dropped by a chain reorg are re-injected
+// into the pool, unless the new chain included them as well.
+func TestReinjectOnChainReorg(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	txsCh := make(chan events.NewTxsEvent, 1)
+	sub := pool.SubscribeNewTxsEvent(txsCh)
+	defer sub.Unsubscribe()
+
+	nonce := pool.Nonce(from)
+	kept := transaction(nonce, 100000, key)
+	readded := transaction(nonce+1, 100000, key)
+
+	header := &types.Header{Height: 1, GasLimit: 1000000, Time: big.NewInt(1)}
+	ancestor := types.NewBlockWithHeader(&types.Header{GasLimit: 1000000, Time: big.NewInt(0)})
+	dropped := types.NewBlock(header, types.Transactions{kept, readded}, &types.Commit{})
+	added := types.NewBlock(header, types.Transactions{readded}, &types.Commit{})
+
+	pool.chain.(*testBlockChain).chainReorgFeed.Send(events.ChainReorgEvent{
+		CommonAncestor: ancestor,
+		Dropped:        []*types.Block{dropped},
+		Added:          []*types.Block{added},
+	})
+
+	select {
+	case ev := <-txsCh:
+		if len(ev.Txs) != 1 || ev.Txs[0].Hash() != kept.Hash() {
+			t.Errorf("re-injected transactions mismatch: have %v, want %x", ev.Txs, kept.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("re-injected transactions not announced")
+	}
+	if pool.Get(kept.Hash()) == nil {
+		t.Errorf("dropped transaction not re-injected")
+	}
+	if pool.Get(readded.Hash()) != nil {
+		t.Errorf("transaction included by the new chain re-injected")
+	}
+}
+
+// Tests that pending transactions added longer than the configured lifetime ago
+// are evicted, even if their account is active, while local ones are kept.
+func TestPendingLifetimeEviction(t *testing.T) {
+	config := testTxPoolConfig
+	config.Lifetime = 400 * time.Millisecond
+
+	pool, remote := setupTxPoolWithConfig(config, 1000000)
+	defer pool.Stop()
+	local, _ := crypto.GenerateKey()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+
+	removedCh := make(chan events.RemovedTxsEvent, 1)
+	sub := pool.SubscribeRemovedTxsEvent(removedCh)
+	defer sub.Unsubscribe()
+
+	// Neither transaction ever gets mined, so both stay pending
+	stuck := transaction(pool.Nonce(crypto.PubkeyToAddress(remote.PublicKey)), 100000, remote)
+	if err := pool.addRemoteSync(stuck); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	kept := transaction(pool.Nonce(crypto.PubkeyToAddress(local.PublicKey)), 100000, local)
+	if err := pool.AddLocal(kept); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	// A later transaction of the same account doesn't save the first one
+	time.Sleep(config.Lifetime * 5 / 8)
+	follow := transaction(stuck.Nonce()+1, 100000, remote)
+	if err := pool.addRemoteSync(follow); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 3 {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", pending, 3)
+	}
+
+	select {
+	case ev := <-removedCh:
+		if ev.Reason != events.TxRemovedStale {
+			t.Errorf("removal reason mismatch: have %v, want %v", ev.Reason, events.TxRemovedStale)
+		}
+		if len(ev.Txs) != 1 || ev.Txs[0].Hash() != stuck.Hash() {
+			t.Errorf("removed transactions mismatch: have %v, want %x", ev.Txs, stuck.Hash())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("stuck transaction not evicted")
+	}
+	if pool.Get(stuck.Hash()) != nil {
+		t.Errorf("expired transaction still in the pool")
+	}
+	if pool.Get(kept.Hash()) == nil {
+		t.Errorf("local transaction evicted")
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Errorf("pool size mismatch: have %d pending and %d queued, want 1 and 1 with the later transaction demoted", pending, queued)
+	}
+}
+
+// Tests that remote transactions priced below the pool's gas price are rejected
+// as underpriced, while local ones at the same price are accepted.
+func TestUnderpricedRejection(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	remote := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, remote, big.NewInt(1000000000))
+	localKey, _ := crypto.GenerateKey()
+	local := crypto.PubkeyToAddress(localKey.PublicKey)
+	testAddBalance(pool, local, big.NewInt(1000000000))
+
+	pool.SetGasPrice(big.NewInt(10))
+
+	if err := pool.AddRemote(pricedTransaction(pool.Nonce(remote), 100000, big.NewInt(9), key)); err != ErrUnderpriced {
+		t.Fatalf("adding underpriced remote transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	if err := pool.addRemoteSync(pricedTransaction(pool.Nonce(remote), 100000, big.NewInt(10), key)); err != nil {
+		t.Fatalf("failed to add remote transaction priced at the threshold: %v", err)
+	}
+	if err := pool.AddLocal(pricedTransaction(pool.Nonce(local), 100000, big.NewInt(9), localKey)); err != nil {
+		t.Fatalf("failed to add underpriced local transaction: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", pending, 2)
+	}
+}
+
+// Tests that raising the pool's gas price evicts cheaper remote transactions of
+// every account, keeps local ones and rejects new remote ones below the floor.
+func TestGasPriceFloorRaise(t *testing.T) {
+	t.Parallel()
+
+	pool, _ := setupTxPool()
+	defer pool.Stop()
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		testAddBalance(pool, crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	var (
+		cheap  = pricedTransaction(pool.Nonce(crypto.PubkeyToAddress(keys[0].PublicKey)), 100000, big.NewInt(2), keys[0])
+		pricey = pricedTransaction(pool.Nonce(crypto.PubkeyToAddress(keys[1].PublicKey)), 100000, big.NewInt(20), keys[1])
+		local  = pricedTransaction(pool.Nonce(crypto.PubkeyToAddress(keys[2].PublicKey)), 100000, big.NewInt(2), keys[2])
+	)
+	if errs := pool.AddRemotesSync([]*types.Transaction{cheap, pricey}); errs[0] != nil || errs[1] != nil {
+		t.Fatalf("failed to add remote transactions: %v", errs)
+	}
+	if err := pool.AddLocal(local); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	pool.SetGasPrice(big.NewInt(10))
+
+	if pool.Get(cheap.Hash()) != nil {
+		t.Errorf("cheap remote transaction still in the pool")
+	}
+	if pool.Get(pricey.Hash()) == nil || pool.Get(local.Hash()) == nil {
+		t.Errorf("transaction above the floor or local one evicted")
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 0 {
+		t.Errorf("pool stats mismatch: have %d/%d, want 2/0", pending, queued)
+	}
+	if err := pool.AddRemote(pricedTransaction(cheap.Nonce(), 100000, big.NewInt(9), keys[0])); err != ErrUnderpriced {
+		t.Errorf("adding remote transaction below the floor error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+}
+
+// movingBlockChain is a test chain whose head can be moved concurrently. The state
+// at every height credits probe with a balance equal to the height.
+type movingBlockChain struct {
+	*testBlockChain
+	lock   sync.RWMutex
+	head   *types.Header
+	states map[uint64]*state.StateDB
+}
+
+func newMovingBlockChain(probe common.Address, heights uint64) *movingBlockChain {
+	chain := &movingBlockChain{
+		testBlockChain: &testBlockChain{nil, 1000000, new(event.Feed), new(event.Feed)},
+		states:         make(map[uint64]*state.StateDB),
+	}
+	for height := uint64(0); height < heights; height++ {
+		statedb, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+		statedb.AddBalance(probe, new(big.Int).SetUint64(height))
+		chain.states[height] = statedb
+	}
+	chain.setHead(0)
+	return chain
+}
+
+func (bc *movingBlockChain) setHead(height uint64) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.head = &types.Header{Height: height, GasLimit: 1000000 + height, Time: big.NewInt(0)}
+}
+
+func (bc *movingBlockChain) CurrentBlock() *types.Block {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return types.NewBlockWithHeader(bc.head)
+}
+
+func (bc *movingBlockChain) StateAt(height uint64) (*state.StateDB, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.states[height].Copy(), nil
+}
+
+// Tests that the pool state, nonces and gas cap are always derived from the same
+// head, even if the chain head keeps moving while the pool resets.
+func TestResetConsistentHead(t *testing.T) {
+	t.Parallel()
+
+	const heights = 16
+	probe := common.HexToAddress("0x01")
+	chain := newMovingBlockChain(probe, heights)
+	pool := NewTxPool(testTxPoolConfig, nil, chain)
+	defer pool.Stop()
+
+	var (
+		quit = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for height := uint64(0); ; height = (height + 1) % heights {
+			select {
+			case <-quit:
+				return
+			default:
+				chain.setHead(height)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-quit:
+				return
+			default:
+				pool.State()
+				pool.ProposeTransactions()
+			}
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		<-pool.requestReset(nil, nil)
+
+		pool.mu.Lock()
+		head, maxGas := pool.currentHead, pool.currentMaxGas
+		balance := pool.currentState.GetBalance(probe)
+		pool.mu.Unlock()
+
+		if maxGas != head.GasLimit {
+			t.Fatalf("reset %d: gas cap mismatch: have %d, want %d of head %d", i, maxGas, head.GasLimit, head.Height)
+		}
+		if balance.Uint64() != head.Height {
+			t.Fatalf("reset %d: state mismatch: have state of height %d, want %d", i, balance.Uint64(), head.Height)
+		}
+	}
+	close(quit)
+	wg.Wait()
+}
+
+// Tests that transactions requesting more than the configured share of the block
+// gas limit are rejected, even though they would fit into a block.
+func TestMaxTxGasFraction(t *testing.T) {
+	t.Parallel()
+
+	config := testTxPoolConfig
+	config.MaxTxGasFraction = 0.5
+
+	pool, key := setupTxPoolWithConfig(config, 1000000)
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	nonce := pool.Nonce(from)
+	if err := pool.AddRemote(transaction(nonce, 500001, key)); err != ErrGasLimit {
+		t.Fatalf("adding transaction over the per-tx gas cap error mismatch: have %v, want %v", err, ErrGasLimit)
+	}
+	if err := pool.AddLocal(transaction(nonce, 500001, key)); err != ErrGasLimit {
+		t.Fatalf("adding local transaction over the per-tx gas cap error mismatch: have %v, want %v", err, ErrGasLimit)
+	}
+	if err := pool.addRemoteSync(transaction(nonce, 500000, key)); err != nil {
+		t.Fatalf("failed to add transaction at the per-tx gas cap: %v", err)
+	}
+}
+
+// Tests that transactions with a negative value or sent to the zero address are
+// rejected before entering the pool, while contract creations are accepted.
+func TestInvalidValueAndRecipient(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	nonce := pool.Nonce(from)
+	negative, _ := types.SignTx(types.HomesteadSigner{}, types.NewTransaction(nonce, testRecipient, big.NewInt(-1), 100000, big.NewInt(1), nil), key)
+	if err := pool.AddLocal(negative); err != ErrNegativeValue {
+		t.Fatalf("adding negative value transaction error mismatch: have %v, want %v", err, ErrNegativeValue)
+	}
+	zero, _ := types.SignTx(types.HomesteadSigner{}, types.NewTransaction(nonce, common.Address{}, big.NewInt(100), 100000, big.NewInt(1), nil), key)
+	if err := pool.AddRemote(zero); err != ErrInvalidRecipient {
+		t.Fatalf("adding zero address transaction error mismatch: have %v, want %v", err, ErrInvalidRecipient)
+	}
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("pool size mismatch: have %d pending and %d queued, want none", pending, queued)
+	}
+	create, _ := types.SignTx(types.HomesteadSigner{}, types.NewContractCreation(nonce, big.NewInt(0), 100000, big.NewInt(1), nil), key)
+	if err := pool.addRemoteSync(create); err != nil {
+		t.Fatalf("failed to add contract creation: %v", err)
+	}
+}
+
+// Tests that remote contract creations and calls are held to their own price
+// floors, so deployments can be priced higher than transfers.
+func TestCreateAndCallPriceLimits(t *testing.T) {
+	t.Parallel()
+
+	config := testTxPoolConfig
+	config.CreatePriceLimit = 10
+	config.CallPriceLimit = 2
+
+	pool, key := setupTxPoolWithConfig(config, 1000000)
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	nonce := pool.Nonce(from)
+	deploy := func(nonce uint64, price int64) *types.Transaction {
+		tx, _ := types.SignTx(types.HomesteadSigner{}, types.NewContractCreation(nonce, big.NewInt(0), 100000, big.NewInt(price), nil), key)
+		return tx
+	}
+	if err := pool.AddRemote(deploy(nonce, 5)); err != ErrUnderpriced {
+		t.Fatalf("adding deployment below the creation floor error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(nonce, 100000, big.NewInt(1), key)); err != ErrUnderpriced {
+		t.Fatalf("adding transfer below the call floor error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	if err := pool.addRemoteSync(pricedTransaction(nonce, 100000, big.NewInt(5), key)); err != nil {
+		t.Fatalf("failed to add transfer priced as the rejected deployment: %v", err)
+	}
+	if err := pool.AddLocal(deploy(nonce+1, 5)); err != nil {
+		t.Fatalf("local deployment rejected by the creation floor: %v", err)
+	}
+}
+
+// Tests that nonce-gapped transactions are queued and visible, then promoted to
+// pending once the gap is filled, either by a new transaction or by the chain.
+func TestQueuedPromotion(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	nonce := pool.Nonce(from)
+	gapped := transaction(nonce+1, 100000, key)
+	if err := pool.addRemoteSync(gapped); err != nil {
+		t.Fatalf("failed to add gapped transaction: %v", err)
+	}
+	if pool.QueuedSize() != 1 || pool.PendingSize() != 0 {
+		t.Fatalf("pool size mismatch: have %d queued and %d pending, want 1 and 0", pool.QueuedSize(), pool.PendingSize())
+	}
+	if _, queued := pool.Content(); len(queued[from]) != 1 || queued[from][0].Hash() != gapped.Hash() {
+		t.Fatalf("queued content mismatch: have %v, want %x", queued[from], gapped.Hash())
+	}
+	if queued := pool.Queued(); len(queued[from]) != 1 || queued[from][0].Hash() != gapped.Hash() {
+		t.Fatalf("queued transactions mismatch: have %v, want %x", queued[from], gapped.Hash())
+	}
+	// Filling the gap through the pool promotes the queued transaction
+	if err := pool.addRemoteSync(transaction(nonce, 100000, key)); err != nil {
+		t.Fatalf("failed to add filler transaction: %v", err)
+	}
+	if pool.QueuedSize() != 0 || pool.PendingSize() != 2 {
+		t.Fatalf("pool size mismatch: have %d queued and %d pending, want 0 and 2", pool.QueuedSize(), pool.PendingSize())
+	}
+
+	// Filling the gap through the chain promotes on reset
+	gapped = transaction(nonce+3, 100000, key)
+	if err := pool.addRemoteSync(gapped); err != nil {
+		t.Fatalf("failed to add gapped transaction: %v", err)
+	}
+	if pool.QueuedSize() != 1 {
+		t.Fatalf("queued size mismatch: have %d, want 1", pool.QueuedSize())
+	}
+	pool.mu.Lock()
+	// Let the chain expect nonce+3, the state reports the stored nonce plus one
+	pool.currentState.SetNonce(from, nonce+2)
+	pool.mu.Unlock()
+	<-pool.requestReset(nil, nil)
+
+	if pool.QueuedSize() != 0 || pool.PendingSize() != 1 {
+		t.Fatalf("pool size mismatch: have %d queued and %d pending, want 0 and 1", pool.QueuedSize(), pool.PendingSize())
+	}
+	if pending, _ := pool.Content(); len(pending[from]) != 1 || pending[from][0].Hash() != gapped.Hash() {
+		t.Fatalf("pending content mismatch: have %v, want %x", pending[from], gapped.Hash())
+	}
+}
+
+// Tests that the pool content is grouped by sender, sorted by nonce and copied,
+// so callers can't corrupt the pool by modifying it.
+func TestContentGroupedBySender(t *testing.T) {
+	pool, key := setupTxPool()
+	defer pool.Stop()
+	other, _ := crypto.GenerateKey()
+
+	from, to := crypto.PubkeyToAddress(key.PublicKey), crypto.PubkeyToAddress(other.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+	testAddBalance(pool, to, big.NewInt(1000000000))
+
+	// Add out of order, with a gap for the second account
+	base := pool.Nonce(from)
+	for _, offset := range []uint64{2, 0, 1} {
+		if err := pool.addRemoteSync(transaction(base+offset, 100000, key)); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", base+offset, err)
+		}
+	}
+	if err := pool.addRemoteSync(transaction(pool.Nonce(to)+1, 100000, other)); err != nil {
+		t.Fatalf("failed to add gapped transaction: %v", err)
+	}
+
+	pending, queued := pool.Content()
+	if len(pending) != 1 || len(pending[from]) != 3 {
+		t.Fatalf("pending content mismatch: have %v", pending)
+	}
+	for i, tx := range pending[from] {
+		if tx.Nonce() != base+uint64(i) {
+			t.Errorf("pending transaction %d: nonce mismatch: have %d, want %d", i, tx.Nonce(), base+uint64(i))
+		}
+	}
+	if len(queued) != 1 || len(queued[to]) != 1 {
+		t.Fatalf("queued content mismatch: have %v", queued)
+	}
+
+	// Mutating the returned content must not leak into the pool
+	pending[from][0] = queued[to][0]
+	delete(pending, from)
+	if again, _ := pool.Content(); len(again[from]) != 3 || again[from][0].Nonce() != base {
+		t.Errorf("pool content modified by caller: have %v", again[from])
+	}
+}
+
+// Tests that local transactions are journaled to disk and reloaded by a restarted
+// pool, and that the journal is compacted to the locals still in the pool.
+func TestLocalTransactionJournaling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txpool-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := testTxPoolConfig
+	config.Journal = filepath.Join(dir, "transactions.rlp")
+
+	statedb, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed), new(event.Feed)}
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(local.PublicKey)
+	statedb.AddBalance(from, big.NewInt(1000000000))
+	statedb.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	pool := NewTxPool(config, nil, blockchain)
+	base := pool.Nonce(from)
+	for i := uint64(0); i < 2; i++ {
+		if err := pool.AddLocal(transaction(base+i, 100000, local)); err != nil {
+			t.Fatalf("failed to add local transaction %d: %v", i, err)
+		}
+	}
+	if err := pool.addRemoteSync(transaction(pool.Nonce(crypto.PubkeyToAddress(remote.PublicKey)), 100000, remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	pool.Stop()
+
+	// Mine the first local transaction while the node is down, then restart. The
+	// state reports the stored nonce plus one as the next nonce.
+	statedb.SetNonce(from, base)
+	pool = NewTxPool(config, nil, blockchain)
+	pending, queued := pool.Stats()
+	if pending != 1 || queued != 0 {
+		t.Fatalf("reloaded pool size mismatch: have %d pending and %d queued, want 1 and 0", pending, queued)
+	}
+	if txs, _ := pool.Pending(); len(txs[from]) != 1 || txs[from][0].Nonce() != base+1 {
+		t.Fatalf("reloaded local transactions mismatch: have %v", txs[from])
+	}
+	pool.Stop()
+
+	// The journal only keeps the still pending local transaction
+	journaled := 0
+	if err := newTxJournal(config.Journal).load(func(txs []*types.Transaction) []error {
+		journaled += len(txs)
+		return make([]error, len(txs))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if journaled != 1 {
+		t.Errorf("journaled transactions mismatch: have %d, want 1", journaled)
+	}
+}
+
+// Tests that a dynamic fee transaction only replaces another one if both its
+// fee cap and its tip cap meet the required price bump.
+func TestDynamicFeeTransactionReplacement(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed), new(event.Feed)}
+	pool := NewTxPool(testTxPoolConfig, &types.ChainConfig{ChainID: big.NewInt(24), LondonBlock: big.NewInt(1)}, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	dynamicTx := func(nonce uint64, tip, feeCap int64) *types.Transaction {
+		tx, _ := types.SignTx(types.HomesteadSigner{}, types.NewDynamicFeeTransaction(big.NewInt(24), nonce, testRecipient, big.NewInt(100), 100000, big.NewInt(tip), big.NewInt(feeCap), nil), key)
+		return tx
+	}
+	bump := func(price int64) int64 { return price * (100 + int64(testTxPoolConfig.PriceBump)) / 100 }
+
+	nonce := pool.Nonce(from)
+	if err := pool.addRemoteSync(dynamicTx(nonce, 100, 1000)); err != nil {
+		t.Fatalf("failed to add original pending transaction: %v", err)
+	}
+	if err := pool.AddRemote(dynamicTx(nonce, 100, bump(1000))); err != ErrReplaceUnderpriced {
+		t.Fatalf("original pending transaction replaced without tip bump: %v", err)
+	}
+	if err := pool.AddRemote(dynamicTx(nonce, bump(100), 1000)); err != ErrReplaceUnderpriced {
+		t.Fatalf("original pending transaction replaced without fee cap bump: %v", err)
+	}
+	replacement := dynamicTx(nonce, bump(100), bump(1000))
+	if err := pool.addRemoteSync(replacement); err != nil {
+		t.Fatalf("failed to replace original pending transaction: %v", err)
+	}
+	if pool.Get(replacement.Hash()) == nil {
+		t.Fatalf("replacement transaction missing from the pool")
+	}
+	// Admission is priced by the tip, as no base fee is charged on top of it.
+	pool.SetGasPrice(big.NewInt(50))
+	if err := pool.AddRemote(dynamicTx(nonce+1, 49, 1000)); err != ErrUnderpriced {
+		t.Fatalf("transaction tipping below the pool price accepted: %v", err)
+	}
+}
+
+// baseFeeBlockChain is a test chain charging a constant base fee.
+type baseFeeBlockChain struct {
+	*testBlockChain
+	baseFee *big.Int
+}
+
+func (bc *baseFeeBlockChain) CalcBaseFee(parent *types.Block) *big.Int {
+	return bc.baseFee
+}
+
+// Tests that remote transactions must pay the base fee of the next block, while
+// local ones are left to block processing.
+func TestBaseFeeAdmission(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(log.New(), common.Hash{}, state.NewDatabase(memorydb.New()))
+	blockchain := &baseFeeBlockChain{&testBlockChain{statedb, 1000000, new(event.Feed), new(event.Feed)}, big.NewInt(10)}
+	pool := NewTxPool(testTxPoolConfig, nil, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	if err := pool.AddRemote(pricedTransaction(pool.Nonce(from), 100000, big.NewInt(9), key)); err != ErrFeeCapTooLow {
+		t.Fatalf("remote transaction below the base fee error mismatch: have %v, want %v", err, ErrFeeCapTooLow)
+	}
+	if err := pool.addRemoteSync(pricedTransaction(pool.Nonce(from), 100000, big.NewInt(10), key)); err != nil {
+		t.Fatalf("failed to add remote transaction paying the base fee: %v", err)
+	}
+	if err := pool.AddLocal(pricedTransaction(pool.Nonce(from), 100000, big.NewInt(0), key)); err != nil {
+		t.Fatalf("failed to add local transaction below the base fee: %v", err)
+	}
+}
+
+// Tests that dynamic fee transactions are rejected before the London fork and
+// when signed for another chain.
+func TestDynamicFeeTransactionAdmission(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	dynamicTx := func(chainID int64) *types.Transaction {
+		tx, _ := types.SignTx(types.HomesteadSigner{}, types.NewDynamicFeeTransaction(big.NewInt(chainID), pool.Nonce(from), testRecipient, big.NewInt(100), 100000, big.NewInt(1), big.NewInt(1), nil), key)
+		return tx
+	}
+	if err := pool.AddRemote(dynamicTx(0)); err != types.ErrTxTypeNotSupported {
+		t.Fatalf("dynamic fee transaction accepted before London: %v", err)
+	}
+	pool.mu.Lock()
+	pool.chainconfig = &types.ChainConfig{ChainID: big.NewInt(24), LondonBlock: big.NewInt(0)}
+	pool.mu.Unlock()
+	if err := pool.AddRemote(dynamicTx(25)); err != types.ErrInvalidChainId {
+		t.Fatalf("dynamic fee transaction of another chain accepted: %v", err)
+	}
+	if err := pool.addRemoteSync(dynamicTx(24)); err != nil {
+		t.Fatalf("failed to add dynamic fee transaction after London: %v", err)
+	}
+}
+
+// Tests that the inclusion filter keeps transactions of non-allowlisted senders
+// out of proposals while leaving them in the pool.
+func TestProposeTransactionsAllowlist(t *testing.T) {
+	t.Parallel()
+
+	allowed, _ := crypto.GenerateKey()
+	denied, _ := crypto.GenerateKey()
+	allowedAddr := crypto.PubkeyToAddress(allowed.PublicKey)
+	deniedAddr := crypto.PubkeyToAddress(denied.PublicKey)
+
+	config := testTxPoolConfig
+	config.ProposerAllowlist = []common.Address{allowedAddr}
+	pool, _ := setupTxPoolWithConfig(config, 1000000)
+	defer pool.Stop()
+
+	testAddBalance(pool, allowedAddr, big.NewInt(1000000000))
+	testAddBalance(pool, deniedAddr, big.NewInt(1000000000))
+	txs := []*types.Transaction{
+		transaction(pool.Nonce(allowedAddr), 100000, allowed),
+		transaction(pool.Nonce(allowedAddr)+1, 100000, allowed),
+		transaction(pool.Nonce(deniedAddr), 100000, denied),
+	}
+	for i, err := range pool.AddRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+
+	proposed := pool.ProposeTransactions()
+	if len(proposed) != 2 {
+		t.Fatalf("proposed transactions mismatch: have %d, want %d", len(proposed), 2)
+	}
+	for _, tx := range proposed {
+		if from, _ := types.Sender(pool.signer, tx); from != allowedAddr {
+			t.Errorf("proposed transaction of non-allowlisted sender %x", from)
+		}
+	}
+	if pending, _ := pool.Stats(); pending != len(txs) {
+		t.Errorf("pending transactions mismatch: have %d, want %d", pending, len(txs))
+	}
+
+	// Removing the filter proposes everything.
+	pool.SetInclusionFilter(nil)
+	if proposed := pool.ProposeTransactions(); len(proposed) != len(txs) {
+		t.Errorf("unfiltered proposed transactions mismatch: have %d, want %d", len(proposed), len(txs))
+	}
+}
@@ -60,6 +60,7 @@ var DefaultConfig = NodeConfig{
 		ChainId:      MainChainID,
 		NetworkId:    DefaultNetworkID,
 		AcceptTxs:    1, // 1 is to allow new transactions, 0 is not
+		Archive:      true,
 	},
 	DualChainConfig: DualChainConfig{
 		DBInfo: storage.NewLevelDbInfo(DualChainDataDir, DefaultDbCache, DefaultDbHandles),
@@ -72,6 +72,7 @@ func (n *Node) Start() error {
 		return ErrNodeRunning
 	}
 	n.log.Info("Starting peer-to-peer node", "instance", n.serverConfig.Name)
+	n.log.Info("Node configuration\n" + n.config.Summary())
 
 	// RPC Endpoint
 	n.httpEndpoint = n.config.HTTPEndpoint()
@@ -206,7 +207,19 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts)
+	if err := rpc.CheckModules(apis, modules); err != nil {
+		if n.config.HTTPStrictModules {
+			return err
+		}
+		n.log.Warn("Ignoring unavailable HTTP modules", "err", err)
+	}
+	limits := rpc.HTTPLimits{
+		MaxRequestContentLength: n.config.HTTPMaxRequestSize,
+		RateLimit:               n.config.HTTPRateLimit,
+		RateBurst:               n.config.HTTPRateBurst,
+		MaxBatchLength:          n.config.HTTPMaxBatchLength,
+	}
+	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, limits)
 	if err != nil {
 		return err
 	}
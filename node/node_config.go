@@ -21,16 +21,20 @@ package node
 import (
 	"crypto/ecdsa"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/kardiachain/go-kardia/mainchain/permissioned"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/kardiachain/go-kardia/configs"
 	"github.com/kardiachain/go-kardia/dualchain/event_pool"
 	"github.com/kardiachain/go-kardia/kai/base"
+	"github.com/kardiachain/go-kardia/kai/downloader"
 	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
 	"github.com/kardiachain/go-kardia/kai/storage"
 	"github.com/kardiachain/go-kardia/kai/storage/kvstore"
@@ -38,6 +42,7 @@ import (
 	"github.com/kardiachain/go-kardia/lib/crypto"
 	"github.com/kardiachain/go-kardia/lib/log"
 	"github.com/kardiachain/go-kardia/lib/p2p"
+	"github.com/kardiachain/go-kardia/mainchain/gasprice"
 	"github.com/kardiachain/go-kardia/mainchain/genesis"
 	"github.com/kardiachain/go-kardia/mainchain/tx_pool"
 	"github.com/kardiachain/go-kardia/types"
@@ -48,6 +53,16 @@ const (
 	datadirDefaultKeyStore = "keystore" // Path within the datadir to the keystore
 )
 
+var (
+	ErrZeroChainId   = errors.New("chain id must not be 0")
+	ErrZeroNetworkId = errors.New("network id must not be 0")
+)
+
+// knownNetworkIds maps well-known chain ids to the network id used by their peers.
+var knownNetworkIds = map[uint64]uint64{
+	MainChainID: DefaultNetworkID,
+}
+
 type MainChainConfig struct {
 	// Mainchain
 	// Index of validators
@@ -58,10 +73,28 @@ type MainChainConfig struct {
 	Genesis *genesis.Genesis
 	// Transaction pool options
 	TxPool tx_pool.TxPoolConfig
+	// Gas price oracle options, gasprice.DefaultConfig is used if nil
+	GasPrice *gasprice.Config
+	// Consensus timeouts, DefaultConsensusConfig is used if nil
+	Consensus *configs.ConsensusConfig
 	// AcceptTxs accept tx sync process or not (1 is yes and 0 is no)
 	AcceptTxs uint32
 	// IsZeroFee is true then sender will be refunded all gas spent for a transaction
 	IsZeroFee bool
+	// MaxReorgDepth is the maximum number of blocks the chain may be rewound, 0 keeps the default
+	MaxReorgDepth uint64
+	// Archive retains the state of every block, otherwise state older than StateRetention blocks is pruned
+	Archive bool
+	// StateRetention is the number of recent blocks whose state is retained when not in archive mode, 0 keeps the default
+	StateRetention uint64
+	// TxExecutionWorkers is the number of workers applying the independent transactions of a block, below 2 is sequential
+	TxExecutionWorkers int
+	// StateSnapshotLimit is the number of recently opened states cached, 0 keeps the default and negative disables the cache
+	StateSnapshotLimit int
+	// SyncMode is how the chain is downloaded from peers (full or headers-first)
+	SyncMode downloader.SyncMode
+	// Checkpoint is a trusted block to start syncing from instead of genesis, nil disables it
+	Checkpoint *downloader.Checkpoint
 	// IsPrivate is true then peerId will be checked through smc to make sure that it has permission to access the chain
 	IsPrivate bool
 	NetworkId uint64
@@ -83,6 +116,8 @@ type DualChainConfig struct {
 	DualGenesis *genesis.Genesis
 	// Dual's event pool options
 	DualEventPool event_pool.Config
+	// Consensus timeouts, DefaultConsensusConfig is used if nil
+	Consensus *configs.ConsensusConfig
 	// IsPrivate is true then peerId will be checked through smc to make sure that it has permission to access the chain
 	IsPrivate bool
 	// Dual protocol name, this name is used if the node is setup as dual node
@@ -134,6 +169,20 @@ type NodeConfig struct {
 	// If the module list is empty, all RPC API endpoints designated public will be
 	// exposed.
 	HTTPModules []string `toml:",omitempty"`
+	// HTTPStrictModules makes the node refuse to start if HTTPModules lists a module
+	// that no service provides. Otherwise such modules are only logged.
+	HTTPStrictModules bool `toml:",omitempty"`
+	// HTTPMaxRequestSize is the maximum size in bytes of an HTTP RPC request body.
+	// Zero keeps the default of 128KB.
+	HTTPMaxRequestSize int64 `toml:",omitempty"`
+	// HTTPRateLimit is the number of HTTP RPC requests per second accepted from a
+	// single IP, bursts of up to HTTPRateBurst requests are allowed. Zero disables
+	// rate limiting.
+	HTTPRateLimit float64 `toml:",omitempty"`
+	HTTPRateBurst int     `toml:",omitempty"`
+	// HTTPMaxBatchLength is the maximum number of requests in an HTTP RPC batch.
+	// Zero keeps the default of 100.
+	HTTPMaxBatchLength int `toml:",omitempty"`
 	// KeyStoreDir is the file system folder that contains private keys. The directory can
 	// be specified as a relative path, in which case it is resolved relative to the
 	// current directory.
@@ -163,6 +212,113 @@ type NodeMetadata struct {
 	ListenAddr  string
 }
 
+// ValidateIds returns an error if the main chain, or the dual chain when it is configured, has no chain id
+// or no network id. Peers with different ids cannot connect to each other.
+func (c *NodeConfig) ValidateIds() error {
+	if err := validateIds(c.MainChainConfig.ChainId, c.MainChainConfig.NetworkId); err != nil {
+		return fmt.Errorf("main chain: %v", err)
+	}
+	dual := c.DualChainConfig
+	if dual.DualProtocolName == "" {
+		return nil
+	}
+	if err := validateIds(dual.ChainId, dual.DualNetworkID); err != nil {
+		return fmt.Errorf("dual chain: %v", err)
+	}
+	return nil
+}
+
+func validateIds(chainId, networkId uint64) error {
+	if chainId == 0 {
+		return ErrZeroChainId
+	}
+	if networkId == 0 {
+		return ErrZeroNetworkId
+	}
+	return nil
+}
+
+// CheckKnownIds returns an error if chainId or networkId is a well-known id but the other one does not match it.
+// The combination is still valid, callers should only warn about it.
+func CheckKnownIds(chainId, networkId uint64) error {
+	for knownChainId, knownNetworkId := range knownNetworkIds {
+		if (chainId == knownChainId) != (networkId == knownNetworkId) {
+			return fmt.Errorf("chain id %v and network id %v do not match the well-known chain id %v with network id %v",
+				chainId, networkId, knownChainId, knownNetworkId)
+		}
+	}
+	return nil
+}
+
+// redacted replaces secrets in the summary.
+const redacted = "<redacted>"
+
+// Summary returns the effective configuration of the node, one setting per line. Private keys and
+// database credentials are redacted so that it can be logged.
+func (c *NodeConfig) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %v\n", c.NodeName())
+	fmt.Fprintf(&b, "dataDir: %v\n", c.DataDir)
+	fmt.Fprintf(&b, "listenAddr: %v\n", c.P2P.ListenAddr)
+	fmt.Fprintf(&b, "http: %v modules=%v\n", c.HTTPEndpoint(), c.HTTPModules)
+	if c.NodeMetadata != nil {
+		fmt.Fprintf(&b, "nodeKey: %v\n", redacted)
+		fmt.Fprintf(&b, "votingPower: %v\n", c.NodeMetadata.VotingPower)
+	}
+	writeBaseAccount(&b, "baseAccount", c.BaseAccount)
+
+	main := c.MainChainConfig
+	fmt.Fprintf(&b, "mainChain.chainId: %v\n", main.ChainId)
+	fmt.Fprintf(&b, "mainChain.networkId: %v\n", main.NetworkId)
+	fmt.Fprintf(&b, "mainChain.db: %v\n", dbSummary(main.DBInfo))
+	fmt.Fprintf(&b, "mainChain.validators: %v\n", len(main.ValidatorIndexes))
+	fmt.Fprintf(&b, "mainChain.zeroFee: %v\n", main.IsZeroFee)
+	fmt.Fprintf(&b, "mainChain.private: %v\n", main.IsPrivate)
+	fmt.Fprintf(&b, "mainChain.acceptTxs: %v\n", main.AcceptTxs == 1)
+	fmt.Fprintf(&b, "mainChain.syncMode: %v\n", main.SyncMode)
+	fmt.Fprintf(&b, "mainChain.archive: %v stateRetention=%v\n", main.Archive, main.StateRetention)
+	fmt.Fprintf(&b, "mainChain.txExecutionWorkers: %v\n", main.TxExecutionWorkers)
+	fmt.Fprintf(&b, "mainChain.stateSnapshotLimit: %v\n", main.StateSnapshotLimit)
+	writeBaseAccount(&b, "mainChain.baseAccount", main.BaseAccount)
+
+	dual := c.DualChainConfig
+	if dual.DualProtocolName != "" || dual.ChainId != 0 {
+		fmt.Fprintf(&b, "dualChain.protocol: %v\n", dual.DualProtocolName)
+		fmt.Fprintf(&b, "dualChain.chainId: %v\n", dual.ChainId)
+		fmt.Fprintf(&b, "dualChain.networkId: %v\n", dual.DualNetworkID)
+		fmt.Fprintf(&b, "dualChain.db: %v\n", dbSummary(dual.DBInfo))
+		fmt.Fprintf(&b, "dualChain.validators: %v\n", len(dual.ValidatorIndexes))
+		fmt.Fprintf(&b, "dualChain.private: %v\n", dual.IsPrivate)
+		writeBaseAccount(&b, "dualChain.baseAccount", dual.BaseAccount)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeBaseAccount writes the address of account, never its private key.
+func writeBaseAccount(b *strings.Builder, name string, account *types.BaseAccount) {
+	if account == nil {
+		return
+	}
+	fmt.Fprintf(b, "%v: %v privateKey=%v\n", name, account.Address.Hex(), redacted)
+}
+
+// dbSummary describes a database backend without its credentials.
+func dbSummary(info storage.DbInfo) string {
+	switch db := info.(type) {
+	case nil:
+		return "none"
+	case *storage.LevelDbInfo:
+		return fmt.Sprintf("%v path=%v", db.Name(), db.ChainData)
+	case *storage.MongoDbInfo:
+		uri := redacted
+		if u, err := url.Parse(db.URI); err == nil {
+			uri = u.Redacted()
+		}
+		return fmt.Sprintf("%v uri=%v database=%v", db.Name(), uri, db.DatabaseName)
+	}
+	return info.Name()
+}
+
 // NodeName returns the devp2p node identifier.
 func (c *NodeConfig) NodeName() string {
 	// TODO: add version & OS to name
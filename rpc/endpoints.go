@@ -17,12 +17,16 @@
 package rpc
 
 import (
-	"github.com/kardiachain/go-kardia/lib/log"
+	"fmt"
 	"net"
+	"sort"
+	"strings"
+
+	"github.com/kardiachain/go-kardia/lib/log"
 )
 
-// StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules
-func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string) (net.Listener, *Server, error) {
+// StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules/limits
+func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string, limits HTTPLimits) (net.Listener, *Server, error) {
 	// Generate the whitelist based on the allowed modules.
 	whitelist := make(map[string]bool)
 	for _, module := range modules {
@@ -38,6 +42,8 @@ func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []str
 				return nil, nil, err
 			}
 			log.Debug("HTTP registered", "namespace", api.Namespace)
+		} else {
+			handler.disableName(api.Namespace)
 		}
 	}
 	// All APIs registered, start the HTTP listener
@@ -50,6 +56,31 @@ func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []str
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return nil, nil, err
 	}
-	go NewHTTPServer(cors, vhosts, handler).Serve(listener)
+	go NewHTTPServer(cors, vhosts, limits, handler).Serve(listener)
 	return listener, handler, err
 }
+
+// CheckModules returns an error naming the modules that aren't the namespace of
+// any of the given APIs.
+func CheckModules(apis []API, modules []string) error {
+	available := make(map[string]bool)
+	for _, api := range apis {
+		available[api.Namespace] = true
+	}
+	var unknown []string
+	for _, module := range modules {
+		if !available[module] {
+			unknown = append(unknown, module)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	namespaces := make([]string, 0, len(available))
+	for namespace := range available {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return fmt.Errorf("unknown RPC modules [%s], available modules are [%s]",
+		strings.Join(unknown, ", "), strings.Join(namespaces, ", "))
+}
@@ -0,0 +1,104 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type TestService struct{}
+
+func (s *TestService) Echo(str string) string { return str }
+
+// callHTTP posts a JSON-RPC request for method to the server at addr and
+// returns the decoded error message, if any.
+func callHTTP(t *testing.T, addr, method string) string {
+	body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":["hi"]}`
+	resp, err := http.Post("http://"+addr, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Result interface{}
+		Error  *struct {
+			Code    int
+			Message string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result.Error == nil {
+		return ""
+	}
+	if result.Error.Code != -32601 {
+		t.Errorf("error code mismatch: have %d, want %d", result.Error.Code, -32601)
+	}
+	return result.Error.Message
+}
+
+func TestHTTPDisabledModule(t *testing.T) {
+	apis := []API{
+		{Namespace: "test", Service: new(TestService), Public: true},
+		{Namespace: "dual", Service: new(TestService), Public: true},
+	}
+	listener, handler, err := StartHTTPEndpoint("127.0.0.1:0", apis, []string{"test"}, nil, nil, DefaultHTTPLimits)
+	if err != nil {
+		t.Fatalf("failed to start endpoint: %v", err)
+	}
+	defer handler.Stop()
+	defer listener.Close()
+	addr := listener.Addr().String()
+
+	if msg := callHTTP(t, addr, "test_echo"); msg != "" {
+		t.Fatalf("enabled module call failed: %s", msg)
+	}
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{"dual_echo", "module dual is not enabled, enabled modules are [rpc, test]"},
+		{"foo_echo", "module foo does not exist, enabled modules are [rpc, test]"},
+		{"test_missing", "The method test_missing does not exist/is not available"},
+	}
+	for _, tt := range tests {
+		if msg := callHTTP(t, addr, tt.method); !strings.Contains(msg, tt.want) {
+			t.Errorf("%s: error mismatch: have %q, want it to contain %q", tt.method, msg, tt.want)
+		}
+	}
+}
+
+func TestCheckModules(t *testing.T) {
+	apis := []API{{Namespace: "kai"}, {Namespace: "tx"}, {Namespace: "kai"}}
+	if err := CheckModules(apis, []string{"kai", "tx"}); err != nil {
+		t.Errorf("available modules rejected: %v", err)
+	}
+	err := CheckModules(apis, []string{"kai", "neo", "dual"})
+	if err == nil {
+		t.Fatal("unknown modules accepted")
+	}
+	if want := "unknown RPC modules [neo, dual], available modules are [kai, tx]"; err.Error() != want {
+		t.Errorf("error mismatch: have %q, want %q", err, want)
+	}
+}
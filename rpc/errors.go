@@ -16,7 +16,10 @@
 
 package rpc
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // request is for an unknown service
 type methodNotFoundError struct {
@@ -30,6 +33,25 @@ func (e *methodNotFoundError) Error() string {
 	return fmt.Sprintf("The method %s%s%s does not exist/is not available", e.service, serviceMethodSeparator, e.method)
 }
 
+// request is for a module that isn't enabled or doesn't exist
+type moduleNotFoundError struct {
+	service  string
+	method   string
+	disabled bool     // whether the module exists but isn't enabled
+	enabled  []string // modules enabled on the server
+}
+
+func (e *moduleNotFoundError) ErrorCode() int { return -32601 }
+
+func (e *moduleNotFoundError) Error() string {
+	reason := "does not exist"
+	if e.disabled {
+		reason = "is not enabled"
+	}
+	return fmt.Sprintf("The method %s%s%s does not exist/is not available: module %s %s, enabled modules are [%s]",
+		e.service, serviceMethodSeparator, e.method, e.service, reason, strings.Join(e.enabled, ", "))
+}
+
 // received message isn't a valid request
 type invalidRequestError struct{ message string }
 
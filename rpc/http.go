@@ -72,13 +72,35 @@ func (t *httpReadWriteNopCloser) Close() error {
 	return nil
 }
 
+// HTTPLimits configures the limits the HTTP RPC server applies to requests.
+type HTTPLimits struct {
+	MaxRequestContentLength int64   // Maximum size of a request body in bytes, 0 uses the default
+	RateLimit               float64 // Requests per second allowed from a single IP, 0 disables rate limiting
+	RateBurst               int     // Requests a single IP may send at once before being limited
+	MaxBatchLength          int     // Maximum number of requests in a batch, 0 uses the default
+}
+
+// DefaultHTTPLimits keeps the default request size and batch length and doesn't
+// rate limit.
+var DefaultHTTPLimits = HTTPLimits{
+	MaxRequestContentLength: maxRequestContentLength,
+	MaxBatchLength:          defaultMaxBatchLength,
+}
+
 // NewHTTPServer creates a new HTTP RPC server around an API provider.
 //
 // Deprecated: Server implements http.Handler
-func NewHTTPServer(cors []string, vhosts []string, srv *Server) *http.Server {
-	// Wrap the CORS-handler within a host-handler
+func NewHTTPServer(cors []string, vhosts []string, limits HTTPLimits, srv *Server) *http.Server {
+	if limits.MaxRequestContentLength > 0 {
+		srv.maxContentLength = limits.MaxRequestContentLength
+	}
+	if limits.MaxBatchLength > 0 {
+		srv.maxBatchLength = limits.MaxBatchLength
+	}
+	// Wrap the CORS-handler within a host-handler, rate limiting before anything else
 	handler := newCorsHandler(srv, cors)
 	handler = newVHostHandler(vhosts, handler)
+	handler = newRateLimitHandler(limits.RateLimit, limits.RateBurst, handler)
 	return &http.Server{
 		Handler:      handler,
 		ReadTimeout:  5 * time.Second,
@@ -93,7 +115,11 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet && r.ContentLength == 0 && r.URL.RawQuery == "" {
 		return
 	}
-	if code, err := validateRequest(r); err != nil {
+	maxContentLength := srv.maxContentLength
+	if maxContentLength <= 0 {
+		maxContentLength = maxRequestContentLength
+	}
+	if code, err := validateRequest(r, maxContentLength); err != nil {
 		http.Error(w, err.Error(), code)
 		return
 	}
@@ -105,7 +131,7 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx = context.WithValue(ctx, "scheme", r.Proto)
 	ctx = context.WithValue(ctx, "local", r.Host)
 
-	body := io.LimitReader(r.Body, maxRequestContentLength)
+	body := io.LimitReader(r.Body, maxContentLength)
 	codec := NewJSONCodec(&httpReadWriteNopCloser{body, w})
 	defer codec.Close()
 
@@ -115,12 +141,12 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // validateRequest returns a non-zero response code and error message if the
 // request is invalid.
-func validateRequest(r *http.Request) (int, error) {
+func validateRequest(r *http.Request, maxContentLength int64) (int, error) {
 	if r.Method == http.MethodPut || r.Method == http.MethodDelete {
 		return http.StatusMethodNotAllowed, errors.New("method not allowed")
 	}
-	if r.ContentLength > maxRequestContentLength {
-		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxRequestContentLength)
+	if r.ContentLength > maxContentLength {
+		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxContentLength)
 		return http.StatusRequestEntityTooLarge, err
 	}
 	mt, _, err := mime.ParseMediaType(r.Header.Get("content-type"))
@@ -191,3 +217,83 @@ func newVHostHandler(vhosts []string, next http.Handler) http.Handler {
 	}
 	return &virtualHostHandler{vhostMap, next}
 }
+
+// rateLimitHandler is a handler which limits the rate of requests per client IP
+// using a token bucket for every IP.
+type rateLimitHandler struct {
+	rate  float64 // tokens added per second
+	burst float64 // bucket capacity
+	next  http.Handler
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// ServeHTTP serves JSON-RPC requests over HTTP, implements http.Handler
+func (h *rateLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !h.allow(ip) {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// allow takes a token from the bucket of ip, reporting whether one was available.
+func (h *rateLimitHandler) allow(ip string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	// Drop buckets that have refilled completely, they carry no state
+	if now.Sub(h.lastSweep) > time.Minute {
+		for key, bucket := range h.buckets {
+			if bucket.tokens+now.Sub(bucket.last).Seconds()*h.rate >= h.burst {
+				delete(h.buckets, key)
+			}
+		}
+		h.lastSweep = now
+	}
+	bucket, ok := h.buckets[ip]
+	if !ok {
+		bucket = &tokenBucket{tokens: h.burst, last: now}
+		h.buckets[ip] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * h.rate
+	if bucket.tokens > h.burst {
+		bucket.tokens = h.burst
+	}
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+func newRateLimitHandler(rate float64, burst int, next http.Handler) http.Handler {
+	// disable rate limiting if user has not specified a rate
+	if rate <= 0 {
+		return next
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitHandler{
+		rate:    rate,
+		burst:   float64(burst),
+		next:    next,
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPRequestSizeLimit(t *testing.T) {
+	srv := NewServer()
+	srv.RegisterName("test", new(TestService))
+	handler := NewHTTPServer(nil, []string{"localhost"}, HTTPLimits{MaxRequestContentLength: 100}, srv).Handler
+
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost", strings.NewReader(body))
+		req.Header.Set("content-type", contentType)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post(`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["hi"]}`); code != http.StatusOK {
+		t.Errorf("small request rejected with status %d", code)
+	}
+	oversized := `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["` + strings.Repeat("a", 100) + `"]}`
+	if code := post(oversized); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized request status mismatch: have %d, want %d", code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestHTTPRateLimit(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := newRateLimitHandler(2, 3, next).(*rateLimitHandler)
+	now := time.Unix(1000, 0)
+	handler.now = func() time.Time { return now }
+
+	get := func(remote string) int {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	// A burst up to the limit is served, the next request is refused.
+	for i := 0; i < 3; i++ {
+		if code := get("10.0.0.1:1000"); code != http.StatusOK {
+			t.Fatalf("request %d within the burst rejected with status %d", i, code)
+		}
+	}
+	if code := get("10.0.0.1:1001"); code != http.StatusTooManyRequests {
+		t.Errorf("request exceeding the burst status mismatch: have %d, want %d", code, http.StatusTooManyRequests)
+	}
+	// Other IPs have their own allowance.
+	if code := get("10.0.0.2:1000"); code != http.StatusOK {
+		t.Errorf("request from another IP rejected with status %d", code)
+	}
+	// Tokens are refilled at the configured rate.
+	now = now.Add(500 * time.Millisecond)
+	if code := get("10.0.0.1:1000"); code != http.StatusOK {
+		t.Errorf("request after refill rejected with status %d", code)
+	}
+	if code := get("10.0.0.1:1000"); code != http.StatusTooManyRequests {
+		t.Errorf("request exceeding the refill status mismatch: have %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	if _, ok := newRateLimitHandler(0, 10, next).(*rateLimitHandler); ok {
+		t.Error("rate limiting enabled with a zero rate")
+	}
+}
+
+func TestHTTPBatchRequest(t *testing.T) {
+	srv := NewServer()
+	srv.RegisterName("test", new(TestService))
+	handler := NewHTTPServer(nil, []string{"localhost"}, HTTPLimits{MaxBatchLength: 2}, srv).Handler
+
+	post := func(body string) string {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost", strings.NewReader(body))
+		req.Header.Set("content-type", contentType)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return strings.TrimSpace(rec.Body.String())
+	}
+
+	batch := `[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["a"]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["b"]}]`
+	want := `[{"jsonrpc":"2.0","id":1,"result":"a"},{"jsonrpc":"2.0","id":2,"result":"b"}]`
+	if have := post(batch); have != want {
+		t.Errorf("batch response mismatch:\nhave %s\nwant %s", have, want)
+	}
+
+	oversized := `[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["a"]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["b"]},{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["c"]}]`
+	want = `{"jsonrpc":"2.0","error":{"code":-32600,"message":"batch of 3 requests exceeds the maximum of 2"}}`
+	if have := post(oversized); have != want {
+		t.Errorf("oversized batch response mismatch:\nhave %s\nwant %s", have, want)
+	}
+
+	want = `{"jsonrpc":"2.0","error":{"code":-32600,"message":"empty batch"}}`
+	if have := post(`[]`); have != want {
+		t.Errorf("empty batch response mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
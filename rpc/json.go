@@ -145,6 +145,10 @@ func (c *jsonCodec) ReadRequestHeaders() ([]rpcRequest, bool, Error) {
 		return nil, false, &invalidRequestError{err.Error()}
 	}
 
+	// check if this is a batch request
+	if isBatch(incomingMsg) {
+		return parseBatchRequest(incomingMsg)
+	}
 	return parseRequest(incomingMsg)
 }
 
@@ -211,6 +215,64 @@ func parseRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error) {
 	return []rpcRequest{{service: elems[0], method: elems[1], id: &in.Id, params: in.Payload}}, false, nil
 }
 
+// parseBatchRequest will parse a batch request into a collection of requests from the given RawMessage, an indication
+// if the request was a batch and an error when the request could not be read.
+func parseBatchRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error) {
+	var in []jsonRequest
+	if err := json.Unmarshal(incomingMsg, &in); err != nil {
+		return nil, false, &invalidMessageError{err.Error()}
+	}
+	if len(in) == 0 {
+		return nil, false, &invalidRequestError{"empty batch"}
+	}
+
+	requests := make([]rpcRequest, len(in))
+	for i, r := range in {
+		if err := checkReqId(r.Id); err != nil {
+			return nil, false, &invalidMessageError{err.Error()}
+		}
+
+		id := &in[i].Id
+
+		// subscribe are special, they will always use `subscribeMethod` as first param in the payload
+		if strings.HasSuffix(r.Method, subscribeMethodSuffix) {
+			requests[i] = rpcRequest{id: id, isPubSub: true}
+			if len(r.Payload) > 0 {
+				// first param must be subscription name
+				var subscribeMethod [1]string
+				if err := json.Unmarshal(r.Payload, &subscribeMethod); err != nil {
+					log.Debug(fmt.Sprintf("Unable to parse subscription method: %v\n", err))
+					return nil, false, &invalidRequestError{"Unable to parse subscription request"}
+				}
+
+				requests[i].service, requests[i].method = strings.TrimSuffix(r.Method, subscribeMethodSuffix), subscribeMethod[0]
+				requests[i].params = r.Payload
+				continue
+			}
+
+			return nil, true, &invalidRequestError{"Unable to parse (un)subscribe request arguments"}
+		}
+
+		if strings.HasSuffix(r.Method, unsubscribeMethodSuffix) {
+			requests[i] = rpcRequest{id: id, isPubSub: true, method: r.Method, params: r.Payload}
+			continue
+		}
+
+		if len(r.Payload) == 0 {
+			requests[i] = rpcRequest{id: id, params: nil}
+		} else {
+			requests[i] = rpcRequest{id: id, params: r.Payload}
+		}
+		if elem := strings.Split(r.Method, serviceMethodSeparator); len(elem) == 2 {
+			requests[i].service, requests[i].method = elem[0], elem[1]
+		} else {
+			requests[i].err = &methodNotFoundError{r.Method, ""}
+		}
+	}
+
+	return requests, true, nil
+}
+
 // ParseRequestArguments tries to parse the given params (json.RawMessage) with the given
 // types. It returns the parsed values or an error when the parsing failed.
 func (c *jsonCodec) ParseRequestArguments(argTypes []reflect.Type, params interface{}) ([]reflect.Value, Error) {
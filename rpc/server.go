@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -31,6 +32,9 @@ import (
 
 const MetadataApi = "rpc"
 
+// defaultMaxBatchLength is the maximum number of requests in a batch accepted by default.
+const defaultMaxBatchLength = 100
+
 // CodecOption specifies which type of messages this codec supports
 type CodecOption int
 
@@ -45,9 +49,10 @@ const (
 // NewServer will create a new server instance with no registered handlers.
 func NewServer() *Server {
 	server := &Server{
-		services: make(serviceRegistry),
-		codecs:   mapset.NewSet(),
-		run:      1,
+		services:       make(serviceRegistry),
+		codecs:         mapset.NewSet(),
+		run:            1,
+		maxBatchLength: defaultMaxBatchLength,
 	}
 
 	// register a default service which will provide meta information about the RPC service such as the services and
@@ -117,6 +122,25 @@ func (s *Server) RegisterName(name string, rcvr interface{}) error {
 	return nil
 }
 
+// disableName marks a namespace as provided by the node but not enabled on this
+// server, so calls to it are reported as disabled rather than unknown.
+func (s *Server) disableName(name string) {
+	if s.disabled == nil {
+		s.disabled = make(map[string]bool)
+	}
+	s.disabled[name] = true
+}
+
+// enabledModules returns the sorted names of the registered services.
+func (s *Server) enabledModules() []string {
+	modules := make([]string, 0, len(s.services))
+	for name := range s.services {
+		modules = append(modules, name)
+	}
+	sort.Strings(modules)
+	return modules
+}
+
 // serveRequest will reads requests from the codec, calls the RPC callback and
 // writes the response to the given codec.
 //
@@ -170,6 +194,16 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 			return nil
 		}
 
+		// refuse batches above the configured maximum length as a whole
+		if batch && s.maxBatchLength > 0 && len(reqs) > s.maxBatchLength {
+			err := &invalidRequestError{fmt.Sprintf("batch of %d requests exceeds the maximum of %d", len(reqs), s.maxBatchLength)}
+			codec.Write(codec.CreateErrorResponse(nil, err))
+			if singleShot {
+				return nil
+			}
+			continue
+		}
+
 		// check if server is ordered to shutdown and return an error
 		// telling the client that his request failed.
 		if atomic.LoadInt32(&s.run) != 1 {
@@ -185,13 +219,13 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 			}
 			return nil
 		}
-		// We focus on single rpc request for now
+		// If a single shot request is executing, run and return immediately
 		if singleShot {
-			s.exec(ctx, codec, reqs[0])
-			// if batch {
-			// 	s.execBatch(ctx, codec, reqs)
-			// } else {
-			// }
+			if batch {
+				s.execBatch(ctx, codec, reqs)
+			} else {
+				s.exec(ctx, codec, reqs[0])
+			}
 			return nil
 		}
 		// For multi-shot connections, start a goroutine to serve and loop back
@@ -199,12 +233,11 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 
 		go func(reqs []*serverRequest, batch bool) {
 			defer pend.Done()
-			s.exec(ctx, codec, reqs[0])
-			// if batch {
-			// s.execBatch(ctx, codec, reqs)
-			// } else {
-
-			// }
+			if batch {
+				s.execBatch(ctx, codec, reqs)
+			} else {
+				s.exec(ctx, codec, reqs[0])
+			}
 		}(reqs, batch)
 	}
 	return nil
@@ -343,6 +376,33 @@ func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest
 	}
 }
 
+// execBatch executes the given requests and writes the result back using the codec.
+// It will only write the response back when the last request is processed.
+func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*serverRequest) {
+	responses := make([]interface{}, len(requests))
+	var callbacks []func()
+	for i, req := range requests {
+		if req.err != nil {
+			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
+		} else {
+			var callback func()
+			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
+				callbacks = append(callbacks, callback)
+			}
+		}
+	}
+
+	if err := codec.Write(responses); err != nil {
+		log.Error(fmt.Sprintf("%v\n", err))
+		codec.Close()
+	}
+
+	// when request holds one of more subscribe requests this allows these subscriptions to be activated
+	for _, c := range callbacks {
+		c()
+	}
+}
+
 // readRequest requests the next (batch) request from the codec. It will return the collection
 // of requests, an indication if the request was a batch, the invalid request identifier and an
 // error when the request could not be read/parsed.
@@ -376,8 +436,8 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 		}
 
 		if svc, ok = s.services[r.service]; !ok { // rpc method isn't available
-			log.Info("RPC service called was not registered in the ServiceRegistry")
-			requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
+			log.Info("RPC service called was not registered in the ServiceRegistry", "service", r.service)
+			requests[i] = &serverRequest{id: r.id, err: &moduleNotFoundError{r.service, r.method, s.disabled[r.service], s.enabledModules()}}
 			continue
 		}
 
@@ -65,6 +65,10 @@ type subscriptions map[string]*callback  // collection of subscription callbacks
 // Server represents a RPC server
 type Server struct {
 	services serviceRegistry
+	disabled map[string]bool // namespaces provided by the node but not enabled on this server
+
+	maxContentLength int64 // maximum size of an HTTP request body, 0 uses the default
+	maxBatchLength   int   // maximum number of requests in a batch, 0 disables the limit
 
 	run      int32
 	codecsMu sync.Mutex
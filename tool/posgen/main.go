@@ -0,0 +1,201 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// posgen generates the PoS genesis deployment (Master, Node and Staker contracts) of the mainchain config
+// from a list of genesis node owners, so operators don't have to write them by hand.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	smc "github.com/kardiachain/go-kardia/kvm/smc"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/mainchain/genesis"
+)
+
+const (
+	// genesis nodes and stakers are deployed at fixed addresses starting from these ones.
+	nodeAddressStart   = 0x10
+	stakerAddressStart = 0x20
+	maxGenesisNodes    = stakerAddressStart - nodeAddressStart
+)
+
+// Params are the parameters used to generate the consensus genesis deployment.
+type Params struct {
+	Owners                      []string
+	PubKeys                     []string
+	Names                       []string
+	RewardPercentage            uint16
+	StakeAmount                 int64 // StakeAmount is staked to each genesis node, in KAI.
+	MasterAddress               string
+	MasterGenesisAmount         int64 // MasterGenesisAmount is in KAI.
+	MaxViolatePercentageAllowed uint64
+	FetchNewValidatorsTime      uint64
+	MaxValidators               uint64
+	ConsensusPeriodInBlock      uint64
+	BlockReward                 string
+	MinimumStakes               string
+	LockedPeriod                uint64
+}
+
+type flags struct {
+	owners  string
+	pubKeys string
+	names   string
+	reward  uint
+	out     string
+	params  Params
+}
+
+var args flags
+
+func init() {
+	flag.StringVar(&args.owners, "owners", "", "comma separated owner addresses of genesis nodes")
+	flag.StringVar(&args.pubKeys, "pubkeys", "", "comma separated public keys of genesis nodes, in the same order as owners")
+	flag.StringVar(&args.names, "names", "", "comma separated names of genesis nodes, default to Node1, Node2...")
+	flag.StringVar(&args.out, "out", "", "output file, default to stdout")
+	flag.StringVar(&args.params.MasterAddress, "master", "0x0000000000000000000000000000000000000009", "Master contract address")
+	flag.Int64Var(&args.params.MasterGenesisAmount, "masterAmount", 1000000000, "Master contract genesis amount in KAI")
+	flag.Int64Var(&args.params.StakeAmount, "stake", 2000000, "amount in KAI staked to each genesis node")
+	flag.UintVar(&args.reward, "reward", 5, "reward percentage of genesis nodes")
+	flag.Uint64Var(&args.params.MaxViolatePercentageAllowed, "maxViolatePercentage", 50, "max violate percentage allowed")
+	flag.Uint64Var(&args.params.FetchNewValidatorsTime, "fetchNewValidatorsTime", 10, "blocks before the end of a consensus period to fetch new validators")
+	flag.Uint64Var(&args.params.MaxValidators, "maxValidators", 10, "max validators of a consensus period")
+	flag.Uint64Var(&args.params.ConsensusPeriodInBlock, "consensusPeriod", 20, "consensus period in blocks")
+	flag.StringVar(&args.params.BlockReward, "blockReward", "100000000000000", "block reward")
+	flag.StringVar(&args.params.MinimumStakes, "minimumStakes", "2000000", "minimum stakes of a node")
+	flag.Uint64Var(&args.params.LockedPeriod, "lockedPeriod", 500000000, "period in blocks that staked KAI cannot be withdrawn")
+}
+
+// generate creates the consensus genesis deployment from params. Each owner gets a node contract
+// and a staker contract staking StakeAmount to its node. The output can be pasted under
+// MainChain.Consensus of the node's config file as is.
+func generate(p Params) (*genesis.ConsensusConfig, error) {
+	if len(p.Owners) == 0 {
+		return nil, fmt.Errorf("at least one genesis node owner is required")
+	}
+	if len(p.Owners) > maxGenesisNodes {
+		return nil, fmt.Errorf("at most %v genesis nodes are supported, got %v", maxGenesisNodes, len(p.Owners))
+	}
+	if len(p.PubKeys) != len(p.Owners) {
+		return nil, fmt.Errorf("got %v public keys for %v owners", len(p.PubKeys), len(p.Owners))
+	}
+	if len(p.Names) > 0 && len(p.Names) != len(p.Owners) {
+		return nil, fmt.Errorf("got %v names for %v owners", len(p.Names), len(p.Owners))
+	}
+	if !common.IsHexAddress(p.MasterAddress) {
+		return nil, fmt.Errorf("invalid master address %v", p.MasterAddress)
+	}
+	if _, ok := new(big.Int).SetString(p.MinimumStakes, 10); !ok {
+		return nil, fmt.Errorf("invalid minimum stakes %v", p.MinimumStakes)
+	}
+	if _, ok := new(big.Int).SetString(p.BlockReward, 10); !ok {
+		return nil, fmt.Errorf("invalid block reward %v", p.BlockReward)
+	}
+	c := &genesis.ConsensusConfig{
+		MaxViolatePercentageAllowed: p.MaxViolatePercentageAllowed,
+		FetchNewValidatorsTime:      p.FetchNewValidatorsTime,
+		MaxValidators:               p.MaxValidators,
+		ConsensusPeriodInBlock:      p.ConsensusPeriodInBlock,
+		BlockReward:                 p.BlockReward,
+		MinimumStakes:               p.MinimumStakes,
+		LockedPeriod:                p.LockedPeriod,
+		Compilation: genesis.Compilation{
+			Master: genesis.CompilationInfo{ByteCode: common.Bytes2Hex(smc.MasterByteCode), ABI: smc.MasterAbi},
+			Staker: genesis.CompilationInfo{ByteCode: common.Bytes2Hex(smc.StakerByteCode), ABI: smc.StakerAbi},
+			Node:   genesis.CompilationInfo{ByteCode: common.Bytes2Hex(smc.NodeByteCode), ABI: smc.NodeAbi},
+		},
+		Deployment: genesis.Deployment{
+			Master: genesis.MasterInfo{
+				Address:       common.HexToAddress(p.MasterAddress).Hex(),
+				GenesisAmount: big.NewInt(p.MasterGenesisAmount).String(),
+			},
+		},
+	}
+	for i, owner := range p.Owners {
+		if !common.IsHexAddress(owner) {
+			return nil, fmt.Errorf("invalid owner address %v", owner)
+		}
+		name := fmt.Sprintf("Node%v", i+1)
+		if len(p.Names) > 0 {
+			name = p.Names[i]
+		}
+		nodeAddress := common.BigToAddress(big.NewInt(int64(nodeAddressStart + i)))
+		stakerAddress := common.BigToAddress(big.NewInt(int64(stakerAddressStart + i)))
+		c.Deployment.Nodes = append(c.Deployment.Nodes, genesis.NodeInfo{
+			Address:          nodeAddress.Hex(),
+			Owner:            common.HexToAddress(owner).Hex(),
+			PubKey:           p.PubKeys[i],
+			Name:             name,
+			RewardPercentage: p.RewardPercentage,
+		})
+		c.Deployment.Stakers = append(c.Deployment.Stakers, genesis.StakerInfo{
+			Address:     stakerAddress.Hex(),
+			Owner:       common.HexToAddress(owner).Hex(),
+			StakedNode:  nodeAddress.Hex(),
+			StakeAmount: big.NewInt(p.StakeAmount).String(),
+		})
+	}
+	return c, nil
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	list := strings.Split(s, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+	return list
+}
+
+func main() {
+	flag.Parse()
+	params := args.params
+	params.Owners = splitList(args.owners)
+	params.PubKeys = splitList(args.pubKeys)
+	params.Names = splitList(args.names)
+	params.RewardPercentage = uint16(args.reward)
+
+	c, err := generate(params)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if args.out == "" {
+		fmt.Print(string(out))
+		return
+	}
+	if err := ioutil.WriteFile(args.out, out, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,170 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/kai/storage/kvstore"
+	"github.com/kardiachain/go-kardia/kvm"
+	"github.com/kardiachain/go-kardia/lib/abi"
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/log"
+	"github.com/kardiachain/go-kardia/mainchain/blockchain"
+	"github.com/kardiachain/go-kardia/mainchain/genesis"
+	"github.com/kardiachain/go-kardia/types"
+)
+
+var (
+	testOwners = []string{
+		"0xc1fe56E3F58D3244F606306611a5d10c8333f1f6",
+		"0x7cefC13B6E2aedEeDFB7Cb6c32457240746BAEe5",
+		"0xfF3dac4f04dDbD24dE5D6039F90596F0a8bb08fd",
+	}
+	testPubKeys = []string{
+		"7a86e2b7628c76fcae76a8b37025cba698a289a44102c5c021594b5c9fce33072ee7ef992f5e018dc44b98fa11fec53824d79015747e8ac474f4ee15b7fbe860",
+		"660889e39b37ade58f789933954123e56d6498986a0cd9ca63d223e866d5521aaedc9e5298e2f4828a5c90f4c58fb24e19613a462ca0210dd962821794f630f0",
+		"2e61f57201ec804f9d5298c4665844fd077a2516cd33eccea48f7bdf93de5182da4f57dc7b4d8870e5e291c179c05ff04100718b49184f64a7c0d40cc66343da",
+	}
+)
+
+func testParams() Params {
+	return Params{
+		Owners:                      testOwners,
+		PubKeys:                     testPubKeys,
+		RewardPercentage:            5,
+		StakeAmount:                 2000000,
+		MasterAddress:               "0x0000000000000000000000000000000000000009",
+		MasterGenesisAmount:         1000000000,
+		MaxViolatePercentageAllowed: 50,
+		FetchNewValidatorsTime:      10,
+		MaxValidators:               10,
+		ConsensusPeriodInBlock:      20,
+		BlockReward:                 "100000000000000",
+		MinimumStakes:               "2000000",
+		LockedPeriod:                500000000,
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	c, err := generate(testParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the generated deployment is written to and read back from yaml as the node does.
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded genesis.ConsensusConfig
+	if err := yaml.Unmarshal(out, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	info, err := loaded.ConsensusInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := make(map[string]*big.Int)
+	for _, owner := range testOwners {
+		accounts[owner] = genesis.ToCell(2000000000)
+	}
+	g := genesis.DefaulTestnetFullGenesisBlock(accounts, map[string]string{})
+	g.ConsensusInfo = info
+	privateKey, _ := crypto.HexToECDSA("8843ebcb1021b00ae9a644db6617f9c6d870e5fd53624cefe374c1d2d710fd06")
+	db := kvstore.NewStoreDB(memorydb.New())
+	chainConfig, _, err := genesis.SetupGenesisBlock(log.New(), db, g, &types.BaseAccount{
+		Address:    common.HexToAddress(testOwners[0]),
+		PrivateKey: *privateKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := blockchain.NewBlockChain(log.New(), db, chainConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := bc.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	masterAbi, err := abi.JSON(strings.NewReader(info.Master.ABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := kvm.NewKVM(kvm.NewInternalKVMContext(common.HexToAddress(testOwners[0]), bc.CurrentHeader(), bc), st, kvm.Config{})
+	input, err := masterAbi.Pack("getTotalAvailableNodes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := kvm.StaticCall(vm, info.Master.Address, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total *big.Int
+	if err := masterAbi.Unpack(&total, "getTotalAvailableNodes", output); err != nil {
+		t.Fatal(err)
+	}
+	if total.Uint64() != uint64(len(testOwners)) {
+		t.Fatalf("expected %v available nodes, got %v", len(testOwners), total)
+	}
+	for _, n := range loaded.Deployment.Nodes {
+		input, err := masterAbi.Pack("getTotalStakes", common.HexToAddress(n.Address))
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := kvm.StaticCall(vm, info.Master.Address, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stakes *big.Int
+		if err := masterAbi.Unpack(&stakes, "getTotalStakes", output); err != nil {
+			t.Fatal(err)
+		}
+		if stakes.Cmp(genesis.ToCell(2000000)) != 0 {
+			t.Fatalf("node %v: expected stakes %v, got %v", n.Address, genesis.ToCell(2000000), stakes)
+		}
+	}
+}
+
+func TestGenerateInvalidParams(t *testing.T) {
+	tests := map[string]func(p *Params){
+		"no owners":      func(p *Params) { p.Owners, p.PubKeys = nil, nil },
+		"missing pubkey": func(p *Params) { p.PubKeys = p.PubKeys[:1] },
+		"names mismatch": func(p *Params) { p.Names = []string{"node1"} },
+		"invalid owner":  func(p *Params) { p.Owners = []string{"owner", testOwners[1], testOwners[2]} },
+		"invalid master": func(p *Params) { p.MasterAddress = "master" },
+		"invalid stakes": func(p *Params) { p.MinimumStakes = "stakes" },
+		"invalid reward": func(p *Params) { p.BlockReward = "" },
+		"too many nodes": func(p *Params) { p.Owners = make([]string, maxGenesisNodes+1) },
+	}
+	for name, modify := range tests {
+		p := testParams()
+		modify(&p)
+		if _, err := generate(p); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -50,6 +50,7 @@ import (
 	"github.com/kardiachain/go-kardia/lib/p2p/nat"
 	"github.com/kardiachain/go-kardia/lib/sysutils"
 	kai "github.com/kardiachain/go-kardia/mainchain"
+	"github.com/kardiachain/go-kardia/mainchain/gasprice"
 	"github.com/kardiachain/go-kardia/mainchain/genesis"
 	"github.com/kardiachain/go-kardia/mainchain/tx_pool"
 	"github.com/kardiachain/go-kardia/node"
@@ -408,7 +409,7 @@ func (c *Config) Start() {
 		logger.Error("Failed to start debug", "err", err)
 	}
 
-	if err := c.StartPump(kardiaService.TxPool()); err != nil {
+	if err := c.StartPump(kardiaService.TxPool(), kardiaService.GasPriceOracle()); err != nil {
 		logger.Error("Failed to start pump txs", "err", err)
 	}
 
@@ -504,9 +505,9 @@ func (c *Config) SaveWatchers(service node.Service, events []Event) {
 }
 
 // StartPump reads dual config and start dual service
-func (c *Config) StartPump(txPool *tx_pool.TxPool) error {
+func (c *Config) StartPump(txPool *tx_pool.TxPool, gpo *gasprice.Oracle) error {
 	if c.GenTxs != nil {
-		go genTxsLoop(c.GenTxs, txPool, c.MainChain.TxPool.GlobalQueue)
+		go genTxsLoop(c.GenTxs, txPool, gpo, c.MainChain.TxPool.GlobalQueue)
 	} else {
 		return fmt.Errorf("cannot start pump txs: %v", c.GenTxs)
 	}
@@ -515,7 +516,7 @@ func (c *Config) StartPump(txPool *tx_pool.TxPool) error {
 
 // genTxsLoop generate & add a batch of transfer txs, repeat after delay flag.
 // Warning: Set txsDelay < 5 secs may build up old subroutines because previous subroutine to add txs won't be finished before new one starts.
-func genTxsLoop(genTxs *GenTxs, txPool *tx_pool.TxPool, globalQueue uint64) {
+func genTxsLoop(genTxs *GenTxs, txPool *tx_pool.TxPool, gpo *gasprice.Oracle, globalQueue uint64) {
 	time.Sleep(15 * time.Second) //decrease it if you want to test it locally
 	var accounts = make([]tool.Account, 0)
 	// get accounts
@@ -559,6 +560,7 @@ func genTxsLoop(genTxs *GenTxs, txPool *tx_pool.TxPool, globalQueue uint64) {
 		// Let's assume that current height is greater than oldHeight, continue generate txs
 		if height > initHeight && uint64(pendingSize) < globalQueue {
 			initHeight = height
+			genTool.SetGasPrice(gpo.SuggestPrice())
 			generateTxs(genTxs, genTool, txPool)
 		} else {
 			log.Warn("Skip GenTxs due to height or max pending txs", "prevHeight", initHeight, "currentHeight", height, "pending", pendingSize)
@@ -64,6 +64,7 @@ var (
 type GeneratorTool struct {
 	nonceMap map[string]uint64 // Map of nonce counter for each address
 	accounts []Account
+	gasPrice *big.Int // Gas price of generated transactions
 	mu       sync.Mutex
 }
 
@@ -71,9 +72,18 @@ func NewGeneratorTool(accounts []Account) *GeneratorTool {
 	genTool := new(GeneratorTool)
 	genTool.nonceMap = make(map[string]uint64, 0)
 	genTool.accounts = accounts
+	genTool.gasPrice = defaultGasPrice
 	return genTool
 }
 
+// SetGasPrice sets the gas price of the transactions generated from now on,
+// e.g. the price suggested by a gas price oracle.
+func (genTool *GeneratorTool) SetGasPrice(price *big.Int) {
+	genTool.mu.Lock()
+	defer genTool.mu.Unlock()
+	genTool.gasPrice = new(big.Int).Set(price)
+}
+
 // GenerateTx generate an array of transfer transactions within genesis accounts.
 // numTx: number of transactions to send, default to 10.
 func (genTool *GeneratorTool) GenerateTx(numTx int) []*types.Transaction {
@@ -97,7 +107,7 @@ func (genTool *GeneratorTool) GenerateTx(numTx int) []*types.Transaction {
 			toAddr,
 			amount,
 			1000,
-			big.NewInt(1),
+			genTool.gasPrice,
 			nil,
 		), senderKey)
 		if err != nil {
@@ -135,7 +145,7 @@ func (genTool *GeneratorTool) GenerateRandomTx(numTx int) types.Transactions {
 			toAddr,
 			amount,
 			DefaultGasLimit,
-			defaultGasPrice,
+			genTool.gasPrice,
 			nil,
 		), senderKey)
 		if err != nil {
@@ -177,7 +187,7 @@ func (genTool *GeneratorTool) GenerateRandomTxWithState(numTx int, stateDb *stat
 			toAddr,
 			amount,
 			DefaultGasLimit,
-			defaultGasPrice,
+			genTool.gasPrice,
 			nil,
 		), senderKey)
 		if err != nil {
@@ -219,7 +229,7 @@ func (genTool *GeneratorTool) GenerateRandomTxWithAddressState(numTx int, txPool
 			toAddr,
 			amount,
 			DefaultGasLimit,
-			defaultGasPrice,
+			genTool.gasPrice,
 			nil,
 		), senderKey)
 		if err != nil {
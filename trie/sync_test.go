@@ -0,0 +1,86 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kardiachain/go-kardia/kai/kaidb/memorydb"
+	"github.com/kardiachain/go-kardia/lib/common"
+)
+
+// checkTrieContents cross references a reconstructed trie with an expected data
+// content map.
+func checkTrieContents(t *testing.T, db *TrieDatabase, root common.Hash, content map[string][]byte) {
+	trie, err := New(root, db)
+	if err != nil {
+		t.Fatalf("failed to create trie at %x: %v", root, err)
+	}
+	for key, val := range content {
+		if have := trie.Get([]byte(key)); !bytes.Equal(have, val) {
+			t.Errorf("entry %x: content mismatch: have %x, want %x", key, have, val)
+		}
+	}
+}
+
+// Tests that a trie is reconstructed from its nodes retrieved in batches.
+func TestIterativeSync(t *testing.T) {
+	srcDb, srcTrie, srcData := makeTestTrie()
+	srcRoot := srcTrie.Hash()
+
+	diskdb := memorydb.New()
+	sched := NewSync(srcRoot, diskdb, nil)
+
+	queue := sched.Missing(100)
+	for len(queue) > 0 {
+		results := make([]SyncResult, len(queue))
+		for i, hash := range queue {
+			data, err := srcDb.Node(hash)
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for %x: %v", hash, err)
+			}
+			results[i] = SyncResult{hash, data}
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+		if _, err := sched.Commit(diskdb); err != nil {
+			t.Fatalf("failed to commit data: %v", err)
+		}
+		queue = sched.Missing(100)
+	}
+	if pending := sched.Pending(); pending != 0 {
+		t.Fatalf("sync finished with %d pending entries", pending)
+	}
+	checkTrieContents(t, NewDatabase(diskdb), srcRoot, srcData)
+
+	// Nothing is left to retrieve once the trie is known
+	if missing := NewSync(srcRoot, diskdb, nil).Missing(0); len(missing) != 0 {
+		t.Errorf("known trie requested again: %d entries", len(missing))
+	}
+}
+
+// Tests that data which wasn't requested is refused.
+func TestSyncUnrequested(t *testing.T) {
+	_, srcTrie, _ := makeTestTrie()
+	sched := NewSync(srcTrie.Hash(), memorydb.New(), nil)
+
+	if _, _, err := sched.Process([]SyncResult{{Hash: common.HexToHash("0x01"), Data: []byte{1}}}); err != ErrNotRequested {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNotRequested)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import "math/big"
+
+const (
+	// InitialBaseFee is the base fee of the first block of the London fork.
+	InitialBaseFee = 1000000000
+
+	// BaseFeeChangeDenominator bounds the change of the base fee between two blocks to 1/8.
+	BaseFeeChangeDenominator = 8
+
+	// ElasticityMultiplier sets the gas target of a block to its gas limit divided by 2.
+	ElasticityMultiplier = 2
+)
+
+// CalcBaseFee returns the base fee of the child of parent, which used parentGasUsed
+// gas. The base fee rises when the parent used more gas than its target and falls
+// when it used less, by at most 1/BaseFeeChangeDenominator per block. The first
+// block of the fork, whose parent has no base fee, starts from InitialBaseFee.
+func CalcBaseFee(parent *Header, parentGasUsed uint64) *big.Int {
+	if parent.BaseFee == nil {
+		return new(big.Int).SetUint64(InitialBaseFee)
+	}
+	target := parent.GasLimit / ElasticityMultiplier
+	if target == 0 || parentGasUsed == target {
+		return new(big.Int).Set(parent.BaseFee)
+	}
+	if parentGasUsed > target {
+		delta := new(big.Int).SetUint64(parentGasUsed - target)
+		delta.Mul(delta, parent.BaseFee)
+		delta.Div(delta, new(big.Int).SetUint64(target))
+		delta.Div(delta, big.NewInt(BaseFeeChangeDenominator))
+		// Always rise on a busy block, so a base fee of zero can recover.
+		if delta.Sign() == 0 {
+			delta.SetUint64(1)
+		}
+		return delta.Add(parent.BaseFee, delta)
+	}
+	delta := new(big.Int).SetUint64(target - parentGasUsed)
+	delta.Mul(delta, parent.BaseFee)
+	delta.Div(delta, new(big.Int).SetUint64(target))
+	delta.Div(delta, big.NewInt(BaseFeeChangeDenominator))
+	return delta.Sub(parent.BaseFee, delta)
+}
@@ -0,0 +1,88 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/kardiachain/go-kardia/lib/rlp"
+)
+
+func TestCalcBaseFee(t *testing.T) {
+	parent := &Header{GasLimit: 20000000, BaseFee: big.NewInt(InitialBaseFee)}
+	tests := []struct {
+		gasUsed uint64
+		want    int64
+	}{
+		{10000000, InitialBaseFee},           // at target
+		{20000000, InitialBaseFee * 9 / 8},   // full
+		{0, InitialBaseFee * 7 / 8},          // empty
+		{15000000, InitialBaseFee * 17 / 16}, // halfway above target
+	}
+	for i, test := range tests {
+		if have := CalcBaseFee(parent, test.gasUsed); have.Cmp(big.NewInt(test.want)) != 0 {
+			t.Errorf("test %d: base fee mismatch: have %v, want %d", i, have, test.want)
+		}
+	}
+
+	// The first block of the fork starts from the initial base fee.
+	if have := CalcBaseFee(&Header{GasLimit: 20000000}, 20000000); have.Cmp(big.NewInt(InitialBaseFee)) != 0 {
+		t.Errorf("initial base fee mismatch: have %v, want %d", have, InitialBaseFee)
+	}
+	// A busy block raises even a base fee too small to move by 1/8.
+	if have := CalcBaseFee(&Header{GasLimit: 20000000, BaseFee: big.NewInt(1)}, 20000000); have.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("small base fee mismatch: have %v, want 2", have)
+	}
+}
+
+func TestHeaderBaseFeeRLP(t *testing.T) {
+	header := CreateNewBlock(1).Header()
+	legacy, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := header.Hash()
+
+	for _, baseFee := range []*big.Int{nil, big.NewInt(InitialBaseFee)} {
+		h := CopyHeader(header)
+		h.BaseFee = baseFee
+		enc, err := rlp.EncodeToBytes(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dec Header
+		if err := rlp.DecodeBytes(enc, &dec); err != nil {
+			t.Fatal(err)
+		}
+		if (dec.BaseFee == nil) != (baseFee == nil) || (baseFee != nil && dec.BaseFee.Cmp(baseFee) != 0) {
+			t.Errorf("base fee mismatch: have %v, want %v", dec.BaseFee, baseFee)
+		}
+		if baseFee == nil {
+			if string(enc) != string(legacy) {
+				t.Error("header without base fee changed its encoding")
+			}
+			if h.Hash() != hash {
+				t.Error("header without base fee changed its hash")
+			}
+		} else if h.Hash() == hash {
+			t.Error("header hash does not depend on the base fee")
+		}
+	}
+}
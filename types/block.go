@@ -20,6 +20,7 @@ package types
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -40,6 +41,10 @@ import (
 
 var (
 	EmptyRootHash = DeriveSha(Transactions{})
+
+	// ErrNilLastCommit is returned by ValidateBasic for a block above height 1 without
+	// a last commit, e.g. one a peer sent with its commit encoded as an empty list.
+	ErrNilLastCommit = errors.New("nil LastCommit")
 )
 
 //go:generate gencodec -type Header -field-override headerMarshaling -out gen_header_json.go
@@ -72,12 +77,90 @@ type Header struct {
 	ValidatorsHash common.Hash `json:"validators_hash"` // validators for the current block
 	ConsensusHash  common.Hash `json:"consensus_hash"`  // consensus params for current block
 	AppHash        common.Hash `json:"app_hash"`        // state after txs from the previous block
+
+	// BaseFee is the burned part of the gas price, only set once the London fork is active.
+	BaseFee *big.Int `json:"baseFee,omitempty" rlp:"-"`
 	//@huny LastResultsHash common.Hash `json:"last_results_hash"` // root hash of all results from the txs from the previous block
 
 	// consensus info
 	//@huny EvidenceHash common.Hash `json:"evidence_hash"` // evidence included in the block
 }
 
+// headerRLP is the RLP encoding of a Header. The base fee is appended only when
+// set, so that headers from before the London fork keep their encoding and hash.
+type headerRLP struct {
+	Height         uint64
+	Time           *big.Int
+	NumTxs         uint64
+	NumDualEvents  uint64
+	GasLimit       uint64
+	GasUsed        uint64
+	LastBlockID    BlockID
+	LastCommitHash common.Hash
+	TxHash         common.Hash
+	DualEventsHash common.Hash
+	Validator      common.Address
+	ValidatorsHash common.Hash
+	ConsensusHash  common.Hash
+	AppHash        common.Hash
+	BaseFee        []*big.Int `rlp:"tail"`
+}
+
+// EncodeRLP implements rlp.Encoder.
+func (h *Header) EncodeRLP(w io.Writer) error {
+	enc := headerRLP{
+		Height:         h.Height,
+		Time:           h.Time,
+		NumTxs:         h.NumTxs,
+		NumDualEvents:  h.NumDualEvents,
+		GasLimit:       h.GasLimit,
+		GasUsed:        h.GasUsed,
+		LastBlockID:    h.LastBlockID,
+		LastCommitHash: h.LastCommitHash,
+		TxHash:         h.TxHash,
+		DualEventsHash: h.DualEventsHash,
+		Validator:      h.Validator,
+		ValidatorsHash: h.ValidatorsHash,
+		ConsensusHash:  h.ConsensusHash,
+		AppHash:        h.AppHash,
+	}
+	if h.BaseFee != nil {
+		enc.BaseFee = []*big.Int{h.BaseFee}
+	}
+	return rlp.Encode(w, &enc)
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (h *Header) DecodeRLP(s *rlp.Stream) error {
+	var dec headerRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+	if len(dec.BaseFee) > 1 {
+		return errors.New("too many trailing header fields")
+	}
+	*h = Header{
+		Height:         dec.Height,
+		Time:           dec.Time,
+		NumTxs:         dec.NumTxs,
+		NumDualEvents:  dec.NumDualEvents,
+		GasLimit:       dec.GasLimit,
+		GasUsed:        dec.GasUsed,
+		LastBlockID:    dec.LastBlockID,
+		LastCommitHash: dec.LastCommitHash,
+		TxHash:         dec.TxHash,
+		DualEventsHash: dec.DualEventsHash,
+		Validator:      dec.Validator,
+		ValidatorsHash: dec.ValidatorsHash,
+		ConsensusHash:  dec.ConsensusHash,
+		AppHash:        dec.AppHash,
+	}
+	if len(dec.BaseFee) == 1 {
+		h.BaseFee = dec.BaseFee[0]
+	}
+	return nil
+}
+
 // Hash returns the block hash of the header, which is simply the keccak256 hash of its
 // RLP encoding.
 func (h *Header) Hash() common.Hash {
@@ -156,7 +239,33 @@ type extblock struct {
 	Header     *Header
 	Txs        []*Transaction
 	DualEvents []*DualEvent
-	LastCommit *Commit
+	LastCommit lastCommitRLP
+}
+
+// lastCommitRLP is the wire form of a block's last commit. RLP cannot tell a
+// nil struct pointer from an empty one, so a nil commit is encoded as an empty
+// list and decoded back to nil, while an empty commit stays non-nil.
+type lastCommitRLP struct {
+	commit *Commit
+}
+
+// EncodeRLP implements rlp.Encoder.
+func (lc lastCommitRLP) EncodeRLP(w io.Writer) error {
+	return lc.commit.EncodeRLP(w)
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (lc *lastCommitRLP) DecodeRLP(s *rlp.Stream) error {
+	blob, err := s.Raw()
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(blob, rlp.EmptyList) {
+		lc.commit = nil
+		return nil
+	}
+	lc.commit = new(Commit)
+	return rlp.DecodeBytes(blob, lc.commit)
 }
 
 // NewBlock creates a new block. The input data is copied,
@@ -232,6 +341,9 @@ func NewBlockWithHeader(header *Header) *Block {
 // modifying a header variable.
 func CopyHeader(h *Header) *Header {
 	cpy := *h
+	if h.BaseFee != nil {
+		cpy.BaseFee = new(big.Int).Set(h.BaseFee)
+	}
 	return &cpy
 }
 
@@ -252,7 +364,7 @@ func (b *Block) DecodeRLP(s *rlp.Stream) error {
 	if err := s.Decode(&eb); err != nil {
 		return err
 	}
-	b.header, b.transactions, b.dualEvents, b.lastCommit = eb.Header, eb.Txs, eb.DualEvents, eb.LastCommit
+	b.header, b.transactions, b.dualEvents, b.lastCommit = eb.Header, eb.Txs, eb.DualEvents, eb.LastCommit.commit
 	b.size.Store(common.StorageSize(rlp.ListSize(size)))
 	return nil
 }
@@ -263,18 +375,136 @@ func (b *Block) EncodeRLP(w io.Writer) error {
 		Header:     b.header,
 		Txs:        b.transactions,
 		DualEvents: b.dualEvents,
-		LastCommit: b.LastCommit(),
+		LastCommit: lastCommitRLP{b.lastCommit},
 	})
 }
 
+// blockJSON is the JSON form of a block. Txs is only set when the full transactions are requested.
+// The dual events and the last commit keep the default encoding of their types.
+type blockJSON struct {
+	Hash       jsonHash       `json:"hash"`
+	Header     *headerJSON    `json:"header"`
+	TxHashes   []jsonHash     `json:"txHashes"`
+	Txs        []*Transaction `json:"txs,omitempty"`
+	DualEvents []*DualEvent   `json:"dualEvents"`
+	LastCommit *Commit        `json:"lastCommit"`
+}
+
+// headerJSON is the JSON form of a header in a block, with its hashes and validator in hex.
+type headerJSON struct {
+	Header
+	LastBlockID    blockIDJSON `json:"last_block_id"`
+	LastCommitHash jsonHash    `json:"last_commit_hash"`
+	TxHash         jsonHash    `json:"data_hash"`
+	DualEventsHash jsonHash    `json:"dual_events_hash"`
+	Validator      jsonAddress `json:"validator"`
+	ValidatorsHash jsonHash    `json:"validators_hash"`
+	ConsensusHash  jsonHash    `json:"consensus_hash"`
+	AppHash        jsonHash    `json:"app_hash"`
+}
+
+// blockIDJSON is the JSON form of a block ID with its hashes in hex.
+type blockIDJSON struct {
+	Hash  jsonHash `json:"hash"`
+	Parts struct {
+		Total common.BigInt `json:"total"`
+		Hash  jsonHash      `json:"hash"`
+	} `json:"parts"`
+}
+
+func newHeaderJSON(h *Header) *headerJSON {
+	enc := &headerJSON{
+		Header:         *h,
+		LastCommitHash: jsonHash(h.LastCommitHash),
+		TxHash:         jsonHash(h.TxHash),
+		DualEventsHash: jsonHash(h.DualEventsHash),
+		Validator:      jsonAddress(h.Validator),
+		ValidatorsHash: jsonHash(h.ValidatorsHash),
+		ConsensusHash:  jsonHash(h.ConsensusHash),
+		AppHash:        jsonHash(h.AppHash),
+	}
+	enc.LastBlockID.Hash = jsonHash(h.LastBlockID.Hash)
+	enc.LastBlockID.Parts.Total = h.LastBlockID.PartsHeader.Total
+	enc.LastBlockID.Parts.Hash = jsonHash(h.LastBlockID.PartsHeader.Hash)
+	return enc
+}
+
+// header converts the JSON form back to a header.
+func (h *headerJSON) header() *Header {
+	head := h.Header
+	head.LastBlockID = BlockID{
+		Hash:        common.Hash(h.LastBlockID.Hash),
+		PartsHeader: PartSetHeader{Total: h.LastBlockID.Parts.Total, Hash: common.Hash(h.LastBlockID.Parts.Hash)},
+	}
+	head.LastCommitHash = common.Hash(h.LastCommitHash)
+	head.TxHash = common.Hash(h.TxHash)
+	head.DualEventsHash = common.Hash(h.DualEventsHash)
+	head.Validator = common.Address(h.Validator)
+	head.ValidatorsHash = common.Hash(h.ValidatorsHash)
+	head.ConsensusHash = common.Hash(h.ConsensusHash)
+	head.AppHash = common.Hash(h.AppHash)
+	return &head
+}
+
+// MarshalJSON encodes the block with its full transactions, see MarshalJSONWithTxs.
+func (b *Block) MarshalJSON() ([]byte, error) {
+	return b.MarshalJSONWithTxs(true)
+}
+
+// MarshalJSONWithTxs encodes the block's hash, header, transaction hashes, dual events and
+// last commit. The transactions themselves are only included if fullTxs is set.
+func (b *Block) MarshalJSONWithTxs(fullTxs bool) ([]byte, error) {
+	enc := blockJSON{
+		Hash:       jsonHash(b.Hash()),
+		Header:     newHeaderJSON(b.header),
+		TxHashes:   make([]jsonHash, len(b.transactions)),
+		DualEvents: b.dualEvents,
+		LastCommit: b.lastCommit,
+	}
+	for i, tx := range b.transactions {
+		enc.TxHashes[i] = jsonHash(tx.Hash())
+	}
+	if fullTxs {
+		enc.Txs = b.transactions
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON decodes a block encoded with its full transactions, checking the encoded
+// block and transaction hashes.
+func (b *Block) UnmarshalJSON(input []byte) error {
+	var dec blockJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Header == nil {
+		return errors.New("missing header in block JSON")
+	}
+	if len(dec.Txs) != len(dec.TxHashes) {
+		return fmt.Errorf("block JSON has %d transaction hashes but %d transactions", len(dec.TxHashes), len(dec.Txs))
+	}
+	for i, tx := range dec.Txs {
+		if tx.Hash() != common.Hash(dec.TxHashes[i]) {
+			return fmt.Errorf("transaction %d hash mismatch: have %x, want %x", i, tx.Hash(), dec.TxHashes[i])
+		}
+	}
+	header := dec.Header.header()
+	if hash := header.Hash(); hash != common.Hash(dec.Hash) {
+		return fmt.Errorf("block hash mismatch: have %x, want %x", hash, dec.Hash)
+	}
+	b.header, b.transactions, b.dualEvents, b.lastCommit = header, dec.Txs, dec.DualEvents, dec.LastCommit
+	b.hash.Store(header.Hash())
+	return nil
+}
+
 //  DecodeRLP implements rlp.Decoder, decodes RLP stream to Body struct.
-// Custom Encode/Decode for Body because of LastCommit RLP issue#73, otherwise Body can use RLP default decoder.
+// Body shares the extblock encoding so that its LastCommit keeps the nil/empty distinction.
 func (b *Body) DecodeRLP(s *rlp.Stream) error {
 	var eb extblock
 	if err := s.Decode(&eb); err != nil {
 		return err
 	}
-	b.Transactions, b.DualEvents, b.LastCommit = eb.Txs, eb.DualEvents, eb.LastCommit
+	b.Transactions, b.DualEvents, b.LastCommit = eb.Txs, eb.DualEvents, eb.LastCommit.commit
 	return nil
 }
 
@@ -283,7 +513,7 @@ func (b *Body) EncodeRLP(w io.Writer) error {
 		Header:     &Header{},
 		Txs:        b.Transactions,
 		DualEvents: b.DualEvents,
-		LastCommit: b.LastCommit,
+		LastCommit: lastCommitRLP{b.LastCommit},
 	})
 }
 
@@ -318,14 +548,21 @@ func (b *Block) GasLimit() uint64 { return b.header.GasLimit }
 func (b *Block) Time() *big.Int   { return b.header.Time }
 func (b *Block) NumTxs() uint64   { return b.header.NumTxs }
 
+// BaseFee returns the base fee of the block, nil before the London fork.
+func (b *Block) BaseFee() *big.Int {
+	if b.header.BaseFee == nil {
+		return nil
+	}
+	return new(big.Int).Set(b.header.BaseFee)
+}
+
 func (b *Block) LastCommitHash() common.Hash { return b.header.LastCommitHash }
 func (b *Block) TxHash() common.Hash         { return b.header.TxHash }
 func (b *Block) LastCommit() *Commit         { return b.lastCommit }
 func (b *Block) AppHash() common.Hash        { return b.header.AppHash }
 
-// TODO(namdoh): This is a hack due to rlp nature of decode both nil or empty
-// struct pointer as nil. After encoding an empty struct and send it over to
-// another node, decoding it would become nil.
+// SetLastCommit sets the block's last commit. Both a nil and an empty commit
+// survive RLP encoding unchanged, so no special handling is needed by callers.
 func (b *Block) SetLastCommit(c *Commit) {
 	b.lastCommit = c
 }
@@ -359,6 +596,52 @@ func (b *Block) MakePartSet(partSize int) *PartSet {
 	return NewPartSetFromData(bz, partSize)
 }
 
+// MakeBlockFromPartSet decodes the block gossipped in the complete part set ps. The
+// length prefix of the RLP encoding is read first to allocate exactly the size of the
+// block, which must not exceed maxBytes.
+func MakeBlockFromPartSet(ps *PartSet, maxBytes uint64) (*Block, error) {
+	if !ps.IsComplete() {
+		return nil, errors.New("incomplete block part set")
+	}
+	r := ps.GetReader()
+
+	// Read the list header: a single byte for short lists, followed by the
+	// big endian content size for long ones.
+	prefix := make([]byte, 1, 9)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, err
+	}
+	var size uint64
+	switch b := prefix[0]; {
+	case b < 0xC0:
+		return nil, errors.New("block encoding is not an RLP list")
+	case b < 0xF8:
+		size = uint64(b - 0xC0)
+	default:
+		prefix = prefix[:1+int(b-0xF7)]
+		if _, err := io.ReadFull(r, prefix[1:]); err != nil {
+			return nil, err
+		}
+		for _, c := range prefix[1:] {
+			size = size<<8 | uint64(c)
+		}
+	}
+	if size > maxBytes || uint64(len(prefix)) > maxBytes-size {
+		return nil, fmt.Errorf("block size %d exceeds the maximum of %d bytes", uint64(len(prefix))+size, maxBytes)
+	}
+
+	bz := make([]byte, uint64(len(prefix))+size)
+	copy(bz, prefix)
+	if _, err := io.ReadFull(r, bz[len(prefix):]); err != nil {
+		return nil, err
+	}
+	block := new(Block)
+	if err := rlp.DecodeBytes(bz, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
 // Size returns the true RLP encoded storage size of the block, either by encoding
 // and returning it, or returning a previously cached value.
 func (b *Block) Size() common.StorageSize {
@@ -392,7 +675,7 @@ func (b *Block) ValidateBasic() error {
 	// Validate the last commit and its hash.
 	if b.header.Height > 1 {
 		if b.lastCommit == nil {
-			return errors.New("nil LastCommit")
+			return ErrNilLastCommit
 		}
 		if err := b.lastCommit.ValidateBasic(); err != nil {
 			return err
@@ -413,7 +696,18 @@ func (b *Block) ValidateBasic() error {
 	//	return errors.New(cmn.Fmt("Wrong Block.Header.EvidenceHash.  Expected %v, got %v", b.EvidenceHash, b.Evidence.Hash()))
 	//}
 
-	//b.logger.Info("Block.ValidateBasic() - implement validate DualEvents.")
+	// An empty transaction list derives to EmptyRootHash.
+	if txHash := b.transactions.Hash(); b.header.TxHash != txHash {
+		return fmt.Errorf("Wrong Block.Header.TxHash.  Expected %v, got %v", txHash.Hex(), b.header.TxHash.Hex())
+	}
+
+	// Blocks of the main chain leave DualEventsHash unset, so only verify it when
+	// the block carries dual events or commits to a hash.
+	if len(b.dualEvents) > 0 || !b.header.DualEventsHash.IsZero() {
+		if !VerifyDualEventsHash(b.dualEvents, b.header.DualEventsHash) {
+			return fmt.Errorf("Wrong Block.Header.DualEventsHash.  Expected %v, got %v", DeriveSha(b.dualEvents), b.header.DualEventsHash)
+		}
+	}
 
 	return nil
 }
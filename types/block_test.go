@@ -19,8 +19,10 @@
 package types
 
 import (
+	"encoding/json"
 	"math/big"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -75,6 +77,57 @@ func TestBlockEncodeDecode(t *testing.T) {
 	}
 }
 
+func TestBlockJSON(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	event := CreateNewDualEvent(42)
+	event.PendingTxMetadata = &TxMetadata{TxHash: common.HexToHash("0x1234"), Target: KARDIA}
+	event, err := SignEvent(event, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := CreateNewBlock(1)
+	block := base.WithBody(&Body{Transactions: base.Transactions(), DualEvents: []*DualEvent{event}, LastCommit: base.LastCommit()})
+
+	enc, err := json.Marshal(block)
+	if err != nil {
+		t.Fatal("marshal error: ", err)
+	}
+	if want := `"hash":"` + block.Hash().Hex() + `"`; !strings.Contains(string(enc), want) {
+		t.Errorf("block hash not rendered as hex: %s", enc)
+	}
+	if want := `"data_hash":"` + block.Header().TxHash.Hex() + `"`; !strings.Contains(string(enc), want) {
+		t.Errorf("header hashes not rendered as hex: %s", enc)
+	}
+	var decoded Block
+	if err := json.Unmarshal(enc, &decoded); err != nil {
+		t.Fatal("unmarshal error: ", err)
+	}
+	if decoded.Hash() != block.Hash() || decoded.Header().Hash() != block.Hash() {
+		t.Errorf("block hash mismatch: have %v, want %v", decoded.Hash().Hex(), block.Hash().Hex())
+	}
+	if decoded.Transactions().Hash() != block.Transactions().Hash() {
+		t.Errorf("transactions mismatch: have %v, want %v", decoded.Transactions().Hash().Hex(), block.Transactions().Hash().Hex())
+	}
+	if DeriveSha(decoded.DualEvents()) != DeriveSha(block.DualEvents()) {
+		t.Errorf("dual events mismatch: have %v, want %v", decoded.DualEvents(), block.DualEvents())
+	}
+	if decoded.LastCommit().Hash() != block.LastCommit().Hash() {
+		t.Errorf("last commit mismatch: have %v, want %v", decoded.LastCommit().Hash().Hex(), block.LastCommit().Hash().Hex())
+	}
+
+	// Without full transactions only their hashes are encoded, which can't be decoded back.
+	enc, err = block.MarshalJSONWithTxs(false)
+	if err != nil {
+		t.Fatal("marshal error: ", err)
+	}
+	if strings.Contains(string(enc), `"txs"`) || !strings.Contains(string(enc), block.Transactions()[0].Hash().Hex()) {
+		t.Errorf("unexpected transactions encoding: %s", enc)
+	}
+	if err := json.Unmarshal(enc, new(Block)); err == nil {
+		t.Error("expected block without full transactions to fail decoding")
+	}
+}
+
 func TestNewDualBlock(t *testing.T) {
 	block := CreateNewDualBlock()
 	if err := block.ValidateBasic(); err != nil {
@@ -82,6 +135,168 @@ func TestNewDualBlock(t *testing.T) {
 	}
 }
 
+func TestDualBlockTamperedEvents(t *testing.T) {
+	block := CreateNewDualBlock()
+	block.dualEvents[0] = NewDualEvent(101, false, "KAI", new(common.Hash), &message.EventMessage{}, []string{})
+	if err := block.ValidateBasic(); err == nil {
+		t.Fatal("expected tampered dual events to fail validation")
+	}
+}
+
+func TestBlockValidateTxHash(t *testing.T) {
+	block := CreateNewBlock(1)
+	if err := block.ValidateBasic(); err != nil {
+		t.Fatalf("valid block failed validation: %v", err)
+	}
+	empty := NewBlock(&Header{Height: 1, Time: big.NewInt(1)}, nil, nil)
+	if empty.Header().TxHash != EmptyRootHash {
+		t.Fatalf("empty block TxHash mismatch: have %x, want %x", empty.Header().TxHash, EmptyRootHash)
+	}
+	if err := empty.ValidateBasic(); err != nil {
+		t.Fatalf("empty block failed validation: %v", err)
+	}
+
+	// Swap the transaction for another with the same count.
+	tampered := CreateNewBlock(1)
+	tampered.transactions[0] = CreateNewBlock(1).transactions[0]
+	if err := tampered.ValidateBasic(); err == nil || !strings.Contains(err.Error(), "TxHash") {
+		t.Fatalf("expected TxHash mismatch, got %v", err)
+	}
+}
+
+// Tests that blocks from peers with a nil or missing last commit are rejected
+// with an error rather than crashing the decoder or later validation.
+func TestBlockDecodeMissingLastCommit(t *testing.T) {
+	block := CreateNewBlock(5)
+	header := block.Header()
+
+	// The commit is present but nil, an empty list on the wire.
+	enc, err := rlp.EncodeToBytes(NewBlock(header, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Block
+	if err := rlp.DecodeBytes(enc, &decoded); err != nil {
+		t.Fatalf("nil commit decode error: %v", err)
+	}
+	if decoded.LastCommit() != nil {
+		t.Fatalf("nil commit decoded as %v", decoded.LastCommit())
+	}
+	if err := decoded.ValidateBasic(); err != ErrNilLastCommit {
+		t.Errorf("nil commit validation mismatch: have %v, want %v", err, ErrNilLastCommit)
+	}
+
+	// The commit is missing from the block list or is not a list at all.
+	for name, fields := range map[string][]interface{}{
+		"missing": {header, block.Transactions(), block.DualEvents()},
+		"string":  {header, block.Transactions(), block.DualEvents(), "commit"},
+	} {
+		enc, err := rlp.EncodeToBytes(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rlp.DecodeBytes(enc, new(Block)); err == nil {
+			t.Errorf("%s commit: block decoded without error", name)
+		}
+	}
+}
+
+func TestBlockLastCommitRLP(t *testing.T) {
+	fullCommit := CreateNewBlock(1).LastCommit()
+	for name, commit := range map[string]*Commit{"nil": nil, "empty": {}, "full": fullCommit} {
+		header := &Header{Height: 1, Time: big.NewInt(1)}
+		block := NewBlock(header, nil, commit)
+		if err := block.ValidateBasic(); commit == nil && err != nil {
+			t.Fatalf("%s: block with nil commit is invalid: %v", name, err)
+		}
+
+		// Send the block and its body across the wire.
+		enc, err := rlp.EncodeToBytes(block)
+		if err != nil {
+			t.Fatalf("%s: block encode error: %v", name, err)
+		}
+		var decoded Block
+		if err := rlp.DecodeBytes(enc, &decoded); err != nil {
+			t.Fatalf("%s: block decode error: %v", name, err)
+		}
+		bodyEnc, err := rlp.EncodeToBytes(block.Body())
+		if err != nil {
+			t.Fatalf("%s: body encode error: %v", name, err)
+		}
+		var body Body
+		if err := rlp.DecodeBytes(bodyEnc, &body); err != nil {
+			t.Fatalf("%s: body decode error: %v", name, err)
+		}
+
+		for src, have := range map[string]*Commit{"block": decoded.LastCommit(), "body": body.LastCommit} {
+			if (commit == nil) != (have == nil) {
+				t.Fatalf("%s: %s commit nil mismatch: have %v, want %v", name, src, have, commit)
+			}
+			if commit != nil && len(have.Precommits) != len(commit.Precommits) {
+				t.Errorf("%s: %s precommits mismatch: have %d, want %d", name, src, len(have.Precommits), len(commit.Precommits))
+			}
+			if have.Hash() != commit.Hash() {
+				t.Errorf("%s: %s commit hash mismatch: have %v, want %v", name, src, have.Hash().Hex(), commit.Hash().Hex())
+			}
+		}
+		if decoded.Hash() != block.Hash() {
+			t.Errorf("%s: block hash mismatch: have %v, want %v", name, decoded.Hash().Hex(), block.Hash().Hex())
+		}
+	}
+}
+
+func TestMakeBlockFromPartSet(t *testing.T) {
+	tiny := CreateNewBlock(1)
+	tinySize := uint64(len(mustEncodeBlock(t, tiny)))
+
+	// A block carrying a large payload spans several parts.
+	large := NewBlock(&Header{Height: 2, Time: big.NewInt(1)}, []*Transaction{
+		NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), make([]byte, 3*BlockPartSizeBytes)),
+	}, &Commit{})
+	largeSize := uint64(len(mustEncodeBlock(t, large)))
+
+	for i, test := range []struct {
+		block    *Block
+		maxBytes uint64
+		err      bool
+	}{
+		{tiny, MaxBlockSizeBytes, false},
+		{tiny, tinySize, false},
+		{tiny, tinySize - 1, true},
+		{large, largeSize, false},
+		{large, largeSize - 1, true},
+	} {
+		parts := test.block.MakePartSet(BlockPartSizeBytes)
+		block, err := MakeBlockFromPartSet(parts, test.maxBytes)
+		if test.err {
+			if err == nil {
+				t.Errorf("test %d: block of %v accepted with a ceiling of %d bytes", i, test.block.Size(), test.maxBytes)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: failed to make block: %v", i, err)
+		}
+		if block.Hash() != test.block.Hash() {
+			t.Errorf("test %d: block hash mismatch: have %v, want %v", i, block.Hash().Hex(), test.block.Hash().Hex())
+		}
+	}
+
+	// Incomplete part sets are rejected.
+	header := large.MakePartSet(BlockPartSizeBytes).Header()
+	if _, err := MakeBlockFromPartSet(NewPartSetFromHeader(header), MaxBlockSizeBytes); err == nil {
+		t.Error("incomplete part set accepted")
+	}
+}
+
+func mustEncodeBlock(t *testing.T, block *Block) []byte {
+	enc, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
 func TestBlockEncodeDecodeFile(t *testing.T) {
 	block := CreateNewBlock(1)
 	blockCopy := block.WithBody(block.Body())
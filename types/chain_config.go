@@ -3,6 +3,8 @@ package types
 import (
 	"crypto/ecdsa"
 	"fmt"
+	"math/big"
+
 	"github.com/kardiachain/go-kardia/lib/common"
 )
 
@@ -17,6 +19,15 @@ type ChainConfig struct {
 
 	// BaseAccount is used to set default execute account for
 	*BaseAccount         `json:"baseAccount,omitempty"`
+
+	// ChainID is returned to contracts by the CHAINID instruction.
+	ChainID *big.Int `json:"chainId,omitempty"`
+
+	// Fork activation heights. A fork stays disabled while no height is set.
+	ConstantinopleBlock *big.Int `json:"constantinopleBlock,omitempty"` // SHL, SHR, SAR and CREATE2 instructions (EIP-145, EIP-1014)
+	IstanbulBlock       *big.Int `json:"istanbulBlock,omitempty"`       // CHAINID and SELFBALANCE instructions (EIP-1344, EIP-1884)
+	LondonBlock         *big.Int `json:"londonBlock,omitempty"`         // Base fee and fee burning (EIP-1559)
+	GasFixBlock         *big.Int `json:"gasFixBlock,omitempty"`         // Constant gas of dynamically priced instructions charged once
 }
 
 // BaseAccount defines information for base (root) account that is used to execute internal smart contract
@@ -46,11 +57,45 @@ func (c *ChainConfig) String() string {
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Constantinople: %v Istanbul: %v London: %v GasFix: %v Engine: %v}",
+		c.ChainID,
+		c.ConstantinopleBlock,
+		c.IstanbulBlock,
+		c.LondonBlock,
+		c.GasFixBlock,
 		engine,
 	)
 }
 
+// IsConstantinople returns whether height is either equal to the Constantinople fork block or greater.
+func (c *ChainConfig) IsConstantinople(height *big.Int) bool {
+	return isForked(c.ConstantinopleBlock, height)
+}
+
+// IsIstanbul returns whether height is either equal to the Istanbul fork block or greater.
+func (c *ChainConfig) IsIstanbul(height *big.Int) bool {
+	return isForked(c.IstanbulBlock, height)
+}
+
+// IsLondon returns whether height is either equal to the London fork block or greater.
+func (c *ChainConfig) IsLondon(height *big.Int) bool {
+	return isForked(c.LondonBlock, height)
+}
+
+// IsGasFix returns whether height is either equal to the gas fix fork block or greater.
+func (c *ChainConfig) IsGasFix(height *big.Int) bool {
+	return isForked(c.GasFixBlock, height)
+}
+
+// isForked returns whether a fork scheduled at block s is active at the given head block.
+// A fork without a scheduled block is never active.
+func isForked(s, head *big.Int) bool {
+	if s == nil || head == nil {
+		return false
+	}
+	return s.Cmp(head) <= 0
+}
+
 func (c *ChainConfig) SetBaseAccount(baseAccount *BaseAccount) {
 	c.BaseAccount = baseAccount
 }
\ No newline at end of file
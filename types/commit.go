@@ -192,8 +192,11 @@ func (commit *Commit) IsCommit() bool {
 	return len(commit.Precommits) != 0
 }
 
-// Hash returns the hash of the commit
+// Hash returns the hash of the commit, or a zero hash for a nil commit.
 func (commit *Commit) Hash() cmn.Hash {
+	if commit == nil {
+		return cmn.Hash{}
+	}
 	// TODO(namdoh): Cache hash so we don't have to re-hash all the time.
 	return rlpHash(commit)
 }
@@ -276,6 +279,7 @@ func (commit *Commit) String() string {
 		commit.hash.Fingerprint())
 }
 
+// DecodeRLP implements rlp.Decoder. Empty votes are decoded as nil precommits.
 func (commit *Commit) DecodeRLP(s *rlp.Stream) error {
 	// Retrieve the entire receipt blob as we need to try multiple decoders
 	blob, err := s.Raw()
@@ -302,7 +306,11 @@ func (commit *Commit) DecodeRLP(s *rlp.Stream) error {
 	return nil
 }
 
+// EncodeRLP implements rlp.Encoder. A nil commit is encoded as an empty list.
 func (commit *Commit) EncodeRLP(w io.Writer) error {
+	if commit == nil {
+		return rlp.Encode(w, []interface{}{})
+	}
 	enc := &commitRLP{
 		BlockID:    commit.BlockID,
 		Precommits: make([]*CommitSig, len(commit.Precommits)),
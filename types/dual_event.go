@@ -42,10 +42,13 @@ const (
 
 // An event pertaining to the current dual node's interests and its derived tx's
 // metadata.
+// TriggeredEvent and PendingTxMetadata are tagged rlp:"nil" so that an unset
+// pointer, which encodes as an empty list, decodes back to nil and re-encodes
+// to the same bytes (and therefore the same hash).
 type DualEvent struct {
 	BlockNumber        uint64     `json:"blockNumber"            gencodec:"required"`
-	TriggeredEvent    *EventData  `json:"triggeredEvent"         gencodec:"required"`
-	PendingTxMetadata *TxMetadata `json:"pendingTxMetadata"      gencodec:"required"`
+	TriggeredEvent    *EventData  `json:"triggeredEvent"         gencodec:"required"    rlp:"nil"`
+	PendingTxMetadata *TxMetadata `json:"pendingTxMetadata"      gencodec:"required"    rlp:"nil"`
 
 	// The smart contract info being submitted externally.
 	KardiaSmcs []*KardiaSmartcontract `json:"kardiaSmcs"         gencodec:"required"`
@@ -229,6 +232,15 @@ func (d DualEvents) GetRlp(i int) []byte {
 	return enc
 }
 
+// VerifyDualEventsHash reports whether root is the DualEventsHash derived from
+// the given events, as computed by NewDualBlock.
+func VerifyDualEventsHash(events DualEvents, root common.Hash) bool {
+	if len(events) == 0 {
+		return root == EmptyRootHash
+	}
+	return DeriveSha(events) == root
+}
+
 // WithSignature returns a new transaction with the given signature.
 // This signature needs to be formatted as described in the yellow paper (v+27).
 func (de *DualEvent) WithSignature(sig []byte) (*DualEvent, error) {
@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
 	"github.com/kardiachain/go-kardia/lib/rlp"
 )
 
@@ -44,3 +45,99 @@ func TestDualEventsEncoding(t *testing.T) {
 func CreateNewDualEvent(nonce uint64) *DualEvent {
 	return NewDualEvent(nonce, false, "KAI", new(common.Hash), &message.EventMessage{}, []string{})
 }
+
+func TestDualEventRLPRoundTrip(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+
+	populated := CreateNewDualEvent(42)
+	populated.TriggeredEvent.Actions = []string{"action1", "action2"}
+	populated.PendingTxMetadata = &TxMetadata{TxHash: common.HexToHash("0x1234"), Target: KARDIA}
+	populated.KardiaSmcs = []*KardiaSmartcontract{{
+		SmcAddress: "0x0a",
+		MasterSmc:  "0x0b",
+		Watchers:   Watchers{{Method: "deposit", DualActions: []string{"dual"}, WatcherActions: []string{"watch"}}},
+	}}
+	signed, err := SignEvent(populated, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ev := range map[string]*DualEvent{
+		"empty":     CreateNewDualEvent(100),
+		"populated": populated,
+		"signed":    signed,
+	} {
+		enc, err := rlp.EncodeToBytes(ev)
+		if err != nil {
+			t.Fatalf("%s: encode error: %v", name, err)
+		}
+		dec := new(DualEvent)
+		if err := rlp.DecodeBytes(enc, dec); err != nil {
+			t.Fatalf("%s: decode error: %v", name, err)
+		}
+		reenc, err := rlp.EncodeToBytes(dec)
+		if err != nil {
+			t.Fatalf("%s: re-encode error: %v", name, err)
+		}
+		if !bytes.Equal(enc, reenc) {
+			t.Errorf("%s: re-encoded bytes mismatch:\nhave %x\nwant %x", name, reenc, enc)
+		}
+		if dec.Hash() != ev.Hash() {
+			t.Errorf("%s: hash mismatch: have %v, want %v", name, dec.Hash().Hex(), ev.Hash().Hex())
+		}
+	}
+}
+
+func TestDualEventRLPNilMetadata(t *testing.T) {
+	enc, err := rlp.EncodeToBytes(CreateNewDualEvent(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := new(DualEvent)
+	if err := rlp.DecodeBytes(enc, dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec.PendingTxMetadata != nil {
+		t.Errorf("PendingTxMetadata should decode to nil, got %v", dec.PendingTxMetadata)
+	}
+	if dec.TriggeredEvent == nil || dec.TriggeredEvent.TxSource != "KAI" {
+		t.Errorf("TriggeredEvent mismatch: %v", dec.TriggeredEvent)
+	}
+}
+
+func TestDualEventsDeriveShaRoundTrip(t *testing.T) {
+	events := DualEvents{CreateNewDualEvent(1), CreateNewDualEvent(2)}
+	enc, err := rlp.EncodeToBytes(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec DualEvents
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if DeriveSha(dec) != DeriveSha(events) {
+		t.Error("DeriveSha of decoded dual events mismatch")
+	}
+}
+
+func TestVerifyDualEventsHash(t *testing.T) {
+	events := DualEvents{CreateNewDualEvent(1), CreateNewDualEvent(2)}
+	root := DeriveSha(events)
+	if !VerifyDualEventsHash(events, root) {
+		t.Error("expected matching dual events hash to verify")
+	}
+	if !VerifyDualEventsHash(DualEvents{}, EmptyRootHash) {
+		t.Error("expected empty dual events to verify against EmptyRootHash")
+	}
+
+	tampered := DualEvents{CreateNewDualEvent(1), CreateNewDualEvent(3)}
+	if VerifyDualEventsHash(tampered, root) {
+		t.Error("expected tampered dual events to fail verification")
+	}
+	if VerifyDualEventsHash(DualEvents{events[1], events[0]}, root) {
+		t.Error("expected reordered dual events to fail verification")
+	}
+	if VerifyDualEventsHash(events[:1], root) {
+		t.Error("expected truncated dual events to fail verification")
+	}
+}
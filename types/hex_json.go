@@ -0,0 +1,66 @@
+/*
+ *  Copyright 2018 KardiaChain
+ *  This file is part of the go-kardia library.
+ *
+ *  The go-kardia library is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Lesser General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  The go-kardia library is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ *  GNU Lesser General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Lesser General Public License
+ *  along with the go-kardia library. If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/kardiachain/go-kardia/lib/common"
+)
+
+// jsonHash is a hash encoded as a 0x-prefixed hex string in the JSON form of blocks and
+// transactions. common.Hash itself keeps its default encoding, which stored data relies on.
+type jsonHash common.Hash
+
+// MarshalText returns the hex representation of h.
+func (h jsonHash) MarshalText() ([]byte, error) {
+	return []byte(common.Hash(h).Hex()), nil
+}
+
+// UnmarshalText parses a hash in hex syntax.
+func (h *jsonHash) UnmarshalText(input []byte) error {
+	return unmarshalFixedText("hash", input, h[:])
+}
+
+// jsonAddress is an address encoded as a 0x-prefixed hex string, see jsonHash.
+type jsonAddress common.Address
+
+// MarshalText returns the hex representation of a.
+func (a jsonAddress) MarshalText() ([]byte, error) {
+	return []byte(common.Address(a).Hex()), nil
+}
+
+// UnmarshalText parses an address in hex syntax.
+func (a *jsonAddress) UnmarshalText(input []byte) error {
+	return unmarshalFixedText("address", input, a[:])
+}
+
+// unmarshalFixedText decodes input as a hex string with 0x prefix into out, whose
+// length the decoded bytes must match exactly. typname is used in error messages.
+func unmarshalFixedText(typname string, input, out []byte) error {
+	dec, err := common.Decode(string(input))
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %v", typname, input, err)
+	}
+	if len(dec) != len(out) {
+		return fmt.Errorf("hex string has length %d, want %d for %s", len(dec)*2, len(out)*2, typname)
+	}
+	copy(out, dec)
+	return nil
+}
@@ -82,6 +82,9 @@ type PartSet struct {
 	parts         []*Part
 	partsBitArray *cmn.BitArray
 	count         int
+
+	// trusted part sets hold locally sourced parts and skip proof verification.
+	trusted bool
 }
 
 // Returns an immutable, full PartSet from the data bytes.
@@ -126,6 +129,14 @@ func NewPartSetFromHeader(header PartSetHeader) *PartSet {
 	}
 }
 
+// Returns an empty PartSet for reassembling parts read from the node's own storage.
+// Proofs of added parts are not verified, so it must never receive parts from peers.
+func NewTrustedPartSetFromHeader(header PartSetHeader) *PartSet {
+	ps := NewPartSetFromHeader(header)
+	ps.trusted = true
+	return ps
+}
+
 func (ps *PartSet) Header() PartSetHeader {
 	if ps == nil {
 		return PartSetHeader{}
@@ -185,7 +196,7 @@ func (ps *PartSet) AddPart(part *Part) (bool, error) {
 	defer ps.mtx.Unlock()
 
 	// Invalid part index
-	if part.Index.Int32() >= ps.Total() {
+	if part.Index.IsLessThanInt(0) || part.Index.Int32() >= ps.Total() {
 		return false, ErrPartSetUnexpectedIndex
 	}
 
@@ -195,8 +206,10 @@ func (ps *PartSet) AddPart(part *Part) (bool, error) {
 	}
 
 	// Check hash proof
-	if part.Proof.Verify(ps.Hash().Bytes(), part.Bytes) != nil {
-		return false, ErrPartSetInvalidProof
+	if !ps.trusted {
+		if err := VerifyPart(ps.Header(), part); err != nil {
+			return false, err
+		}
 	}
 
 	// Add part
@@ -206,6 +219,26 @@ func (ps *PartSet) AddPart(part *Part) (bool, error) {
 	return true, nil
 }
 
+// VerifyPart checks that part belongs to the part set described by header, so that
+// bad parts can be rejected before the part set is assembled.
+func VerifyPart(header PartSetHeader, part *Part) error {
+	if part == nil || part.Index == nil {
+		return ErrPartSetUnexpectedIndex
+	}
+	if part.Index.IsLessThanInt(0) || part.Index.Uint64() >= header.Total.Uint64() {
+		return ErrPartSetUnexpectedIndex
+	}
+	// The proof must be for the part's own position, otherwise a valid part
+	// could be placed at another index.
+	if part.Proof.Index != part.Index.Uint64() || part.Proof.Total != header.Total.Uint64() {
+		return ErrPartSetInvalidProof
+	}
+	if part.Proof.Verify(header.Hash.Bytes(), part.Bytes) != nil {
+		return ErrPartSetInvalidProof
+	}
+	return nil
+}
+
 func (ps *PartSet) GetPart(index int) *Part {
 	ps.mtx.Lock()
 	defer ps.mtx.Unlock()
@@ -216,6 +249,15 @@ func (ps *PartSet) IsComplete() bool {
 	return ps.count == ps.total
 }
 
+// TryComplete decodes the block once every part has been added. It returns a nil
+// block and no error while parts are still missing.
+func (ps *PartSet) TryComplete() (*Block, error) {
+	if !ps.IsComplete() {
+		return nil, nil
+	}
+	return MakeBlockFromPartSet(ps, MaxBlockSizeBytes)
+}
+
 func (ps *PartSet) GetReader() io.Reader {
 	if !ps.IsComplete() {
 		cmn.PanicSanity("Cannot GetReader() on incomplete PartSet")
@@ -1,8 +1,10 @@
 package types
 
 import (
+	"bytes"
 	"testing"
 
+	cmn "github.com/kardiachain/go-kardia/lib/common"
 	"github.com/kardiachain/go-kardia/lib/rlp"
 )
 
@@ -23,3 +25,187 @@ func TestEncodeDecodePart(t *testing.T) {
 	}
 
 }
+
+func TestVerifyPart(t *testing.T) {
+	data := make([]byte, 3*BlockPartSizeBytes+100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	partSet := NewPartSetFromData(data, BlockPartSizeBytes)
+	header := partSet.Header()
+
+	for i := 0; i < partSet.Total(); i++ {
+		if err := VerifyPart(header, partSet.GetPart(i)); err != nil {
+			t.Fatalf("part %d failed verification: %v", i, err)
+		}
+	}
+
+	// A part with tampered bytes no longer matches its proof.
+	part := partSet.GetPart(1)
+	tampered := &Part{Index: part.Index, Bytes: append([]byte{}, part.Bytes...), Proof: part.Proof}
+	tampered.Bytes[0] ^= 0xff
+	if err := VerifyPart(header, tampered); err != ErrPartSetInvalidProof {
+		t.Errorf("tampered part error mismatch: have %v, want %v", err, ErrPartSetInvalidProof)
+	}
+
+	// A valid part moved to another index is rejected.
+	moved := &Part{Index: cmn.NewBigInt32(2), Bytes: part.Bytes, Proof: part.Proof}
+	if err := VerifyPart(header, moved); err != ErrPartSetInvalidProof {
+		t.Errorf("moved part error mismatch: have %v, want %v", err, ErrPartSetInvalidProof)
+	}
+
+	// Parts outside the part set are rejected.
+	outside := &Part{Index: cmn.NewBigInt32(partSet.Total()), Bytes: part.Bytes, Proof: part.Proof}
+	if err := VerifyPart(header, outside); err != ErrPartSetUnexpectedIndex {
+		t.Errorf("out of range part error mismatch: have %v, want %v", err, ErrPartSetUnexpectedIndex)
+	}
+
+	// Parts of another part set are rejected, and never added.
+	other := NewPartSetFromData(data[1:], BlockPartSizeBytes)
+	if err := VerifyPart(other.Header(), part); err != ErrPartSetInvalidProof {
+		t.Errorf("foreign part error mismatch: have %v, want %v", err, ErrPartSetInvalidProof)
+	}
+	assembled := NewPartSetFromHeader(header)
+	if added, err := assembled.AddPart(tampered); added || err != ErrPartSetInvalidProof {
+		t.Errorf("tampered part added: %v, %v", added, err)
+	}
+}
+
+func TestTryComplete(t *testing.T) {
+	block := CreateNewBlock(1)
+	parts := block.MakePartSet(BlockPartSizeBytes)
+	assembled := NewPartSetFromHeader(parts.Header())
+
+	if decoded, err := assembled.TryComplete(); decoded != nil || err != nil {
+		t.Fatalf("incomplete part set decoded: %v, %v", decoded, err)
+	}
+	for i := 0; i < parts.Total(); i++ {
+		if _, err := assembled.AddPart(parts.GetPart(i)); err != nil {
+			t.Fatalf("failed to add part %d: %v", i, err)
+		}
+	}
+	decoded, err := assembled.TryComplete()
+	if err != nil {
+		t.Fatalf("failed to decode complete part set: %v", err)
+	}
+	if decoded.Hash() != block.Hash() {
+		t.Errorf("block hash mismatch: have %v, want %v", decoded.Hash().Hex(), block.Hash().Hex())
+	}
+}
+
+// sendParts sends every part of ps over the wire in reverse order and reassembles them in
+// a part set built from the header only, as a peer receiving a proposal block does.
+func sendParts(t *testing.T, ps *PartSet, tamper func(*Part)) (*PartSet, error) {
+	received := NewPartSetFromHeader(ps.Header())
+	for i := ps.Total() - 1; i >= 0; i-- {
+		enc, err := rlp.EncodeToBytes(ps.GetPart(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		part := new(Part)
+		if err := rlp.DecodeBytes(enc, part); err != nil {
+			t.Fatal(err)
+		}
+		if tamper != nil {
+			tamper(part)
+		}
+		if added, err := received.AddPart(part); err != nil {
+			return received, err
+		} else if !added {
+			t.Fatalf("part %d was not added", i)
+		}
+	}
+	return received, nil
+}
+
+func TestPartSetBlockRoundTrip(t *testing.T) {
+	block := CreateNewBlock(1)
+	want, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Use small parts so the block spans several of them.
+	ps := block.MakePartSet(64)
+	if ps.Total() < 2 {
+		t.Fatalf("expected several parts, got %d", ps.Total())
+	}
+
+	received, err := sendParts(t, ps, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !received.IsComplete() {
+		t.Fatalf("part set incomplete: %d of %d parts", received.Count(), received.Total())
+	}
+	var decoded *Block
+	if err := rlp.Decode(received.GetReader(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	have, err := rlp.EncodeToBytes(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Fatalf("reassembled block encoding mismatch:\nhave %x\nwant %x", have, want)
+	}
+	if decoded.Hash() != block.Hash() {
+		t.Errorf("block hash mismatch: have %x, want %x", decoded.Hash(), block.Hash())
+	}
+	if decoded.MakePartSet(64).Hash() != ps.Hash() {
+		t.Error("reassembled block does not hash to the same part set")
+	}
+}
+
+func TestPartSetTamperedPart(t *testing.T) {
+	ps := CreateNewBlock(1).MakePartSet(64)
+
+	if _, err := sendParts(t, ps, func(p *Part) { p.Bytes[0] ^= 0xff }); err != ErrPartSetInvalidProof {
+		t.Errorf("tampered bytes: have error %v, want %v", err, ErrPartSetInvalidProof)
+	}
+	// A valid part placed at another index must not verify either.
+	swapped := &Part{Index: ps.GetPart(1).Index, Bytes: ps.GetPart(0).Bytes, Proof: ps.GetPart(1).Proof}
+	if _, err := NewPartSetFromHeader(ps.Header()).AddPart(swapped); err != ErrPartSetInvalidProof {
+		t.Errorf("swapped part: have error %v, want %v", err, ErrPartSetInvalidProof)
+	}
+	outOfRange := *ps.GetPart(0)
+	outOfRange.Index = cmn.NewBigInt32(ps.Total())
+	if _, err := NewPartSetFromHeader(ps.Header()).AddPart(&outOfRange); err != ErrPartSetUnexpectedIndex {
+		t.Errorf("out of range part: have error %v, want %v", err, ErrPartSetUnexpectedIndex)
+	}
+	// A part set with another root rejects genuine parts.
+	other := NewPartSetFromHeader(CreateNewBlock(1).MakePartSet(64).Header())
+	if _, err := other.AddPart(ps.GetPart(0)); err != ErrPartSetInvalidProof {
+		t.Errorf("foreign part: have error %v, want %v", err, ErrPartSetInvalidProof)
+	}
+}
+
+func TestTrustedPartSet(t *testing.T) {
+	block := CreateNewBlock(1)
+	ps := block.MakePartSet(64)
+
+	// Local parts carry no proof, which only a trusted part set accepts.
+	trusted := NewTrustedPartSetFromHeader(ps.Header())
+	untrusted := NewPartSetFromHeader(ps.Header())
+	for i := 0; i < ps.Total(); i++ {
+		part := &Part{Index: ps.GetPart(i).Index, Bytes: ps.GetPart(i).Bytes}
+		if added, err := trusted.AddPart(part); !added || err != nil {
+			t.Fatalf("trusted part set rejected part %d: %v", i, err)
+		}
+		if _, err := untrusted.AddPart(part); err != ErrPartSetInvalidProof {
+			t.Fatalf("part %d without proof: have error %v, want %v", i, err, ErrPartSetInvalidProof)
+		}
+	}
+	var decoded *Block
+	if err := rlp.Decode(trusted.GetReader(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Hash() != block.Hash() {
+		t.Errorf("block hash mismatch: have %x, want %x", decoded.Hash(), block.Hash())
+	}
+
+	// Index bounds are still enforced.
+	outOfRange := &Part{Index: cmn.NewBigInt32(ps.Total())}
+	if _, err := NewTrustedPartSetFromHeader(ps.Header()).AddPart(outOfRange); err != ErrPartSetUnexpectedIndex {
+		t.Errorf("out of range part: have error %v, want %v", err, ErrPartSetUnexpectedIndex)
+	}
+}
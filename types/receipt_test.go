@@ -55,6 +55,27 @@ func TestReceiptEncodeRLP(t *testing.T) {
 	}
 }
 
+func TestReceiptStatusRLP(t *testing.T) {
+	for _, status := range []uint64{ReceiptStatusFailed, ReceiptStatusSuccessful} {
+		receipt := NewReceipt(nil, status == ReceiptStatusFailed, 21000)
+		enc, err := rlp.EncodeToBytes(receipt)
+		if err != nil {
+			t.Fatal("Error encoding receipt", err)
+		}
+		var decoded Receipt
+		if err := rlp.DecodeBytes(enc, &decoded); err != nil {
+			t.Fatal("Error decoding receipt", err)
+		}
+		if decoded.Status != status {
+			t.Errorf("status mismatch: have %d, want %d", decoded.Status, status)
+		}
+	}
+	succeeded, failed := NewReceipt(nil, false, 21000), NewReceipt(nil, true, 21000)
+	if DeriveSha(Receipts{succeeded}) == DeriveSha(Receipts{failed}) {
+		t.Error("receipt root does not depend on the receipt status")
+	}
+}
+
 func CreateNewReceipt() *Receipt {
 	addr := common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87")
 	emptyTx := NewTransaction(
@@ -61,3 +61,10 @@ type StoreDB interface {
 	DeleteBlockPart(hash common.Hash, height uint64)
 	DeleteCanonicalHash(height uint64)
 }
+
+// SeenCommitStore is implemented by the stores which keep the seen commits of blocks and
+// can overwrite them.
+type SeenCommitStore interface {
+	ReadSeenCommit(height uint64) *Commit
+	WriteSeenCommit(height uint64, commit *Commit)
+}
@@ -19,7 +19,9 @@
 package types
 
 import (
+	"bytes"
 	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -28,16 +30,28 @@ import (
 
 	"github.com/kardiachain/go-kardia/lib/common"
 	"github.com/kardiachain/go-kardia/lib/crypto"
+	"github.com/kardiachain/go-kardia/lib/crypto/sha3"
 	"github.com/kardiachain/go-kardia/lib/rlp"
 )
 
 //go:generate gencodec -type txdata -field-override txdataMarshaling -out gen_tx_json.go
 
 var (
-	ErrInvalidSig = errors.New("invalid transaction v, r, s values")
+	ErrInvalidSig         = errors.New("invalid transaction v, r, s values")
+	ErrTxTypeNotSupported = errors.New("transaction type not supported")
+	ErrInvalidChainId     = errors.New("invalid chain id for signer")
+
+	errAccessListNotSupported = errors.New("transaction access lists not supported")
+)
+
+// Transaction types.
+const (
+	LegacyTxType     = 0x00
+	DynamicFeeTxType = 0x02
 )
 
 type Transaction struct {
+	typ  uint8
 	data txdata
 	// caches
 	hash atomic.Value
@@ -60,6 +74,34 @@ type txdata struct {
 
 	// This is only used when marshaling to JSON.
 	Hash *common.Hash `json:"hash" rlp:"-"`
+
+	// Chain id and fee caps of dynamic fee transactions, Price holds GasFeeCap for those.
+	ChainID   *big.Int `json:"chainId,omitempty"              rlp:"-"`
+	GasTipCap *big.Int `json:"maxPriorityFeePerGas,omitempty" rlp:"-"`
+	GasFeeCap *big.Int `json:"maxFeePerGas,omitempty"         rlp:"-"`
+}
+
+// dynamicFeeTxdata is the consensus encoding of a dynamic fee transaction, laid
+// out as in EIP-1559.
+type dynamicFeeTxdata struct {
+	ChainID      *big.Int
+	AccountNonce uint64
+	GasTipCap    *big.Int
+	GasFeeCap    *big.Int
+	GasLimit     uint64
+	Recipient    *common.Address `rlp:"nil"`
+	Amount       *big.Int
+	Payload      []byte
+	AccessList   []accessTuple
+
+	V, R, S *big.Int
+}
+
+// accessTuple is an entry of an EIP-2930 access list. Access lists aren't
+// supported, the list is only encoded empty to keep the EIP-1559 layout.
+type accessTuple struct {
+	Address     common.Address
+	StorageKeys []common.Hash
 }
 
 func NewTransaction(nonce uint64, to common.Address, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte) *Transaction {
@@ -95,22 +137,161 @@ func newTransaction(nonce uint64, to *common.Address, amount *big.Int, gasLimit
 	return &Transaction{data: d}
 }
 
-// EncodeRLP implements rlp.Encoder
+// NewDynamicFeeTransaction creates a transaction for the chain chainID whose gas
+// price is derived from the base fee plus gasTipCap, capped by gasFeeCap.
+func NewDynamicFeeTransaction(chainID *big.Int, nonce uint64, to common.Address, amount *big.Int, gasLimit uint64, gasTipCap, gasFeeCap *big.Int, data []byte) *Transaction {
+	tx := newTransaction(nonce, &to, amount, gasLimit, gasFeeCap, data)
+	tx.typ = DynamicFeeTxType
+	tx.data.ChainID = new(big.Int)
+	if chainID != nil {
+		tx.data.ChainID.Set(chainID)
+	}
+	tx.data.GasFeeCap = new(big.Int).Set(tx.data.Price)
+	tx.data.GasTipCap = new(big.Int)
+	if gasTipCap != nil {
+		tx.data.GasTipCap.Set(gasTipCap)
+	}
+	return tx
+}
+
+// EncodeRLP implements rlp.Encoder. Legacy transactions are encoded as a list,
+// typed ones as a string holding the type byte followed by their payload.
 func (tx *Transaction) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, &tx.data)
+	if tx.typ == LegacyTxType {
+		return rlp.Encode(w, &tx.data)
+	}
+	enc, err := tx.encodeTyped()
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w, enc)
 }
 
 // DecodeRLP implements rlp.Decoder
 func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
-	_, size, _ := s.Kind()
-	err := s.Decode(&tx.data)
-	if err == nil {
+	kind, size, err := s.Kind()
+	if err != nil {
+		return err
+	}
+	if kind == rlp.List {
+		var data txdata
+		if err := s.Decode(&data); err != nil {
+			return err
+		}
+		tx.typ, tx.data = LegacyTxType, data
 		tx.size.Store(common.StorageSize(rlp.ListSize(size)))
+		return nil
+	}
+	b, err := s.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := tx.decodeTyped(b); err != nil {
+		return err
+	}
+	tx.size.Store(common.StorageSize(rlp.ListSize(size)))
+	return nil
+}
+
+// encodeTyped returns the type byte of tx followed by its RLP payload.
+func (tx *Transaction) encodeTyped() ([]byte, error) {
+	if tx.typ != DynamicFeeTxType {
+		return nil, ErrTxTypeNotSupported
+	}
+	payload, err := rlp.EncodeToBytes(&dynamicFeeTxdata{
+		ChainID:      tx.data.ChainID,
+		AccountNonce: tx.data.AccountNonce,
+		GasTipCap:    tx.data.GasTipCap,
+		GasFeeCap:    tx.data.GasFeeCap,
+		GasLimit:     tx.data.GasLimit,
+		Recipient:    tx.data.Recipient,
+		Amount:       tx.data.Amount,
+		Payload:      tx.data.Payload,
+		V:            tx.data.V,
+		R:            tx.data.R,
+		S:            tx.data.S,
+	})
+	if err != nil {
+		return nil, err
 	}
+	return append([]byte{tx.typ}, payload...), nil
+}
 
-	return err
+// decodeTyped decodes the typed transaction encoding produced by encodeTyped.
+func (tx *Transaction) decodeTyped(b []byte) error {
+	if len(b) == 0 {
+		return errors.New("typed transaction too short")
+	}
+	if b[0] != DynamicFeeTxType {
+		return ErrTxTypeNotSupported
+	}
+	var inner dynamicFeeTxdata
+	if err := rlp.DecodeBytes(b[1:], &inner); err != nil {
+		return err
+	}
+	if len(inner.AccessList) > 0 {
+		return errAccessListNotSupported
+	}
+	tx.typ = b[0]
+	tx.data = txdata{
+		AccountNonce: inner.AccountNonce,
+		Price:        inner.GasFeeCap,
+		GasLimit:     inner.GasLimit,
+		Recipient:    inner.Recipient,
+		Amount:       inner.Amount,
+		Payload:      inner.Payload,
+		V:            inner.V,
+		R:            inner.R,
+		S:            inner.S,
+		ChainID:      inner.ChainID,
+		GasTipCap:    inner.GasTipCap,
+		GasFeeCap:    inner.GasFeeCap,
+	}
+	return nil
 }
 
+// txJSON is the JSON form of a transaction, with its recipient and hash in hex.
+type txJSON struct {
+	txdata
+	Recipient *jsonAddress `json:"to"`
+	Hash      *jsonHash    `json:"hash"`
+}
+
+// MarshalJSON encodes the transaction fields along with its hash.
+func (tx *Transaction) MarshalJSON() ([]byte, error) {
+	hash := tx.Hash()
+	return json.Marshal(&txJSON{
+		txdata:    tx.data,
+		Recipient: (*jsonAddress)(tx.data.Recipient),
+		Hash:      (*jsonHash)(&hash),
+	})
+}
+
+// UnmarshalJSON decodes a transaction encoded by MarshalJSON, checking its hash if present.
+func (tx *Transaction) UnmarshalJSON(input []byte) error {
+	var enc txJSON
+	if err := json.Unmarshal(input, &enc); err != nil {
+		return err
+	}
+	dec := enc.txdata
+	if dec.Price == nil || dec.Amount == nil || dec.V == nil || dec.R == nil || dec.S == nil {
+		return errors.New("missing required field in transaction JSON")
+	}
+	dec.Recipient, dec.Hash = (*common.Address)(enc.Recipient), nil
+	tx.typ, tx.data = LegacyTxType, dec
+	if dec.ChainID != nil || dec.GasTipCap != nil || dec.GasFeeCap != nil {
+		if dec.ChainID == nil || dec.GasTipCap == nil || dec.GasFeeCap == nil || dec.GasFeeCap.Cmp(dec.Price) != 0 {
+			return errors.New("inconsistent fee caps in transaction JSON")
+		}
+		tx.typ = DynamicFeeTxType
+	}
+	if enc.Hash != nil && common.Hash(*enc.Hash) != tx.Hash() {
+		return fmt.Errorf("transaction hash mismatch: have %x, want %x", tx.Hash(), *enc.Hash)
+	}
+	return nil
+}
+
+func (tx *Transaction) Type() uint8        { return tx.typ }
 func (tx *Transaction) Data() []byte       { return common.CopyBytes(tx.data.Payload) }
 func (tx *Transaction) Gas() uint64        { return tx.data.GasLimit }
 func (tx *Transaction) GasPrice() *big.Int { return new(big.Int).Set(tx.data.Price) }
@@ -118,6 +299,71 @@ func (tx *Transaction) Value() *big.Int    { return new(big.Int).Set(tx.data.Amo
 func (tx *Transaction) Nonce() uint64      { return tx.data.AccountNonce }
 func (tx *Transaction) CheckNonce() bool   { return true }
 
+// ChainId returns the id of the chain a dynamic fee transaction is signed for.
+// It is nil for legacy transactions.
+func (tx *Transaction) ChainId() *big.Int {
+	if tx.data.ChainID == nil {
+		return nil
+	}
+	return new(big.Int).Set(tx.data.ChainID)
+}
+
+// GasTipCap returns the tip per gas paid on top of the base fee. It is the gas
+// price for legacy transactions.
+func (tx *Transaction) GasTipCap() *big.Int {
+	if tx.data.GasTipCap == nil {
+		return new(big.Int).Set(tx.data.Price)
+	}
+	return new(big.Int).Set(tx.data.GasTipCap)
+}
+
+// GasFeeCap returns the maximum price per gas the sender is willing to pay. It
+// is the gas price for legacy transactions.
+func (tx *Transaction) GasFeeCap() *big.Int {
+	if tx.data.GasFeeCap == nil {
+		return new(big.Int).Set(tx.data.Price)
+	}
+	return new(big.Int).Set(tx.data.GasFeeCap)
+}
+
+// EffectiveGasPrice returns the price per gas tx pays under the given base fee.
+func (tx *Transaction) EffectiveGasPrice(baseFee *big.Int) *big.Int {
+	return EffectiveGasPrice(baseFee, tx.GasTipCap(), tx.GasFeeCap())
+}
+
+// EffectiveGasPrice returns baseFee plus gasTipCap, capped by gasFeeCap. A nil
+// baseFee is treated as zero.
+func EffectiveGasPrice(baseFee, gasTipCap, gasFeeCap *big.Int) *big.Int {
+	price := new(big.Int).Set(gasTipCap)
+	if baseFee != nil {
+		price.Add(price, baseFee)
+	}
+	if price.Cmp(gasFeeCap) > 0 {
+		price.Set(gasFeeCap)
+	}
+	return price
+}
+
+// CheckTxType returns an error if tx can't be included in a block at height of
+// a chain under config. Typed transactions are only valid from the London fork
+// on and must be signed for the chain's id.
+func CheckTxType(config *ChainConfig, height *big.Int, tx *Transaction) error {
+	if tx.typ == LegacyTxType {
+		return nil
+	}
+	if config == nil || !config.IsLondon(height) {
+		return ErrTxTypeNotSupported
+	}
+	chainID := config.ChainID
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+	if tx.data.ChainID == nil || tx.data.ChainID.Cmp(chainID) != 0 {
+		return ErrInvalidChainId
+	}
+	return nil
+}
+
 // To returns the recipient address of the transaction.
 // It returns nil if the transaction is a contract creation.
 func (tx *Transaction) To() *common.Address {
@@ -134,7 +380,13 @@ func (tx *Transaction) Hash() common.Hash {
 	if hash := tx.hash.Load(); hash != nil {
 		return hash.(common.Hash)
 	}
-	v := rlpHash(tx)
+	var v common.Hash
+	if tx.typ == LegacyTxType {
+		v = rlpHash(tx)
+	} else {
+		enc, _ := tx.encodeTyped()
+		v = common.BytesToHash(crypto.Keccak256(enc))
+	}
 	tx.hash.Store(v)
 	return v
 }
@@ -146,18 +398,20 @@ func (tx *Transaction) Size() common.StorageSize {
 		return size.(common.StorageSize)
 	}
 	c := writeCounter(0)
-	rlp.Encode(&c, &tx.data)
+	rlp.Encode(&c, tx)
 	tx.size.Store(common.StorageSize(c))
 	return common.StorageSize(c)
 }
 
-// AsMessage returns the transaction as a core.Message.
-//
-func (tx *Transaction) AsMessage(s Signer) (Message, error) {
+// AsMessage returns the transaction as a core.Message. Its gas price is the
+// effective gas price under baseFee, nil when the block carries none.
+func (tx *Transaction) AsMessage(s Signer, baseFee *big.Int) (Message, error) {
 	msg := Message{
 		nonce:      tx.data.AccountNonce,
 		gasLimit:   tx.data.GasLimit,
-		gasPrice:   new(big.Int).Set(tx.data.Price),
+		gasPrice:   tx.EffectiveGasPrice(baseFee),
+		gasFeeCap:  tx.GasFeeCap(),
+		gasTipCap:  tx.GasTipCap(),
 		to:         tx.data.Recipient,
 		amount:     tx.data.Amount,
 		data:       tx.data.Payload,
@@ -173,6 +427,15 @@ func (tx *Transaction) RawSignatureValues() (*big.Int, *big.Int, *big.Int) {
 	return tx.data.V, tx.data.R, tx.data.S
 }
 
+// recoveryV returns the V value in the 27/28 form expected by recoverPlain.
+// Typed transactions carry the bare recovery id like Ethereum does.
+func (tx *Transaction) recoveryV() *big.Int {
+	if tx.typ == LegacyTxType {
+		return tx.data.V
+	}
+	return new(big.Int).Add(tx.data.V, big.NewInt(27))
+}
+
 // WithSignature returns a new transaction with the given signature.
 // This signature needs to be in the [R || S || V] format where V is 0 or 1.
 func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, error) {
@@ -180,12 +443,13 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	if err != nil {
 		return nil, err
 	}
-	cpy := &Transaction{data: tx.data}
+	cpy := &Transaction{typ: tx.typ, data: tx.data}
 	cpy.data.R, cpy.data.S, cpy.data.V = r, s, v
 	return cpy, nil
 }
 
-// Cost returns amount + gasprice * gaslimit.
+// Cost returns amount + gasprice * gaslimit, where the gas price of dynamic fee
+// transactions is their fee cap.
 func (tx *Transaction) Cost() *big.Int {
 	total := new(big.Int).Mul(tx.data.Price, new(big.Int).SetUint64(tx.data.GasLimit))
 	total.Add(total, tx.data.Amount)
@@ -272,14 +536,47 @@ func (s Transactions) Remove(indexes []int) Transactions {
 	return txs
 }
 
+// FilterByNonceRange returns the transactions whose nonce lies within the
+// inclusive range [from, to], preserving their order in s.
+func (s Transactions) FilterByNonceRange(from, to uint64) Transactions {
+	txs := make(Transactions, 0)
+	for _, tx := range s {
+		if nonce := tx.Nonce(); nonce >= from && nonce <= to {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+// BySender groups the transactions by their sender, preserving their order in
+// s. Transactions whose sender can't be derived are skipped.
+func (s Transactions) BySender() map[common.Address]Transactions {
+	txs := make(map[common.Address]Transactions)
+	for _, tx := range s {
+		from, err := Sender(HomesteadSigner{}, tx)
+		if err != nil {
+			continue
+		}
+		txs[from] = append(txs[from], tx)
+	}
+	return txs
+}
+
 // TxByNonce implements the sort interface to allow sorting a list of transactions
 // by their nonces. This is usually only useful for sorting transactions from a
-// single account, otherwise a nonce comparison doesn't make much sense.
+// single account, otherwise a nonce comparison doesn't make much sense. Equal
+// nonces are ordered by hash so the order is total.
 type TxByNonce Transactions
 
-func (s TxByNonce) Len() int           { return len(s) }
-func (s TxByNonce) Less(i, j int) bool { return s[i].data.AccountNonce < s[j].data.AccountNonce }
-func (s TxByNonce) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s TxByNonce) Len() int { return len(s) }
+func (s TxByNonce) Less(i, j int) bool {
+	if s[i].data.AccountNonce != s[j].data.AccountNonce {
+		return s[i].data.AccountNonce < s[j].data.AccountNonce
+	}
+	hi, hj := s[i].Hash(), s[j].Hash()
+	return bytes.Compare(hi[:], hj[:]) < 0
+}
+func (s TxByNonce) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 
 // TxDifference returns a new set t which is the difference between a to b.
 func TxDifference(a, b Transactions) (keep Transactions) {
@@ -320,6 +617,24 @@ func SignTx(signer Signer, tx *Transaction, prv *ecdsa.PrivateKey) (*Transaction
 // sigHash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func sigHash(tx *Transaction) common.Hash {
+	if tx.typ == DynamicFeeTxType {
+		hw := sha3.NewKeccak256()
+		hw.Write([]byte{tx.typ})
+		rlp.Encode(hw, []interface{}{
+			tx.data.ChainID,
+			tx.data.AccountNonce,
+			tx.data.GasTipCap,
+			tx.data.GasFeeCap,
+			tx.data.GasLimit,
+			tx.data.Recipient,
+			tx.data.Amount,
+			tx.data.Payload,
+			[]accessTuple{},
+		})
+		var h common.Hash
+		hw.Sum(h[:0])
+		return h
+	}
 	return rlpHash([]interface{}{
 		tx.data.AccountNonce,
 		tx.data.Price,
@@ -367,6 +682,8 @@ type Message struct {
 	amount     *big.Int
 	gasLimit   uint64
 	gasPrice   *big.Int
+	gasFeeCap  *big.Int
+	gasTipCap  *big.Int
 	data       []byte
 	checkNonce bool
 }
@@ -379,6 +696,8 @@ func NewMessage(from common.Address, to *common.Address, nonce uint64, amount *b
 		amount:     amount,
 		gasLimit:   gasLimit,
 		gasPrice:   gasPrice,
+		gasFeeCap:  gasPrice,
+		gasTipCap:  gasPrice,
 		data:       data,
 		checkNonce: checkNonce,
 	}
@@ -387,6 +706,8 @@ func NewMessage(from common.Address, to *common.Address, nonce uint64, amount *b
 func (m Message) From() common.Address { return m.from }
 func (m Message) To() *common.Address  { return m.to }
 func (m Message) GasPrice() *big.Int   { return m.gasPrice }
+func (m Message) GasFeeCap() *big.Int  { return m.gasFeeCap }
+func (m Message) GasTipCap() *big.Int  { return m.gasTipCap }
 func (m Message) Value() *big.Int      { return m.amount }
 func (m Message) Gas() uint64          { return m.gasLimit }
 func (m Message) Nonce() uint64        { return m.nonce }
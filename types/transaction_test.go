@@ -21,7 +21,9 @@ package types
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"encoding/json"
 	"math/big"
+	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -184,3 +186,166 @@ func TestDecodeTransactionFromKaiTool(t *testing.T) {
 	require.NoError(t, err)
 	println(tx.Value().String())
 }
+
+func TestTransactionsFilterByNonceRange(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	txs := make(Transactions, 0)
+	for _, nonce := range []uint64{5, 1, 3, 2, 4} {
+		tx, _ := SignTx(HomesteadSigner{}, NewTransaction(nonce, common.Address{}, new(big.Int), 0, new(big.Int), nil), key)
+		txs = append(txs, tx)
+	}
+
+	filtered := txs.FilterByNonceRange(2, 4)
+	require.Len(t, filtered, 3)
+	for i, want := range []uint64{3, 2, 4} {
+		require.EqualValues(t, want, filtered[i].Nonce())
+	}
+	require.Len(t, txs.FilterByNonceRange(3, 3), 1)
+	require.Empty(t, txs.FilterByNonceRange(6, 10))
+	require.Empty(t, txs.FilterByNonceRange(4, 2))
+	require.Empty(t, Transactions{}.FilterByNonceRange(0, 10))
+}
+
+func TestTransactionsBySender(t *testing.T) {
+	key1, _ := crypto.GenerateKey()
+	key2, _ := crypto.GenerateKey()
+	addr1 := crypto.PubkeyToAddress(key1.PublicKey)
+	addr2 := crypto.PubkeyToAddress(key2.PublicKey)
+
+	sign := func(nonce uint64, key *ecdsa.PrivateKey) *Transaction {
+		tx, _ := SignTx(HomesteadSigner{}, NewTransaction(nonce, common.Address{}, new(big.Int), 0, new(big.Int), nil), key)
+		return tx
+	}
+	txs := Transactions{sign(0, key1), sign(0, key2), sign(1, key1), sign(2, key1), sign(1, key2)}
+
+	senders := txs.BySender()
+	require.Len(t, senders, 2)
+	require.Len(t, senders[addr1], 3)
+	require.Len(t, senders[addr2], 2)
+	for i, tx := range senders[addr1] {
+		require.EqualValues(t, i, tx.Nonce())
+	}
+	for i, tx := range senders[addr2] {
+		require.EqualValues(t, i, tx.Nonce())
+	}
+	require.Empty(t, Transactions{}.BySender())
+}
+
+func TestDynamicFeeTransaction(t *testing.T) {
+	key, addr := defaultTestKey()
+	to := common.HexToAddress("b94f5374fce5edbc8e2a8697c15331677e6ebf0b")
+	tx, err := SignTx(HomesteadSigner{}, NewDynamicFeeTransaction(big.NewInt(24), 3, to, big.NewInt(10), 21000, big.NewInt(2), big.NewInt(50), common.FromHex("5544")), key)
+	require.NoError(t, err)
+	require.EqualValues(t, DynamicFeeTxType, tx.Type())
+
+	// Typed transactions are wrapped in an RLP string, prefixed by their type.
+	enc, err := rlp.EncodeToBytes(tx)
+	require.NoError(t, err)
+	var payload []byte
+	require.NoError(t, rlp.DecodeBytes(enc, &payload))
+	require.EqualValues(t, DynamicFeeTxType, payload[0])
+	require.Equal(t, common.BytesToHash(crypto.Keccak256(payload)), tx.Hash())
+	require.EqualValues(t, len(enc), tx.Size())
+
+	decoded, err := decodeTx(enc)
+	require.NoError(t, err)
+	require.EqualValues(t, DynamicFeeTxType, decoded.Type())
+	require.Equal(t, tx.Hash(), decoded.Hash())
+	require.Equal(t, big.NewInt(2), decoded.GasTipCap())
+	require.Equal(t, big.NewInt(50), decoded.GasFeeCap())
+	require.Equal(t, big.NewInt(50), decoded.GasPrice())
+	require.Equal(t, big.NewInt(24), decoded.ChainId())
+	require.Nil(t, rightvrsTx.ChainId())
+
+	// The payload follows the EIP-1559 layout, with an empty access list.
+	var fields []rlp.RawValue
+	require.NoError(t, rlp.DecodeBytes(payload[1:], &fields))
+	require.Len(t, fields, 12)
+	require.Equal(t, rlp.EmptyList, []byte(fields[8]))
+
+	from, err := Sender(HomesteadSigner{}, decoded)
+	require.NoError(t, err)
+	require.Equal(t, addr, from)
+
+	// The effective price is the base fee plus the tip, capped by the fee cap.
+	require.Equal(t, big.NewInt(2), tx.EffectiveGasPrice(nil))
+	require.Equal(t, big.NewInt(12), tx.EffectiveGasPrice(big.NewInt(10)))
+	require.Equal(t, big.NewInt(50), tx.EffectiveGasPrice(big.NewInt(49)))
+	require.Equal(t, big.NewInt(1), rightvrsTx.EffectiveGasPrice(big.NewInt(49)))
+
+	// The fee caps survive a JSON round trip, keeping the hash of the typed encoding.
+	enc, err = json.Marshal(tx)
+	require.NoError(t, err)
+	var fromJSON Transaction
+	require.NoError(t, json.Unmarshal(enc, &fromJSON))
+	require.EqualValues(t, DynamicFeeTxType, fromJSON.Type())
+	require.Equal(t, tx.Hash(), fromJSON.Hash())
+
+	msg, err := decoded.AsMessage(HomesteadSigner{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(2), msg.GasPrice())
+	require.Equal(t, big.NewInt(50), msg.GasFeeCap())
+	require.Equal(t, big.NewInt(2), msg.GasTipCap())
+}
+
+func TestDynamicFeeTransactionTampered(t *testing.T) {
+	key, addr := defaultTestKey()
+	tx, err := SignTx(HomesteadSigner{}, NewDynamicFeeTransaction(big.NewInt(24), 0, addr, new(big.Int), 21000, big.NewInt(1), big.NewInt(2), nil), key)
+	require.NoError(t, err)
+
+	// Raising the tip changes the signed hash, so the sender no longer matches.
+	tampered := &Transaction{typ: tx.typ, data: tx.data}
+	tampered.data.GasTipCap = big.NewInt(2)
+	from, err := Sender(HomesteadSigner{}, tampered)
+	require.True(t, err != nil || from != addr)
+
+	// So does replaying it on another chain.
+	replayed := &Transaction{typ: tx.typ, data: tx.data}
+	replayed.data.ChainID = big.NewInt(25)
+	from, err = Sender(HomesteadSigner{}, replayed)
+	require.True(t, err != nil || from != addr)
+
+	_, err = decodeTx(common.FromHex("820103"))
+	require.Equal(t, ErrTxTypeNotSupported, err)
+}
+
+func TestCheckTxType(t *testing.T) {
+	tx := NewDynamicFeeTransaction(big.NewInt(24), 0, common.Address{}, new(big.Int), 21000, big.NewInt(1), big.NewInt(2), nil)
+	config := &ChainConfig{ChainID: big.NewInt(24), LondonBlock: big.NewInt(10)}
+
+	// Legacy transactions are valid regardless of forks.
+	require.NoError(t, CheckTxType(nil, big.NewInt(0), rightvrsTx))
+	require.NoError(t, CheckTxType(config, big.NewInt(0), rightvrsTx))
+
+	require.Equal(t, ErrTxTypeNotSupported, CheckTxType(nil, big.NewInt(10), tx))
+	require.Equal(t, ErrTxTypeNotSupported, CheckTxType(&ChainConfig{ChainID: big.NewInt(24)}, big.NewInt(10), tx))
+	require.Equal(t, ErrTxTypeNotSupported, CheckTxType(config, big.NewInt(9), tx))
+	require.NoError(t, CheckTxType(config, big.NewInt(10), tx))
+	require.Equal(t, ErrInvalidChainId, CheckTxType(&ChainConfig{ChainID: big.NewInt(25), LondonBlock: big.NewInt(0)}, big.NewInt(10), tx))
+}
+
+func TestTxByNonceEqualNonces(t *testing.T) {
+	txs := make(Transactions, 0)
+	for _, nonce := range []uint64{2, 1, 1, 1, 0, 1} {
+		key, _ := crypto.GenerateKey()
+		tx, _ := SignTx(HomesteadSigner{}, NewTransaction(nonce, common.Address{}, new(big.Int), 0, new(big.Int), nil), key)
+		txs = append(txs, tx)
+	}
+	sorted := make(Transactions, len(txs))
+	copy(sorted, txs)
+	sort.Sort(TxByNonce(sorted))
+
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		require.True(t, prev.Nonce() <= cur.Nonce())
+		if prev.Nonce() == cur.Nonce() {
+			require.True(t, bytes.Compare(prev.Hash().Bytes(), cur.Hash().Bytes()) < 0)
+		}
+	}
+	// Any input order gives the same result
+	for i, j := 0, len(txs)-1; i < j; i, j = i+1, j-1 {
+		txs[i], txs[j] = txs[j], txs[i]
+	}
+	sort.Sort(TxByNonce(txs))
+	require.Equal(t, sorted, txs)
+}
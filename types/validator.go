@@ -302,6 +302,29 @@ func (valSet *ValidatorSet) AdvanceProposer(times int64) {
 	}
 }
 
+// SelectProposer returns the address of the proposer for the given height and
+// round using weighted round-robin over the validators' voting power. The
+// validators carry the priority state (Accum) left by the previous height, as
+// persisted in consensus' LastValidators, so the selection only takes the step
+// of this height plus one step per round instead of replaying the schedule from
+// genesis. The height itself does not enter the computation.
+func SelectProposer(validators []Validator, height, round uint64) common.Address {
+	if len(validators) == 0 {
+		return common.Address{}
+	}
+	vals := make([]*Validator, len(validators))
+	for i := range validators {
+		vals[i] = &validators[i]
+	}
+	// NewValidatorSet copies the validators, so the caller's priority state is
+	// left untouched.
+	valSet := NewValidatorSet(vals, 0, 0)
+	for i := uint64(0); i <= round; i++ {
+		valSet.AdvanceProposer(1)
+	}
+	return valSet.Proposer.Address
+}
+
 // Iterate will run the given function over the set.
 func (valSet *ValidatorSet) Iterate(fn func(index int, val *Validator) bool) {
 	for i, val := range valSet.Validators {
@@ -24,6 +24,9 @@ import (
 	"crypto/rand"
 	"sort"
 	"testing"
+
+	"github.com/kardiachain/go-kardia/lib/common"
+	"github.com/kardiachain/go-kardia/lib/crypto"
 )
 
 func TestGetProposerUniformVotingPower(t *testing.T) {
@@ -98,3 +101,87 @@ func generatePublicKey() ecdsa.PublicKey {
 	priv, _ := ecdsa.GenerateKey(p256, rand.Reader)
 	return priv.PublicKey
 }
+
+func TestSelectProposerDeterministic(t *testing.T) {
+	vals := []Validator{*newSecpValidator(t, 3), *newSecpValidator(t, 1), *newSecpValidator(t, 2)}
+	vals[0].Accum, vals[1].Accum, vals[2].Accum = -2, 4, -2
+	for round := uint64(0); round < 5; round++ {
+		first := SelectProposer(vals, 10, round)
+		// The ordering of the input must not affect the selection.
+		shuffled := []Validator{vals[2], vals[0], vals[1]}
+		if second := SelectProposer(shuffled, 10, round); first != second {
+			t.Fatalf("proposer at round %d is not deterministic: %v != %v", round, first.Hex(), second.Hex())
+		}
+	}
+	if vals[0].Accum != -2 || vals[1].Accum != 4 || vals[2].Accum != -2 {
+		t.Error("selection modified the priority state of the input")
+	}
+	if addr := SelectProposer(nil, 1, 0); addr != (common.Address{}) {
+		t.Errorf("expected zero address for an empty validator list, got %v", addr.Hex())
+	}
+}
+
+func TestSelectProposerMatchesAdvanceProposer(t *testing.T) {
+	valSet := NewValidatorSet([]*Validator{newSecpValidator(t, 5), newSecpValidator(t, 2), newSecpValidator(t, 3)}, 0, 0)
+	for height := uint64(1); height < 25; height++ {
+		// Consensus advances its validators once per height and once per
+		// round, keeping the state before the step as LastValidators.
+		last := validatorValues(valSet)
+		valSet.AdvanceProposer(1)
+		if have, want := SelectProposer(last, height, 0), valSet.GetProposer().Address; have != want {
+			t.Fatalf("proposer mismatch at height %d: have %v, want %v", height, have.Hex(), want.Hex())
+		}
+		rounds := valSet.Copy()
+		for round := uint64(1); round < 4; round++ {
+			rounds.AdvanceProposer(1)
+			if have, want := SelectProposer(last, height, round), rounds.GetProposer().Address; have != want {
+				t.Fatalf("proposer mismatch at %d/%d: have %v, want %v", height, round, have.Hex(), want.Hex())
+			}
+		}
+		// A round moves on to the proposer the next height would have.
+		if SelectProposer(last, height, 1) != SelectProposer(validatorValues(valSet), height+1, 0) {
+			t.Fatalf("round 1 at height %d should select the proposer of height %d, round 0", height, height+1)
+		}
+	}
+}
+
+func TestSelectProposerProportionalToStake(t *testing.T) {
+	powers := []int64{1, 2, 3, 4}
+	vals := make([]*Validator, len(powers))
+	total := int64(0)
+	for i, power := range powers {
+		vals[i] = newSecpValidator(t, power)
+		total += power
+	}
+	valSet := NewValidatorSet(vals, 0, 0)
+
+	const heights = 1000
+	counts := make(map[common.Address]int64)
+	for height := uint64(1); height <= heights; height++ {
+		counts[SelectProposer(validatorValues(valSet), height, 0)]++
+		valSet.AdvanceProposer(1)
+	}
+	for _, val := range vals {
+		want := heights * val.VotingPower / total
+		if have := counts[val.Address]; have != want {
+			t.Errorf("validator with power %d proposed %d times, want %d", val.VotingPower, have, want)
+		}
+	}
+}
+
+// validatorValues returns a copy of the validators of valSet, priority state included.
+func validatorValues(valSet *ValidatorSet) []Validator {
+	vals := make([]Validator, len(valSet.Validators))
+	for i, val := range valSet.Validators {
+		vals[i] = *val.Copy()
+	}
+	return vals
+}
+
+func newSecpValidator(t *testing.T, votingPower int64) *Validator {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewValidator(key.PublicKey, votingPower)
+}